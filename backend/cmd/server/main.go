@@ -1,136 +1,301 @@
 package main
 
 import (
-	"bufio"
 	"context"
-	"log"
+	"errors"
+	"flag"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
 	"time"
 
 	"github.com/connect-four/internal/api"
+	"github.com/connect-four/internal/auth"
+	"github.com/connect-four/internal/config"
+	"github.com/connect-four/internal/events"
 	"github.com/connect-four/internal/game"
 	"github.com/connect-four/internal/kafka"
+	"github.com/connect-four/internal/loadhistory"
+	"github.com/connect-four/internal/logging"
 	"github.com/connect-four/internal/matchmaker"
+	"github.com/connect-four/internal/origins"
+	"github.com/connect-four/internal/ratelimit"
 	"github.com/connect-four/internal/storage"
 	"github.com/connect-four/internal/websocket"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/cors"
 )
 
-// loadEnvFile loads environment variables from a .env file
-func loadEnvFile(filename string) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return // .env file is optional
+func main() {
+	rebuildAnalytics := flag.Bool("rebuild-analytics", false,
+		"ignore the saved analytics snapshot and replay the topic from the start position")
+	flag.Parse()
+
+	// Load .env file if present
+	if err := config.LoadDotEnv(".env"); err != nil {
+		slog.Error("reading .env failed", "error", err)
+		os.Exit(1)
 	}
-	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) == 2 {
-			key := strings.TrimSpace(parts[0])
-			value := strings.TrimSpace(parts[1])
-			if os.Getenv(key) == "" { // Don't override existing env vars
-				os.Setenv(key, value)
-			}
-		}
+	cfg, err := config.Load(os.Getenv)
+	if err != nil {
+		slog.Error("invalid configuration, not starting", "error", err)
+		os.Exit(1)
 	}
-}
+	logger := logging.New(cfg.Server.LogLevel)
 
-func main() {
-	// Load .env file if present
-	loadEnvFile(".env")
-	
 	ctx := context.Background()
 
-	// Initialize PostgreSQL store
-	store, err := storage.NewPostgresStore(ctx)
-	if err != nil {
-		log.Printf("Warning: Database not available: %v", err)
-		log.Println("Running in memory-only mode (games won't be persisted)")
-		store = nil
-	} else {
+	// "server migrate" applies pending database migrations and exits
+	if flag.Arg(0) == "migrate" {
+		applied, err := storage.Migrate(ctx, cfg.Database.URL)
+		if err != nil {
+			slog.Error("database migration failed", "error", err)
+			os.Exit(1)
+		}
+		slog.Info("database schema up to date", "applied", applied)
+		return
+	}
+
+	// "server backfill-win-types" classifies games saved before the win
+	// type was recorded and exits
+	if flag.Arg(0) == "backfill-win-types" {
+		store, err := storage.Open(ctx, cfg.Database.URL)
+		if err != nil {
+			slog.Error("database not available", "error", err)
+			os.Exit(1)
+		}
 		defer store.Close()
+
+		updated, err := store.BackfillWinTypes(ctx)
+		if err != nil {
+			slog.Error("win type backfill failed", "error", err)
+			os.Exit(1)
+		}
+		slog.Info("win types backfilled", "updated", updated)
+		return
 	}
 
-	// Initialize Kafka producer
-	producer, err := kafka.NewProducer()
-	if err != nil {
-		log.Printf("Warning: Kafka producer not available: %v", err)
+	// Initialize the store. A PostgreSQL or SQLite database that can't be
+	// reached leaves the server running without it until it comes back.
+	var store storage.Store
+	var resilient *storage.ResilientStore
+	if cfg.Database.URL == storage.MemoryURL {
+		slog.Warn("running in memory-only mode (games won't survive a restart)")
+		store = storage.NewMemoryStore()
+	} else {
+		resilient, err = storage.OpenResilient(ctx, cfg.Database.URL, cfg.Database.ConnectAttempts, logger)
+		if err != nil {
+			slog.Error("database not available, reconnecting in the background", "error", err)
+		}
+		go resilient.Run(ctx)
+		store = resilient
 	}
-	defer producer.Close()
+	defer store.Close()
 
-	// Initialize Kafka consumer (optional)
-	var consumer *kafka.Consumer
-	if producer.IsEnabled() {
-		consumer, err = kafka.NewConsumer()
+	// Pick where game events go
+	var emitter events.Emitter = events.Nop{}
+	var producer *kafka.Producer
+	var analytics api.Analytics
+	switch cfg.Kafka.Events {
+	case config.EventsKafka:
+		// Initialize Kafka producer
+		producer, err = kafka.NewProducer(cfg.Kafka.Brokers)
 		if err != nil {
-			log.Printf("Warning: Kafka consumer not available: %v", err)
-		} else {
-			consumer.Start()
-			defer consumer.Stop()
+			slog.Error("Kafka producer not available", "error", err)
+			os.Exit(1)
 		}
+		emitter = producer
+
+		// Initialize Kafka consumer; like the producer it connects in the
+		// background once Kafka is reachable
+		consumer, err := kafka.NewConsumer(cfg.Kafka.Brokers, cfg.Kafka.ConsumeFrom)
+		if err != nil {
+			slog.Warn("Kafka consumer not available", "error", err)
+			break
+		}
+		// Snapshots cover the topic up to their offsets, so they are not
+		// used when consuming from the newest offset. From a timestamp the
+		// replayed events the snapshot already counted are skipped.
+		if cfg.Kafka.ConsumeFrom.Mode != kafka.StartNewest {
+			consumer.SetSnapshots(store, cfg.Kafka.SnapshotInterval)
+			if *rebuildAnalytics {
+				slog.Info("rebuilding analytics from the start position", "from", cfg.Kafka.ConsumeFrom)
+			} else if _, err := consumer.RestoreSnapshot(ctx); err != nil {
+				slog.Warn("analytics snapshot not restored, replaying the topic", "error", err)
+			}
+		}
+		consumer.SetDeadLetters(producer)
+		consumer.Start()
+		defer consumer.Stop()
+		analytics = consumer
+	case config.EventsLocal:
+		local := kafka.NewLocalAnalytics()
+		emitter, analytics = local, local
+		slog.Info("aggregating game events in process; analytics reset on restart")
+	case config.EventsNone:
+		slog.Info("game events disabled")
 	}
+	defer emitter.Close()
 
 	// Initialize matchmaker
 	mm := matchmaker.NewMatchmaker()
+	mm.SetLogger(logger)
+	mm.SetTurnTimeout(cfg.Game.TurnTimeout)
+	mm.SetReconnectWindow(cfg.Game.ReconnectWindow)
+	mm.SetMatchmakingTimeout(cfg.Game.MatchmakingTimeout)
+	mm.SetFirstPlayerPolicy(cfg.Game.FirstPlayer)
+	mm.SetMaxTakebacks(cfg.Game.MaxTakebacks)
+	mm.SetLimits(cfg.Game.MaxWaiting, cfg.Game.MaxActiveGames)
+	mm.SetFinishedGrace(cfg.Game.FinishedGrace)
 
 	// Initialize WebSocket hub
 	hub := websocket.NewHub(mm)
+	hub.SetLogger(logger)
+	hub.SetTurnTimeoutAction(cfg.Game.TurnTimeoutAction)
+	hub.SetSessionPolicy(cfg.Server.SessionPolicy)
+	hub.SetHeartbeat(cfg.Server.PingInterval, cfg.Server.PongTimeout)
+	hub.SetMessageRateLimit(cfg.Server.WSMessageRate, cfg.Server.WSMessageBurst)
+	hub.SetMaxConnections(cfg.Server.MaxConnections)
 
 	// Set up game start callback for Kafka events
-	mm.SetOnGameStart(func(g *game.Game) {
-		producer.EmitGameStart(g)
-	})
+	mm.SetOnGameStart(emitter.EmitGameStart)
+
+	mm.SetOnFallback(emitter.EmitMatchmakingFallback)
+
+	// Set up move and connection callbacks for Kafka events
+	hub.SetOnMove(emitter.EmitMove)
+	hub.SetOnDisconnect(emitter.EmitDisconnect)
+	hub.SetOnReconnect(emitter.EmitReconnect)
 
-	// Set up game end callback for persistence and Kafka
-	hub.SetOnGameEnd(func(g *game.Game) {
+	// Set up game end callback for persistence and Kafka, shared by games
+	// the matchmaker's sweep ends
+	// Saves that fail are retried in the background
+	saves := storage.NewSaveQueue(store)
+	saves.SetLogger(logger)
+	go saves.Run(ctx)
+
+	onGameEnd := func(g *game.Game) {
 		// Emit Kafka event
-		producer.EmitGameEnd(g)
+		emitter.EmitGameEnd(g)
 
 		// Persist to database
-		if store != nil {
-			if err := store.SaveGame(context.Background(), g); err != nil {
-				log.Printf("Error saving game: %v", err)
-			}
+		saves.Save(context.Background(), g)
+	}
+	hub.SetOnGameEnd(onGameEnd)
+
+	// Usernames keep the spelling they already have in game history
+	hub.SetUsernameResolver(func(ctx context.Context, username string) string {
+		var near *storage.NearCollisionError
+		if _, err := store.ResolveUsername(ctx, username); errors.As(err, &near) {
+			return near.Suggestion
 		}
+		return username
 	})
+	hub.SetReplayLoader(func(ctx context.Context, gameID string) (*websocket.ReplayGame, error) {
+		cg, err := store.GetGameByID(ctx, gameID)
+		if errors.Is(err, storage.ErrGameNotFound) {
+			return nil, game.ErrGameNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &websocket.ReplayGame{
+			ID:      cg.ID,
+			Player1: cg.Player1,
+			Player2: cg.Player2,
+			Winner:  cg.Winner,
+			Result:  cg.Result,
+			Moves:   cg.MoveList,
+			Size:    cg.BoardSize,
+		}, nil
+	})
+	mm.SetOnGameEnd(func(g *game.Game) {
+		onGameEnd(g)
+		hub.ForgetGame(g.ID)
+	})
+	mm.SetAttendanceCheck(hub.IsGameAttended)
+
+	// With a database, unfinished games are checkpointed and picked up
+	// again after a restart; players reconnect to them as usual
+	if _, inMemory := store.(*storage.MemoryStore); !inMemory {
+		mm.SetGameStore(store)
+		if _, err := mm.RecoverGames(ctx); err != nil {
+			slog.Error("recovering in-progress games failed", "error", err)
+		}
+		go mm.RunCheckpoints(ctx)
+		hub.SetKeepGamesOnShutdown(true)
+	}
 
 	// Start WebSocket hub
 	go hub.Run()
+	go hub.RunIdleWatchdog(cfg.Game.IdleAfter)
+	go mm.RunReaper(cfg.Game.StaleAfter)
+	go storage.RunAnalyticsRollup(ctx, store)
 
 	// Create message handler
 	handler := websocket.NewHandler(hub, mm)
+	allowedOrigins := origins.NewMatcher(cfg.Server.AllowedOrigins)
+	if allowedOrigins.Permissive() && cfg.Server.AppEnv == "production" {
+		slog.Warn("ALLOWED_ORIGINS is unset; any origin may call the API and connect")
+	}
+	hub.SetAllowedOrigins(allowedOrigins)
+	var signer *auth.Signer
+	if cfg.Server.AuthEnabled {
+		signer = auth.NewSigner([]byte(cfg.Server.AuthSecret), cfg.Server.AuthTokenTTL)
+		hub.SetAuth(signer)
+	}
+	handler.SetAvatarHosts(cfg.Server.AvatarAllowedHosts)
 
 	// Set up HTTP router
 	r := chi.NewRouter()
 
 	// Middleware
-	r.Use(middleware.Logger)
+	r.Use(logging.Middleware(logger))
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.RealIP)
-	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
-		AllowCredentials: true,
-		MaxAge:           300,
-	}))
+	r.Use(api.CORS(allowedOrigins))
+
+	// API handlers
+	apiHandlers := api.NewHandlers(store, mm, producer, analytics)
+
+	// Record load history for the status dashboard
+	history := loadhistory.NewRecorder(loadhistory.Source{
+		ConnectedClients: hub.ClientCount,
+		ActiveGames:      mm.GetActiveGameCount,
+		QueueDepth:       mm.GetWaitingCount,
+		Broadcasts:       hub.BroadcastCount,
+		BotSearches:      game.BotSearchCount,
+		APIRequests:      apiHandlers.RequestCount,
+	})
+	history.Start()
+	defer history.Stop()
+	apiHandlers.SetLoadHistory(history)
+	apiHandlers.SetHub(hub)
+	apiHandlers.SetSaveQueue(saves)
+	apiHandlers.SetCacheTTL(cfg.Server.APICacheTTL)
+	if signer != nil {
+		apiHandlers.SetAuth(signer)
+	}
+	if resilient != nil {
+		apiHandlers.SetStoreState(resilient.State)
+	}
+	apiHandlers.SetAdminOptions(api.AdminOptions{
+		APIKey:     cfg.Server.AdminAPIKey,
+		Production: cfg.Server.AppEnv == "production",
+		DumpDir:    cfg.Server.DebugDumpDir,
+	})
+
+	if cfg.Server.APIRequestRate > 0 {
+		limiter := ratelimit.NewLimiter(cfg.Server.APIRequestRate, cfg.Server.APIRequestBurst, 10*time.Minute)
+		defer limiter.Stop()
+		apiHandlers.SetRateLimiter(limiter)
+	}
 
 	// API routes
 	r.Route("/api", func(r chi.Router) {
-		apiHandlers := api.NewHandlers(store, mm, producer, consumer)
 		apiHandlers.RegisterRoutes(r)
 	})
 
@@ -139,16 +304,12 @@ func main() {
 		websocket.ServeWs(hub, handler, w, r)
 	})
 
-	// Health check
-	r.Get("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte("OK"))
-	})
+	// Health checks; /health is kept as an alias of /health/live
+	r.Get("/health", apiHandlers.GetLive)
+	r.Get("/health/live", apiHandlers.GetLive)
+	r.Get("/health/ready", apiHandlers.GetReady)
 
-	// Get port from environment or default
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
+	port := cfg.Server.Port
 
 	// Create server
 	server := &http.Server{
@@ -161,11 +322,12 @@ func main() {
 
 	// Start server in goroutine
 	go func() {
-		log.Printf("Server starting on port %s", port)
-		log.Printf("WebSocket endpoint: ws://localhost:%s/ws", port)
-		log.Printf("API endpoint: http://localhost:%s/api", port)
+		slog.Info("server starting", "port", port,
+			"websocket", "ws://localhost:"+port+"/ws",
+			"api", "http://localhost:"+port+"/api")
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Server error: %v", err)
+			slog.Error("server error", "error", err)
+			os.Exit(1)
 		}
 	}()
 
@@ -173,14 +335,19 @@ func main() {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
-	log.Println("Shutting down server...")
+	slog.Info("shutting down server")
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	// Tell players and save their games while the connections still work
+	hub.Shutdown(ctx)
+	saves.Flush(ctx)
+
 	if err := server.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+		slog.Error("server forced to shutdown", "error", err)
+		os.Exit(1)
 	}
 
-	log.Println("Server exited properly")
+	slog.Info("server exited properly")
 }
@@ -0,0 +1,168 @@
+// Command simulate plays bots against each other to compare difficulty
+// tiers and evaluation changes. Games never touch storage; with -kafka they
+// are emitted as events flagged simulated, which analytics ignores.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/connect-four/internal/config"
+	"github.com/connect-four/internal/events"
+	"github.com/connect-four/internal/game"
+	"github.com/connect-four/internal/kafka"
+)
+
+// botSpec describes one side of the simulation
+type botSpec struct {
+	name       string
+	difficulty game.Difficulty
+	depth      int // overrides the difficulty's depth when set
+}
+
+// build creates the bot for a seat
+func (s botSpec) build(player int, rng *game.Rand) *game.Bot {
+	if s.depth > 0 {
+		return game.NewBotWithDepth(player, s.depth, game.WithRand(rng))
+	}
+	return game.NewBot(player, s.difficulty, game.WithRand(rng))
+}
+
+// tally accumulates one side's results
+type tally struct {
+	wins     int
+	moves    int
+	moveTime time.Duration
+}
+
+func main() {
+	games := flag.Int("games", 100, "number of games to play")
+	difficultyA := flag.String("a", "medium", "difficulty of bot A (easy, medium, hard)")
+	difficultyB := flag.String("b", "medium", "difficulty of bot B (easy, medium, hard)")
+	depthA := flag.Int("depth-a", 0, "search depth of bot A, overriding its difficulty")
+	depthB := flag.Int("depth-b", 0, "search depth of bot B, overriding its difficulty")
+	seed := flag.Int64("seed", 0, "random seed (0 seeds from the clock)")
+	alternate := flag.Bool("alternate", true, "swap which bot moves first every game")
+	emit := flag.Bool("kafka", false, "emit games to Kafka flagged as simulated")
+	flag.Parse()
+
+	specs := [2]botSpec{
+		{name: "SIM-A", depth: *depthA},
+		{name: "SIM-B", depth: *depthB},
+	}
+	for i, name := range []string{*difficultyA, *difficultyB} {
+		difficulty, ok := game.ParseDifficulty(name)
+		if !ok {
+			log.Fatalf("Unknown difficulty %q", name)
+		}
+		specs[i].difficulty = difficulty
+	}
+
+	rng := game.NewTimeSeededRand()
+	if *seed != 0 {
+		rng = game.NewRand(*seed)
+	}
+
+	var emitter events.Emitter = events.Nop{}
+	if *emit {
+		cfg, err := config.Load(os.Getenv)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		producer, err := kafka.NewProducer(cfg.Kafka.Brokers)
+		if err != nil {
+			log.Fatalf("Kafka producer: %v", err)
+		}
+		emitter = producer
+	}
+	defer emitter.Close()
+
+	var results [2]tally
+	draws, totalMoves := 0, 0
+	for i := 0; i < *games; i++ {
+		first := 0
+		if *alternate && i%2 == 1 {
+			first = 1
+		}
+		winner, moves := playGame(specs, first, rng, emitter, &results)
+		totalMoves += moves
+		if winner < 0 {
+			draws++
+		} else {
+			results[winner].wins++
+		}
+	}
+
+	fmt.Printf("%d games, average length %.1f moves\n", *games, float64(totalMoves)/float64(max(*games, 1)))
+	for i, spec := range specs {
+		avgMove := time.Duration(0)
+		if results[i].moves > 0 {
+			avgMove = results[i].moveTime / time.Duration(results[i].moves)
+		}
+		fmt.Printf("%s (%s): %d wins (%.1f%%), average move time %s\n",
+			spec.name, describe(spec), results[i].wins, percent(results[i].wins, *games), avgMove)
+	}
+	fmt.Printf("Draws: %d (%.1f%%)\n", draws, percent(draws, *games))
+}
+
+// playGame plays one game with specs[first] as Player1. It returns the index
+// of the winning spec, or -1 for a draw, and the number of moves played.
+func playGame(specs [2]botSpec, first int, rng *game.Rand, emitter events.Emitter, results *[2]tally) (int, int) {
+	seats := [3]int{0, first, 1 - first} // player number -> spec index
+
+	g := game.NewGame(specs[seats[game.Player1]].name)
+	g.AddPlayer2(specs[seats[game.Player2]].name, false)
+	g.Player1.IsBot = true
+	g.Player2.IsBot = true
+	g.Simulated = true
+	g.TurnTimeout = 0
+
+	bots := [3]*game.Bot{
+		nil,
+		specs[seats[game.Player1]].build(game.Player1, rng),
+		specs[seats[game.Player2]].build(game.Player2, rng),
+	}
+	emitter.EmitGameStart(g)
+
+	for g.GetState().Status == game.StatusPlaying {
+		player := g.GetState().CurrentTurn
+		started := time.Now()
+		column := bots[player].GetBestMove(g.Board)
+		results[seats[player]].moveTime += time.Since(started)
+		results[seats[player]].moves++
+
+		row, err := g.MakeMove(player, column)
+		if err != nil {
+			log.Fatalf("Bot played an illegal move: %v", err)
+		}
+		emitter.EmitMove(g, specs[seats[player]].name, column, row, len(g.Moves))
+	}
+	emitter.EmitGameEnd(g)
+
+	switch game.GameResult(g.GetState().Result) {
+	case game.ResultWinPlayer1:
+		return seats[game.Player1], len(g.Moves)
+	case game.ResultWinPlayer2:
+		return seats[game.Player2], len(g.Moves)
+	}
+	return -1, len(g.Moves)
+}
+
+// describe summarises a bot's settings
+func describe(spec botSpec) string {
+	if spec.depth > 0 {
+		return fmt.Sprintf("depth %d", spec.depth)
+	}
+	return string(spec.difficulty)
+}
+
+// percent returns n as a percentage of total
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total) * 100
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/connect-four/internal/matchmaker"
+	"github.com/connect-four/internal/websocket"
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	// maxDumpGames caps how many games a debug dump includes
+	maxDumpGames = 500
+
+	// maxDumpClients caps how many clients a debug dump includes
+	maxDumpClients = 1000
+)
+
+// AdminOptions configures the admin endpoints
+type AdminOptions struct {
+	APIKey     string // bearer token; empty disables the admin endpoints
+	Production bool   // debug dumps require ?confirm=true
+	DumpDir    string // where ?toFile=true dumps go; empty means the temp dir
+}
+
+// SetAdminOptions sets the admin API key and debug dump settings
+func (h *Handlers) SetAdminOptions(opts AdminOptions) {
+	h.admin = opts
+}
+
+// requireAdmin rejects requests without the admin API key bearer token.
+// Missing credentials get 401, wrong ones 403. The key is never logged.
+func (h *Handlers) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := h.admin.APIKey
+		if key == "" {
+			http.Error(w, "Admin API is disabled", http.StatusForbidden)
+			return
+		}
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(key)) != 1 {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// SetHub sets the WebSocket hub used by the debug endpoints
+func (h *Handlers) SetHub(hub *websocket.Hub) {
+	h.hub = hub
+}
+
+// GetDebugDump returns a snapshot of in-memory matchmaker and hub state.
+// In production it additionally requires ?confirm=true. With ?toFile=true
+// the dump is written to the configured dump dir (or the temp dir) instead.
+func (h *Handlers) GetDebugDump(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if h.admin.Production && query.Get("confirm") != "true" {
+		http.Error(w, "Pass confirm=true to dump state in production", http.StatusPreconditionRequired)
+		return
+	}
+
+	// Each component is snapshotted under its own lock, one at a time
+	dump := map[string]interface{}{
+		"generatedAt": time.Now(),
+		"matchmaker":  redactMatchmaker(h.matchmaker.DebugSnapshot(maxDumpGames)),
+	}
+	if h.hub != nil {
+		dump["hub"] = h.hub.DebugSnapshot(maxDumpClients, maxDumpGames)
+	}
+
+	if query.Get("toFile") != "true" {
+		respondJSON(w, dump)
+		return
+	}
+
+	dir := h.admin.DumpDir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	path := filepath.Join(dir, fmt.Sprintf("debug-dump-%s.json", time.Now().UTC().Format("20060102T150405Z")))
+
+	data, err := json.MarshalIndent(dump, "", "  ")
+	if err != nil {
+		http.Error(w, "Failed to encode dump", http.StatusInternalServerError)
+		return
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		http.Error(w, "Failed to write dump", http.StatusInternalServerError)
+		return
+	}
+
+	respondJSON(w, map[string]string{"file": path})
+}
+
+// ForceEndGame ends a stuck game, forfeiting the player whose turn it is (or
+// the human if the bot is on turn) so the game is still persisted
+func (h *Handlers) ForceEndGame(w http.ResponseWriter, r *http.Request) {
+	if h.hub == nil {
+		http.Error(w, "Game play unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	g := h.matchmaker.GetGame(chi.URLParam(r, "id"))
+	if g == nil {
+		http.Error(w, "Game not found", http.StatusNotFound)
+		return
+	}
+	if !h.hub.ForceEnd(g) {
+		http.Error(w, "Game is not in progress", http.StatusConflict)
+		return
+	}
+
+	respondJSON(w, g.GetState())
+}
+
+// redactMatchmaker strips query strings and fragments from avatar URLs,
+// which may carry signed-URL tokens
+func redactMatchmaker(snapshot matchmaker.DebugSnapshot) matchmaker.DebugSnapshot {
+	for _, g := range snapshot.Games {
+		if g.State.Player1AvatarURL != "" {
+			g.State.Player1AvatarURL = redactURL(g.State.Player1AvatarURL)
+		}
+		if g.State.Player2AvatarURL != "" {
+			g.State.Player2AvatarURL = redactURL(g.State.Player2AvatarURL)
+		}
+	}
+	return snapshot
+}
+
+// redactURL removes credentials, query and fragment from a URL
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "[redacted]"
+	}
+	u.User = nil
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
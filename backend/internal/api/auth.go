@@ -0,0 +1,61 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/connect-four/internal/auth"
+	"github.com/connect-four/internal/jsonutil"
+	"github.com/connect-four/internal/usernames"
+)
+
+// GuestTokenRequest is the body accepted by IssueGuestToken
+type GuestTokenRequest struct {
+	Username string `json:"username"`
+}
+
+// Validate checks the guest token request fields
+func (req *GuestTokenRequest) Validate() error {
+	verr := &jsonutil.ValidationError{}
+	if name, err := usernames.Validate(req.Username); err != nil {
+		verr.Add("username", err.Error())
+	} else {
+		req.Username = name
+	}
+	return verr.ErrOrNil()
+}
+
+// SetAuth sets the signer for guest tokens; without one, auth is off and
+// the guest token endpoint answers 404
+func (h *Handlers) SetAuth(signer *auth.Signer) {
+	h.auth = signer
+}
+
+// IssueGuestToken returns a short-lived token binding the requested
+// username, for connecting to the WebSocket when auth is on
+func (h *Handlers) IssueGuestToken(w http.ResponseWriter, r *http.Request) {
+	if h.auth == nil {
+		http.Error(w, "Guest auth is disabled", http.StatusNotFound)
+		return
+	}
+
+	var req GuestTokenRequest
+	if err := jsonutil.DecodeReader(r.Body, jsonutil.DefaultMaxBodySize, &req); err != nil {
+		respondDecodeError(w, err)
+		return
+	}
+	if h.hub != nil {
+		req.Username = h.hub.CanonicalUsername(r.Context(), req.Username)
+	}
+
+	token, expires, err := h.auth.Issue(req.Username)
+	if err != nil {
+		http.Error(w, "Failed to issue token", http.StatusInternalServerError)
+		return
+	}
+	respondJSON(w, map[string]interface{}{
+		"token":     token,
+		"username":  req.Username,
+		"expiresAt": expires.UTC().Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,243 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a settable time source for the cache
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	c.mu.Unlock()
+}
+
+// cachingServer is a test server caching results for ttl on a fake clock
+func cachingServer(t *testing.T, ttl time.Duration) (*testServer, *fakeClock) {
+	t.Helper()
+	s := newTestServer(t)
+	s.handlers.SetCacheTTL(ttl)
+	clock := &fakeClock{now: time.Now()}
+	s.handlers.cache.now = clock.Now
+	return s, clock
+}
+
+func TestResultCacheExpires(t *testing.T) {
+	clock := &fakeClock{now: time.Now()}
+	c := newResultCache(10 * time.Second)
+	c.now = clock.Now
+
+	if _, ok := c.get("k"); ok {
+		t.Fatal("empty cache has a value")
+	}
+	c.set("k", 1)
+	clock.Advance(9 * time.Second)
+	if v, ok := c.get("k"); !ok || v != 1 {
+		t.Errorf("before the TTL get = %v, %v; want 1", v, ok)
+	}
+	clock.Advance(time.Second)
+	if _, ok := c.get("k"); ok {
+		t.Error("entry still served at the TTL")
+	}
+
+	// Setting again after expiry refreshes the entry for a full TTL
+	c.set("k", 2)
+	clock.Advance(9 * time.Second)
+	if v, ok := c.get("k"); !ok || v != 2 {
+		t.Errorf("refreshed entry get = %v, %v; want 2", v, ok)
+	}
+
+	c.clear()
+	if _, ok := c.get("k"); ok {
+		t.Error("entry survived clear")
+	}
+
+	c.set("k", 3)
+	c.setTTL(0)
+	if _, ok := c.get("k"); ok {
+		t.Error("entry survived a TTL change")
+	}
+	c.set("k", 4)
+	if _, ok := c.get("k"); ok {
+		t.Error("a zero TTL cache stored a value")
+	}
+}
+
+func TestResultCacheConcurrentUse(t *testing.T) {
+	c := newResultCache(time.Minute)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				key := fmt.Sprintf("k%d", j%10)
+				c.set(key, i)
+				c.get(key)
+				if j%100 == 0 {
+					c.clear()
+				}
+				c.maxAge()
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// leaderboardUsers fetches the leaderboard and returns who is on it
+func (s *testServer) leaderboardUsers(t *testing.T) []string {
+	t.Helper()
+	rec := s.get("/api/leaderboard")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		Entries []struct {
+			Username string `json:"username"`
+		} `json:"entries"`
+	}
+	decodeJSON(t, rec, &resp)
+	var users []string
+	for _, e := range resp.Entries {
+		users = append(users, e.Username)
+	}
+	return users
+}
+
+func TestLeaderboardCacheRefreshesAfterTTL(t *testing.T) {
+	s, clock := cachingServer(t, 10*time.Second)
+	savePlayed(t, s.store, "alice", "bob", time.Now().Add(-time.Hour))
+
+	if users := s.leaderboardUsers(t); !slices.Contains(users, "alice") {
+		t.Fatalf("leaderboard = %v, want alice", users)
+	}
+
+	// A game saved within the TTL isn't seen until the entry goes stale
+	savePlayed(t, s.store, "carol", "dave", time.Now().Add(-time.Hour))
+	clock.Advance(5 * time.Second)
+	if users := s.leaderboardUsers(t); slices.Contains(users, "carol") {
+		t.Errorf("leaderboard within the TTL = %v, want the cached result", users)
+	}
+	clock.Advance(5 * time.Second)
+	if users := s.leaderboardUsers(t); !slices.Contains(users, "carol") {
+		t.Errorf("leaderboard after the TTL = %v, want carol's game", users)
+	}
+}
+
+func TestAnalyticsCacheRefreshesAfterTTL(t *testing.T) {
+	s, clock := cachingServer(t, 10*time.Second)
+	totalGames := func() int {
+		t.Helper()
+		rec := s.get("/api/analytics")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d", rec.Code)
+		}
+		var resp struct {
+			Database struct {
+				TotalGames int `json:"totalGames"`
+			} `json:"database"`
+		}
+		decodeJSON(t, rec, &resp)
+		return resp.Database.TotalGames
+	}
+
+	savePlayed(t, s.store, "alice", "bob", time.Now().Add(-time.Hour))
+	if n := totalGames(); n != 1 {
+		t.Fatalf("total games = %d, want 1", n)
+	}
+	savePlayed(t, s.store, "carol", "dave", time.Now().Add(-time.Hour))
+	if n := totalGames(); n != 1 {
+		t.Errorf("total games within the TTL = %d, want the cached 1", n)
+	}
+	clock.Advance(10 * time.Second)
+	if n := totalGames(); n != 2 {
+		t.Errorf("total games after the TTL = %d, want 2", n)
+	}
+}
+
+func TestClearLeaderboardInvalidatesCache(t *testing.T) {
+	s, _ := cachingServer(t, time.Hour)
+	s.handlers.SetAdminOptions(AdminOptions{APIKey: testAdminKey})
+	savePlayed(t, s.store, "alice", "bob", time.Now().Add(-time.Hour))
+	if users := s.leaderboardUsers(t); len(users) == 0 {
+		t.Fatal("leaderboard is empty")
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/admin/leaderboard", nil)
+	req.Header.Set("Authorization", "Bearer "+testAdminKey)
+	if rec := s.do(req); rec.Code != http.StatusOK {
+		t.Fatalf("clear: status = %d, body %s", rec.Code, rec.Body)
+	}
+	if users := s.leaderboardUsers(t); len(users) != 0 {
+		t.Errorf("leaderboard after clearing = %v, want empty well within the TTL", users)
+	}
+}
+
+func TestCacheableResponseHeaders(t *testing.T) {
+	s, clock := cachingServer(t, 20*time.Second)
+	savePlayed(t, s.store, "alice", "bob", time.Now().Add(-time.Hour))
+
+	for _, path := range []string{"/api/leaderboard", "/api/analytics"} {
+		rec := s.get(path)
+		etag := rec.Header().Get("ETag")
+		if rec.Code != http.StatusOK || etag == "" {
+			t.Fatalf("%s: status = %d, ETag %q", path, rec.Code, etag)
+		}
+		if cc := rec.Header().Get("Cache-Control"); cc != "public, max-age=20" {
+			t.Errorf("%s: Cache-Control = %q", path, cc)
+		}
+		if again := s.get(path).Header().Get("ETag"); again != etag {
+			t.Errorf("%s: ETag changed from %s to %s for the same result", path, etag, again)
+		}
+
+		// A conditional GET for the current result gets no body
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("If-None-Match", etag)
+		notModified := s.do(req)
+		if notModified.Code != http.StatusNotModified || notModified.Body.Len() != 0 {
+			t.Errorf("%s: conditional GET = %d with %d bytes, want 304 and no body", path, notModified.Code, notModified.Body.Len())
+		}
+		if notModified.Header().Get("ETag") != etag {
+			t.Errorf("%s: 304 without the ETag", path)
+		}
+	}
+
+	// Once the result changes the old ETag no longer matches
+	rec := s.get("/api/leaderboard")
+	etag := rec.Header().Get("ETag")
+	savePlayed(t, s.store, "carol", "dave", time.Now().Add(-time.Hour))
+	clock.Advance(20 * time.Second)
+	req := httptest.NewRequest(http.MethodGet, "/api/leaderboard", nil)
+	req.Header.Set("If-None-Match", etag)
+	changed := s.do(req)
+	if changed.Code != http.StatusOK || changed.Header().Get("ETag") == etag {
+		t.Errorf("changed result: status = %d, ETag %q, want 200 with a new ETag", changed.Code, changed.Header().Get("ETag"))
+	}
+}
+
+func TestCacheDisabledHeaders(t *testing.T) {
+	s := newTestServer(t) // caching is off in test servers
+	rec := s.get("/api/leaderboard")
+	if cc := rec.Header().Get("Cache-Control"); cc != "no-cache" {
+		t.Errorf("Cache-Control = %q, want no-cache", cc)
+	}
+	if rec.Header().Get("ETag") == "" {
+		t.Error("no ETag with caching off")
+	}
+}
@@ -0,0 +1,191 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/connect-four/internal/game"
+	"github.com/connect-four/internal/storage"
+)
+
+const (
+	// consistencyCheckTimeout bounds each audit check independently
+	consistencyCheckTimeout = 3 * time.Second
+
+	// maxGameAge is how long a game may stay active before it is considered stuck
+	maxGameAge = 2 * time.Hour
+
+	// finishedGameGrace is how long a finished game normally lingers in the
+	// matchmaker before the hub removes it
+	finishedGameGrace = time.Minute
+)
+
+// Consistency check statuses
+const (
+	checkOK          = "ok"
+	checkDiscrepancy = "discrepancy"
+	checkSkipped     = "skipped"
+	checkError       = "error"
+)
+
+// ConsistencyCheck is the outcome of one audit check
+type ConsistencyCheck struct {
+	Name          string   `json:"name"`
+	Status        string   `json:"status"`
+	Detail        string   `json:"detail,omitempty"`
+	Discrepancies []string `json:"discrepancies,omitempty"`
+	Remediation   string   `json:"remediation,omitempty"`
+}
+
+// GetConsistency audits games across the matchmaker, the store and the
+// Kafka aggregates and reports anything that doesn't line up
+func (h *Handlers) GetConsistency(w http.ResponseWriter, r *http.Request) {
+	games := h.matchmaker.DebugSnapshot(-1).Games
+	now := time.Now()
+
+	checks := []func(ctx context.Context) ConsistencyCheck{
+		func(ctx context.Context) ConsistencyCheck { return h.checkGamesToday(ctx, games, now) },
+		func(ctx context.Context) ConsistencyCheck { return h.checkDailyRollup(ctx, now) },
+		func(ctx context.Context) ConsistencyCheck { return checkStaleActiveGames(games, now) },
+		func(ctx context.Context) ConsistencyCheck { return checkFinishedStillActive(games, now) },
+	}
+
+	// Run checks concurrently so one slow source doesn't hold up the rest
+	results := make([]ConsistencyCheck, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check func(ctx context.Context) ConsistencyCheck) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(r.Context(), consistencyCheckTimeout)
+			defer cancel()
+			results[i] = check(ctx)
+		}(i, check)
+	}
+	wg.Wait()
+
+	consistent := true
+	for _, result := range results {
+		if result.Status == checkDiscrepancy {
+			consistent = false
+		}
+	}
+
+	respondJSON(w, map[string]interface{}{
+		"checkedAt":  now,
+		"consistent": consistent,
+		"checks":     results,
+	})
+}
+
+// checkGamesToday compares games started today according to the event
+// analytics with the games persisted today plus those still being played
+func (h *Handlers) checkGamesToday(ctx context.Context, games []game.GameDebug, now time.Time) ConsistencyCheck {
+	check := ConsistencyCheck{Name: "gamesToday"}
+	if h.analytics == nil {
+		check.Status = checkSkipped
+		check.Detail = "event analytics are disabled"
+		return check
+	}
+
+	analytics, err := h.store.GetAnalytics(ctx)
+	if err != nil {
+		check.Status = checkError
+		check.Detail = "store: " + err.Error()
+		return check
+	}
+
+	today := now.Truncate(24 * time.Hour)
+	inProgress := 0
+	for _, g := range games {
+		if g.EndedAt == nil && !g.CreatedAt.Before(today) {
+			inProgress++
+		}
+	}
+
+	started := h.analytics.GetMetrics().GamesPerDay[today.Format("2006-01-02")]
+	expected := analytics.GamesToday + inProgress
+
+	check.Detail = fmt.Sprintf("kafka started %d, store finished %d, in progress %d", started, analytics.GamesToday, inProgress)
+	if !h.analytics.GetReplayProgress().CaughtUp {
+		check.Status = checkSkipped
+		check.Detail += "; consumer is still warming"
+		return check
+	}
+	if started == expected {
+		check.Status = checkOK
+		return check
+	}
+
+	check.Status = checkDiscrepancy
+	check.Discrepancies = []string{fmt.Sprintf("kafka counted %d games started today, expected %d", started, expected)}
+	check.Remediation = "Imported games and failed saves are not in Kafka; if the store was cleared with DELETE /api/leaderboard the Kafka aggregates keep the old counts until the consumer restarts"
+	return check
+}
+
+// checkDailyRollup compares today's hourly analytics buckets with the games
+// stored for each hour
+func (h *Handlers) checkDailyRollup(ctx context.Context, now time.Time) ConsistencyCheck {
+	check := ConsistencyCheck{Name: "dailyRollup"}
+	mismatches, err := h.store.CompareRollup(ctx, now.Truncate(24*time.Hour))
+	if errors.Is(err, storage.ErrNoRollup) {
+		check.Status = checkSkipped
+		check.Detail = "unsupported: the " + h.store.Backend() + " store keeps no rollup"
+		return check
+	}
+	if err != nil {
+		check.Status = checkError
+		check.Detail = "store: " + err.Error()
+		return check
+	}
+
+	if len(mismatches) == 0 {
+		check.Status = checkOK
+		check.Detail = "today's rolled up hours match the stored games"
+		return check
+	}
+
+	check.Status = checkDiscrepancy
+	for _, m := range mismatches {
+		check.Discrepancies = append(check.Discrepancies, fmt.Sprintf("hour %s rolled up %d games, the store has %d", m.Hour.UTC().Format(time.RFC3339), m.Rolled, m.Stored))
+	}
+	check.Remediation = "Games were saved or deleted after their hour was rolled up; the next hourly rollup recomputes the last day of buckets"
+	return check
+}
+
+// checkStaleActiveGames lists games that have been active longer than maxGameAge
+func checkStaleActiveGames(games []game.GameDebug, now time.Time) ConsistencyCheck {
+	check := ConsistencyCheck{Name: "staleActiveGames", Status: checkOK}
+	for _, g := range games {
+		if g.EndedAt == nil && now.Sub(g.CreatedAt) > maxGameAge {
+			check.Discrepancies = append(check.Discrepancies, fmt.Sprintf("game %s active for %s", g.State.ID, now.Sub(g.CreatedAt).Round(time.Second)))
+		}
+	}
+
+	check.Detail = fmt.Sprintf("%d active games checked against a %s limit", len(games), maxGameAge)
+	if len(check.Discrepancies) > 0 {
+		check.Status = checkDiscrepancy
+		check.Remediation = "Stuck games are only cleared by a restart; inspect them with GET /api/debug/dump"
+	}
+	return check
+}
+
+// checkFinishedStillActive lists finished games the hub never removed from the matchmaker
+func checkFinishedStillActive(games []game.GameDebug, now time.Time) ConsistencyCheck {
+	check := ConsistencyCheck{Name: "finishedStillActive", Status: checkOK}
+	for _, g := range games {
+		if g.EndedAt != nil && now.Sub(*g.EndedAt) > finishedGameGrace {
+			check.Discrepancies = append(check.Discrepancies, fmt.Sprintf("game %s finished %s ago but is still in the matchmaker", g.State.ID, now.Sub(*g.EndedAt).Round(time.Second)))
+		}
+	}
+
+	if len(check.Discrepancies) > 0 {
+		check.Status = checkDiscrepancy
+		check.Remediation = "The game-end cleanup did not run; the players cannot join a new game until it is removed, which currently requires a restart"
+	}
+	return check
+}
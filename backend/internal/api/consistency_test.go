@@ -0,0 +1,156 @@
+package api
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/connect-four/internal/game"
+	"github.com/connect-four/internal/kafka"
+	"github.com/connect-four/internal/storage"
+)
+
+// fakeAnalytics reports fixed consumer metrics; the consistency audit only
+// reads the metrics and the replay progress
+type fakeAnalytics struct {
+	Analytics
+	metrics  *kafka.AnalyticsMetrics
+	progress kafka.ReplayProgress
+}
+
+func (f *fakeAnalytics) GetMetrics() *kafka.AnalyticsMetrics     { return f.metrics }
+func (f *fakeAnalytics) GetReplayProgress() kafka.ReplayProgress { return f.progress }
+
+type consistencyReport struct {
+	Consistent bool               `json:"consistent"`
+	Checks     []ConsistencyCheck `json:"checks"`
+}
+
+// getConsistency runs the audit and returns its checks by name
+func (s *testServer) getConsistency(t *testing.T) (consistencyReport, map[string]ConsistencyCheck) {
+	t.Helper()
+	rec := s.getAdmin("/api/admin/consistency")
+	if rec.Code != 200 {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	var report consistencyReport
+	decodeJSON(t, rec, &report)
+	checks := make(map[string]ConsistencyCheck)
+	for _, c := range report.Checks {
+		checks[c.Name] = c
+	}
+	return report, checks
+}
+
+// savePlayed stores a finished game between a and b created at start
+func savePlayed(t *testing.T, store storage.Store, a, b string, start time.Time) {
+	t.Helper()
+	moves, err := game.FromNotation("1212121")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := game.NewImportedGame(a, b, moves)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Imported = false
+	g.StartTime, g.PlayStartedAt, g.EndTime = start, start, start.Add(time.Minute)
+	if err := store.SaveGame(context.Background(), g); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConsistencyDetectsEachDiscrepancy(t *testing.T) {
+	store, err := storage.NewSQLiteStore(context.Background(), filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(store.Close)
+
+	now := time.Now()
+	today := now.Truncate(24 * time.Hour)
+	analytics := &fakeAnalytics{
+		metrics:  &kafka.AnalyticsMetrics{GamesPerDay: map[string]int{today.Format("2006-01-02"): 100}},
+		progress: kafka.ReplayProgress{CaughtUp: true},
+	}
+	s := newTestServerWith(t, store, analytics)
+	s.handlers.SetAdminOptions(AdminOptions{APIKey: testAdminKey})
+
+	// A game saved after its hour was rolled up leaves the bucket behind.
+	// Only finished hours are rolled up, so this needs an hour of today to
+	// have passed.
+	rollupSeeded := now.Sub(today) >= time.Hour
+	if rollupSeeded {
+		savePlayed(t, store, "alice", "bob", today.Add(10*time.Minute))
+		if err := store.RollupAnalytics(context.Background(), now); err != nil {
+			t.Fatal(err)
+		}
+		savePlayed(t, store, "alice", "carol", today.Add(20*time.Minute))
+	}
+
+	stale := s.humanGame(t, "erin", "frank")
+	stale.StartTime = now.Add(-3 * time.Hour)
+
+	finished := s.humanGame(t, "grace", "heidi")
+	finished.Forfeit(game.Player1)
+	finished.EndTime = now.Add(-5 * time.Minute)
+
+	report, checks := s.getConsistency(t)
+	if report.Consistent {
+		t.Error("report is consistent despite seeded discrepancies")
+	}
+
+	expect := map[string]string{
+		"gamesToday":          "kafka counted 100 games started today",
+		"staleActiveGames":    stale.ID,
+		"finishedStillActive": finished.ID,
+	}
+	if rollupSeeded {
+		expect["dailyRollup"] = "rolled up 1 games, the store has 2"
+	} else {
+		t.Log("first hour of the day: no rolled up hour to seed a rollup mismatch in")
+	}
+	for name, want := range expect {
+		c, ok := checks[name]
+		if !ok {
+			t.Errorf("check %s missing from %+v", name, report.Checks)
+			continue
+		}
+		if c.Status != checkDiscrepancy {
+			t.Errorf("%s: status %q (%s), want discrepancy", name, c.Status, c.Detail)
+			continue
+		}
+		if len(c.Discrepancies) != 1 || !strings.Contains(c.Discrepancies[0], want) {
+			t.Errorf("%s: discrepancies %q, want one mentioning %q", name, c.Discrepancies, want)
+		}
+		if c.Remediation == "" {
+			t.Errorf("%s: no remediation", name)
+		}
+	}
+}
+
+func TestConsistencySkipsUnsupportedChecks(t *testing.T) {
+	s := newTestServer(t)
+	s.handlers.SetAdminOptions(AdminOptions{APIKey: testAdminKey})
+	s.humanGame(t, "alice", "bob")
+
+	report, checks := s.getConsistency(t)
+	if !report.Consistent {
+		t.Errorf("fresh server is inconsistent: %+v", report.Checks)
+	}
+
+	rollup := checks["dailyRollup"]
+	if rollup.Status != checkSkipped || !strings.Contains(rollup.Detail, "unsupported") {
+		t.Errorf("dailyRollup on the memory store = %q (%s), want skipped as unsupported", rollup.Status, rollup.Detail)
+	}
+	if c := checks["gamesToday"]; c.Status != checkSkipped {
+		t.Errorf("gamesToday without event analytics = %q, want skipped", c.Status)
+	}
+	for _, name := range []string{"staleActiveGames", "finishedStillActive"} {
+		if c := checks[name]; c.Status != checkOK {
+			t.Errorf("%s = %q %q, want ok", name, c.Status, c.Discrepancies)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/connect-four/internal/origins"
+	"github.com/go-chi/cors"
+)
+
+// CORS returns middleware letting the allowed origins call the API from a
+// browser. The allowed origin is reflected rather than sent as "*", which
+// browsers refuse for credentialed requests.
+func CORS(allowed *origins.Matcher) func(http.Handler) http.Handler {
+	return cors.Handler(cors.Options{
+		AllowOriginFunc: func(r *http.Request, origin string) bool {
+			return allowed.Allowed(origin)
+		},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
+		ExposedHeaders:   []string{"Link"},
+		AllowCredentials: true,
+		MaxAge:           300,
+	})
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/connect-four/internal/origins"
+	"github.com/go-chi/chi/v5"
+)
+
+// corsRouter serves the API behind CORS allowing allowed
+func corsRouter(t *testing.T, allowed ...string) http.Handler {
+	t.Helper()
+	s := newTestServer(t)
+	r := chi.NewRouter()
+	r.Use(CORS(origins.NewMatcher(allowed)))
+	r.Route("/api", s.handlers.RegisterRoutes)
+	return r
+}
+
+// corsRequest serves method on path from origin and returns the response
+func corsRequest(h http.Handler, method, path, origin string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Origin", origin)
+	if method == http.MethodOptions {
+		req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCORS(t *testing.T) {
+	router := corsRouter(t, "https://play.example.com", "https://*.example.org")
+	tests := []struct {
+		name    string
+		origin  string
+		allowed bool
+	}{
+		{"allowed origin", "https://play.example.com", true},
+		{"rejected origin", "https://evil.example.net", false},
+		{"wildcard subdomain", "https://beta.example.org", true},
+		{"wildcard parent domain", "https://example.org", false},
+		{"wrong scheme", "http://play.example.com", false},
+	}
+	for _, tt := range tests {
+		for _, method := range []string{http.MethodGet, http.MethodOptions} {
+			rec := corsRequest(router, method, "/api/leaderboard", tt.origin)
+			got := rec.Header().Get("Access-Control-Allow-Origin")
+			if tt.allowed {
+				if got != tt.origin {
+					t.Errorf("%s %s: Access-Control-Allow-Origin = %q, want the origin reflected", tt.name, method, got)
+				}
+				if rec.Header().Get("Access-Control-Allow-Credentials") != "true" {
+					t.Errorf("%s %s: credentials not allowed", tt.name, method)
+				}
+			} else if got != "" {
+				t.Errorf("%s %s: Access-Control-Allow-Origin = %q for a refused origin", tt.name, method, got)
+			}
+		}
+	}
+
+	// CORS only decides what the browser may read; the request is still served
+	if rec := corsRequest(router, http.MethodGet, "/api/leaderboard", "https://evil.example.net"); rec.Code != http.StatusOK {
+		t.Errorf("refused origin: status = %d", rec.Code)
+	}
+}
+
+func TestCORSPermissiveWhenUnset(t *testing.T) {
+	router := corsRouter(t)
+	origin := "http://localhost:5173"
+	rec := corsRequest(router, http.MethodGet, "/api/leaderboard", origin)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != origin {
+		t.Errorf("Access-Control-Allow-Origin = %q, want %q reflected rather than *", got, origin)
+	}
+}
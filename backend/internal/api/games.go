@@ -0,0 +1,338 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/connect-four/internal/game"
+	"github.com/connect-four/internal/jsonutil"
+	"github.com/connect-four/internal/matchmaker"
+	"github.com/connect-four/internal/storage"
+	"github.com/connect-four/internal/usernames"
+	"github.com/go-chi/chi/v5"
+)
+
+// CreateGameRequest is the body accepted by CreateBotGame
+type CreateGameRequest struct {
+	Username   string `json:"username"`
+	Difficulty string `json:"difficulty,omitempty"` // easy, medium (default) or hard
+}
+
+// Validate checks the create game request fields
+func (req *CreateGameRequest) Validate() error {
+	verr := &jsonutil.ValidationError{}
+	if name, err := usernames.Validate(req.Username); err != nil {
+		verr.Add("username", err.Error())
+	} else {
+		req.Username = name
+	}
+	if _, ok := game.ParseDifficulty(req.Difficulty); !ok {
+		verr.Add("difficulty", "must be easy, medium or hard")
+	}
+	return verr.ErrOrNil()
+}
+
+// MoveRequest is the body accepted by PostMove
+type MoveRequest struct {
+	Column int    `json:"column"`
+	Token  string `json:"token"`
+}
+
+// Validate checks the move request fields
+func (req *MoveRequest) Validate() error {
+	verr := &jsonutil.ValidationError{}
+	// The game checks the column against its own board
+	if req.Column < 0 || req.Column >= game.MaxBoardSide {
+		verr.Add("column", "must be between 0 and 9")
+	}
+	if req.Token == "" {
+		verr.Add("token", "is required")
+	}
+	return verr.ErrOrNil()
+}
+
+// CreateBotGame starts a game against the bot that is played over REST
+func (h *Handlers) CreateBotGame(w http.ResponseWriter, r *http.Request) {
+	if h.hub == nil {
+		http.Error(w, "Game play unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req CreateGameRequest
+	if err := jsonutil.DecodeReader(r.Body, jsonutil.DefaultMaxBodySize, &req); err != nil {
+		respondDecodeError(w, err)
+		return
+	}
+	difficulty, _ := game.ParseDifficulty(req.Difficulty)
+	req.Username = h.hub.CanonicalUsername(r.Context(), req.Username)
+
+	g, err := h.matchmaker.StartBotGame(req.Username, matchmaker.JoinOptions{BotDifficulty: difficulty})
+	if errors.Is(err, matchmaker.ErrShuttingDown) || errors.Is(err, matchmaker.ErrServerBusy) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	// The bot opens at once when it moves first
+	response := map[string]interface{}{"gameId": g.ID}
+	if state := g.GetState(); state.CurrentTurn == state.BotPlayer {
+		if col, row, ok := h.hub.PlayBotMove(g); ok {
+			response["botMove"] = game.MoveInfo{Column: col, Row: row}
+		}
+	}
+	h.hub.ScheduleTurnTimer(g)
+
+	playerNum := g.GetPlayerByUsername(req.Username)
+	state := g.GetState()
+	response["playerNum"] = playerNum
+	response["token"] = h.matchmaker.PlayerToken(g.ID, playerNum)
+	response["state"] = state
+	w.Header().Set("ETag", state.ETag())
+	respondJSONStatus(w, http.StatusCreated, response)
+}
+
+// GetGame returns the state of an active game. A matching If-None-Match
+// header gets 304 Not Modified.
+func (h *Handlers) GetGame(w http.ResponseWriter, r *http.Request) {
+	g := h.matchmaker.GetGame(chi.URLParam(r, "id"))
+	if g == nil {
+		http.Error(w, "Game not found", http.StatusNotFound)
+		return
+	}
+
+	state := g.GetState()
+	etag := state.ETag()
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	respondJSON(w, state)
+}
+
+// PostMove plays the token holder's move and, in a bot game, the bot's
+// reply before responding. The If-Match header is required: the move only
+// applies if the game is still at that version, and a stale one gets 412
+// with the current state.
+func (h *Handlers) PostMove(w http.ResponseWriter, r *http.Request) {
+	if h.hub == nil {
+		http.Error(w, "Game play unavailable", http.StatusServiceUnavailable)
+		return
+	}
+
+	g := h.matchmaker.GetGame(chi.URLParam(r, "id"))
+	if g == nil {
+		http.Error(w, "Game not found", http.StatusNotFound)
+		return
+	}
+
+	var req MoveRequest
+	if err := jsonutil.DecodeReader(r.Body, jsonutil.DefaultMaxBodySize, &req); err != nil {
+		respondDecodeError(w, err)
+		return
+	}
+
+	playerNum := 0
+	for _, seat := range []int{game.Player1, game.Player2} {
+		if h.matchmaker.ValidateToken(g.ID, seat, req.Token) == nil {
+			playerNum = seat
+			break
+		}
+	}
+	if playerNum == 0 {
+		http.Error(w, matchmaker.ErrInvalidToken.Error(), http.StatusForbidden)
+		return
+	}
+
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		http.Error(w, "If-Match header is required", http.StatusPreconditionRequired)
+		return
+	}
+	version, ok := game.ParseETag(g.ID, ifMatch)
+	if !ok {
+		http.Error(w, "Malformed If-Match header", http.StatusBadRequest)
+		return
+	}
+	row, err := g.MakeMoveIfVersion(playerNum, req.Column, version)
+	if err != nil {
+		respondMoveError(w, g, err)
+		return
+	}
+
+	response := map[string]interface{}{
+		"move": game.MoveInfo{Column: req.Column, Row: row},
+	}
+	// The players are read from a state snapshot, under the game lock
+	moved := g.GetState()
+	player := moved.Player1
+	if playerNum == game.Player2 {
+		player = moved.Player2
+	}
+	if !h.hub.FinishMove(g, player, req.Column, row) {
+		if g.BotPlayer() != 0 {
+			if col, botRow, ok := h.hub.PlayBotMove(g); ok {
+				response["botMove"] = game.MoveInfo{Column: col, Row: botRow}
+			}
+		}
+		if g.GetState().Status == game.StatusPlaying {
+			h.hub.ScheduleTurnTimer(g)
+		}
+	}
+
+	state := g.GetState()
+	response["state"] = state
+	w.Header().Set("ETag", state.ETag())
+	respondJSON(w, response)
+}
+
+// respondMoveError maps a rejected move to its HTTP status. A version
+// conflict gets the current state and its ETag, so the client can retry
+// without another request.
+func respondMoveError(w http.ResponseWriter, g *game.Game, err error) {
+	switch {
+	case errors.Is(err, game.ErrVersionConflict):
+		state := g.GetState()
+		w.Header().Set("ETag", state.ETag())
+		respondJSONStatus(w, http.StatusPreconditionFailed, state)
+	case errors.Is(err, game.ErrGameNotInProgress), errors.Is(err, game.ErrNotYourTurn):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// Paging limits for GetRecentGames
+const (
+	defaultRecentGamesLimit = 20
+	maxRecentGamesLimit     = 100
+)
+
+// GetRecentGames lists completed games, newest first. ?limit caps the page,
+// ?before and ?beforeId (the previous page's nextBefore and nextBeforeId)
+// continue from where it ended, and ?includeBots=true adds bot games.
+func (h *Handlers) GetRecentGames(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	opts := storage.RecentGamesOptions{Limit: defaultRecentGamesLimit}
+	if raw := query.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxRecentGamesLimit {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxRecentGamesLimit), http.StatusBadRequest)
+			return
+		}
+		opts.Limit = n
+	}
+	if raw := query.Get("before"); raw != "" {
+		before, err := time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			http.Error(w, "before must be an RFC 3339 timestamp", http.StatusBadRequest)
+			return
+		}
+		opts.Before = before
+		opts.BeforeID = query.Get("beforeId")
+	}
+	switch raw := query.Get("includeBots"); raw {
+	case "", "false":
+	case "true":
+		opts.IncludeBots = true
+	default:
+		http.Error(w, "includeBots must be true or false", http.StatusBadRequest)
+		return
+	}
+
+	games, err := h.store.GetRecentGames(r.Context(), opts)
+	if err != nil {
+		respondStoreError(w, err, "Failed to get recent games")
+		return
+	}
+	if games == nil {
+		games = []storage.RecentGame{}
+	}
+
+	response := map[string]interface{}{
+		"games": games,
+		"limit": opts.Limit,
+	}
+	// A full page may have more behind it
+	if len(games) == opts.Limit {
+		last := games[len(games)-1]
+		response["nextBefore"] = last.CreatedAt.UTC().Format(time.RFC3339Nano)
+		response["nextBeforeId"] = last.ID
+	}
+	respondJSON(w, response)
+}
+
+// Paging limits for GetActiveGames
+const (
+	defaultActiveGamesLimit = 50
+	maxActiveGamesLimit     = 200
+)
+
+// GetActiveGames lists the games in progress, oldest first. ?status=playing
+// or ?status=disconnected filters by status; ?limit and ?offset page the list.
+func (h *Handlers) GetActiveGames(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	status := game.GameStatus(query.Get("status"))
+	switch status {
+	case "", game.StatusPlaying, game.StatusDisconnect:
+	default:
+		http.Error(w, "status must be playing or disconnected", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultActiveGamesLimit
+	if raw := query.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxActiveGamesLimit {
+			http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxActiveGamesLimit), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	offset := 0
+	if raw := query.Get("offset"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			http.Error(w, "offset must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+
+	summaries := make([]game.GameSummary, 0)
+	for _, g := range h.matchmaker.ListGames() {
+		summary := g.Summary()
+		if status != "" && summary.Status != status {
+			continue
+		}
+		summaries = append(summaries, summary)
+	}
+	// Stable order so pages don't overlap between requests
+	sort.Slice(summaries, func(i, j int) bool {
+		if !summaries[i].StartedAt.Equal(summaries[j].StartedAt) {
+			return summaries[i].StartedAt.Before(summaries[j].StartedAt)
+		}
+		return summaries[i].ID < summaries[j].ID
+	})
+
+	// Clamp before adding so a huge offset can't overflow the page end
+	total := len(summaries)
+	start := min(offset, total)
+	page := summaries[start : start+min(limit, total-start)]
+
+	respondJSON(w, map[string]interface{}{
+		"games":  page,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
@@ -0,0 +1,238 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/connect-four/internal/game"
+	"github.com/connect-four/internal/matchmaker"
+	"github.com/connect-four/internal/storage"
+	"github.com/connect-four/internal/websocket"
+	"github.com/go-chi/chi/v5"
+)
+
+// testServer is an API router over a store, a matchmaker and a hub
+type testServer struct {
+	handlers *Handlers
+	mm       *matchmaker.Matchmaker
+	hub      *websocket.Hub
+	store    storage.Store
+	router   chi.Router
+}
+
+// newTestServer serves the API over a memory store without event analytics
+func newTestServer(t *testing.T) *testServer {
+	return newTestServerWith(t, storage.NewMemoryStore(), nil)
+}
+
+func newTestServerWith(t *testing.T, store storage.Store, analytics Analytics) *testServer {
+	t.Helper()
+	mm := matchmaker.NewMatchmaker()
+	hub := websocket.NewHub(mm)
+	h := NewHandlers(store, mm, nil, analytics)
+	h.SetHub(hub)
+	h.SetCacheTTL(0)
+	router := chi.NewRouter()
+	router.Route("/api", h.RegisterRoutes)
+	return &testServer{handlers: h, mm: mm, hub: hub, store: store, router: router}
+}
+
+// do serves a request and returns the recorded response
+func (s *testServer) do(req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func (s *testServer) get(path string) *httptest.ResponseRecorder {
+	return s.do(httptest.NewRequest(http.MethodGet, path, nil))
+}
+
+// post serves a JSON POST with extra headers as name/value pairs
+func (s *testServer) post(path, body string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	return s.do(req)
+}
+
+// humanGame starts a game between two human players through the queue
+func (s *testServer) humanGame(t *testing.T, a, b string) *game.Game {
+	t.Helper()
+	if _, err := s.mm.JoinQueue(a, matchmaker.JoinOptions{NoBotFallback: true}); err != nil {
+		t.Fatal(err)
+	}
+	ch, err := s.mm.JoinQueue(b, matchmaker.JoinOptions{NoBotFallback: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return <-ch
+}
+
+func decodeJSON(t *testing.T, rec *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+		t.Fatalf("decoding %q: %v", rec.Body.String(), err)
+	}
+}
+
+func TestGetActiveGamesPaging(t *testing.T) {
+	s := newTestServer(t)
+	for _, name := range []string{"alice", "bob", "carol"} {
+		if _, err := s.mm.StartBotGame(name, matchmaker.JoinOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		games int
+	}{
+		{"", 3},
+		{"?limit=2", 2},
+		{"?limit=2&offset=2", 1},
+		{"?offset=3", 0},
+		{"?offset=10", 0},
+		{"?offset=9223372036854775807", 0},
+		{"?limit=200&offset=9223372036854775807", 0},
+	}
+	for _, tt := range tests {
+		rec := s.get("/api/games/active" + tt.query)
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: status %d, body %q", tt.query, rec.Code, rec.Body.String())
+			continue
+		}
+		var body struct {
+			Games []json.RawMessage `json:"games"`
+			Total int               `json:"total"`
+		}
+		decodeJSON(t, rec, &body)
+		if len(body.Games) != tt.games || body.Total != 3 {
+			t.Errorf("%s: got %d games of %d, want %d of 3", tt.query, len(body.Games), body.Total, tt.games)
+		}
+	}
+}
+
+func TestPostMoveRequiresIfMatch(t *testing.T) {
+	s := newTestServer(t)
+	g := s.humanGame(t, "alice", "bob")
+	turn := g.GetState().CurrentTurn
+	body := `{"column": 3, "token": "` + s.mm.PlayerToken(g.ID, turn) + `"}`
+
+	rec := s.post("/api/games/"+g.ID+"/moves", body)
+	if rec.Code != http.StatusPreconditionRequired {
+		t.Fatalf("without If-Match: status %d, want 428", rec.Code)
+	}
+	if moves := g.GetState().MoveCount; moves != 0 {
+		t.Fatalf("move applied without If-Match: %d moves", moves)
+	}
+
+	rec = s.post("/api/games/"+g.ID+"/moves", body, "If-Match", `"nonsense"`)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("malformed If-Match: status %d, want 400", rec.Code)
+	}
+
+	state := g.GetState()
+	rec = s.post("/api/games/"+g.ID+"/moves", body, "If-Match", state.ETag())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("current If-Match: status %d, body %q", rec.Code, rec.Body.String())
+	}
+}
+
+// A WebSocket move bumps the version under an in-flight REST move, which
+// must then fail with 412 and the fresh state rather than apply
+func TestPostMoveStaleETagAfterWebSocketMove(t *testing.T) {
+	s := newTestServer(t)
+	g := s.humanGame(t, "alice", "bob")
+	state := g.GetState()
+	stale := state.ETag()
+	mover := state.CurrentTurn
+	other := game.Player1 + game.Player2 - mover
+
+	// The WebSocket handler applies its moves straight to the game
+	if _, err := g.MakeMove(mover, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	body := `{"column": 1, "token": "` + s.mm.PlayerToken(g.ID, other) + `"}`
+	rec := s.post("/api/games/"+g.ID+"/moves", body, "If-Match", stale)
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("stale If-Match: status %d, want 412", rec.Code)
+	}
+	fresh := g.GetState()
+	if got := rec.Header().Get("ETag"); got != fresh.ETag() {
+		t.Errorf("ETag %q, want the current %q", got, fresh.ETag())
+	}
+	var body412 game.GameState
+	decodeJSON(t, rec, &body412)
+	if body412.StateVersion != fresh.StateVersion || body412.MoveCount != 1 {
+		t.Errorf("412 body at version %d with %d moves, want version %d with 1",
+			body412.StateVersion, body412.MoveCount, fresh.StateVersion)
+	}
+	if fresh.MoveCount != 1 {
+		t.Errorf("stale move applied: %d moves", fresh.MoveCount)
+	}
+
+	// Retrying with the returned ETag succeeds
+	rec = s.post("/api/games/"+g.ID+"/moves", body, "If-Match", fresh.ETag())
+	if rec.Code != http.StatusOK {
+		t.Fatalf("retry: status %d, body %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestGetRecentGamesPagesThroughTies(t *testing.T) {
+	s := newTestServer(t)
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	saved := make(map[string]bool)
+	for i := 0; i < 5; i++ {
+		moves, err := game.FromNotation("1212121")
+		if err != nil {
+			t.Fatal(err)
+		}
+		g, err := game.NewImportedGame("alice", "bob", moves)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g.StartTime = created
+		if err := s.store.SaveGame(context.Background(), g); err != nil {
+			t.Fatal(err)
+		}
+		saved[g.ID] = true
+	}
+
+	var page struct {
+		Games        []storage.RecentGame `json:"games"`
+		NextBefore   string               `json:"nextBefore"`
+		NextBeforeID string               `json:"nextBeforeId"`
+	}
+	path := "/api/games/recent?limit=2"
+	for i := 0; i < 5 && path != ""; i++ {
+		rec := s.get(path)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, body %s", path, rec.Code, rec.Body)
+		}
+		page.NextBefore, page.NextBeforeID = "", ""
+		decodeJSON(t, rec, &page)
+		for _, g := range page.Games {
+			if !saved[g.ID] {
+				t.Errorf("game %s listed twice", g.ID)
+			}
+			delete(saved, g.ID)
+		}
+		path = ""
+		if page.NextBefore != "" {
+			path = "/api/games/recent?limit=2&before=" + url.QueryEscape(page.NextBefore) + "&beforeId=" + url.QueryEscape(page.NextBeforeID)
+		}
+	}
+	if len(saved) != 0 {
+		t.Errorf("%d games sharing a creation time were skipped between pages", len(saved))
+	}
+}
@@ -3,65 +3,247 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
 
+	"github.com/connect-four/internal/auth"
+	"github.com/connect-four/internal/game"
+	"github.com/connect-four/internal/jsonutil"
 	"github.com/connect-four/internal/kafka"
+	"github.com/connect-four/internal/loadhistory"
 	"github.com/connect-four/internal/matchmaker"
+	"github.com/connect-four/internal/ratelimit"
+	"github.com/connect-four/internal/safego"
 	"github.com/connect-four/internal/storage"
+	"github.com/connect-four/internal/usernames"
+	"github.com/connect-four/internal/websocket"
 	"github.com/go-chi/chi/v5"
 )
 
+// Analytics serves the metrics aggregated from game events, either by
+// the Kafka consumer or in process by kafka.LocalAnalytics
+type Analytics interface {
+	GetMetrics() *kafka.AnalyticsMetrics
+	GetAverageGameDuration() float64
+	GetMostFrequentWinner() string
+	GetGamesPerHour() map[string]int
+	GetConnectionStats() kafka.ConnectionStats
+	GetMoveStats() kafka.MoveStats
+	GetReplayProgress() kafka.ReplayProgress
+}
+
 // Handlers holds API handler dependencies
 type Handlers struct {
-	store      *storage.PostgresStore
+	store      storage.Store
 	matchmaker *matchmaker.Matchmaker
-	producer   *kafka.Producer
-	consumer   *kafka.Consumer
+	producer   *kafka.Producer // nil unless events go to Kafka
+	analytics  Analytics       // nil when events are discarded
+	history    *loadhistory.Recorder
+	hub        *websocket.Hub
+	saves      *storage.SaveQueue
+	storeState func() storage.StoreState // nil for the in-memory store
+	limiter    *ratelimit.Limiter
+	cache      *resultCache // leaderboard and analytics query results
+	auth       *auth.Signer // nil when auth is off
+	admin      AdminOptions
+	requests   atomic.Int64
+	started    time.Time
 }
 
-// NewHandlers creates a new API handlers instance
-func NewHandlers(store *storage.PostgresStore, mm *matchmaker.Matchmaker, producer *kafka.Producer, consumer *kafka.Consumer) *Handlers {
+// NewHandlers creates a new API handlers instance; producer and analytics
+// may be nil
+func NewHandlers(store storage.Store, mm *matchmaker.Matchmaker, producer *kafka.Producer, analytics Analytics) *Handlers {
 	return &Handlers{
 		store:      store,
 		matchmaker: mm,
 		producer:   producer,
-		consumer:   consumer,
+		analytics:  analytics,
+		cache:      newResultCache(DefaultCacheTTL),
+		started:    time.Now(),
+	}
+}
+
+// SetCacheTTL sets how long leaderboard and analytics results are reused;
+// zero disables caching
+func (h *Handlers) SetCacheTTL(ttl time.Duration) {
+	h.cache.setTTL(ttl)
+}
+
+// SetLoadHistory sets the recorder backing the status history endpoint
+func (h *Handlers) SetLoadHistory(history *loadhistory.Recorder) {
+	h.history = history
+}
+
+// SetSaveQueue sets the game save retry queue reported by /api/status
+func (h *Handlers) SetSaveQueue(saves *storage.SaveQueue) {
+	h.saves = saves
+}
+
+// SetStoreState sets how /api/status learns whether the database is up
+func (h *Handlers) SetStoreState(state func() storage.StoreState) {
+	h.storeState = state
+}
+
+// RequestCount returns the total number of API requests served
+func (h *Handlers) RequestCount() int64 {
+	return h.requests.Load()
+}
+
+// countRequests is middleware counting every API request
+func (h *Handlers) countRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.requests.Add(1)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// SetRateLimiter sets the per-client-IP limiter applied to every API request
+func (h *Handlers) SetRateLimiter(limiter *ratelimit.Limiter) {
+	h.limiter = limiter
+}
+
+// limitRequests is middleware rejecting clients over the rate limit with
+// 429. The client IP is the request's RemoteAddr, which middleware.RealIP
+// has already replaced with X-Forwarded-For or X-Real-IP when present.
+func (h *Handlers) limitRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.limiter != nil && !h.limiter.Allow(clientIP(r)) {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientIP returns the host part of the request's remote address
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
 	}
+	return r.RemoteAddr
 }
 
 // RegisterRoutes registers API routes
 func (h *Handlers) RegisterRoutes(r chi.Router) {
+	r.Use(h.countRequests)
+	r.Use(h.limitRequests)
+
+	r.Post("/auth/guest", h.IssueGuestToken)
 	r.Get("/leaderboard", h.GetLeaderboard)
-	r.Delete("/leaderboard", h.ClearLeaderboard)
 	r.Get("/stats/{username}", h.GetPlayerStats)
+	r.Get("/stats/{username}/vs/{opponent}", h.GetHeadToHead)
 	r.Get("/analytics", h.GetAnalytics)
+	r.Get("/analytics/bots", h.GetBotAnalytics)
+	r.Get("/analytics/hourly", h.GetHourlyAnalytics)
 	r.Get("/status", h.GetStatus)
+	r.Get("/status/history", h.GetStatusHistory)
+	r.Post("/analyze", h.AnalyzePosition)
+	r.Get("/games/{id}/replay", h.GetGameReplay)
+	r.Post("/games", h.CreateBotGame)
+	r.Get("/games/active", h.GetActiveGames)
+	r.Get("/games/recent", h.GetRecentGames)
+	r.Get("/games/{id}", h.GetGame)
+	r.Post("/games/{id}/moves", h.PostMove)
+
+	r.Group(func(r chi.Router) {
+		r.Use(h.requireAdmin)
+		r.Get("/debug/dump", h.GetDebugDump)
+		r.Post("/games/import", h.ImportGame)
+		r.Get("/admin/consistency", h.GetConsistency)
+		r.Delete("/admin/leaderboard", h.ClearLeaderboard)
+		r.Get("/admin/games", h.GetActiveGames)
+		r.Post("/admin/games/{id}/end", h.ForceEndGame)
+	})
 }
 
-// GetLeaderboard returns the top players
+// GetLeaderboard returns the top players. Games against the bot only
+// count with ?includeBots=true.
 func (h *Handlers) GetLeaderboard(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	
-	entries, err := h.store.GetLeaderboard(ctx, 20)
+
+	opts := storage.LeaderboardOptions{Limit: 20, Sort: storage.SortByWins}
+	switch sort := r.URL.Query().Get("sort"); sort {
+	case "", string(storage.SortByWins):
+	case string(storage.SortByRating):
+		opts.Sort = storage.SortByRating
+	default:
+		http.Error(w, "sort must be wins or rating", http.StatusBadRequest)
+		return
+	}
+
+	period := storage.LeaderboardPeriod(r.URL.Query().Get("period"))
+	if period == "" {
+		period = storage.PeriodAll
+	}
+	since, ok := period.WindowStart(time.Now())
+	if !ok {
+		http.Error(w, "period must be day, week, month or all", http.StatusBadRequest)
+		return
+	}
+	opts.Since = since
+
+	switch raw := r.URL.Query().Get("includeBots"); raw {
+	case "", "false":
+	case "true":
+		opts.IncludeBots = true
+	default:
+		http.Error(w, "includeBots must be true or false", http.StatusBadRequest)
+		return
+	}
+
+	key := fmt.Sprintf("leaderboard:%s:%s:%t", opts.Sort, period, opts.IncludeBots)
+	if cached, ok := h.cache.get(key); ok {
+		h.respondCacheable(w, r, cached)
+		return
+	}
+
+	entries, err := h.store.GetLeaderboard(ctx, opts)
 	if err != nil {
-		http.Error(w, "Failed to get leaderboard", http.StatusInternalServerError)
+		respondStoreError(w, err, "Failed to get leaderboard")
 		return
 	}
+	if entries == nil {
+		entries = []storage.LeaderboardEntry{}
+	}
 
-	respondJSON(w, entries)
+	response := map[string]interface{}{
+		"period":  period,
+		"entries": entries,
+	}
+	if !since.IsZero() {
+		response["windowStart"] = since.UTC()
+	}
+	h.cache.set(key, response)
+	h.respondCacheable(w, r, response)
 }
 
-// ClearLeaderboard deletes all games and resets the leaderboard
+// ClearLeaderboard deletes all games and resets the leaderboard.
+// It refuses to run while games are in progress unless ?force=true is passed.
 func (h *Handlers) ClearLeaderboard(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	
-	err := h.store.ClearAllGames(ctx)
+
+	if active := h.matchmaker.GetActiveGameCount(); active > 0 && r.URL.Query().Get("force") != "true" {
+		http.Error(w, "Games are in progress; pass force=true to clear anyway", http.StatusConflict)
+		return
+	}
+
+	deleted, err := h.store.ClearAllGames(ctx)
 	if err != nil {
-		http.Error(w, "Failed to clear leaderboard", http.StatusInternalServerError)
+		respondStoreError(w, err, "Failed to clear leaderboard")
 		return
 	}
+	h.cache.clear()
 
-	respondJSON(w, map[string]string{"message": "Leaderboard cleared successfully"})
+	respondJSON(w, map[string]interface{}{
+		"message":      "Leaderboard cleared successfully",
+		"deletedGames": deleted,
+	})
 }
 
 // GetPlayerStats returns statistics for a specific player
@@ -73,24 +255,72 @@ func (h *Handlers) GetPlayerStats(w http.ResponseWriter, r *http.Request) {
 	}
 
 	ctx := r.Context()
+	username, err := h.store.ResolveUsername(ctx, username)
+	if err != nil {
+		respondPlayerLookupError(w, err)
+		return
+	}
+
 	stats, err := h.store.GetPlayerStats(ctx, username)
 	if err != nil {
-		http.Error(w, "Failed to get player stats", http.StatusInternalServerError)
+		respondStoreError(w, err, "Failed to get player stats")
 		return
 	}
 
 	respondJSON(w, stats)
 }
 
-// GetAnalytics returns game analytics
+// GetHeadToHead returns the record between two players
+func (h *Handlers) GetHeadToHead(w http.ResponseWriter, r *http.Request) {
+	player := chi.URLParam(r, "username")
+	opponent := chi.URLParam(r, "opponent")
+	if player == "" || opponent == "" {
+		http.Error(w, "Both usernames required", http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	player, err := h.store.ResolveUsername(ctx, player)
+	if err != nil {
+		respondPlayerLookupError(w, err)
+		return
+	}
+	opponent, err = h.store.ResolveUsername(ctx, opponent)
+	if err != nil {
+		respondPlayerLookupError(w, err)
+		return
+	}
+	if player == opponent {
+		http.Error(w, "A player has no head-to-head record with themselves", http.StatusBadRequest)
+		return
+	}
+
+	h2h, err := h.store.GetHeadToHead(ctx, player, opponent)
+	if err != nil {
+		respondStoreError(w, err, "Failed to get head-to-head stats")
+		return
+	}
+
+	respondJSON(w, h2h)
+}
+
+// GetAnalytics returns game analytics. The database part is cached; the
+// realtime and event metrics are always current.
 func (h *Handlers) GetAnalytics(w http.ResponseWriter, r *http.Request) {
 	ctx := context.Background()
-	
+
 	// Get DB analytics
-	dbAnalytics, err := h.store.GetAnalytics(ctx)
-	if err != nil {
-		http.Error(w, "Failed to get analytics", http.StatusInternalServerError)
-		return
+	var dbAnalytics *storage.GameAnalytics
+	if cached, ok := h.cache.get("analytics"); ok {
+		dbAnalytics = cached.(*storage.GameAnalytics)
+	} else {
+		var err error
+		dbAnalytics, err = h.store.GetAnalytics(ctx)
+		if err != nil {
+			respondStoreError(w, err, "Failed to get analytics")
+			return
+		}
+		h.cache.set("analytics", dbAnalytics)
 	}
 
 	response := map[string]interface{}{
@@ -98,33 +328,425 @@ func (h *Handlers) GetAnalytics(w http.ResponseWriter, r *http.Request) {
 		"realtime": map[string]interface{}{
 			"activeGames":    h.matchmaker.GetActiveGameCount(),
 			"playersWaiting": h.matchmaker.GetWaitingCount(),
-			"kafkaEnabled":   h.producer.IsEnabled(),
+			"kafkaEnabled":   h.producer != nil && h.producer.IsEnabled(),
 		},
 	}
 
-	// Add Kafka metrics if available
-	if h.consumer != nil {
+	// Add event metrics if available; the key predates local analytics
+	if h.analytics != nil {
+		progress := h.analytics.GetReplayProgress()
+		status := "current"
+		if !progress.CaughtUp {
+			status = "warming"
+		}
 		response["kafka"] = map[string]interface{}{
-			"avgGameDuration":    h.consumer.GetAverageGameDuration(),
-			"mostFrequentWinner": h.consumer.GetMostFrequentWinner(),
-			"gamesPerHour":       h.consumer.GetGamesPerHour(),
-			"metrics":            h.consumer.GetMetrics(),
+			"status":             status,
+			"eventsSkipped":      progress.EventsSkipped,
+			"deadLettered":       progress.DeadLettered,
+			"avgGameDuration":    h.analytics.GetAverageGameDuration(),
+			"mostFrequentWinner": h.analytics.GetMostFrequentWinner(),
+			"gamesPerHour":       h.analytics.GetGamesPerHour(),
+			"connections":        h.analytics.GetConnectionStats(),
+			"moves":              h.analytics.GetMoveStats(),
+			"metrics":            h.analytics.GetMetrics(),
 		}
 	}
 
+	h.respondCacheable(w, r, response)
+}
+
+// ReplayStep is one move of a replay with the board after it
+type ReplayStep struct {
+	game.Move
+	Board [][]int `json:"board"`
+}
+
+// GetGameReplay returns a finished game's moves with the board after each one
+func (h *Handlers) GetGameReplay(w http.ResponseWriter, r *http.Request) {
+	cg, err := h.store.GetGameByID(r.Context(), chi.URLParam(r, "id"))
+	if errors.Is(err, storage.ErrGameNotFound) {
+		http.Error(w, "Game not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		respondStoreError(w, err, "Failed to load game")
+		return
+	}
+
+	snapshots, err := game.ReplayMoves(cg.BoardSize, cg.MoveList)
+	if err != nil {
+		http.Error(w, "Stored move sequence is corrupt: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	steps := make([]ReplayStep, len(cg.MoveList))
+	for i, m := range cg.MoveList {
+		steps[i] = ReplayStep{Move: m, Board: snapshots[i]}
+	}
+
+	response := map[string]interface{}{
+		"id":        cg.ID,
+		"player1":   cg.Player1,
+		"player2":   cg.Player2,
+		"winner":    cg.Winner,
+		"result":    cg.Result,
+		"isDraw":    cg.IsDraw,
+		"isForfeit": cg.IsForfeit,
+		"createdAt": cg.CreatedAt,
+		"endedAt":   cg.EndedAt,
+		"rows":      cg.Rows,
+		"columns":   cg.Columns,
+		"winLength": cg.WinLength,
+		"moves":     steps,
+	}
+	// Notation only describes games on the standard board with Player1
+	// moving first
+	if cg.IsStandard() && (len(cg.MoveList) == 0 || cg.MoveList[0].PlayerNum == game.Player1) {
+		response["notation"] = game.ToNotation(cg.MoveList)
+	}
 	respondJSON(w, response)
 }
 
+// ImportGameRequest is the body accepted by ImportGame
+type ImportGameRequest struct {
+	Player1  string `json:"player1"`
+	Player2  string `json:"player2"`
+	Notation string `json:"notation"`
+}
+
+// Validate checks the import request fields
+func (req *ImportGameRequest) Validate() error {
+	verr := &jsonutil.ValidationError{}
+	player1, err1 := usernames.Validate(req.Player1)
+	if err1 != nil {
+		verr.Add("player1", err1.Error())
+	}
+	player2, err2 := usernames.Validate(req.Player2)
+	if err2 != nil {
+		verr.Add("player2", err2.Error())
+	}
+	if err1 == nil && err2 == nil {
+		if usernames.Fold(player1) == usernames.Fold(player2) {
+			verr.Add("player2", "must differ from player1")
+		}
+		req.Player1, req.Player2 = player1, player2
+	}
+	if req.Notation == "" {
+		verr.Add("notation", "is required")
+	}
+	return verr.ErrOrNil()
+}
+
+// ImportGame stores a finished game played outside the server from its notation
+func (h *Handlers) ImportGame(w http.ResponseWriter, r *http.Request) {
+	var req ImportGameRequest
+	if err := jsonutil.DecodeReader(r.Body, jsonutil.DefaultMaxBodySize, &req); err != nil {
+		respondDecodeError(w, err)
+		return
+	}
+
+	moves, err := game.FromNotation(req.Notation)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	g, err := game.NewImportedGame(req.Player1, req.Player2, moves)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if g.GetState().Status != game.StatusFinished {
+		http.Error(w, "Only finished games can be imported", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.store.SaveGame(r.Context(), g); err != nil {
+		respondStoreError(w, err, "Failed to import game")
+		return
+	}
+
+	respondJSON(w, map[string]interface{}{
+		"id":       g.ID,
+		"notation": game.ToNotation(g.Moves),
+		"imported": true,
+	})
+}
+
+// defaultAnalysisDepth is the search depth used when a request doesn't set one
+const defaultAnalysisDepth = 7
+
+// AnalyzeRequest is the body accepted by AnalyzePosition. The position is
+// either a board or the notation of the moves leading to it; with notation
+// the player to move follows from the moves and may be left out.
+type AnalyzeRequest struct {
+	Board    [][]int `json:"board,omitempty"`
+	Notation string  `json:"notation,omitempty"`
+	Player   int     `json:"player,omitempty"` // player to move, 1 or 2
+	Depth    int     `json:"depth,omitempty"`  // search depth, defaults to 7
+}
+
+// Validate checks the analyze request fields; the position itself is
+// checked when the board is built
+func (req *AnalyzeRequest) Validate() error {
+	verr := &jsonutil.ValidationError{}
+	switch {
+	case req.Board == nil && req.Notation == "":
+		verr.Add("board", "board or notation is required")
+	case req.Board != nil && req.Notation != "":
+		verr.Add("notation", "can't be combined with board")
+	}
+	playerOptional := req.Notation != "" && req.Player == 0
+	if !playerOptional && req.Player != game.Player1 && req.Player != game.Player2 {
+		verr.Add("player", "must be 1 or 2")
+	}
+	if req.Depth < 0 || req.Depth > game.MaxSearchDepth {
+		verr.Add("depth", fmt.Sprintf("must be between 1 and %d", game.MaxSearchDepth))
+	}
+	return verr.ErrOrNil()
+}
+
+// AnalyzePosition evaluates an arbitrary legal position for the player to
+// move. Scores are from that player's perspective.
+func (h *Handlers) AnalyzePosition(w http.ResponseWriter, r *http.Request) {
+	var req AnalyzeRequest
+	if err := jsonutil.DecodeReader(r.Body, jsonutil.DefaultMaxBodySize, &req); err != nil {
+		respondDecodeError(w, err)
+		return
+	}
+
+	board, err := req.position()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Player == 0 {
+		req.Player = board.NextPlayer()
+	}
+	if next := board.NextPlayer(); next != req.Player {
+		http.Error(w, fmt.Sprintf("disc counts mean player %d is to move", next), http.StatusBadRequest)
+		return
+	}
+	if board.CheckWin(game.Player1) || board.CheckWin(game.Player2) || board.IsFull() {
+		http.Error(w, "Game is already over in this position", http.StatusBadRequest)
+		return
+	}
+
+	depth := req.Depth
+	if depth == 0 {
+		depth = defaultAnalysisDepth
+	}
+	columns := game.NewBotWithDepth(req.Player, depth).ScoreColumns(board)
+
+	// Ties go to the column nearest the centre, as the bot plays
+	best := columns[0]
+	for _, c := range columns[1:] {
+		if c.Score > best.Score || (c.Score == best.Score && centreDistance(c.Column) < centreDistance(best.Column)) {
+			best = c
+		}
+	}
+
+	respondJSON(w, map[string]interface{}{
+		"player":     req.Player,
+		"depth":      depth,
+		"bestColumn": best.Column,
+		"score":      best.Score,
+		"columns":    columns,
+		"forcedWin":  game.IsForcedWin(best.Score),
+		"forcedLoss": game.IsForcedLoss(best.Score),
+	})
+}
+
+// position builds the board to analyze from the request's board or notation
+func (req *AnalyzeRequest) position() (*game.Board, error) {
+	if req.Notation == "" {
+		return game.NewBoardFromSlice(req.Board)
+	}
+	moves, err := game.FromNotation(req.Notation)
+	if err != nil {
+		return nil, err
+	}
+	board := game.NewBoard()
+	for _, m := range moves {
+		if _, err := board.DropDisc(m.Column, m.PlayerNum); err != nil {
+			return nil, err
+		}
+	}
+	return board, nil
+}
+
+// centreDistance is how many columns col is from the middle of the board
+func centreDistance(col int) int {
+	if d := col - game.Columns/2; d > 0 {
+		return d
+	}
+	return game.Columns/2 - col
+}
+
+// GetBotAnalytics returns bot game win rates segmented by bot engine version
+func (h *Handlers) GetBotAnalytics(w http.ResponseWriter, r *http.Request) {
+	versions, err := h.store.GetBotVersionStats(r.Context())
+	if err != nil {
+		respondStoreError(w, err, "Failed to get bot analytics")
+		return
+	}
+
+	respondJSON(w, map[string]interface{}{
+		"currentVersion": game.BotVersion,
+		"versions":       versions,
+	})
+}
+
+// maxHourlyAnalyticsHours caps ?hours on the hourly analytics endpoint
+const maxHourlyAnalyticsHours = 30 * 24
+
+// GetHourlyAnalytics returns games played per hour for the last ?hours
+// (default 48, max 720), the current partial hour included
+func (h *Handlers) GetHourlyAnalytics(w http.ResponseWriter, r *http.Request) {
+	hours := 48
+	if v := r.URL.Query().Get("hours"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "hours must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		hours = min(n, maxHourlyAnalyticsHours)
+	}
+
+	now := time.Now()
+	since := now.Add(-time.Duration(hours-1) * time.Hour)
+	buckets, err := h.store.GetHourlyAnalytics(r.Context(), since, now)
+	if err != nil {
+		respondStoreError(w, err, "Failed to get hourly analytics")
+		return
+	}
+
+	respondJSON(w, map[string]interface{}{
+		"hours":   hours,
+		"buckets": buckets,
+	})
+}
+
 // GetStatus returns server status
 func (h *Handlers) GetStatus(w http.ResponseWriter, r *http.Request) {
-	respondJSON(w, map[string]interface{}{
+	status := map[string]interface{}{
 		"status":         "ok",
+		"version":        buildVersion(),
+		"uptimeSeconds":  int(time.Since(h.started).Seconds()),
+		"storage":        h.store.Backend(),
 		"activeGames":    h.matchmaker.GetActiveGameCount(),
 		"playersWaiting": h.matchmaker.GetWaitingCount(),
-		"kafkaEnabled":   h.producer.IsEnabled(),
+		"reapedGames":    h.matchmaker.ReapedCount(),
+		"kafkaEnabled":   h.producer != nil && h.producer.IsEnabled(),
+		"panics":         safego.PanicCount(),
+	}
+	wait, matches := h.matchmaker.AverageMatchWait()
+	status["matchmaking"] = map[string]interface{}{
+		"averageWaitSeconds": wait.Seconds(),
+		"recentMatches":      matches,
+	}
+	status["capacity"] = h.matchmaker.Capacity()
+	if h.producer != nil {
+		status["kafkaProducer"] = h.producer.Stats()
+	}
+	if h.analytics != nil {
+		status["kafkaConsumer"] = h.analytics.GetReplayProgress()
+	}
+	if h.saves != nil {
+		status["saveQueue"] = h.saves.Stats()
+	}
+	if h.storeState != nil {
+		status["storageState"] = h.storeState()
+	}
+	if h.hub != nil {
+		status["connections"] = map[string]int{
+			"total": h.hub.ClientCount(),
+			"alive": h.hub.AliveClientCount(),
+		}
+		status["hub"] = h.hub.Stats()
+	}
+	respondJSON(w, status)
+}
+
+// GetStatusHistory returns the recorded load samples for the last ?hours (default 6, max 48)
+func (h *Handlers) GetStatusHistory(w http.ResponseWriter, r *http.Request) {
+	if h.history == nil {
+		http.Error(w, "Load history not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	hours := 6
+	if v := r.URL.Query().Get("hours"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "hours must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		hours = n
+	}
+	if maxHours := int(loadhistory.Retention / time.Hour); hours > maxHours {
+		hours = maxHours
+	}
+
+	since := time.Now().Add(-time.Duration(hours) * time.Hour)
+	respondJSON(w, map[string]interface{}{
+		"hours":           hours,
+		"intervalSeconds": int(loadhistory.SampleInterval.Seconds()),
+		"samples":         h.history.Since(since),
 	})
 }
 
+// respondPlayerLookupError writes the response for a failed ResolveUsername
+func respondPlayerLookupError(w http.ResponseWriter, err error) {
+	var near *storage.NearCollisionError
+	switch {
+	case errors.As(err, &near):
+		respondJSONStatus(w, http.StatusNotFound, map[string]string{
+			"error":      "player not found",
+			"didYouMean": near.Suggestion,
+		})
+	case errors.Is(err, storage.ErrUnknownPlayer):
+		respondJSONStatus(w, http.StatusNotFound, map[string]string{"error": "player not found"})
+	default:
+		respondStoreError(w, err, "Failed to look up player")
+	}
+}
+
+// respondStoreError writes the response for a failed store call: 503 with
+// a JSON error while the database is unavailable, otherwise a 500 with
+// message
+func respondStoreError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, storage.ErrStoreUnavailable) {
+		w.Header().Set("Retry-After", "5")
+		respondJSONStatus(w, http.StatusServiceUnavailable, map[string]string{"error": "database unavailable"})
+		return
+	}
+	http.Error(w, message, http.StatusInternalServerError)
+}
+
+// respondDecodeError writes the response for a failed strict decode
+func respondDecodeError(w http.ResponseWriter, err error) {
+	var verr *jsonutil.ValidationError
+	switch {
+	case errors.As(err, &verr):
+		respondJSONStatus(w, http.StatusBadRequest, map[string]interface{}{
+			"error":  "validation failed",
+			"fields": verr.Fields,
+		})
+	case errors.Is(err, jsonutil.ErrBodyTooLarge):
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+	default:
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	}
+}
+
+// respondJSONStatus writes a JSON response with the given status code
+func respondJSONStatus(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
 // respondJSON writes a JSON response
 func respondJSON(w http.ResponseWriter, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
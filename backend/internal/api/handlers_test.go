@@ -0,0 +1,358 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/connect-four/internal/game"
+	"github.com/connect-four/internal/kafka"
+	"github.com/connect-four/internal/matchmaker"
+	"github.com/connect-four/internal/storage"
+	"github.com/connect-four/internal/websocket"
+)
+
+func TestImportGameRequiresAdmin(t *testing.T) {
+	s := newTestServer(t)
+	s.handlers.SetAdminOptions(AdminOptions{APIKey: "secret"})
+	body := `{"player1": "alice", "player2": "bob", "notation": "1212121 1-0"}`
+
+	if rec := s.post("/api/games/import", body); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("without a key: status = %d, want 401", rec.Code)
+	}
+	if rec := s.post("/api/games/import", body, "Authorization", "Bearer wrong"); rec.Code != http.StatusForbidden {
+		t.Fatalf("with a wrong key: status = %d, want 403", rec.Code)
+	}
+	rec := s.post("/api/games/import", body, "Authorization", "Bearer secret")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("with the key: status = %d, body %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		ID       string `json:"id"`
+		Notation string `json:"notation"`
+	}
+	decodeJSON(t, rec, &resp)
+	if resp.Notation != "1212121 1-0" {
+		t.Errorf("notation = %q, want %q", resp.Notation, "1212121 1-0")
+	}
+}
+
+func TestAnalyzeNotation(t *testing.T) {
+	s := newTestServer(t)
+
+	// Player 1 has three in column 1 and wins by playing it again
+	rec := s.post("/api/analyze", `{"notation": "121212", "depth": 3}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		Player     int  `json:"player"`
+		BestColumn int  `json:"bestColumn"`
+		ForcedWin  bool `json:"forcedWin"`
+	}
+	decodeJSON(t, rec, &resp)
+	if resp.Player != 1 || resp.BestColumn != 0 || !resp.ForcedWin {
+		t.Errorf("analysis = %+v, want player 1 winning in column 0", resp)
+	}
+}
+
+func TestAnalyzeRejects(t *testing.T) {
+	s := newTestServer(t)
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"no position", `{"player": 1}`},
+		{"board and notation", `{"board": [[0]], "notation": "4", "player": 2}`},
+		{"malformed notation", `{"notation": "48"}`},
+		{"wrong player for notation", `{"notation": "4", "player": 1}`},
+		{"finished notation", `{"notation": "1212121"}`},
+		{"board without player", `{"board": [[0,0,0,0,0,0,0],[0,0,0,0,0,0,0],[0,0,0,0,0,0,0],[0,0,0,0,0,0,0],[0,0,0,0,0,0,0],[0,0,0,0,0,0,0]]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := s.post("/api/analyze", tt.body); rec.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400; body %s", rec.Code, rec.Body)
+			}
+		})
+	}
+}
+
+// saveGame stores a finished game between a and b, a winning
+func (s *testServer) saveGame(t *testing.T, a, b string) {
+	t.Helper()
+	moves, err := game.FromNotation("1212121")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := game.NewImportedGame(a, b, moves)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.store.SaveGame(context.Background(), g); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetHeadToHeadResolvesUsernames(t *testing.T) {
+	s := newTestServer(t)
+	s.saveGame(t, "alice", "bob")
+
+	if rec := s.get("/api/stats/alice/vs/bob"); rec.Code != http.StatusOK {
+		t.Fatalf("known players: status = %d, body %s", rec.Code, rec.Body)
+	}
+
+	tests := []struct {
+		path       string
+		didYouMean string
+	}{
+		{"/api/stats/alice/vs/nobody", ""},
+		{"/api/stats/nobody/vs/bob", ""},
+		{"/api/stats/alice/vs/BOB", "bob"},
+		{"/api/stats/Alice/vs/bob", "alice"},
+	}
+	for _, tt := range tests {
+		rec := s.get(tt.path)
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: status = %d, want 404", tt.path, rec.Code)
+			continue
+		}
+		var resp map[string]string
+		decodeJSON(t, rec, &resp)
+		if resp["didYouMean"] != tt.didYouMean {
+			t.Errorf("%s: didYouMean = %q, want %q", tt.path, resp["didYouMean"], tt.didYouMean)
+		}
+	}
+}
+
+func TestGetPlayerStatsResolvesUsernames(t *testing.T) {
+	s := newTestServer(t)
+	s.saveGame(t, "alice", "bob")
+
+	rec := s.get("/api/stats/alice")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("known player: status = %d, body %s", rec.Code, rec.Body)
+	}
+	var stats storage.PlayerStats
+	decodeJSON(t, rec, &stats)
+	if stats.Username != "alice" || stats.TotalGames != 1 {
+		t.Errorf("stats = %+v, want alice with one game", stats)
+	}
+
+	for path, didYouMean := range map[string]string{
+		"/api/stats/nobody": "",
+		"/api/stats/ALICE":  "alice",
+		"/api/stats/%20Bob": "bob",
+	} {
+		rec := s.get(path)
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: status = %d, want 404", path, rec.Code)
+			continue
+		}
+		var resp map[string]string
+		decodeJSON(t, rec, &resp)
+		if resp["error"] != "player not found" || resp["didYouMean"] != didYouMean {
+			t.Errorf("%s: body %v, want player not found with didYouMean %q", path, resp, didYouMean)
+		}
+	}
+}
+
+func TestGetLeaderboardPeriod(t *testing.T) {
+	s := newTestServer(t)
+	now := time.Now()
+	savePlayed(t, s.store, "alice", "bob", now.Add(-time.Hour))
+	savePlayed(t, s.store, "carol", "bob", now.Add(-3*24*time.Hour))
+
+	type leaderboard struct {
+		Period      string                     `json:"period"`
+		WindowStart *time.Time                 `json:"windowStart"`
+		Entries     []storage.LeaderboardEntry `json:"entries"`
+	}
+	fetch := func(query string) leaderboard {
+		t.Helper()
+		rec := s.get("/api/leaderboard" + query)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, body %s", query, rec.Code, rec.Body)
+		}
+		var resp leaderboard
+		decodeJSON(t, rec, &resp)
+		return resp
+	}
+
+	all := fetch("")
+	if all.Period != "all" || all.WindowStart != nil || !hasEntry(all.Entries, "carol") {
+		t.Errorf("default leaderboard = %+v, want all time without a window start", all)
+	}
+
+	day := fetch("?period=day")
+	if day.Period != "day" || day.WindowStart == nil {
+		t.Fatalf("day leaderboard = %+v, want the period and window start echoed", day)
+	}
+	if d := now.Add(-24 * time.Hour).Sub(*day.WindowStart); d < -time.Minute || d > time.Minute {
+		t.Errorf("day window starts at %v, want about a day ago", day.WindowStart)
+	}
+	if !hasEntry(day.Entries, "alice") || hasEntry(day.Entries, "carol") {
+		t.Errorf("day leaderboard = %+v, want alice's game only", day.Entries)
+	}
+	if week := fetch("?period=week"); !hasEntry(week.Entries, "carol") {
+		t.Errorf("week leaderboard = %+v, want carol's game from 3 days ago", week.Entries)
+	}
+
+	if rec := s.get("/api/leaderboard?period=year"); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown period: status = %d, want 400", rec.Code)
+	}
+}
+
+func TestGetLeaderboardIncludeBots(t *testing.T) {
+	s := newTestServer(t)
+	start := time.Now().Add(-time.Hour)
+	savePlayed(t, s.store, "alice", "bob", start)
+	savePlayed(t, s.store, "alice", "BOT", start.Add(time.Minute))
+	savePlayed(t, s.store, "alice", "BOT", start.Add(2*time.Minute))
+	savePlayed(t, s.store, "BOT", "alice", start.Add(3*time.Minute))
+	savePlayed(t, s.store, "dave", "BOT", start.Add(4*time.Minute))
+
+	fetch := func(query string) map[string]storage.LeaderboardEntry {
+		t.Helper()
+		rec := s.get("/api/leaderboard" + query)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, body %s", query, rec.Code, rec.Body)
+		}
+		var resp struct {
+			Entries []storage.LeaderboardEntry `json:"entries"`
+		}
+		decodeJSON(t, rec, &resp)
+		byName := make(map[string]storage.LeaderboardEntry)
+		for _, e := range resp.Entries {
+			byName[e.Username] = e
+		}
+		return byName
+	}
+
+	for _, query := range []string{"", "?includeBots=false"} {
+		board := fetch(query)
+		if alice := board["alice"]; alice.Wins != 1 || alice.Losses != 0 || alice.Games != 1 {
+			t.Errorf("%q: alice = %+v, want only her human win", query, alice)
+		}
+		if _, ok := board["dave"]; ok {
+			t.Errorf("%q: dave ranked on bot games alone", query)
+		}
+	}
+
+	board := fetch("?includeBots=true")
+	if alice := board["alice"]; alice.Wins != 3 || alice.Losses != 1 || alice.Games != 4 {
+		t.Errorf("with bots: alice = %+v, want 3-1 over 4 games", alice)
+	}
+	if dave := board["dave"]; dave.Wins != 1 {
+		t.Errorf("with bots: dave = %+v, want his bot win", dave)
+	}
+	if _, ok := board["BOT"]; ok {
+		t.Error("with bots: the bot is ranked")
+	}
+
+	if rec := s.get("/api/leaderboard?includeBots=yes"); rec.Code != http.StatusBadRequest {
+		t.Errorf("includeBots=yes: status = %d, want 400", rec.Code)
+	}
+
+	// Player stats keep bot results in the record and split them out
+	rec := s.get("/api/stats/alice")
+	var stats storage.PlayerStats
+	decodeJSON(t, rec, &stats)
+	if stats.Wins != 3 || stats.Losses != 1 || stats.BotWins != 2 || stats.BotLosses != 1 {
+		t.Errorf("alice stats = %d-%d with bot record %d-%d, want 3-1 and 2-1", stats.Wins, stats.Losses, stats.BotWins, stats.BotLosses)
+	}
+}
+
+func hasEntry(entries []storage.LeaderboardEntry, username string) bool {
+	for _, e := range entries {
+		if e.Username == username {
+			return true
+		}
+	}
+	return false
+}
+
+// playEmitting plays columns between a and b, the players taking turns
+// from whoever the game picks to start, emitting each event to analytics
+func playEmitting(t *testing.T, analytics *kafka.LocalAnalytics, a, b string, columns ...int) {
+	t.Helper()
+	g := game.NewGame(a)
+	g.AddPlayer2(b, false)
+	analytics.EmitGameStart(g)
+	for i, col := range columns {
+		player := g.Player1.Username
+		if g.CurrentTurn == game.Player2 {
+			player = g.Player2.Username
+		}
+		row, err := g.MakeMove(g.CurrentTurn, col)
+		if err != nil {
+			t.Fatal(err)
+		}
+		analytics.EmitMove(g, player, col, row, i+1)
+	}
+	if g.Status != game.StatusFinished {
+		t.Fatalf("game %v did not finish", columns)
+	}
+	analytics.EmitGameEnd(g)
+}
+
+func TestGetAnalyticsMoveStats(t *testing.T) {
+	analytics := kafka.NewLocalAnalytics()
+	s := newTestServerWith(t, storage.NewMemoryStore(), analytics)
+
+	// The first mover wins the first game and loses the second; column 1
+	// takes 7 of the 15 moves
+	playEmitting(t, analytics, "alice", "bob", 0, 1, 0, 1, 0, 1, 0)
+	playEmitting(t, analytics, "carol", "dave", 0, 1, 0, 1, 2, 1, 6, 1)
+
+	rec := s.get("/api/analytics")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	var body struct {
+		Kafka struct {
+			Moves kafka.MoveStats `json:"moves"`
+		} `json:"kafka"`
+	}
+	decodeJSON(t, rec, &body)
+	moves := body.Kafka.Moves
+
+	if moves.FirstMoverGames != 2 || moves.FirstMoverWinRate != 0.5 {
+		t.Errorf("first mover won %.2f of %d games, want 0.50 of 2", moves.FirstMoverWinRate, moves.FirstMoverGames)
+	}
+	if got := moves.ColumnShare[1]; got < 7.0/15-1e-9 || got > 7.0/15+1e-9 {
+		t.Errorf("column 1 share = %.4f, want 7/15", got)
+	}
+	if len(moves.OpeningShare) != 1 || moves.OpeningShare[0] != 1 {
+		t.Errorf("opening share = %v, want every opening in column 0", moves.OpeningShare)
+	}
+}
+
+func TestGetStatusReportsCapacity(t *testing.T) {
+	s := newTestServer(t)
+	s.mm.SetLimits(10, 2)
+	s.hub.SetMaxConnections(50)
+	s.humanGame(t, "alice", "bob")
+	s.humanGame(t, "carol", "dave")
+	if _, err := s.mm.JoinQueue("erin", matchmaker.JoinOptions{NoBotFallback: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := s.get("/api/status")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	var status struct {
+		Capacity matchmaker.Capacity `json:"capacity"`
+		Hub      websocket.HubStats  `json:"hub"`
+	}
+	decodeJSON(t, rec, &status)
+	want := matchmaker.Capacity{ActiveGames: 2, MaxGames: 2, Waiting: 1, MaxWaiting: 10, GamesDeferred: 1}
+	if status.Capacity != want {
+		t.Errorf("capacity = %+v, want %+v", status.Capacity, want)
+	}
+	if status.Hub.MaxConnections != 50 || status.Hub.RefusedConnections != 0 {
+		t.Errorf("hub = %+v, want a limit of 50 and nothing refused", status.Hub)
+	}
+}
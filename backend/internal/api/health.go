@@ -0,0 +1,58 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// readyPingTimeout bounds the database ping in the readiness check
+const readyPingTimeout = 2 * time.Second
+
+// GetLive reports that the process is up
+func (h *Handlers) GetLive(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte("OK"))
+}
+
+// GetReady reports whether the server's dependencies are usable. It
+// returns 503 when the database can't be reached. Kafka is optional, so
+// its state is reported but never fails the check.
+func (h *Handlers) GetReady(w http.ResponseWriter, r *http.Request) {
+	ready := true
+
+	ctx, cancel := context.WithTimeout(r.Context(), readyPingTimeout)
+	defer cancel()
+	database := map[string]interface{}{"backend": h.store.Backend(), "status": "ok"}
+	if err := h.store.Ping(ctx); err != nil {
+		ready = false
+		database["status"] = "error"
+		database["error"] = err.Error()
+	}
+
+	kafkaState := map[string]interface{}{"status": "disabled"}
+	switch {
+	case h.producer == nil:
+		// Events are handled in process or discarded
+	case h.producer.IsConnecting():
+		kafkaState["status"] = "connecting"
+	case h.producer.IsEnabled():
+		kafkaState["status"] = "ok"
+		if at, err := h.producer.LastError(); err != nil {
+			kafkaState["status"] = "error"
+			kafkaState["error"] = err.Error()
+			kafkaState["failedAt"] = at
+		}
+	}
+
+	status := http.StatusOK
+	response := map[string]interface{}{
+		"status":   "ready",
+		"database": database,
+		"kafka":    kafkaState,
+	}
+	if !ready {
+		status = http.StatusServiceUnavailable
+		response["status"] = "unavailable"
+	}
+	respondJSONStatus(w, status, response)
+}
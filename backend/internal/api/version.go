@@ -0,0 +1,35 @@
+package api
+
+import (
+	"runtime/debug"
+	"sync"
+)
+
+// Version is the build version reported by /api/status. Release builds set
+// it with -ldflags "-X github.com/connect-four/internal/api.Version=v1.2.3";
+// otherwise it comes from the VCS revision Go stamped into the binary.
+var Version string
+
+// buildVersion returns Version, or the binary's VCS revision without it
+var buildVersion = sync.OnceValue(func() string {
+	if Version != "" {
+		return Version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	version, dirty := info.Main.Version, false
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			version = setting.Value[:min(len(setting.Value), 12)]
+		case "vcs.modified":
+			dirty = setting.Value == "true"
+		}
+	}
+	if dirty {
+		version += "-dirty"
+	}
+	return version
+})
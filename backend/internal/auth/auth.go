@@ -0,0 +1,122 @@
+// Package auth issues and verifies the signed tokens binding a WebSocket
+// connection to a username. Tokens are JWTs signed with HS256.
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// DefaultTokenTTL is how long an issued token can be used to connect
+const DefaultTokenTTL = 30 * time.Minute
+
+// MinSecretLength is the shortest signing secret accepted, in bytes
+const MinSecretLength = 32
+
+// Error codes returned to clients when a token is rejected
+const (
+	CodeTokenRequired = "tokenRequired"
+	CodeTokenInvalid  = "tokenInvalid"
+	CodeTokenExpired  = "tokenExpired"
+)
+
+var (
+	// ErrTokenRequired is returned when a connection carries no token
+	ErrTokenRequired = &Error{CodeTokenRequired, "an auth token is required"}
+
+	// ErrTokenInvalid is returned for malformed tokens, tokens signed
+	// with another key and tokens without a username
+	ErrTokenInvalid = &Error{CodeTokenInvalid, "auth token is invalid"}
+
+	// ErrTokenExpired is returned for a correctly signed token past its
+	// expiry
+	ErrTokenExpired = &Error{CodeTokenExpired, "auth token has expired"}
+)
+
+// Error describes why a token was rejected
+type Error struct {
+	Code string
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return e.Msg
+}
+
+// Claims is what a token asserts
+type Claims struct {
+	Username  string `json:"sub"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// header is the only JWT header tokens are issued with or accepted under
+var header = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// Signer issues and verifies tokens with one secret. It is safe for
+// concurrent use.
+type Signer struct {
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// NewSigner creates a signer whose tokens last ttl
+func NewSigner(secret []byte, ttl time.Duration) *Signer {
+	return &Signer{secret: secret, ttl: ttl, now: time.Now}
+}
+
+// Issue returns a token binding username, and when it expires
+func (s *Signer) Issue(username string) (string, time.Time, error) {
+	now := s.now()
+	expires := now.Add(s.ttl)
+	payload, err := json.Marshal(Claims{
+		Username:  username,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expires.Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + s.sign(unsigned), expires, nil
+}
+
+// Verify checks a token's signature and expiry and returns its claims
+func (s *Signer) Verify(token string) (Claims, error) {
+	if token == "" {
+		return Claims{}, ErrTokenRequired
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != header {
+		return Claims{}, ErrTokenInvalid
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(s.sign(parts[0]+"."+parts[1]))) {
+		return Claims{}, ErrTokenInvalid
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return Claims{}, ErrTokenInvalid
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Username == "" {
+		return Claims{}, ErrTokenInvalid
+	}
+	if !s.now().Before(time.Unix(claims.ExpiresAt, 0)) {
+		return Claims{}, ErrTokenExpired
+	}
+	return claims, nil
+}
+
+// sign returns the encoded HS256 signature of the token's first two parts
+func (s *Signer) sign(unsigned string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(unsigned))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,185 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/connect-four/internal/websocket"
+)
+
+// env returns a getenv reading from vars
+func env(vars map[string]string) func(string) string {
+	return func(key string) string { return vars[key] }
+}
+
+func TestSessionPolicyDefault(t *testing.T) {
+	secret := "0123456789abcdef0123456789abcdef"
+	tests := []struct {
+		name string
+		vars map[string]string
+		want websocket.SessionPolicy
+	}{
+		{"auth off", nil, websocket.SessionReject},
+		{"auth on", map[string]string{"AUTH_ENABLED": "true", "AUTH_SECRET": secret}, websocket.SessionReplace},
+		{"auth off, replace set", map[string]string{"DUPLICATE_SESSION_POLICY": "replace"}, websocket.SessionReplace},
+		{"auth on, reject set", map[string]string{"AUTH_ENABLED": "true", "AUTH_SECRET": secret, "DUPLICATE_SESSION_POLICY": "reject"}, websocket.SessionReject},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Load(env(tt.vars))
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if cfg.Server.SessionPolicy != tt.want {
+				t.Errorf("SessionPolicy = %q, want %q", cfg.Server.SessionPolicy, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	cfg, err := Load(env(nil))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !reflect.DeepEqual(cfg, Default()) {
+		t.Errorf("Load with nothing set = %+v, want Default() %+v", cfg, Default())
+	}
+	if err := Default().Validate(); err != nil {
+		t.Errorf("Default() does not validate: %v", err)
+	}
+}
+
+func TestLoadReadsValues(t *testing.T) {
+	cfg, err := Load(env(map[string]string{
+		"PORT":                 "9090",
+		"DATABASE_URL":         "sqlite:/tmp/games.db",
+		"KAFKA_BROKERS":        "kafka-1:9092, kafka-2:9093,",
+		"TURN_TIMEOUT_SECONDS": "45",
+		"STALE_GAME_MINUTES":   "3",
+		"WS_MAX_CONNECTIONS":   "500",
+		"ALLOWED_ORIGINS":      "https://example.com,https://*.example.com",
+	}))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Server.Port != "9090" {
+		t.Errorf("Port = %q", cfg.Server.Port)
+	}
+	if cfg.Database.URL != "sqlite:/tmp/games.db" {
+		t.Errorf("Database.URL = %q", cfg.Database.URL)
+	}
+	if want := []string{"kafka-1:9092", "kafka-2:9093"}; !reflect.DeepEqual(cfg.Kafka.Brokers, want) {
+		t.Errorf("Brokers = %q, want %q", cfg.Kafka.Brokers, want)
+	}
+	if cfg.Game.TurnTimeout != 45*time.Second {
+		t.Errorf("TurnTimeout = %v", cfg.Game.TurnTimeout)
+	}
+	if cfg.Game.StaleAfter != 3*time.Minute {
+		t.Errorf("StaleAfter = %v", cfg.Game.StaleAfter)
+	}
+	if cfg.Server.MaxConnections != 500 {
+		t.Errorf("MaxConnections = %d", cfg.Server.MaxConnections)
+	}
+	if len(cfg.Server.AllowedOrigins) != 2 {
+		t.Errorf("AllowedOrigins = %q", cfg.Server.AllowedOrigins)
+	}
+}
+
+func TestLoadReportsProblems(t *testing.T) {
+	tests := []struct {
+		name string
+		vars map[string]string
+		want []string // one problem mentioning each
+	}{
+		{"port not a number", map[string]string{"PORT": "http"}, []string{`PORT: must be a port number between 1 and 65535, got "http"`}},
+		{"port out of range", map[string]string{"PORT": "70000"}, []string{"PORT: must be a port number"}},
+		{"port zero", map[string]string{"PORT": "0"}, []string{"PORT: must be a port number"}},
+		{"database scheme", map[string]string{"DATABASE_URL": "mysql://localhost/games"}, []string{`DATABASE_URL: scheme must be postgres, postgresql or sqlite, got "mysql"`}},
+		{"database URL unparseable", map[string]string{"DATABASE_URL": "postgres://%zz"}, []string{"DATABASE_URL: not a valid URL"}},
+		{"database URL without host", map[string]string{"DATABASE_URL": "postgres:///games"}, []string{"DATABASE_URL: URL has no host"}},
+		{"sqlite without path", map[string]string{"DATABASE_URL": "sqlite:"}, []string{"DATABASE_URL: sqlite: URL has no file path"}},
+		{"broker without port", map[string]string{"KAFKA_BROKERS": "kafka-1:9092,kafka-2"}, []string{`KAFKA_BROKERS: each broker must be host:port, got "kafka-2"`}},
+		{"blank brokers", map[string]string{"KAFKA_BROKERS": " , "}, []string{"KAFKA_BROKERS: must list at least one host:port"}},
+		{"timeout not a number", map[string]string{"TURN_TIMEOUT_SECONDS": "30s"}, []string{`TURN_TIMEOUT_SECONDS: must be a whole number, got "30s"`}},
+		{"negative timeout", map[string]string{"TURN_TIMEOUT_SECONDS": "-1"}, []string{"TURN_TIMEOUT_SECONDS: must not be negative"}},
+		{"unknown log level", map[string]string{"LOG_LEVEL": "loud"}, []string{"LOG_LEVEL: must be debug, info, warn or error"}},
+		{"auth without secret", map[string]string{"AUTH_ENABLED": "true"}, []string{"AUTH_SECRET: must be at least"}},
+		{"unknown events backend", map[string]string{"EVENTS_BACKEND": "redis"}, []string{`EVENTS_BACKEND: must be kafka, local or none, got "redis"`}},
+		{"every problem at once", map[string]string{
+			"PORT":                 "http",
+			"DATABASE_URL":         "mysql://localhost/games",
+			"TURN_TIMEOUT_SECONDS": "soon",
+		}, []string{"PORT:", "DATABASE_URL:", "TURN_TIMEOUT_SECONDS:"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(env(tt.vars))
+			var cfgErr *Error
+			if !errors.As(err, &cfgErr) {
+				t.Fatalf("Load error = %v, want an *Error", err)
+			}
+			if len(cfgErr.Problems) != len(tt.want) {
+				t.Errorf("problems = %q, want %d", cfgErr.Problems, len(tt.want))
+			}
+			for _, want := range tt.want {
+				found := false
+				for _, p := range cfgErr.Problems {
+					found = found || strings.Contains(p, want)
+				}
+				if !found {
+					t.Errorf("problems %q, want one mentioning %q", cfgErr.Problems, want)
+				}
+			}
+			if !strings.HasPrefix(err.Error(), "invalid configuration: ") {
+				t.Errorf("error = %q", err)
+			}
+		})
+	}
+}
+
+func TestLoadAcceptsDatabaseURLForms(t *testing.T) {
+	for _, url := range []string{
+		"postgres://user:pass@db:5432/games?sslmode=disable",
+		"postgresql://db/games",
+		"host=db user=postgres dbname=games",
+		"sqlite:games.db",
+		"sqlite:///var/lib/games.db",
+		"sqlite:memory",
+	} {
+		if _, err := Load(env(map[string]string{"DATABASE_URL": url})); err != nil {
+			t.Errorf("DATABASE_URL %q: %v", url, err)
+		}
+	}
+}
+
+// TestConfigLiteral builds a configuration the way tests and embedders do,
+// without touching the environment
+func TestConfigLiteral(t *testing.T) {
+	cfg := Default()
+	cfg.Server.Port = "0"
+	cfg.Database.URL = "sqlite:memory"
+	cfg.Kafka.Events = EventsNone
+	cfg.Kafka.Brokers = nil
+	cfg.Game.TurnTimeout = 0
+
+	err := cfg.Validate()
+	var cfgErr *Error
+	if !errors.As(err, &cfgErr) || len(cfgErr.Problems) != 1 || !strings.HasPrefix(cfgErr.Problems[0], "PORT:") {
+		t.Fatalf("Validate = %v, want only the port rejected", err)
+	}
+
+	cfg.Server.Port = "18080"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate: %v", err)
+	}
+
+	// Kafka events need brokers
+	cfg.Kafka.Events = EventsKafka
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "KAFKA_BROKERS") {
+		t.Errorf("kafka events without brokers: Validate = %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// LoadDotEnv sets environment variables from a .env file, leaving ones
+// that are already set alone. A missing file is not an error.
+//
+// Lines are KEY=value, optionally prefixed with "export ". Values may be
+// double quoted (with \n, \t, \" and \\ escapes) or single quoted (taken
+// literally); unquoted values end at a " #" comment.
+func LoadDotEnv(filename string) error {
+	file, err := os.Open(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		key, value, ok, err := parseDotEnvLine(scanner.Text())
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", filename, lineNum, err)
+		}
+		if ok && os.Getenv(key) == "" {
+			os.Setenv(key, value)
+		}
+	}
+	return scanner.Err()
+}
+
+// parseDotEnvLine parses one .env line, reporting false for blank lines
+// and comments
+func parseDotEnvLine(line string) (key, value string, ok bool, err error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", "", false, nil
+	}
+	line = strings.TrimPrefix(line, "export ")
+
+	key, raw, found := strings.Cut(line, "=")
+	key = strings.TrimSpace(key)
+	if !found || key == "" {
+		return "", "", false, fmt.Errorf("expected KEY=value")
+	}
+	raw = strings.TrimSpace(raw)
+
+	if raw == "" || (raw[0] != '"' && raw[0] != '\'') {
+		if i := strings.Index(raw, " #"); i >= 0 {
+			raw = strings.TrimSpace(raw[:i])
+		}
+		return key, raw, true, nil
+	}
+
+	quote := raw[0]
+	var b strings.Builder
+	for i := 1; i < len(raw); i++ {
+		c := raw[i]
+		switch {
+		case c == quote:
+			if rest := strings.TrimSpace(raw[i+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+				return "", "", false, fmt.Errorf("unexpected text after closing quote in %s", key)
+			}
+			return key, b.String(), true, nil
+		case c == '\\' && quote == '"' && i+1 < len(raw):
+			i++
+			switch raw[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			default: // \" \\ and anything else stand for themselves
+				b.WriteByte(raw[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", "", false, fmt.Errorf("unterminated quoted value for %s", key)
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseDotEnvLine(t *testing.T) {
+	tests := []struct {
+		line       string
+		key, value string
+		ok         bool
+	}{
+		{"", "", "", false},
+		{"   ", "", "", false},
+		{"# a comment", "", "", false},
+		{"PORT=8080", "PORT", "8080", true},
+		{"  PORT = 8080  ", "PORT", "8080", true},
+		{"export PORT=8080", "PORT", "8080", true},
+		{"EMPTY=", "EMPTY", "", true},
+		{"PORT=8080 # the API port", "PORT", "8080", true},
+		{"URL=postgres://db/games?a=b", "URL", "postgres://db/games?a=b", true},
+		{"HASH=abc#def", "HASH", "abc#def", true},
+		{`NAME="Connect Four"`, "NAME", "Connect Four", true},
+		{`NAME="Connect # Four" # comment`, "NAME", "Connect # Four", true},
+		{`MULTI="one\ntwo\tthree"`, "MULTI", "one\ntwo\tthree", true},
+		{`ESCAPED="say \"hi\" \\ bye"`, "ESCAPED", `say "hi" \ bye`, true},
+		{`EMPTY_QUOTED=""`, "EMPTY_QUOTED", "", true},
+		{`SINGLE='literal \n $HOME'`, "SINGLE", `literal \n $HOME`, true},
+		{`SINGLE='it"s'`, "SINGLE", `it"s`, true},
+		{`SECRET="a=b=c"`, "SECRET", "a=b=c", true},
+	}
+	for _, tt := range tests {
+		key, value, ok, err := parseDotEnvLine(tt.line)
+		if err != nil {
+			t.Errorf("%q: %v", tt.line, err)
+			continue
+		}
+		if key != tt.key || value != tt.value || ok != tt.ok {
+			t.Errorf("%q = %q, %q, %v; want %q, %q, %v", tt.line, key, value, ok, tt.key, tt.value, tt.ok)
+		}
+	}
+}
+
+func TestParseDotEnvLineErrors(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"PORT", "expected KEY=value"},
+		{"=8080", "expected KEY=value"},
+		{`NAME="unterminated`, "unterminated quoted value for NAME"},
+		{`NAME='unterminated`, "unterminated quoted value for NAME"},
+		{`NAME="ends with escape\"`, "unterminated quoted value for NAME"},
+		{`NAME="quoted" trailing`, "unexpected text after closing quote in NAME"},
+	}
+	for _, tt := range tests {
+		_, _, _, err := parseDotEnvLine(tt.line)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%q: err = %v, want %q", tt.line, err, tt.want)
+		}
+	}
+}
+
+func TestLoadDotEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	contents := "# settings\nDOTENV_TEST_NAME=\"Connect Four\"\nexport DOTENV_TEST_SET=from-file\n\nDOTENV_TEST_RAW='a\\nb'\n"
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DOTENV_TEST_SET", "from-env")
+	t.Setenv("DOTENV_TEST_NAME", "")
+	t.Setenv("DOTENV_TEST_RAW", "")
+
+	if err := LoadDotEnv(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := os.Getenv("DOTENV_TEST_NAME"); got != "Connect Four" {
+		t.Errorf("DOTENV_TEST_NAME = %q", got)
+	}
+	if got := os.Getenv("DOTENV_TEST_RAW"); got != `a\nb` {
+		t.Errorf("DOTENV_TEST_RAW = %q", got)
+	}
+	if got := os.Getenv("DOTENV_TEST_SET"); got != "from-env" {
+		t.Errorf("DOTENV_TEST_SET = %q, the file overrode the environment", got)
+	}
+
+	if err := LoadDotEnv(filepath.Join(t.TempDir(), "missing")); err != nil {
+		t.Errorf("missing file: %v", err)
+	}
+
+	bad := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(bad, []byte("OK=1\nBROKEN=\"open\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadDotEnv(bad); err == nil || !strings.Contains(err.Error(), ":2: unterminated") {
+		t.Errorf("bad file: err = %v, want the line number", err)
+	}
+}
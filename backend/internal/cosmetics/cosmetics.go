@@ -0,0 +1,160 @@
+package cosmetics
+
+import (
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// MaxDiscEmojiBytes caps the encoded size of a disc emoji; the longest
+	// RGI sequences, kiss and couple with two skin tones, are 35 bytes
+	MaxDiscEmojiBytes = 64
+
+	// MaxAvatarURLLength caps the length of an avatar URL
+	MaxAvatarURLLength = 512
+)
+
+// Warning codes returned to the client when a cosmetic is dropped
+const (
+	WarningDiscEmojiRejected = "discEmojiRejected"
+	WarningAvatarURLRejected = "avatarUrlRejected"
+)
+
+const (
+	zeroWidthJoiner   = 0x200D
+	variationSelector = 0xFE0F
+	keycapCombining   = 0x20E3
+)
+
+// isEmojiBase reports whether r can start an emoji grapheme
+func isEmojiBase(r rune) bool {
+	switch {
+	case r >= 0x1F300 && r <= 0x1FAFF: // pictographs, emoticons, transport, supplemental symbols
+		return true
+	case r >= 0x2600 && r <= 0x27BF: // misc symbols and dingbats
+		return true
+	case r >= 0x2B00 && r <= 0x2BFF: // arrows, stars and squares
+		return true
+	case r == 0x00A9 || r == 0x00AE || r == 0x203C || r == 0x2049 || r == 0x2122:
+		return true
+	}
+	return false
+}
+
+// isSkinTone reports whether r is a Fitzpatrick skin tone modifier
+func isSkinTone(r rune) bool {
+	return r >= 0x1F3FB && r <= 0x1F3FF
+}
+
+// isRegionalIndicator reports whether r is a flag letter
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+// isTag reports whether r is a tag character used in subdivision flags
+func isTag(r rune) bool {
+	return r >= 0xE0020 && r <= 0xE007F
+}
+
+// isKeycapBase reports whether r can start a keycap sequence
+func isKeycapBase(r rune) bool {
+	return (r >= '0' && r <= '9') || r == '#' || r == '*'
+}
+
+// IsSingleEmoji reports whether s is exactly one emoji grapheme from the
+// allowed ranges. It accepts skin tone modifiers, variation selectors,
+// ZWJ sequences, flags, keycaps and tag sequences, and rejects everything
+// else including plain text and multiple emoji.
+func IsSingleEmoji(s string) bool {
+	if s == "" || len(s) > MaxDiscEmojiBytes || !utf8.ValidString(s) {
+		return false
+	}
+
+	runes := []rune(s)
+
+	// Flag: exactly two regional indicators
+	if isRegionalIndicator(runes[0]) {
+		return len(runes) == 2 && isRegionalIndicator(runes[1])
+	}
+
+	// Keycap: base, optional variation selector, combining keycap
+	if isKeycapBase(runes[0]) {
+		rest := runes[1:]
+		if len(rest) > 0 && rest[0] == variationSelector {
+			rest = rest[1:]
+		}
+		return len(rest) == 1 && rest[0] == keycapCombining
+	}
+
+	i := 0
+	for {
+		// Each ZWJ-joined element is a base emoji with optional modifiers
+		if i >= len(runes) || !isEmojiBase(runes[i]) {
+			return false
+		}
+		i++
+		if i < len(runes) && runes[i] == variationSelector {
+			i++
+		}
+		if i < len(runes) && isSkinTone(runes[i]) {
+			i++
+		}
+		for i < len(runes) && isTag(runes[i]) {
+			i++
+		}
+
+		if i == len(runes) {
+			return true
+		}
+		if runes[i] != zeroWidthJoiner {
+			return false
+		}
+		i++
+	}
+}
+
+// ParseHostList splits a comma-separated host allowlist into lowercase hosts
+func ParseHostList(value string) []string {
+	var hosts []string
+	for _, host := range strings.Split(value, ",") {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if host != "" {
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
+
+// IsAllowedAvatarURL reports whether raw is an https URL on one of the
+// allowed hosts. Credentials, non-default ports, backslashes, control
+// characters and anything that does not round-trip cleanly are rejected.
+func IsAllowedAvatarURL(raw string, allowedHosts []string) bool {
+	if raw == "" || len(raw) > MaxAvatarURLLength || len(allowedHosts) == 0 {
+		return false
+	}
+	for _, r := range raw {
+		if r < 0x21 || r == 0x7F || r == '\\' || r > 0x7E {
+			return false
+		}
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	if u.Scheme != "https" || u.Opaque != "" || u.User != nil || u.Host == "" {
+		return false
+	}
+	if port := u.Port(); port != "" && port != "443" {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range allowedHosts {
+		if host == allowed {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,33 @@
+// Package events defines how game events leave the game server. The Kafka
+// producer, kafka.LocalAnalytics and Nop implement Emitter; main picks one
+// from the configuration.
+package events
+
+import (
+	"time"
+
+	"github.com/connect-four/internal/game"
+)
+
+// Emitter publishes game events for analytics. Emit calls come from game
+// callbacks, so implementations must not block on the network.
+type Emitter interface {
+	EmitGameStart(g *game.Game)
+	EmitMove(g *game.Game, player string, column, row, moveNum int)
+	EmitGameEnd(g *game.Game)
+	EmitDisconnect(g *game.Game, player string)
+	EmitReconnect(g *game.Game, player string, success bool)
+	EmitMatchmakingFallback(g *game.Game, player string, waited time.Duration)
+	Close() error
+}
+
+// Nop discards every event
+type Nop struct{}
+
+func (Nop) EmitGameStart(*game.Game)                                  {}
+func (Nop) EmitMove(*game.Game, string, int, int, int)                {}
+func (Nop) EmitGameEnd(*game.Game)                                    {}
+func (Nop) EmitDisconnect(*game.Game, string)                         {}
+func (Nop) EmitReconnect(*game.Game, string, bool)                    {}
+func (Nop) EmitMatchmakingFallback(*game.Game, string, time.Duration) {}
+func (Nop) Close() error                                              { return nil }
@@ -0,0 +1,136 @@
+package game
+
+import "math/bits"
+
+// The board keeps one bitboard per player alongside the cell array. Each
+// column takes rows+1 bits, bottom row first, with the extra bit left empty
+// so that shifted lines never wrap from the top of one column into the
+// bottom of the next. On the standard board:
+//
+//	6 13 20 27 34 41 48   <- always zero
+//	5 12 19 26 33 40 47   <- row 0 (top)
+//	...
+//	0  7 14 21 28 35 42   <- row Rows-1 (bottom)
+//
+// Two words hold the largest board, MaxBoardSide x (MaxBoardSide+1) bits.
+type bitboard [2]uint64
+
+// bit returns the bitboard with only bit i set
+func bit(i int) bitboard {
+	var b bitboard
+	b[i/64] = 1 << uint(i%64)
+	return b
+}
+
+func (b bitboard) and(o bitboard) bitboard    { return bitboard{b[0] & o[0], b[1] & o[1]} }
+func (b bitboard) or(o bitboard) bitboard     { return bitboard{b[0] | o[0], b[1] | o[1]} }
+func (b bitboard) andNot(o bitboard) bitboard { return bitboard{b[0] &^ o[0], b[1] &^ o[1]} }
+func (b bitboard) isZero() bool               { return b[0]|b[1] == 0 }
+func (b bitboard) count() int                 { return bits.OnesCount64(b[0]) + bits.OnesCount64(b[1]) }
+
+// shr shifts the bitboard down by s bits
+func (b bitboard) shr(s uint) bitboard {
+	if s >= 64 {
+		return bitboard{b[1] >> (s - 64), 0}
+	}
+	if s == 0 {
+		return b
+	}
+	return bitboard{b[0]>>s | b[1]<<(64-s), b[1] >> s}
+}
+
+// bitsPerColumn is how many bits each column takes
+func (b *Board) bitsPerColumn() int {
+	return b.rows + 1
+}
+
+// bitboardShifts are the bit distances between neighbouring cells along
+// each line direction: vertical, horizontal and the two diagonals
+func bitboardShifts(size BoardSize) [4]uint {
+	n := uint(size.Rows + 1)
+	return [4]uint{1, n, n - 1, n + 1}
+}
+
+// fitsOneWord reports whether a board's bitboards fit in their low word,
+// as the standard board's do
+func fitsOneWord(size BoardSize) bool {
+	return size.Columns*(size.Rows+1) <= 64
+}
+
+// cellBit returns the bitboard mask of a cell
+func (b *Board) cellBit(row, col int) bitboard {
+	return bit(col*b.bitsPerColumn() + b.rows - 1 - row)
+}
+
+// hasLineUnsafe reports whether a bitboard has winLength discs in a row in
+// any direction. Runs are doubled in length with each shift, then topped
+// up to winLength.
+func (b *Board) hasLineUnsafe(discs bitboard) bool {
+	if b.oneWord {
+		return b.hasLineInWord(discs[0])
+	}
+	for _, s := range b.shifts {
+		runs, length := discs, 1
+		for length*2 <= b.winLength {
+			runs = runs.and(runs.shr(uint(length) * s))
+			length *= 2
+		}
+		if length < b.winLength {
+			runs = runs.and(runs.shr(uint(b.winLength-length) * s))
+		}
+		if !runs.isZero() {
+			return true
+		}
+	}
+	return false
+}
+
+// hasLineInWord is hasLineUnsafe for boards that fit in one word. The bot
+// checks lines in its innermost loop, so this path matters, and four in a
+// row gets its own two-shift version.
+func (b *Board) hasLineInWord(discs uint64) bool {
+	if b.winLength == 4 {
+		for _, s := range b.shifts {
+			pairs := discs & (discs >> s)
+			if pairs&(pairs>>(2*s)) != 0 {
+				return true
+			}
+		}
+		return false
+	}
+	for _, s := range b.shifts {
+		runs, length := discs, 1
+		for length*2 <= b.winLength {
+			runs &= runs >> (uint(length) * s)
+			length *= 2
+		}
+		if length < b.winLength {
+			runs &= runs >> (uint(b.winLength-length) * s)
+		}
+		if runs != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// completesLineUnsafe reports whether a disc at row, col would give discs
+// a winning line
+func (b *Board) completesLineUnsafe(discs bitboard, row, col int) bool {
+	i := col*b.bitsPerColumn() + b.rows - 1 - row
+	if b.oneWord {
+		return b.hasLineInWord(discs[0] | 1<<uint(i))
+	}
+	return b.hasLineUnsafe(discs.or(bit(i)))
+}
+
+// setCellUnsafe writes a cell and keeps the bitboards in step
+func (b *Board) setCellUnsafe(row, col, player int) {
+	mask := b.cellBit(row, col)
+	b.bits[Player1] = b.bits[Player1].andNot(mask)
+	b.bits[Player2] = b.bits[Player2].andNot(mask)
+	if player != Empty {
+		b.bits[player] = b.bits[player].or(mask)
+	}
+	b.cells[row][col] = player
+}
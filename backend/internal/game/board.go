@@ -2,12 +2,22 @@ package game
 
 import (
 	"errors"
+	"fmt"
 	"sync"
 )
 
+// The standard board: six rows, seven columns, four in a row to win
 const (
-	Rows    = 6
-	Columns = 7
+	Rows      = 6
+	Columns   = 7
+	WinLength = 4
+)
+
+// Limits on board variants
+const (
+	MinBoardSide = 4  // fewest rows or columns
+	MaxBoardSide = 10 // most rows or columns
+	MinWinLength = 3
 )
 
 const (
@@ -16,15 +26,114 @@ const (
 	Player2 = 2
 )
 
+// BoardSize is a board's dimensions and how many discs in a row win
+type BoardSize struct {
+	Rows      int `json:"rows"`
+	Columns   int `json:"columns"`
+	WinLength int `json:"winLength"`
+}
+
+// StandardSize is the classic 6x7 board with four in a row to win
+var StandardSize = BoardSize{Rows: Rows, Columns: Columns, WinLength: WinLength}
+
+// NewBoardSize returns a validated board size; a zero value takes its
+// standard counterpart, so NewBoardSize(0, 0, 5) is connect-5 on 6x7
+func NewBoardSize(rows, columns, winLength int) (BoardSize, error) {
+	size := BoardSize{Rows: rows, Columns: columns, WinLength: winLength}.OrStandard()
+	if err := size.Validate(); err != nil {
+		return BoardSize{}, err
+	}
+	return size, nil
+}
+
+// OrStandard fills in zero dimensions with the standard ones
+func (s BoardSize) OrStandard() BoardSize {
+	if s.Rows == 0 {
+		s.Rows = Rows
+	}
+	if s.Columns == 0 {
+		s.Columns = Columns
+	}
+	if s.WinLength == 0 {
+		s.WinLength = WinLength
+	}
+	return s
+}
+
+// Validate checks that the size is within the variant limits and that a
+// line of WinLength fits both across and down the board
+func (s BoardSize) Validate() error {
+	switch {
+	case s.Rows < MinBoardSide || s.Rows > MaxBoardSide:
+		return &BoardError{fmt.Sprintf("rows must be between %d and %d", MinBoardSide, MaxBoardSide)}
+	case s.Columns < MinBoardSide || s.Columns > MaxBoardSide:
+		return &BoardError{fmt.Sprintf("columns must be between %d and %d", MinBoardSide, MaxBoardSide)}
+	case s.WinLength < MinWinLength || s.WinLength > min(s.Rows, s.Columns):
+		return &BoardError{fmt.Sprintf("win length must be between %d and %d", MinWinLength, min(s.Rows, s.Columns))}
+	}
+	return nil
+}
+
+// IsStandard reports whether the size is the classic board
+func (s BoardSize) IsStandard() bool {
+	return s.OrStandard() == StandardSize
+}
+
+// String describes the size, e.g. "6x7 connect-4"
+func (s BoardSize) String() string {
+	return fmt.Sprintf("%dx%d connect-%d", s.Rows, s.Columns, s.WinLength)
+}
+
 // Board represents the game board
 type Board struct {
-	cells [Rows][Columns]int
+	rows, columns, winLength int
+	shifts                   [4]uint // see bitboardShifts
+	oneWord                  bool    // see fitsOneWord
+
+	cells [][]int
+	bits  [3]bitboard // per-player bitboards indexed by player number, see bitboard.go
+	all   bitboard    // every cell of the board
 	mu    sync.RWMutex
 }
 
-// NewBoard creates a new empty board
+// NewBoard creates a new empty standard board
 func NewBoard() *Board {
-	return &Board{}
+	return NewBoardOfSize(StandardSize)
+}
+
+// NewBoardOfSize creates a new empty board of a validated size
+func NewBoardOfSize(size BoardSize) *Board {
+	size = size.OrStandard()
+	b := &Board{
+		rows:      size.Rows,
+		columns:   size.Columns,
+		winLength: size.WinLength,
+		shifts:    bitboardShifts(size),
+		oneWord:   fitsOneWord(size),
+	}
+	b.cells = make([][]int, size.Rows)
+	for row := range b.cells {
+		b.cells[row] = make([]int, size.Columns)
+		for col := range b.cells[row] {
+			b.all = b.all.or(b.cellBit(row, col))
+		}
+	}
+	return b
+}
+
+// Size returns the board's dimensions and win length
+func (b *Board) Size() BoardSize {
+	return BoardSize{Rows: b.rows, Columns: b.columns, WinLength: b.winLength}
+}
+
+// Rows returns how many rows the board has
+func (b *Board) Rows() int {
+	return b.rows
+}
+
+// Columns returns how many columns the board has
+func (b *Board) Columns() int {
+	return b.columns
 }
 
 // Clone creates a deep copy of the board
@@ -32,12 +141,11 @@ func (b *Board) Clone() *Board {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
-	newBoard := &Board{}
-	for i := 0; i < Rows; i++ {
-		for j := 0; j < Columns; j++ {
-			newBoard.cells[i][j] = b.cells[i][j]
-		}
+	newBoard := NewBoardOfSize(b.Size())
+	for i := range b.cells {
+		copy(newBoard.cells[i], b.cells[i])
 	}
+	newBoard.bits = b.bits
 	return newBoard
 }
 
@@ -48,27 +156,13 @@ func (b *Board) GetCell(row, col int) int {
 	return b.cells[row][col]
 }
 
-// GetCells returns a copy of the board cells
-func (b *Board) GetCells() [Rows][Columns]int {
-	b.mu.RLock()
-	defer b.mu.RUnlock()
-
-	var cells [Rows][Columns]int
-	for i := 0; i < Rows; i++ {
-		for j := 0; j < Columns; j++ {
-			cells[i][j] = b.cells[i][j]
-		}
-	}
-	return cells
-}
-
 // DropDisc drops a disc into the specified column for the given player
 // Returns the row where the disc landed, or error if column is full/invalid
 func (b *Board) DropDisc(column, player int) (int, error) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	if column < 0 || column >= Columns {
+	if column < 0 || column >= b.columns {
 		return -1, errors.New("invalid column")
 	}
 
@@ -77,9 +171,9 @@ func (b *Board) DropDisc(column, player int) (int, error) {
 	}
 
 	// Find the lowest empty row in the column
-	for row := Rows - 1; row >= 0; row-- {
+	for row := b.rows - 1; row >= 0; row-- {
 		if b.cells[row][column] == Empty {
-			b.cells[row][column] = player
+			b.setCellUnsafe(row, column, player)
 			return row, nil
 		}
 	}
@@ -89,13 +183,13 @@ func (b *Board) DropDisc(column, player int) (int, error) {
 
 // DropDiscUnsafe is like DropDisc but without locking (for bot calculations)
 func (b *Board) DropDiscUnsafe(column, player int) (int, error) {
-	if column < 0 || column >= Columns {
+	if column < 0 || column >= b.columns {
 		return -1, errors.New("invalid column")
 	}
 
-	for row := Rows - 1; row >= 0; row-- {
+	for row := b.rows - 1; row >= 0; row-- {
 		if b.cells[row][column] == Empty {
-			b.cells[row][column] = player
+			b.setCellUnsafe(row, column, player)
 			return row, nil
 		}
 	}
@@ -105,14 +199,32 @@ func (b *Board) DropDiscUnsafe(column, player int) (int, error) {
 
 // UndoMove removes the top disc from a column (for bot calculations)
 func (b *Board) UndoMove(column int) {
-	for row := 0; row < Rows; row++ {
+	for row := 0; row < b.rows; row++ {
 		if b.cells[row][column] != Empty {
-			b.cells[row][column] = Empty
+			b.setCellUnsafe(row, column, Empty)
 			return
 		}
 	}
 }
 
+// RemoveTopDisc removes the top disc from a column, returning the row it
+// was on, or -1 if the column is empty
+func (b *Board) RemoveTopDisc(column int) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if column < 0 || column >= b.columns {
+		return -1
+	}
+	for row := 0; row < b.rows; row++ {
+		if b.cells[row][column] != Empty {
+			b.setCellUnsafe(row, column, Empty)
+			return row
+		}
+	}
+	return -1
+}
+
 // CheckWin checks if the specified player has won
 func (b *Board) CheckWin(player int) bool {
 	b.mu.RLock()
@@ -120,57 +232,94 @@ func (b *Board) CheckWin(player int) bool {
 	return b.checkWinUnsafe(player)
 }
 
-// checkWinUnsafe checks win without locking
+// checkWinUnsafe checks win without locking, using the player's bitboard
 func (b *Board) checkWinUnsafe(player int) bool {
-	// Check horizontal
-	for row := 0; row < Rows; row++ {
-		for col := 0; col <= Columns-4; col++ {
-			if b.cells[row][col] == player &&
-				b.cells[row][col+1] == player &&
-				b.cells[row][col+2] == player &&
-				b.cells[row][col+3] == player {
-				return true
-			}
-		}
+	if player != Player1 && player != Player2 {
+		return false
 	}
+	return b.hasLineUnsafe(b.bits[player])
+}
 
-	// Check vertical
-	for row := 0; row <= Rows-4; row++ {
-		for col := 0; col < Columns; col++ {
-			if b.cells[row][col] == player &&
-				b.cells[row+1][col] == player &&
-				b.cells[row+2][col] == player &&
-				b.cells[row+3][col] == player {
-				return true
-			}
-		}
+// CheckWinFromCell checks whether the disc at row, col completes a winning
+// line for the player. Only the four lines through that cell are examined.
+func (b *Board) CheckWinFromCell(row, col, player int) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.checkWinFromCellUnsafe(row, col, player)
+}
+
+// checkWinFromCellUnsafe checks a localized win without locking
+func (b *Board) checkWinFromCellUnsafe(row, col, player int) bool {
+	if !b.inBounds(row, col) || b.cells[row][col] != player {
+		return false
 	}
 
-	// Check diagonal (down-right)
-	for row := 0; row <= Rows-4; row++ {
-		for col := 0; col <= Columns-4; col++ {
-			if b.cells[row][col] == player &&
-				b.cells[row+1][col+1] == player &&
-				b.cells[row+2][col+2] == player &&
-				b.cells[row+3][col+3] == player {
-				return true
-			}
+	for _, d := range winDirections {
+		count := 1
+		for r, c := row+d[0], col+d[1]; b.inBounds(r, c) && b.cells[r][c] == player; r, c = r+d[0], c+d[1] {
+			count++
+		}
+		for r, c := row-d[0], col-d[1]; b.inBounds(r, c) && b.cells[r][c] == player; r, c = r-d[0], c-d[1] {
+			count++
+		}
+		if count >= b.winLength {
+			return true
 		}
 	}
+	return false
+}
+
+// inBounds reports whether a cell is on the board
+func (b *Board) inBounds(row, col int) bool {
+	return row >= 0 && row < b.rows && col >= 0 && col < b.columns
+}
 
-	// Check diagonal (up-right)
-	for row := 3; row < Rows; row++ {
-		for col := 0; col <= Columns-4; col++ {
-			if b.cells[row][col] == player &&
-				b.cells[row-1][col+1] == player &&
-				b.cells[row-2][col+2] == player &&
-				b.cells[row-3][col+3] == player {
-				return true
+// winDirections are the row/column steps of the four line directions:
+// horizontal, vertical, diagonal down-right and diagonal up-right
+var winDirections = [4][2]int{{0, 1}, {1, 0}, {1, 1}, {-1, 1}}
+
+// WinningCells returns the cells of every winning line (win length or more
+// discs) for the player. Each line is listed from its leftmost cell (topmost for vertical
+// lines) and lines appear in board scan order, so the result is stable. A
+// cell shared by two lines is returned once.
+func (b *Board) WinningCells(player int) []MoveInfo {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	var cells []MoveInfo
+	seen := make(map[[2]int]bool)
+
+	for row := 0; row < b.rows; row++ {
+		for col := 0; col < b.columns; col++ {
+			if b.cells[row][col] != player {
+				continue
+			}
+			for _, d := range winDirections {
+				// Only start at the first disc of a run
+				pr, pc := row-d[0], col-d[1]
+				if b.inBounds(pr, pc) && b.cells[pr][pc] == player {
+					continue
+				}
+
+				var line []MoveInfo
+				for r, c := row, col; b.inBounds(r, c) && b.cells[r][c] == player; r, c = r+d[0], c+d[1] {
+					line = append(line, MoveInfo{Column: c, Row: r})
+				}
+				if len(line) < b.winLength {
+					continue
+				}
+				for _, cell := range line {
+					key := [2]int{cell.Row, cell.Column}
+					if !seen[key] {
+						seen[key] = true
+						cells = append(cells, cell)
+					}
+				}
 			}
 		}
 	}
 
-	return false
+	return cells
 }
 
 // IsFull checks if the board is completely full (draw condition)
@@ -182,7 +331,7 @@ func (b *Board) IsFull() bool {
 
 // isFullUnsafe checks if board is full without locking
 func (b *Board) isFullUnsafe() bool {
-	for col := 0; col < Columns; col++ {
+	for col := 0; col < b.columns; col++ {
 		if b.cells[0][col] == Empty {
 			return false
 		}
@@ -199,8 +348,8 @@ func (b *Board) GetValidColumns() []int {
 
 // getValidColumnsUnsafe returns valid columns without locking
 func (b *Board) getValidColumnsUnsafe() []int {
-	validCols := make([]int, 0, Columns)
-	for col := 0; col < Columns; col++ {
+	validCols := make([]int, 0, b.columns)
+	for col := 0; col < b.columns; col++ {
 		if b.cells[0][col] == Empty {
 			validCols = append(validCols, col)
 		}
@@ -212,7 +361,7 @@ func (b *Board) getValidColumnsUnsafe() []int {
 func (b *Board) IsColumnValid(column int) bool {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
-	return column >= 0 && column < Columns && b.cells[0][column] == Empty
+	return column >= 0 && column < b.columns && b.cells[0][column] == Empty
 }
 
 // ToSlice converts the board to a 2D slice for JSON serialization
@@ -220,12 +369,9 @@ func (b *Board) ToSlice() [][]int {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
-	result := make([][]int, Rows)
-	for i := 0; i < Rows; i++ {
-		result[i] = make([]int, Columns)
-		for j := 0; j < Columns; j++ {
-			result[i][j] = b.cells[i][j]
-		}
+	result := make([][]int, b.rows)
+	for i := range b.cells {
+		result[i] = append([]int(nil), b.cells[i]...)
 	}
 	return result
 }
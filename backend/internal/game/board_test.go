@@ -0,0 +1,311 @@
+package game
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// testSizes are the board shapes the property tests play on: the standard
+// board and ones that need both bitboard words or a longer or shorter line
+var testSizes = []BoardSize{
+	StandardSize,
+	{Rows: 4, Columns: 4, WinLength: 3},
+	{Rows: 7, Columns: 8, WinLength: 4},
+	{Rows: 10, Columns: 10, WinLength: 5},
+	{Rows: 9, Columns: 6, WinLength: 6},
+}
+
+// arrayHasLine reports whether player has winLength in a row by scanning
+// every cell of the array in every direction, independently of the
+// bitboards
+func arrayHasLine(b *Board, player int) bool {
+	for row := 0; row < b.rows; row++ {
+		for col := 0; col < b.columns; col++ {
+			for _, d := range winDirections {
+				n := 0
+				for r, c := row, col; b.inBounds(r, c) && b.cells[r][c] == player; r, c = r+d[0], c+d[1] {
+					n++
+				}
+				if n >= b.winLength {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// checkBitsMatchCells fails unless the bitboards hold exactly the cells
+func checkBitsMatchCells(t *testing.T, b *Board) {
+	t.Helper()
+	var want [3]bitboard
+	for row := range b.cells {
+		for col, cell := range b.cells[row] {
+			if cell != Empty {
+				want[cell] = want[cell].or(b.cellBit(row, col))
+			}
+		}
+	}
+	if b.bits != want {
+		t.Fatalf("bitboards %v out of step with cells %v", b.bits, b.ToSlice())
+	}
+}
+
+func TestBitboardMatchesArrayWinDetection(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, size := range testSizes {
+		positions := 0
+		for game := 0; game < 300; game++ {
+			b := NewBoardOfSize(size)
+			player := Player1
+			// Play on past wins, so positions where both players have a
+			// line are covered too
+			for len(b.GetValidColumns()) > 0 {
+				valid := b.GetValidColumns()
+				if _, err := b.DropDisc(valid[rng.Intn(len(valid))], player); err != nil {
+					t.Fatal(err)
+				}
+				positions++
+				for _, p := range []int{Player1, Player2} {
+					if got, want := b.CheckWin(p), arrayHasLine(b, p); got != want {
+						t.Fatalf("%s: CheckWin(%d) = %v, array scan %v on %v", size, p, got, want, b.ToSlice())
+					}
+				}
+				player = 3 - player
+			}
+			checkBitsMatchCells(t, b)
+		}
+		if positions < 1000 {
+			t.Errorf("%s: only %d positions checked", size, positions)
+		}
+	}
+}
+
+func TestCheckWinFromCellMatchesFullCheck(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for _, size := range testSizes {
+		for game := 0; game < 500; game++ {
+			b := NewBoardOfSize(size)
+			player := Player1
+			for len(b.GetValidColumns()) > 0 {
+				valid := b.GetValidColumns()
+				col := valid[rng.Intn(len(valid))]
+				row, err := b.DropDisc(col, player)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				// Until someone wins, the last disc wins exactly when the
+				// whole board has a line
+				full := b.CheckWin(player)
+				if got := b.CheckWinFromCell(row, col, player); got != full {
+					t.Fatalf("%s: CheckWinFromCell(%d, %d) = %v, CheckWin = %v on %v", size, row, col, got, full, b.ToSlice())
+				}
+				if b.CheckWinFromCell(row, col, 3-player) {
+					t.Fatalf("%s: opponent wins from a cell they don't hold", size)
+				}
+				if full {
+					break
+				}
+				player = 3 - player
+			}
+		}
+	}
+}
+
+func TestCompletesLineMatchesDrop(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	for _, size := range testSizes {
+		for game := 0; game < 200; game++ {
+			b := NewBoardOfSize(size)
+			player := Player1
+			for len(b.GetValidColumns()) > 0 && !b.CheckWin(Player1) && !b.CheckWin(Player2) {
+				for _, col := range b.GetValidColumns() {
+					row, _ := b.DropDiscUnsafe(col, player)
+					want := b.checkWinUnsafe(player)
+					b.UndoMove(col)
+					if got := b.completesLineUnsafe(b.bits[player], row, col); got != want {
+						t.Fatalf("%s: completesLine(%d, %d) = %v, dropping there wins = %v on %v", size, row, col, got, want, b.ToSlice())
+					}
+				}
+				valid := b.GetValidColumns()
+				b.DropDisc(valid[rng.Intn(len(valid))], player)
+				player = 3 - player
+			}
+		}
+	}
+}
+
+func TestUndoKeepsBitboardsInStep(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	for _, size := range testSizes {
+		b := NewBoardOfSize(size)
+		var played []int
+		player := Player1
+		for step := 0; step < 2000; step++ {
+			valid := b.GetValidColumns()
+			if len(played) > 0 && (len(valid) == 0 || rng.Intn(3) == 0) {
+				col := played[len(played)-1]
+				played = played[:len(played)-1]
+				if rng.Intn(2) == 0 {
+					b.UndoMove(col)
+				} else if b.RemoveTopDisc(col) < 0 {
+					t.Fatalf("%s: RemoveTopDisc(%d) found no disc", size, col)
+				}
+				player = 3 - player
+			} else {
+				col := valid[rng.Intn(len(valid))]
+				if _, err := b.DropDisc(col, player); err != nil {
+					t.Fatal(err)
+				}
+				played = append(played, col)
+				player = 3 - player
+			}
+
+			checkBitsMatchCells(t, b)
+			if b.MoveCount() != len(played) {
+				t.Fatalf("%s: move count %d after %d moves", size, b.MoveCount(), len(played))
+			}
+			for _, p := range []int{Player1, Player2} {
+				if b.CheckWin(p) != arrayHasLine(b, p) {
+					t.Fatalf("%s: CheckWin(%d) disagrees with the cells after an undo", size, p)
+				}
+			}
+		}
+
+		clone := b.Clone()
+		checkBitsMatchCells(t, clone)
+		if clone.bits != b.bits {
+			t.Errorf("%s: clone bitboards differ", size)
+		}
+	}
+}
+
+// BenchmarkBotSearch measures a fixed-depth search from a middlegame
+// position, reporting the positions visited per second alongside ns/op
+func BenchmarkBotSearch(b *testing.B) {
+	moves, err := FromNotation("4453 3526")
+	if err != nil {
+		b.Fatal(err)
+	}
+	board := NewBoard()
+	for _, m := range moves {
+		board.DropDisc(m.Column, m.PlayerNum)
+	}
+	bot := NewBotWithDepth(Player1, 7, WithRand(NewRand(1)))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bot.search(board.Clone())
+	}
+	b.ReportMetric(float64(bot.nodes.Load())/b.Elapsed().Seconds(), "nodes/s")
+}
+
+// boardOf sets up a board from rows written top first, with . for empty,
+// without checking the position could arise in play
+func boardOf(t *testing.T, rows ...string) *Board {
+	t.Helper()
+	b := NewBoard()
+	for row, cells := range parseGrid(t, rows...) {
+		for col, cell := range cells {
+			b.setCellUnsafe(row, col, cell)
+		}
+	}
+	return b
+}
+
+func TestWinningCells(t *testing.T) {
+	tests := []struct {
+		name string
+		rows []string
+		want []MoveInfo
+	}{
+		{"horizontal", []string{
+			".......",
+			".......",
+			".......",
+			".......",
+			".......",
+			".1111..",
+		}, []MoveInfo{{1, 5}, {2, 5}, {3, 5}, {4, 5}}},
+		{"vertical", []string{
+			".......",
+			".......",
+			"......1",
+			"......1",
+			"......1",
+			"......1",
+		}, []MoveInfo{{6, 2}, {6, 3}, {6, 4}, {6, 5}}},
+		{"diagonal down", []string{
+			"1......",
+			".1.....",
+			"..1....",
+			"...1...",
+			".......",
+			".......",
+		}, []MoveInfo{{0, 0}, {1, 1}, {2, 2}, {3, 3}}},
+		{"diagonal up", []string{
+			".......",
+			".......",
+			"......1",
+			".....1.",
+			"....1..",
+			"...1...",
+		}, []MoveInfo{{3, 5}, {4, 4}, {5, 3}, {6, 2}}},
+		{"five in a row", []string{
+			".......",
+			".......",
+			".......",
+			".......",
+			".......",
+			"11111..",
+		}, []MoveInfo{{0, 5}, {1, 5}, {2, 5}, {3, 5}, {4, 5}}},
+		{"crossing lines", []string{
+			".......",
+			".......",
+			"...1...",
+			"...1...",
+			"...1...",
+			"1111...",
+		}, []MoveInfo{{3, 2}, {3, 3}, {3, 4}, {3, 5}, {0, 5}, {1, 5}, {2, 5}}},
+		{"three only", []string{
+			".......",
+			".......",
+			".......",
+			".......",
+			"1......",
+			"111.1..",
+		}, nil},
+	}
+	for _, tt := range tests {
+		b := boardOf(t, tt.rows...)
+		if got := b.WinningCells(Player1); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: WinningCells = %v, want %v", tt.name, got, tt.want)
+		}
+		if got := b.WinningCells(Player2); got != nil {
+			t.Errorf("%s: player 2 has winning cells %v", tt.name, got)
+		}
+	}
+}
+
+func TestWinningCellsInGameState(t *testing.T) {
+	g := newPlayingGame(t)
+	for _, col := range []int{0, 6, 1, 6, 2, 6, 3} {
+		if _, err := g.MakeMove(g.CurrentTurn, col); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []MoveInfo{{0, 5}, {1, 5}, {2, 5}, {3, 5}}
+	if state := g.GetState(); !reflect.DeepEqual(state.WinningCells, want) {
+		t.Errorf("finished state winning cells = %v, want %v", state.WinningCells, want)
+	}
+
+	// Taking the winning move back clears them
+	if err := g.UndoLastMoves(1); err != nil {
+		t.Fatal(err)
+	}
+	if cells := g.GetState().WinningCells; cells != nil {
+		t.Errorf("reopened game still has winning cells %v", cells)
+	}
+}
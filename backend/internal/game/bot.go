@@ -1,32 +1,175 @@
 package game
 
 import (
+	"fmt"
 	"math"
-	"math/rand"
+	"slices"
+	"sync/atomic"
+	"time"
 )
 
+// BotVersion identifies the engine revision. Bump it with every change to the
+// search or evaluation so bot-game stats can be compared across versions.
+const BotVersion = "2"
+
+// EngineParams are the effective settings a bot plays with
+type EngineParams struct {
+	Version     string        `json:"version"`
+	Depth       int           `json:"depth"`
+	TimeBudget  time.Duration `json:"timeBudget"`
+	Personality string        `json:"personality"`
+	BookEnabled bool          `json:"bookEnabled"`
+}
+
+// String encodes the parameters as a compact identifier, e.g.
+// "v1/depth=5/time=0s/personality=medium/book=false"
+func (p EngineParams) String() string {
+	return fmt.Sprintf("v%s/depth=%d/time=%s/personality=%s/book=%t",
+		p.Version, p.Depth, p.TimeBudget, p.Personality, p.BookEnabled)
+}
+
+// botSearches counts every GetBestMove call across all bots
+var botSearches atomic.Int64
+
+// BotSearchCount returns the total number of bot move searches
+func BotSearchCount() int64 {
+	return botSearches.Load()
+}
+
+// Difficulty controls how strong the bot plays
+type Difficulty string
+
+const (
+	DifficultyEasy   Difficulty = "easy"
+	DifficultyMedium Difficulty = "medium"
+	DifficultyHard   Difficulty = "hard"
+)
+
+// easyRandomMoveChance is how often the easy bot plays a random column
+const easyRandomMoveChance = 0.3
+
+// ParseDifficulty returns the difficulty for a name, defaulting to medium when empty
+func ParseDifficulty(name string) (Difficulty, bool) {
+	switch Difficulty(name) {
+	case "":
+		return DifficultyMedium, true
+	case DifficultyEasy, DifficultyMedium, DifficultyHard:
+		return Difficulty(name), true
+	}
+	return "", false
+}
+
 // Bot represents the AI player
 type Bot struct {
-	player   int
-	opponent int
-	maxDepth int
+	player     int
+	opponent   int
+	maxDepth   int
+	difficulty Difficulty
+	rng        *Rand
+	nodes      atomic.Int64 // positions minimax has visited
+}
+
+// BotOption customises a bot created by NewBot
+type BotOption func(*Bot)
+
+// WithRand makes the bot draw its random moves from rng
+func WithRand(rng *Rand) BotOption {
+	return func(bot *Bot) {
+		bot.rng = rng
+	}
+}
+
+// NewBotWithSeed creates a bot whose random moves are fixed by seed
+func NewBotWithSeed(player int, difficulty Difficulty, seed int64) *Bot {
+	return NewBot(player, difficulty, WithRand(NewRand(seed)))
 }
 
-// NewBot creates a new bot instance
-func NewBot(player int) *Bot {
+// NewBot creates a new bot instance. Without WithRand it uses a time-seeded source.
+func NewBot(player int, difficulty Difficulty, opts ...BotOption) *Bot {
 	opponent := Player1
 	if player == Player1 {
 		opponent = Player2
 	}
-	return &Bot{
-		player:   player,
-		opponent: opponent,
-		maxDepth: 5, // Search depth for minimax
+
+	// Search depth for minimax
+	maxDepth := 5
+	switch difficulty {
+	case DifficultyEasy:
+		maxDepth = 2
+	case DifficultyHard:
+		maxDepth = 7
+	default:
+		difficulty = DifficultyMedium
+	}
+
+	bot := &Bot{
+		player:     player,
+		opponent:   opponent,
+		maxDepth:   maxDepth,
+		difficulty: difficulty,
 	}
+	for _, opt := range opts {
+		opt(bot)
+	}
+	if bot.rng == nil {
+		bot.rng = NewTimeSeededRand()
+	}
+	return bot
 }
 
+// MaxSearchDepth is the deepest search NewBotWithDepth allows
+const MaxSearchDepth = 9
+
+// NewBotWithDepth creates a medium-personality bot that searches depth
+// plies, clamped to 1..MaxSearchDepth
+func NewBotWithDepth(player, depth int, opts ...BotOption) *Bot {
+	bot := NewBot(player, DifficultyMedium, opts...)
+	bot.maxDepth = max(1, min(depth, MaxSearchDepth))
+	return bot
+}
+
+// Difficulty returns the bot's difficulty level
+func (bot *Bot) Difficulty() Difficulty {
+	return bot.difficulty
+}
+
+// Params returns the engine parameters the bot plays with
+func (bot *Bot) Params() EngineParams {
+	return EngineParams{
+		Version:     BotVersion,
+		Depth:       bot.maxDepth,
+		Personality: string(bot.difficulty),
+	}
+}
+
+// winScore is the minimax score of a won position, before the depth bonus
+const winScore = 10000
+
 // GetBestMove returns the best column to play using minimax with alpha-beta pruning
 func (bot *Bot) GetBestMove(board *Board) int {
+	botSearches.Add(1)
+
+	// Easy bots occasionally play a random column
+	if bot.difficulty == DifficultyEasy && bot.rng.Float64() < easyRandomMoveChance {
+		if col := GetRandomValidMove(board, bot.rng); col >= 0 {
+			return col
+		}
+	}
+
+	col, _ := bot.search(board)
+	return col
+}
+
+// BestMoveWithScore returns the best column for the bot's side along with its
+// evaluation: positive favours the bot, and scores beyond ±10000 are forced
+// wins or losses. It never plays randomly, whatever the difficulty.
+func (bot *Bot) BestMoveWithScore(board *Board) (int, int) {
+	botSearches.Add(1)
+	return bot.search(board)
+}
+
+// search runs the full move search on a clone of board
+func (bot *Bot) search(board *Board) (int, int) {
 	// Clone the board for calculations
 	b := board.Clone()
 
@@ -36,7 +179,7 @@ func (bot *Bot) GetBestMove(board *Board) int {
 		b.DropDiscUnsafe(col, bot.player)
 		if b.checkWinUnsafe(bot.player) {
 			b.UndoMove(col)
-			return col
+			return col, winScore + bot.maxDepth
 		}
 		b.UndoMove(col)
 	}
@@ -46,7 +189,11 @@ func (bot *Bot) GetBestMove(board *Board) int {
 		b.DropDiscUnsafe(col, bot.opponent)
 		if b.checkWinUnsafe(bot.opponent) {
 			b.UndoMove(col)
-			return col // Block the winning move
+			// Block the winning move, scored as the position after blocking
+			b.DropDiscUnsafe(col, bot.player)
+			score := bot.minimax(b, bot.maxDepth-1, math.MinInt32, math.MaxInt32, false)
+			b.UndoMove(col)
+			return col, score
 		}
 		b.UndoMove(col)
 	}
@@ -55,19 +202,8 @@ func (bot *Bot) GetBestMove(board *Board) int {
 	bestScore := math.MinInt32
 	bestCol := validCols[0]
 
-	// Prefer center column for tie-breaking
-	columnOrder := []int{3, 2, 4, 1, 5, 0, 6}
-	orderedCols := make([]int, 0, len(validCols))
-	for _, col := range columnOrder {
-		for _, validCol := range validCols {
-			if col == validCol {
-				orderedCols = append(orderedCols, col)
-				break
-			}
-		}
-	}
-
-	for _, col := range orderedCols {
+	// Prefer center columns for tie-breaking
+	for _, col := range centerFirst(validCols, b.columns) {
 		b.DropDiscUnsafe(col, bot.player)
 		score := bot.minimax(b, bot.maxDepth-1, math.MinInt32, math.MaxInt32, false)
 		b.UndoMove(col)
@@ -78,20 +214,75 @@ func (bot *Bot) GetBestMove(board *Board) int {
 		}
 	}
 
-	return bestCol
+	return bestCol, bestScore
+}
+
+// centerFirst orders columns by distance from the middle of a board
+// columns wide, the left one first on ties: 3 2 4 1 5 0 6 on seven columns
+func centerFirst(cols []int, columns int) []int {
+	ordered := slices.Clone(cols)
+	slices.SortStableFunc(ordered, func(a, b int) int {
+		return centerDistance(a, columns) - centerDistance(b, columns)
+	})
+	return ordered
 }
 
-// minimax implements the minimax algorithm with alpha-beta pruning
+// centerDistance is twice a column's distance from the middle of the board
+func centerDistance(col, columns int) int {
+	d := 2*col - (columns - 1)
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// ColumnScore is the bot's evaluation of playing one column
+type ColumnScore struct {
+	Column int `json:"column"`
+	Score  int `json:"score"`
+}
+
+// ScoreColumns evaluates every valid column for the bot's side in column
+// order, searching each to the bot's full depth
+func (bot *Bot) ScoreColumns(board *Board) []ColumnScore {
+	botSearches.Add(1)
+
+	b := board.Clone()
+	validCols := b.getValidColumnsUnsafe()
+	scores := make([]ColumnScore, 0, len(validCols))
+	for _, col := range validCols {
+		b.DropDiscUnsafe(col, bot.player)
+		score := bot.minimax(b, bot.maxDepth-1, math.MinInt32, math.MaxInt32, false)
+		b.UndoMove(col)
+		scores = append(scores, ColumnScore{Column: col, Score: score})
+	}
+	return scores
+}
+
+// IsForcedWin reports whether a search score means a win within the search depth
+func IsForcedWin(score int) bool {
+	return score >= winScore
+}
+
+// IsForcedLoss reports whether a search score means a loss within the search depth
+func IsForcedLoss(score int) bool {
+	return score <= -winScore
+}
+
+// minimax implements the minimax algorithm with alpha-beta pruning.
+// Win checks use the bitboards, so terminal detection is a few shifts.
 func (bot *Bot) minimax(board *Board, depth int, alpha, beta int, isMaximizing bool) int {
-	// Terminal conditions
-	if board.checkWinUnsafe(bot.player) {
-		return 10000 + depth // Prefer winning sooner
+	bot.nodes.Add(1)
+
+	// Terminal conditions: the previous mover is the opposite of whoever moves now
+	if !isMaximizing && board.checkWinUnsafe(bot.player) {
+		return winScore + depth // Prefer winning sooner
 	}
-	if board.checkWinUnsafe(bot.opponent) {
-		return -10000 - depth // Prefer losing later
+	if isMaximizing && board.checkWinUnsafe(bot.opponent) {
+		return -winScore - depth // Prefer losing later
 	}
 	if board.isFullUnsafe() || depth == 0 {
-		return bot.evaluateBoard(board)
+		return bot.evaluateBoard(board, isMaximizing)
 	}
 
 	validCols := board.getValidColumnsUnsafe()
@@ -130,126 +321,143 @@ func (bot *Bot) minimax(board *Board, depth int, alpha, beta int, isMaximizing b
 	}
 }
 
-// evaluateBoard scores the current board position for the bot
-func (bot *Bot) evaluateBoard(board *Board) int {
-	score := 0
+const (
+	// forkScore is the score of a position one side is bound to win within
+	// two moves. It ranks below an actual win.
+	forkScore = winScore / 2
+
+	// stackedThreatScore rewards a stacked threat further up a column, the
+	// shape behind the "seven trap"
+	stackedThreatScore = 50
+)
+
+// evaluateBoard scores the current board position for the bot; botToMove
+// says whose turn it is
+func (bot *Bot) evaluateBoard(board *Board, botToMove bool) int {
+	botNow, botForced, botStacked := board.threatsUnsafe(bot.player)
+	oppNow, oppForced, oppStacked := board.threatsUnsafe(bot.opponent)
+
+	// The side to move wins with any immediate threat; otherwise it loses
+	// when the other side has two, or a stacked pair it can't block
+	moverNow, waiterNow, waiterForced, sign := botNow, oppNow, oppForced, 1
+	if !botToMove {
+		moverNow, waiterNow, waiterForced, sign = oppNow, botNow, botForced, -1
+	}
+	if moverNow > 0 {
+		return sign * forkScore
+	}
+	if waiterNow >= 2 || waiterForced > 0 {
+		return -sign * forkScore
+	}
+
+	score := (botStacked - oppStacked) * stackedThreatScore
 
 	// Score center column (strategic advantage)
-	centerCol := Columns / 2
+	centerCol := board.columns / 2
 	centerCount := 0
-	for row := 0; row < Rows; row++ {
+	for row := 0; row < board.rows; row++ {
 		if board.cells[row][centerCol] == bot.player {
 			centerCount++
 		}
 	}
 	score += centerCount * 3
 
-	// Score all windows of 4
+	// Score all windows of the win length
 	score += bot.scoreAllWindows(board)
 
 	return score
 }
 
-// scoreAllWindows evaluates all possible 4-cell windows
+// scoreAllWindows evaluates every window of win length cells in a line:
+// horizontal, vertical and both diagonals
 func (bot *Bot) scoreAllWindows(board *Board) int {
 	score := 0
-
-	// Horizontal windows
-	for row := 0; row < Rows; row++ {
-		for col := 0; col <= Columns-4; col++ {
-			window := [4]int{
-				board.cells[row][col],
-				board.cells[row][col+1],
-				board.cells[row][col+2],
-				board.cells[row][col+3],
-			}
-			score += bot.scoreWindow(window)
+	n := board.winLength
+	for _, d := range winDirections {
+		// The cells a window can start from and stay on the board
+		rowFrom, rowTo := 0, board.rows
+		switch {
+		case d[0] > 0:
+			rowTo -= n - 1
+		case d[0] < 0:
+			rowFrom = n - 1
 		}
-	}
-
-	// Vertical windows
-	for row := 0; row <= Rows-4; row++ {
-		for col := 0; col < Columns; col++ {
-			window := [4]int{
-				board.cells[row][col],
-				board.cells[row+1][col],
-				board.cells[row+2][col],
-				board.cells[row+3][col],
+		colTo := board.columns - d[1]*(n-1)
+
+		for row := rowFrom; row < rowTo; row++ {
+			for col := 0; col < colTo; col++ {
+				var counts [3]int
+				for i, r, c := 0, row, col; i < n; i, r, c = i+1, r+d[0], c+d[1] {
+					counts[board.cells[r][c]]++
+				}
+				score += bot.scoreWindow(counts, n)
 			}
-			score += bot.scoreWindow(window)
 		}
 	}
-
-	// Diagonal (down-right) windows
-	for row := 0; row <= Rows-4; row++ {
-		for col := 0; col <= Columns-4; col++ {
-			window := [4]int{
-				board.cells[row][col],
-				board.cells[row+1][col+1],
-				board.cells[row+2][col+2],
-				board.cells[row+3][col+3],
-			}
-			score += bot.scoreWindow(window)
-		}
-	}
-
-	// Diagonal (up-right) windows
-	for row := 3; row < Rows; row++ {
-		for col := 0; col <= Columns-4; col++ {
-			window := [4]int{
-				board.cells[row][col],
-				board.cells[row-1][col+1],
-				board.cells[row-2][col+2],
-				board.cells[row-3][col+3],
-			}
-			score += bot.scoreWindow(window)
-		}
-	}
-
 	return score
 }
 
-// scoreWindow evaluates a window of 4 cells
-func (bot *Bot) scoreWindow(window [4]int) int {
-	botCount := 0
-	oppCount := 0
-	emptyCount := 0
-
-	for _, cell := range window {
-		switch cell {
-		case bot.player:
-			botCount++
-		case bot.opponent:
-			oppCount++
-		case Empty:
-			emptyCount++
-		}
-	}
+// scoreWindow evaluates a window of n cells from how many of them are
+// empty and how many each player holds, indexed by player number
+func (bot *Bot) scoreWindow(counts [3]int, n int) int {
+	botCount := counts[bot.player]
+	oppCount := counts[bot.opponent]
+	emptyCount := counts[Empty]
 
 	// Scoring heuristics
-	if botCount == 4 {
+	if botCount == n {
 		return 100
 	}
-	if botCount == 3 && emptyCount == 1 {
+	if botCount == n-1 && emptyCount == 1 {
 		return 5
 	}
-	if botCount == 2 && emptyCount == 2 {
+	if botCount == n-2 && emptyCount == 2 {
 		return 2
 	}
 
 	// Penalize opponent threats
-	if oppCount == 3 && emptyCount == 1 {
+	if oppCount == n-1 && emptyCount == 1 {
 		return -4
 	}
 
 	return 0
 }
 
-// GetRandomValidMove returns a random valid column (fallback)
-func GetRandomValidMove(board *Board) int {
+// threatsUnsafe counts the player's threats. immediate is how many columns
+// they could drop into and win right now. A stacked threat is a pair of
+// empty cells directly above each other that would both complete a line:
+// blocking the lower one hands over the upper one. forced counts stacked
+// pairs whose lower cell is playable now, stacked the ones higher up.
+func (b *Board) threatsUnsafe(player int) (immediate, forced, stacked int) {
+	discs := b.bits[player]
+	for col := 0; col < b.columns; col++ {
+		playable := b.rows - 1
+		for playable >= 0 && b.cells[playable][col] != Empty {
+			playable--
+		}
+
+		below := false
+		for row := playable; row >= 0; row-- {
+			threat := b.completesLineUnsafe(discs, row, col)
+			switch {
+			case threat && row == playable:
+				immediate++
+			case threat && below && row == playable-1:
+				forced++
+			case threat && below:
+				stacked++
+			}
+			below = threat
+		}
+	}
+	return immediate, forced, stacked
+}
+
+// GetRandomValidMove returns a random valid column drawn from rng (fallback)
+func GetRandomValidMove(board *Board, rng *Rand) int {
 	validCols := board.GetValidColumns()
 	if len(validCols) == 0 {
 		return -1
 	}
-	return validCols[rand.Intn(len(validCols))]
+	return validCols[rng.Intn(len(validCols))]
 }
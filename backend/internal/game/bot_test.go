@@ -0,0 +1,139 @@
+package game
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEngineParamsString(t *testing.T) {
+	tests := []struct {
+		params EngineParams
+		want   string
+	}{
+		{EngineParams{}, "v/depth=0/time=0s/personality=/book=false"},
+		{
+			EngineParams{Version: "2", Depth: 7, TimeBudget: 1500 * time.Millisecond, Personality: "hard", BookEnabled: true},
+			"v2/depth=7/time=1.5s/personality=hard/book=true",
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.params.String(); got != tt.want {
+			t.Errorf("%+v.String() = %q, want %q", tt.params, got, tt.want)
+		}
+	}
+}
+
+func TestBotParams(t *testing.T) {
+	tests := []struct {
+		difficulty  Difficulty
+		depth       int
+		personality string
+	}{
+		{DifficultyEasy, 2, "easy"},
+		{DifficultyMedium, 5, "medium"},
+		{DifficultyHard, 7, "hard"},
+		{"", 5, "medium"},
+	}
+	encoded := make(map[string]bool)
+	for _, tt := range tests {
+		p := NewBotWithSeed(Player2, tt.difficulty, 1).Params()
+		if p.Version != BotVersion || p.Depth != tt.depth || p.Personality != tt.personality {
+			t.Errorf("%q bot params = %+v, want version %s depth %d personality %s", tt.difficulty, p, BotVersion, tt.depth, tt.personality)
+		}
+		encoded[p.String()] = true
+	}
+	// The default is medium, so the unnamed difficulty adds no encoding
+	if len(encoded) != 3 {
+		t.Errorf("difficulties encode to %d distinct parameter strings, want 3", len(encoded))
+	}
+
+	if p := NewBotWithDepth(Player2, 99).Params(); p.Depth != MaxSearchDepth {
+		t.Errorf("depth 99 bot params depth = %d, want %d", p.Depth, MaxSearchDepth)
+	}
+}
+
+func TestBotVersionInGameState(t *testing.T) {
+	g := NewGame("alice")
+	g.AddBot(DifficultyHard)
+	state := g.GetState()
+	if state.BotVersion != g.Bot.Params().String() {
+		t.Errorf("state bot version = %q, want %q", state.BotVersion, g.Bot.Params().String())
+	}
+	if !strings.HasPrefix(state.BotVersion, "v"+BotVersion+"/") {
+		t.Errorf("state bot version %q does not start with the engine version", state.BotVersion)
+	}
+
+	human := newPlayingGame(t)
+	if v := human.GetState().BotVersion; v != "" {
+		t.Errorf("human game bot version = %q, want none", v)
+	}
+}
+
+// TestBotRefutesForks plays positions where the opponent threatens to build
+// two threats at once, and which the engine before double-threat scoring
+// lost. safe lists the columns after which the opponent can't force a win
+// within nine plies.
+func TestBotRefutesForks(t *testing.T) {
+	tests := []struct {
+		notation string
+		depth    int
+		safe     []int
+	}{
+		{"4741775", 2, []int{2, 5}},
+		{"47743", 2, []int{1, 4}},
+		{"7374", 2, []int{1, 4, 6}},
+		{"44575", 2, []int{1, 2, 5}},
+		{"6116436614", 2, []int{0, 2}},
+		{"4741775", 5, []int{2, 5}},
+		{"24331415", 5, []int{4, 6}},
+		{"641325444", 5, []int{0, 1, 2, 4}},
+		{"6116436614", 5, []int{0, 2}},
+		{"6116436614", 7, []int{0, 2}},
+	}
+	for _, tt := range tests {
+		moves, err := FromNotation(tt.notation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		board := NewBoard()
+		for _, m := range moves {
+			board.DropDisc(m.Column, m.PlayerNum)
+		}
+		bot := NewBotWithDepth(board.NextPlayer(), tt.depth, WithRand(NewRand(1)))
+		if col := bot.GetBestMove(board); !slices.Contains(tt.safe, col) {
+			t.Errorf("%s at depth %d: played column %d, want one of %v", tt.notation, tt.depth, col, tt.safe)
+		}
+	}
+}
+
+func TestThreats(t *testing.T) {
+	// Player 1 has open threes on the bottom two rows: both ends of the
+	// bottom row win now, and the cells above them win next
+	b := NewBoard()
+	for _, col := range []int{1, 2, 3, 1, 2, 3} {
+		b.DropDisc(col, Player1)
+	}
+	immediate, forced, stacked := b.threatsUnsafe(Player1)
+	if immediate != 2 || forced != 2 || stacked != 0 {
+		t.Errorf("stacked open threes: threats = %d, %d, %d; want 2, 2, 0", immediate, forced, stacked)
+	}
+	if immediate, forced, stacked := b.threatsUnsafe(Player2); immediate+forced+stacked != 0 {
+		t.Errorf("player 2 has threats %d, %d, %d on an empty side", immediate, forced, stacked)
+	}
+
+	// Raised a row on player 2's discs, the pairs start a row above the
+	// playable cells at either end
+	b = NewBoard()
+	for _, col := range []int{1, 2, 3} {
+		b.DropDisc(col, Player2)
+	}
+	for _, col := range []int{1, 2, 3, 1, 2, 3} {
+		b.DropDisc(col, Player1)
+	}
+	immediate, forced, stacked = b.threatsUnsafe(Player1)
+	if immediate != 0 || forced != 0 || stacked != 2 {
+		t.Errorf("raised open threes: threats = %d, %d, %d; want 0, 0, 2", immediate, forced, stacked)
+	}
+}
@@ -0,0 +1,129 @@
+package game
+
+import "time"
+
+// DrawOfferTimeout is how long a draw offer stands before it lapses
+const DrawOfferTimeout = 30 * time.Second
+
+// DrawReason says how a game came to be drawn
+type DrawReason string
+
+const (
+	DrawBoardFull DrawReason = "boardFull" // nobody won before the board filled up
+	DrawDead      DrawReason = "dead"      // nobody could win any more, see Board.IsDeadDraw
+	DrawAgreed    DrawReason = "agreed"    // the players agreed to a draw
+)
+
+// OfferDraw offers the opponent a draw. Each game has at most one offer
+// standing at a time, and none in bot games. It returns when the offer
+// lapses; onExpire runs then if nobody answered it and the game hasn't
+// ended.
+func (g *Game) OfferDraw(playerNum int, onExpire func()) (time.Time, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.Status != StatusPlaying {
+		return time.Time{}, ErrGameNotInProgress
+	}
+	if playerNum != Player1 && playerNum != Player2 {
+		return time.Time{}, ErrPlayerNotFound
+	}
+	if g.botPlayerLocked() != 0 {
+		return time.Time{}, ErrDrawOffersDisabled
+	}
+	now := time.Now()
+	if g.drawOfferLocked(now) != 0 {
+		return time.Time{}, ErrDrawOfferPending
+	}
+
+	expires := now.Add(DrawOfferTimeout)
+	g.clearDrawOfferLocked()
+	g.drawOffer = playerNum
+	g.drawOfferExpires = expires
+	g.drawOfferTimer = time.AfterFunc(DrawOfferTimeout, func() {
+		if g.ExpireDrawOffer(playerNum, expires) && onExpire != nil {
+			onExpire()
+		}
+	})
+	g.version++
+	return expires, nil
+}
+
+// AnswerDraw accepts or declines the draw playerNum's opponent offered.
+// Accepting ends the game as a draw. It returns who made the offer.
+func (g *Game) AnswerDraw(playerNum int, accept bool) (int, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	offerer := g.drawOfferLocked(time.Now())
+	if offerer == 0 || offerer == playerNum {
+		return 0, ErrNoDrawOffer
+	}
+	if g.Status != StatusPlaying {
+		return 0, ErrGameNotInProgress
+	}
+
+	g.clearDrawOfferLocked()
+	g.version++
+	if accept {
+		g.endInDrawLocked(DrawAgreed)
+	}
+	return offerer, nil
+}
+
+// ExpireDrawOffer withdraws playerNum's draw offer if it is still standing
+// at its deadline, reporting whether it did
+func (g *Game) ExpireDrawOffer(playerNum int, expires time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.drawOffer != playerNum || !g.drawOfferExpires.Equal(expires) {
+		return false
+	}
+	g.clearDrawOfferLocked()
+	g.version++
+	return true
+}
+
+// clearDrawOfferLocked withdraws any draw offer and stops its expiry
+// timer; caller holds the lock
+func (g *Game) clearDrawOfferLocked() {
+	g.drawOffer = 0
+	if g.drawOfferTimer != nil {
+		g.drawOfferTimer.Stop()
+		g.drawOfferTimer = nil
+	}
+}
+
+// drawOfferLocked returns who has a draw offer standing at now, 0 if
+// nobody; caller holds the lock
+func (g *Game) drawOfferLocked(now time.Time) int {
+	if g.drawOffer == 0 || !now.Before(g.drawOfferExpires) {
+		return 0
+	}
+	return g.drawOffer
+}
+
+// EndInDraw ends a game in progress as a draw for reason, as when the
+// players agree to one before the board is full
+func (g *Game) EndInDraw(reason DrawReason) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.Status != StatusPlaying && g.Status != StatusDisconnect {
+		return ErrGameNotInProgress
+	}
+	g.endInDrawLocked(reason)
+	return nil
+}
+
+// endInDrawLocked finishes the game as a draw; caller holds the lock
+func (g *Game) endInDrawLocked(reason DrawReason) {
+	g.Status = StatusFinished
+	g.EndTime = time.Now()
+	g.Result = ResultDraw
+	g.DrawReason = reason
+	g.clearDrawOfferLocked()
+	g.endReconnectWaitLocked()
+	g.version++
+}
@@ -0,0 +1,106 @@
+package game
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// offerDraw has alice offer bob a draw and returns the offer's timer
+func offerDraw(t *testing.T, g *Game) *time.Timer {
+	t.Helper()
+	if _, err := g.OfferDraw(Player1, func() { t.Error("an offer that was dealt with expired") }); err != nil {
+		t.Fatalf("OfferDraw: %v", err)
+	}
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	if g.drawOfferTimer == nil {
+		t.Fatal("offer has no expiry timer")
+	}
+	return g.drawOfferTimer
+}
+
+func TestDrawOfferTimerStops(t *testing.T) {
+	tests := []struct {
+		name string
+		end  func(t *testing.T, g *Game)
+	}{
+		{"declined", func(t *testing.T, g *Game) {
+			if _, err := g.AnswerDraw(Player2, false); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"accepted", func(t *testing.T, g *Game) {
+			if _, err := g.AnswerDraw(Player2, true); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"moved on", func(t *testing.T, g *Game) {
+			if _, err := g.MakeMove(Player1, 3); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"drawn", func(t *testing.T, g *Game) {
+			if err := g.EndInDraw(DrawDead); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"resigned", func(t *testing.T, g *Game) {
+			if err := g.Resign(Player2); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"aborted", func(t *testing.T, g *Game) { g.Abort() }},
+		{"expired", func(t *testing.T, g *Game) {
+			g.mu.RLock()
+			expires := g.drawOfferExpires
+			g.mu.RUnlock()
+			if !g.ExpireDrawOffer(Player1, expires) {
+				t.Fatal("offer didn't expire")
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newPlayingGame(t)
+			timer := offerDraw(t, g)
+			tt.end(t, g)
+
+			if timer.Stop() {
+				t.Error("expiry timer still running")
+			}
+			g.mu.RLock()
+			offer, pending := g.drawOffer, g.drawOfferTimer
+			g.mu.RUnlock()
+			if offer != 0 || pending != nil {
+				t.Errorf("offer %d and timer %v left behind", offer, pending)
+			}
+		})
+	}
+}
+
+func TestDrawOfferOnePerGame(t *testing.T) {
+	g := newPlayingGame(t)
+	timer := offerDraw(t, g)
+	if _, err := g.OfferDraw(Player2, nil); !errors.Is(err, ErrDrawOfferPending) {
+		t.Fatalf("second offer: err = %v, want ErrDrawOfferPending", err)
+	}
+	if _, err := g.AnswerDraw(Player1, true); !errors.Is(err, ErrNoDrawOffer) {
+		t.Errorf("offerer answering their own offer: err = %v, want ErrNoDrawOffer", err)
+	}
+
+	// Once declined, the other player may offer, with a timer of their own
+	if _, err := g.AnswerDraw(Player2, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.OfferDraw(Player2, nil); err != nil {
+		t.Fatalf("offer after a decline: %v", err)
+	}
+	g.mu.RLock()
+	second := g.drawOfferTimer
+	g.mu.RUnlock()
+	if second == nil || second == timer {
+		t.Error("the new offer has no timer of its own")
+	}
+	g.Abort()
+}
@@ -1,6 +1,10 @@
 package game
 
 import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -25,14 +29,19 @@ const (
 	ResultWinPlayer2 GameResult = "player2_win"
 	ResultDraw       GameResult = "draw"
 	ResultForfeit    GameResult = "forfeit"
+	ResultResign     GameResult = "resign"
+	ResultAbandoned  GameResult = "abandoned" // the player left on purpose
+	ResultAborted    GameResult = "aborted"   // interrupted by a server shutdown or left idle, no winner
 )
 
 // Player represents a player in the game
 type Player struct {
 	Username    string
-	PlayerNum   int  // Player1 or Player2
+	PlayerNum   int // Player1 or Player2
 	IsBot       bool
 	IsConnected bool
+	DiscEmoji   string // validated single emoji shown to the opponent
+	AvatarURL   string // validated https avatar URL, not persisted
 }
 
 // Move represents a single move in the game
@@ -41,6 +50,28 @@ type Move struct {
 	Column    int       `json:"column"`
 	Row       int       `json:"row"`
 	Timestamp time.Time `json:"timestamp"`
+	ThinkMs   int64     `json:"thinkMs,omitempty"` // since the previous move, or play start for the first
+	Bot       bool      `json:"bot,omitempty"`     // played by the bot
+}
+
+// DefaultTurnTimeout is how long a player has to make each move
+const DefaultTurnTimeout = 30 * time.Second
+
+// DefaultReconnectWindow is how long a disconnected player has to come back
+const DefaultReconnectWindow = 30 * time.Second
+
+// MaxHintsPerGame is how many hints each player may ask for in one game
+const MaxHintsPerGame = 3
+
+// DefaultMaxTakebacks is how many takebacks each player may have accepted
+// in one friendly game
+const DefaultMaxTakebacks = 2
+
+// Hint is a suggested move for the player whose turn it is
+type Hint struct {
+	Column    int `json:"column"`
+	Score     int `json:"score"`     // positive favours the requesting player
+	Remaining int `json:"remaining"` // hints left for this player in the game
 }
 
 // Game represents a Connect Four game instance
@@ -50,20 +81,48 @@ type Game struct {
 	Player2            *Player
 	Board              *Board
 	CurrentTurn        int // Player1 or Player2
+	FirstPlayer        int // seat that moves first, Player1 unless SetFirstPlayer chose otherwise
 	Status             GameStatus
 	Winner             *Player
 	Result             GameResult
+	ForfeitedBy        int        // player who forfeited or resigned, 0 otherwise
+	DrawReason         DrawReason // how a drawn game was drawn
 	Moves              []Move
-	StartTime          time.Time
+	StartTime          time.Time // when the game was created
+	PlayStartedAt      time.Time // when both seats were filled and play began
 	EndTime            time.Time
 	DisconnectTime     time.Time
 	DisconnectedPlayer int
 	Bot                *Bot
+	Imported           bool          // played outside this server and imported from notation
+	Simulated          bool          // bot-vs-bot simulation, never stored or counted in analytics
+	Friendly           bool          // unrated game between people, where takebacks are allowed
+	MaxTakebacks       int           // takebacks each player may have accepted, zero disables them
+	TurnTimeout        time.Duration // zero disables the turn clock
+	TurnStartedAt      time.Time
+	ReconnectWindow    time.Duration // grace period for a disconnected player
+	turnRemaining      time.Duration // clock left for the stalled turn while disconnected
+	version            int           // bumped on every state change, exposed as StateVersion
+	WinningCells       []MoveInfo    // the connected line(s) when the game was won on the board
+	hintsUsed          [3]int        // hints taken, indexed by player number
+	takebacksUsed      [3]int        // takebacks accepted, indexed by player number
+	takebackPending    int           // player asking for a takeback, 0 if nobody is
+	drawOffer          int           // player offering a draw, 0 if nobody is
+	drawOfferExpires   time.Time     // when the draw offer lapses
+	drawOfferTimer     *time.Timer   // expires the standing draw offer
+	botSearching       bool          // MakeBotMove is searching with the lock released
+	reconnectWait      chan struct{} // closed when the disconnect wait ends early
+	now                func() time.Time
 	mu                 sync.RWMutex
 }
 
-// NewGame creates a new game instance
+// NewGame creates a new game instance on the standard board
 func NewGame(player1Username string) *Game {
+	return NewGameOfSize(player1Username, StandardSize)
+}
+
+// NewGameOfSize creates a new game instance on a board of a validated size
+func NewGameOfSize(player1Username string, size BoardSize) *Game {
 	return &Game{
 		ID: uuid.New().String(),
 		Player1: &Player{
@@ -72,14 +131,39 @@ func NewGame(player1Username string) *Game {
 			IsBot:       false,
 			IsConnected: true,
 		},
-		Board:       NewBoard(),
-		CurrentTurn: Player1,
-		Status:      StatusWaiting,
-		Moves:       make([]Move, 0),
-		StartTime:   time.Now(),
+		Board:           NewBoardOfSize(size),
+		CurrentTurn:     Player1,
+		FirstPlayer:     Player1,
+		Status:          StatusWaiting,
+		Moves:           make([]Move, 0),
+		StartTime:       time.Now(),
+		TurnTimeout:     DefaultTurnTimeout,
+		ReconnectWindow: DefaultReconnectWindow,
+		MaxTakebacks:    DefaultMaxTakebacks,
+		now:             time.Now,
 	}
 }
 
+// NewImportedGame rebuilds a game played elsewhere from its move list
+func NewImportedGame(player1Username, player2Username string, moves []Move) (*Game, error) {
+	g := NewGame(player1Username)
+	g.AddPlayer2(player2Username, false)
+	g.Imported = true
+	if len(moves) > 0 {
+		if err := g.SetFirstPlayer(moves[0].PlayerNum); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, m := range moves {
+		if _, err := g.MakeMove(m.PlayerNum, m.Column); err != nil {
+			return nil, err
+		}
+	}
+
+	return g, nil
+}
+
 // AddPlayer2 adds the second player to the game
 func (g *Game) AddPlayer2(username string, isBot bool) {
 	g.mu.Lock()
@@ -92,17 +176,121 @@ func (g *Game) AddPlayer2(username string, isBot bool) {
 		IsConnected: true,
 	}
 	g.Status = StatusPlaying
+	g.PlayStartedAt = time.Now()
+	g.TurnStartedAt = g.PlayStartedAt
+	g.version++
 
 	if isBot {
-		g.Bot = NewBot(Player2)
+		g.Bot = NewBot(Player2, DifficultyMedium)
+	}
+}
+
+// AddBot adds a bot as the second player with the given difficulty
+func (g *Game) AddBot(difficulty Difficulty, opts ...BotOption) {
+	g.AddPlayer2("BOT", true)
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.Bot = NewBot(Player2, difficulty, opts...)
+}
+
+// AddBotAsPlayer1 seats a bot with the given difficulty as Player1 and moves
+// the waiting human to Player2, so the bot makes the first move
+func (g *Game) AddBotAsPlayer1(difficulty Difficulty, opts ...BotOption) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	human := g.Player1
+	human.PlayerNum = Player2
+	g.Player2 = human
+	g.Player1 = &Player{
+		Username:    "BOT",
+		PlayerNum:   Player1,
+		IsBot:       true,
+		IsConnected: true,
+	}
+	g.Bot = NewBot(Player1, difficulty, opts...)
+	g.Status = StatusPlaying
+	g.PlayStartedAt = time.Now()
+	g.TurnStartedAt = g.PlayStartedAt
+	g.version++
+}
+
+// SetFirstPlayer picks the seat that moves first. It can only be changed
+// before the first move.
+func (g *Game) SetFirstPlayer(playerNum int) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if playerNum != Player1 && playerNum != Player2 {
+		return ErrPlayerNotFound
+	}
+	if len(g.Moves) > 0 {
+		return ErrGameAlreadyStarted
 	}
+	g.FirstPlayer = playerNum
+	g.CurrentTurn = playerNum
+	g.version++
+	return nil
+}
+
+// BotPlayer returns the seat the bot plays, or 0 in a game between humans
+func (g *Game) BotPlayer() int {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.botPlayerLocked()
+}
+
+// botPlayerLocked returns the bot's seat; caller holds the lock
+func (g *Game) botPlayerLocked() int {
+	switch {
+	case g.Player1 != nil && g.Player1.IsBot:
+		return Player1
+	case g.Player2 != nil && g.Player2.IsBot:
+		return Player2
+	}
+	return 0
+}
+
+// SetCosmetics sets the disc emoji and avatar shown for a player
+func (g *Game) SetCosmetics(playerNum int, discEmoji, avatarURL string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	player := g.Player1
+	if playerNum == Player2 {
+		player = g.Player2
+	}
+	if player == nil {
+		return
+	}
+	player.DiscEmoji = discEmoji
+	player.AvatarURL = avatarURL
 }
 
 // MakeMove makes a move for the specified player
 func (g *Game) MakeMove(playerNum, column int) (int, error) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
+	return g.makeMoveLocked(playerNum, column)
+}
 
+// MakeMoveIfVersion makes a move only if the game is still at expectedVersion.
+// The version check and the move are applied atomically under the game lock,
+// so a client acting on a stale state gets ErrVersionConflict instead of a
+// move applied to a position it never saw.
+func (g *Game) MakeMoveIfVersion(playerNum, column, expectedVersion int) (int, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.version != expectedVersion {
+		return -1, ErrVersionConflict
+	}
+	return g.makeMoveLocked(playerNum, column)
+}
+
+// makeMoveLocked applies a move; caller holds the lock
+func (g *Game) makeMoveLocked(playerNum, column int) (int, error) {
 	if g.Status != StatusPlaying {
 		return -1, ErrGameNotInProgress
 	}
@@ -116,18 +304,32 @@ func (g *Game) MakeMove(playerNum, column int) (int, error) {
 		return -1, err
 	}
 
-	// Record the move
+	// Record the move, timed from the previous one
+	now := time.Now()
+	thinkFrom := g.playStartLocked()
+	if len(g.Moves) > 0 {
+		thinkFrom = g.Moves[len(g.Moves)-1].Timestamp
+	}
 	g.Moves = append(g.Moves, Move{
 		PlayerNum: playerNum,
 		Column:    column,
 		Row:       row,
-		Timestamp: time.Now(),
+		Timestamp: now,
+		ThinkMs:   max(now.Sub(thinkFrom).Milliseconds(), 0),
+		Bot:       playerNum == g.botPlayerLocked(),
 	})
+	g.version++
+
+	// Moving on instead of answering declines a takeback request or a
+	// draw offer
+	g.takebackPending = 0
+	g.clearDrawOfferLocked()
 
 	// Check for win
-	if g.Board.CheckWin(playerNum) {
+	if g.Board.CheckWinFromCell(row, column, playerNum) {
 		g.Status = StatusFinished
 		g.EndTime = time.Now()
+		g.WinningCells = g.Board.WinningCells(playerNum)
 		if playerNum == Player1 {
 			g.Winner = g.Player1
 			g.Result = ResultWinPlayer1
@@ -138,11 +340,14 @@ func (g *Game) MakeMove(playerNum, column int) (int, error) {
 		return row, nil
 	}
 
-	// Check for draw
+	// Check for draw. Imported games are replayed to their recorded end,
+	// so only live games stop at a dead draw.
 	if g.Board.IsFull() {
-		g.Status = StatusFinished
-		g.EndTime = time.Now()
-		g.Result = ResultDraw
+		g.endInDrawLocked(DrawBoardFull)
+		return row, nil
+	}
+	if !g.Imported && g.Board.IsDeadDraw() {
+		g.endInDrawLocked(DrawDead)
 		return row, nil
 	}
 
@@ -152,24 +357,39 @@ func (g *Game) MakeMove(playerNum, column int) (int, error) {
 	} else {
 		g.CurrentTurn = Player1
 	}
+	g.TurnStartedAt = time.Now()
 
 	return row, nil
 }
 
-// MakeBotMove makes a move for the bot
+// MakeBotMove makes a move for the bot. The bot searches a copy of the
+// board taken under the game lock, so the game isn't locked while it
+// thinks, and the move is refused with ErrVersionConflict if the game
+// changed in the meantime. Comparing the state version rather than the
+// move count also catches a takeback followed by a new move.
 func (g *Game) MakeBotMove() (int, int, error) {
 	g.mu.Lock()
-	if g.Bot == nil || g.CurrentTurn != Player2 {
+	seat := g.botPlayerLocked()
+	if g.Bot == nil || seat == 0 || g.CurrentTurn != seat {
 		g.mu.Unlock()
 		return -1, -1, ErrNotYourTurn
 	}
+	board := g.Board.Clone()
+	version := g.version
+	g.botSearching = true
 	g.mu.Unlock()
 
 	// Get best move from bot
-	column := g.Bot.GetBestMove(g.Board)
+	column := g.Bot.GetBestMove(board)
 
-	// Make the move
-	row, err := g.MakeMove(Player2, column)
+	// Make the move, if the position is still the one searched
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.botSearching = false
+	if g.version != version {
+		return -1, -1, ErrVersionConflict
+	}
+	row, err := g.makeMoveLocked(seat, column)
 	return column, row, err
 }
 
@@ -182,9 +402,14 @@ func (g *Game) PlayerDisconnected(playerNum int) {
 		return
 	}
 
+	// Pause the turn clock until the player is back
+	g.turnRemaining = g.turnRemainingLocked(time.Now())
+
 	g.DisconnectedPlayer = playerNum
-	g.DisconnectTime = time.Now()
+	g.DisconnectTime = g.now()
 	g.Status = StatusDisconnect
+	g.reconnectWait = make(chan struct{})
+	g.version++
 
 	if playerNum == Player1 {
 		g.Player1.IsConnected = false
@@ -198,18 +423,45 @@ func (g *Game) PlayerReconnected(playerNum int) bool {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
+	// A game restored after a restart has its players marked disconnected
+	// without a reconnect window running; they just take their seat back,
+	// with a full turn clock if it's their move
+	var player *Player
+	switch playerNum {
+	case Player1:
+		player = g.Player1
+	case Player2:
+		player = g.Player2
+	}
+	if player != nil && !player.IsConnected && g.DisconnectedPlayer != playerNum &&
+		(g.Status == StatusPlaying || g.Status == StatusDisconnect) {
+		player.IsConnected = true
+		if g.Status == StatusPlaying && g.CurrentTurn == playerNum {
+			g.TurnStartedAt = time.Now()
+		}
+		g.version++
+		return true
+	}
+
 	if g.Status != StatusDisconnect || g.DisconnectedPlayer != playerNum {
 		return false
 	}
 
-	// Check if within 30-second window
-	if time.Since(g.DisconnectTime) > 30*time.Second {
+	// Reconnecting exactly at the deadline still counts
+	if g.now().After(g.reconnectDeadlineLocked()) {
 		return false
 	}
 
 	g.Status = StatusPlaying
 	g.DisconnectedPlayer = 0
 	g.DisconnectTime = time.Time{}
+	g.endReconnectWaitLocked()
+	g.version++
+
+	// Resume the turn clock where it was paused
+	if g.TurnTimeout > 0 {
+		g.TurnStartedAt = time.Now().Add(g.turnRemaining - g.TurnTimeout)
+	}
 
 	if playerNum == Player1 {
 		g.Player1.IsConnected = true
@@ -220,14 +472,330 @@ func (g *Game) PlayerReconnected(playerNum int) bool {
 	return true
 }
 
+// IsPlayerConnected reports whether playerNum is connected to an unfinished
+// game. It is per seat: while the opponent is disconnected, the other
+// player is still connected.
+func (g *Game) IsPlayerConnected(playerNum int) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	switch g.Status {
+	case StatusPlaying:
+	case StatusDisconnect:
+		if g.DisconnectedPlayer == playerNum {
+			return false
+		}
+	default:
+		return false
+	}
+	switch playerNum {
+	case Player1:
+		return g.Player1 != nil && g.Player1.IsConnected
+	case Player2:
+		return g.Player2 != nil && g.Player2.IsConnected
+	}
+	return false
+}
+
+// ReconnectDeadline returns when the disconnected player forfeits, or the
+// zero time if nobody is disconnected
+func (g *Game) ReconnectDeadline() time.Time {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	if g.Status != StatusDisconnect {
+		return time.Time{}
+	}
+	return g.reconnectDeadlineLocked()
+}
+
+// reconnectDeadlineLocked returns the end of the reconnect window; caller holds the lock
+func (g *Game) reconnectDeadlineLocked() time.Time {
+	return g.DisconnectTime.Add(g.ReconnectWindow)
+}
+
+// ForfeitIfDisconnected forfeits the game for playerNum only if they are
+// still the disconnected player and their reconnect window, ending at
+// deadline, has passed. A reconnect exactly at the deadline still counts,
+// so the forfeit is refused until the clock is after it. The check and the
+// forfeit happen under one lock so a reconnect can't slip in between.
+func (g *Game) ForfeitIfDisconnected(playerNum int, deadline time.Time) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.Status != StatusDisconnect || g.DisconnectedPlayer != playerNum {
+		return false
+	}
+	if !g.reconnectDeadlineLocked().Equal(deadline) || !g.now().After(deadline) {
+		return false
+	}
+	g.forfeitLocked(playerNum)
+	return true
+}
+
+// ForfeitIfStillOnTurn forfeits the game for playerNum only if it is still
+// their turn after moveCount moves and their clock has run out. The check
+// and the forfeit happen under one lock so a move can't slip in between.
+func (g *Game) ForfeitIfStillOnTurn(playerNum, moveCount int) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.Status != StatusPlaying || g.CurrentTurn != playerNum || len(g.Moves) != moveCount {
+		return false
+	}
+	if g.TurnTimeout > 0 && g.turnRemainingLocked(time.Now()) > 0 {
+		return false
+	}
+	g.forfeitLocked(playerNum)
+	return true
+}
+
+// ReconnectWait returns a channel that is closed once the current disconnect
+// wait ends because the player came back or the game ended. It is nil when
+// no player is disconnected.
+func (g *Game) ReconnectWait() <-chan struct{} {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.reconnectWait
+}
+
+// endReconnectWaitLocked releases anyone waiting on ReconnectWait
+func (g *Game) endReconnectWaitLocked() {
+	if g.reconnectWait != nil {
+		close(g.reconnectWait)
+		g.reconnectWait = nil
+	}
+}
+
+// Hint suggests a move for playerNum on their turn. Each player gets
+// MaxHintsPerGame hints; the search runs on a copy of the board with the
+// game unlocked.
+func (g *Game) Hint(playerNum int) (*Hint, error) {
+	g.mu.Lock()
+	if g.Status != StatusPlaying {
+		g.mu.Unlock()
+		return nil, ErrGameNotInProgress
+	}
+	if playerNum != Player1 && playerNum != Player2 {
+		g.mu.Unlock()
+		return nil, ErrPlayerNotFound
+	}
+	if g.CurrentTurn != playerNum {
+		g.mu.Unlock()
+		return nil, ErrNotYourTurn
+	}
+	if g.hintsUsed[playerNum] >= MaxHintsPerGame {
+		g.mu.Unlock()
+		return nil, ErrNoHintsLeft
+	}
+	g.hintsUsed[playerNum]++
+	remaining := MaxHintsPerGame - g.hintsUsed[playerNum]
+	board := g.Board.Clone()
+	g.mu.Unlock()
+
+	column, score := NewBot(playerNum, DifficultyMedium).BestMoveWithScore(board)
+	return &Hint{Column: column, Score: score, Remaining: remaining}, nil
+}
+
+// RequestTakeback asks the opponent to undo playerNum's last move, and
+// their reply to it if they have moved since. Takebacks are only allowed
+// in friendly games, up to MaxTakebacks accepted per player. It returns how
+// many moves accepting would undo.
+func (g *Game) RequestTakeback(playerNum int) (int, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.Status != StatusPlaying {
+		return 0, ErrGameNotInProgress
+	}
+	if playerNum != Player1 && playerNum != Player2 {
+		return 0, ErrPlayerNotFound
+	}
+	if !g.Friendly || g.botPlayerLocked() != 0 || g.MaxTakebacks <= 0 {
+		return 0, ErrTakebacksDisabled
+	}
+	if g.takebacksUsed[playerNum] >= g.MaxTakebacks {
+		return 0, ErrNoTakebacksLeft
+	}
+	if g.takebackPending != 0 {
+		return 0, ErrTakebackPending
+	}
+	n := g.takebackLengthLocked(playerNum)
+	if n == 0 {
+		return 0, ErrNothingToTakeBack
+	}
+
+	g.takebackPending = playerNum
+	g.version++
+	return n, nil
+}
+
+// AnswerTakeback accepts or declines the takeback playerNum's opponent
+// asked for. Accepting undoes the moves and counts against the requester's
+// takebacks. It returns the requester and how many moves were undone.
+func (g *Game) AnswerTakeback(playerNum int, accept bool) (requester, undone int, err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	requester = g.takebackPending
+	if requester == 0 || requester == playerNum {
+		return 0, 0, ErrNoTakebackPending
+	}
+	if g.Status != StatusPlaying {
+		return 0, 0, ErrGameNotInProgress
+	}
+	g.takebackPending = 0
+	g.version++
+	if !accept {
+		return requester, 0, nil
+	}
+
+	undone = g.takebackLengthLocked(requester)
+	if err := g.undoLastMovesLocked(undone); err != nil {
+		return 0, 0, err
+	}
+	g.takebacksUsed[requester]++
+	return requester, undone, nil
+}
+
+// takebackLengthLocked returns how many moves take back playerNum's last
+// move: one if it was the last move, two if the opponent has replied, zero
+// if playerNum hasn't moved; caller holds the lock
+func (g *Game) takebackLengthLocked(playerNum int) int {
+	for n := 1; n <= 2 && n <= len(g.Moves); n++ {
+		if g.Moves[len(g.Moves)-n].PlayerNum == playerNum {
+			return n
+		}
+	}
+	return 0
+}
+
+// UndoLastMoves takes back the last n moves: their discs, the move list and
+// the turn, which goes back to whoever made the earliest of them. A game
+// those moves won or drew is reopened; one that ended any other way can't
+// be undone.
+func (g *Game) UndoLastMoves(n int) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.undoLastMovesLocked(n)
+}
+
+// undoLastMovesLocked implements UndoLastMoves; caller holds the lock
+func (g *Game) undoLastMovesLocked(n int) error {
+	if n <= 0 || n > len(g.Moves) {
+		return ErrNothingToTakeBack
+	}
+	if g.Status == StatusFinished {
+		switch g.Result {
+		case ResultWinPlayer1, ResultWinPlayer2, ResultDraw:
+		default:
+			return ErrGameNotInProgress
+		}
+	} else if g.Status != StatusPlaying {
+		return ErrGameNotInProgress
+	}
+
+	for i := 0; i < n; i++ {
+		last := g.Moves[len(g.Moves)-1]
+		g.Board.RemoveTopDisc(last.Column)
+		g.Moves = g.Moves[:len(g.Moves)-1]
+		g.CurrentTurn = last.PlayerNum
+	}
+
+	if g.Status == StatusFinished {
+		g.Status = StatusPlaying
+		g.Winner = nil
+		g.Result = ""
+		g.DrawReason = ""
+		g.EndTime = time.Time{}
+		g.WinningCells = nil
+	}
+	g.TurnStartedAt = time.Now()
+	g.version++
+	return nil
+}
+
 // Forfeit ends the game with a forfeit
 func (g *Game) Forfeit(loserPlayerNum int) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
+	g.forfeitLocked(loserPlayerNum)
+}
+
+// Resign concedes the game for playerNum. The opponent may be disconnected,
+// but a finished game can't be resigned.
+func (g *Game) Resign(playerNum int) error {
+	return g.concede(playerNum, ResultResign)
+}
+
+// Abandon ends the game with playerNum losing because they left it on
+// purpose rather than losing their connection
+func (g *Game) Abandon(playerNum int) error {
+	return g.concede(playerNum, ResultAbandoned)
+}
+
+// concede ends an unfinished game with playerNum losing and result as the reason
+func (g *Game) concede(playerNum int, result GameResult) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.Status != StatusPlaying && g.Status != StatusDisconnect {
+		return ErrGameNotInProgress
+	}
+	if playerNum != Player1 && playerNum != Player2 {
+		return ErrPlayerNotFound
+	}
+
+	g.forfeitLocked(playerNum)
+	g.Result = result
+	return nil
+}
+
+// ForfeitStalled ends an abandoned game by forfeiting the player whose turn
+// it is, or the human if the bot was due to move. It returns the loser, or 0
+// if the game was not in progress.
+func (g *Game) ForfeitStalled() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.Status != StatusPlaying && g.Status != StatusDisconnect {
+		return 0
+	}
+	loser := g.CurrentTurn
+	if loser == g.botPlayerLocked() {
+		loser = Player1 + Player2 - loser
+	}
+	g.forfeitLocked(loser)
+	return loser
+}
+
+// Abort ends a game in progress without a winner, e.g. because the server
+// is shutting down or nobody has moved for too long. It returns false if the game was not in progress.
+func (g *Game) Abort() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
 
+	if g.Status != StatusPlaying && g.Status != StatusDisconnect {
+		return false
+	}
+	g.Status = StatusFinished
+	g.EndTime = time.Now()
+	g.Result = ResultAborted
+	g.clearDrawOfferLocked()
+	g.endReconnectWaitLocked()
+	g.version++
+	return true
+}
+
+// forfeitLocked ends the game with loserPlayerNum losing; caller holds the lock
+func (g *Game) forfeitLocked(loserPlayerNum int) {
 	g.Status = StatusFinished
 	g.EndTime = time.Now()
 	g.Result = ResultForfeit
+	g.ForfeitedBy = loserPlayerNum
+	g.clearDrawOfferLocked()
+	g.endReconnectWaitLocked()
+	g.version++
 
 	if loserPlayerNum == Player1 {
 		g.Winner = g.Player2
@@ -236,43 +804,203 @@ func (g *Game) Forfeit(loserPlayerNum int) {
 	}
 }
 
+// TurnRemaining returns how much of the current turn's clock is left and the
+// move count it applies to. ok is false when the game is not being played or
+// the clock is disabled.
+func (g *Game) TurnRemaining() (remaining time.Duration, moveCount int, ok bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	if g.Status != StatusPlaying || g.TurnTimeout <= 0 {
+		return 0, len(g.Moves), false
+	}
+	return g.turnRemainingLocked(time.Now()), len(g.Moves), true
+}
+
+// turnRemainingLocked computes the turn clock at now; caller holds the lock
+func (g *Game) turnRemainingLocked(now time.Time) time.Duration {
+	if g.Status == StatusDisconnect {
+		return g.turnRemaining
+	}
+	remaining := g.TurnTimeout - now.Sub(g.TurnStartedAt)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
 // GetState returns the current game state for serialization
 func (g *Game) GetState() *GameState {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
+	return g.stateLocked()
+}
+
+// GetStateWithHistory returns the current game state with every move
+// played so far, for clients catching up on a game
+func (g *Game) GetStateWithHistory() *GameState {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	state := g.stateLocked()
+	state.Moves = slices.Clone(g.Moves)
+	return state
+}
 
+// stateLocked builds the game state without the move history; caller
+// holds the lock
+func (g *Game) stateLocked() *GameState {
 	state := &GameState{
-		ID:          g.ID,
-		Board:       g.Board.ToSlice(),
-		CurrentTurn: g.CurrentTurn,
-		Status:      g.Status,
-		MoveCount:   len(g.Moves),
+		ID:           g.ID,
+		Board:        g.Board.ToSlice(),
+		BoardSize:    g.Board.Size(),
+		CurrentTurn:  g.CurrentTurn,
+		FirstPlayer:  g.FirstPlayer,
+		Friendly:     g.Friendly,
+		Status:       g.Status,
+		MoveCount:    len(g.Moves),
+		StateVersion: g.version,
 	}
 
 	if g.Player1 != nil {
 		state.Player1 = g.Player1.Username
+		state.Player1DiscEmoji = g.Player1.DiscEmoji
+		state.Player1AvatarURL = g.Player1.AvatarURL
 	}
 	if g.Player2 != nil {
 		state.Player2 = g.Player2.Username
-		state.IsVsBot = g.Player2.IsBot
+		state.Player2DiscEmoji = g.Player2.DiscEmoji
+		state.Player2AvatarURL = g.Player2.AvatarURL
+	}
+	state.BotPlayer = g.botPlayerLocked()
+	state.IsVsBot = state.BotPlayer != 0
+	state.TakebackPending = g.takebackPending
+	state.DrawOffer = g.drawOfferLocked(time.Now())
+	state.DrawReason = g.DrawReason
+	if g.Bot != nil {
+		state.BotDifficulty = string(g.Bot.Difficulty())
+		state.BotVersion = g.Bot.Params().String()
 	}
 	if g.Winner != nil {
 		state.Winner = g.Winner.Username
 	}
+	switch {
+	case g.ForfeitedBy == Player1 && g.Player1 != nil:
+		state.ForfeitedBy = g.Player1.Username
+	case g.ForfeitedBy == Player2 && g.Player2 != nil:
+		state.ForfeitedBy = g.Player2.Username
+	}
 	if len(g.Moves) > 0 {
 		lastMove := g.Moves[len(g.Moves)-1]
 		state.LastMove = &MoveInfo{
 			Column: lastMove.Column,
 			Row:    lastMove.Row,
 		}
+		movedAt := lastMove.Timestamp
+		state.LastMoveAt = &movedAt
+	}
+	if g.Status == StatusPlaying || g.Status == StatusDisconnect {
+		if g.CurrentTurn == Player1 {
+			state.CurrentTurnUsername = state.Player1
+		} else {
+			state.CurrentTurnUsername = state.Player2
+		}
 	}
 	if g.Result != "" {
 		state.Result = string(g.Result)
 	}
+	if len(g.WinningCells) > 0 {
+		state.WinningCells = append([]MoveInfo(nil), g.WinningCells...)
+	}
+	state.WinType = g.winTypeLocked()
+	if g.TurnTimeout > 0 && (g.Status == StatusPlaying || g.Status == StatusDisconnect) {
+		state.TurnSecondsRemaining = int(g.turnRemainingLocked(time.Now()).Round(time.Second).Seconds())
+	}
 
 	return state
 }
 
+// GameSummary is the short description of a live game used in listings
+type GameSummary struct {
+	ID             string     `json:"id"`
+	Player1        string     `json:"player1"`
+	Player2        string     `json:"player2"`
+	MoveCount      int        `json:"moveCount"`
+	Status         GameStatus `json:"status"`
+	IsVsBot        bool       `json:"isVsBot"`
+	StartedAt      time.Time  `json:"startedAt"`
+	ElapsedSeconds int        `json:"elapsedSeconds"`
+}
+
+// Summary returns the game's listing entry
+func (g *Game) Summary() GameSummary {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	summary := GameSummary{
+		ID:        g.ID,
+		MoveCount: len(g.Moves),
+		Status:    g.Status,
+		IsVsBot:   g.botPlayerLocked() != 0,
+		StartedAt: g.playStartLocked(),
+	}
+	if g.Player1 != nil {
+		summary.Player1 = g.Player1.Username
+	}
+	if g.Player2 != nil {
+		summary.Player2 = g.Player2.Username
+	}
+	end := g.EndTime
+	if end.IsZero() {
+		end = time.Now()
+	}
+	summary.ElapsedSeconds = int(end.Sub(summary.StartedAt).Seconds())
+	return summary
+}
+
+// GameDebug is an internal-state snapshot of a game for post-incident debugging
+type GameDebug struct {
+	State                *GameState `json:"state"`
+	CreatedAt            time.Time  `json:"createdAt"`
+	EndedAt              *time.Time `json:"endedAt,omitempty"`
+	DisconnectedPlayer   int        `json:"disconnectedPlayer,omitempty"`
+	DisconnectedSeconds  int        `json:"disconnectedSeconds,omitempty"`
+	TurnRemainingSeconds float64    `json:"turnRemainingSeconds"`
+	TurnTimeoutSeconds   float64    `json:"turnTimeoutSeconds"`
+	HasBot               bool       `json:"hasBot"`
+	BotMovePending       bool       `json:"botMovePending"` // the bot is on turn in a game being played
+	BotSearching         bool       `json:"botSearching"`   // a bot search is running right now
+}
+
+// DebugSnapshot returns the game's state plus internal fields
+func (g *Game) DebugSnapshot() GameDebug {
+	state := g.GetState()
+
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	snapshot := GameDebug{
+		State:                state,
+		CreatedAt:            g.StartTime,
+		DisconnectedPlayer:   g.DisconnectedPlayer,
+		TurnRemainingSeconds: g.turnRemainingLocked(time.Now()).Seconds(),
+		TurnTimeoutSeconds:   g.TurnTimeout.Seconds(),
+		HasBot:               g.Bot != nil,
+		BotSearching:         g.botSearching,
+	}
+	if seat := g.botPlayerLocked(); seat != 0 {
+		snapshot.BotMovePending = g.Status == StatusPlaying && g.CurrentTurn == seat
+	}
+	if !g.EndTime.IsZero() {
+		ended := g.EndTime
+		snapshot.EndedAt = &ended
+	}
+	if !g.DisconnectTime.IsZero() {
+		snapshot.DisconnectedSeconds = int(time.Since(g.DisconnectTime).Seconds())
+	}
+	return snapshot
+}
+
 // GetPlayerByUsername returns the player number for a username
 func (g *Game) GetPlayerByUsername(username string) int {
 	g.mu.RLock()
@@ -287,30 +1015,142 @@ func (g *Game) GetPlayerByUsername(username string) int {
 	return 0
 }
 
-// GetDuration returns the game duration in seconds
+// FirstMover returns the username of the player who made the first move,
+// or "" if nobody has moved
+func (g *Game) FirstMover() string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	if len(g.Moves) == 0 {
+		return ""
+	}
+	if g.Moves[0].PlayerNum == Player1 {
+		return g.Player1.Username
+	}
+	return g.Player2.Username
+}
+
+// LastActivity returns when the game last changed: its end, its last move,
+// or the start of play
+func (g *Game) LastActivity() time.Time {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	switch {
+	case !g.EndTime.IsZero():
+		return g.EndTime
+	case len(g.Moves) > 0:
+		return g.Moves[len(g.Moves)-1].Timestamp
+	case !g.TurnStartedAt.IsZero():
+		return g.TurnStartedAt
+	}
+	return g.StartTime
+}
+
+// HasConnectedHuman reports whether any human player is marked connected
+func (g *Game) HasConnectedHuman() bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	for _, p := range []*Player{g.Player1, g.Player2} {
+		if p != nil && !p.IsBot && p.IsConnected {
+			return true
+		}
+	}
+	return false
+}
+
+// GetDuration returns the game duration in seconds, counted from the start
+// of play so time spent waiting for an opponent is left out
 func (g *Game) GetDuration() int {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
 
+	start := g.playStartLocked()
 	if g.EndTime.IsZero() {
-		return int(time.Since(g.StartTime).Seconds())
+		return int(time.Since(start).Seconds())
+	}
+	return int(g.EndTime.Sub(start).Seconds())
+}
+
+// PlayStart returns when play began, or the creation time if it hasn't
+func (g *Game) PlayStart() time.Time {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.playStartLocked()
+}
+
+// playStartLocked returns the play start time; caller holds the lock
+func (g *Game) playStartLocked() time.Time {
+	if g.PlayStartedAt.IsZero() {
+		return g.StartTime
 	}
-	return int(g.EndTime.Sub(g.StartTime).Seconds())
+	return g.PlayStartedAt
 }
 
 // GameState represents the serializable game state
 type GameState struct {
-	ID          string     `json:"id"`
-	Player1     string     `json:"player1"`
-	Player2     string     `json:"player2"`
-	IsVsBot     bool       `json:"isVsBot"`
-	Board       [][]int    `json:"board"`
-	CurrentTurn int        `json:"currentTurn"`
-	Status      GameStatus `json:"status"`
-	Winner      string     `json:"winner,omitempty"`
-	Result      string     `json:"result,omitempty"`
-	LastMove    *MoveInfo  `json:"lastMove,omitempty"`
-	MoveCount   int        `json:"moveCount"`
+	ID                   string     `json:"id"`
+	Player1              string     `json:"player1"`
+	Player2              string     `json:"player2"`
+	Player1DiscEmoji     string     `json:"player1DiscEmoji,omitempty"`
+	Player2DiscEmoji     string     `json:"player2DiscEmoji,omitempty"`
+	Player1AvatarURL     string     `json:"player1AvatarUrl,omitempty"`
+	Player2AvatarURL     string     `json:"player2AvatarUrl,omitempty"`
+	IsVsBot              bool       `json:"isVsBot"`
+	BotPlayer            int        `json:"botPlayer,omitempty"` // seat the bot plays in a bot game
+	BotDifficulty        string     `json:"botDifficulty,omitempty"`
+	BotVersion           string     `json:"botVersion,omitempty"`
+	Board                [][]int    `json:"board"`
+	BoardSize                       // rows, columns and winLength of the board
+	CurrentTurn          int        `json:"currentTurn"`
+	CurrentTurnUsername  string     `json:"currentTurnUsername,omitempty"` // empty once the game is over
+	FirstPlayer          int        `json:"firstPlayer"`                   // seat that moved, or moves, first
+	Friendly             bool       `json:"friendly,omitempty"`            // unrated, with takebacks allowed
+	TakebackPending      int        `json:"takebackPending,omitempty"`     // seat waiting for a takeback answer
+	DrawOffer            int        `json:"drawOffer,omitempty"`           // seat with a draw offer standing
+	DrawReason           DrawReason `json:"drawReason,omitempty"`          // how a drawn game was drawn
+	Status               GameStatus `json:"status"`
+	Winner               string     `json:"winner,omitempty"`
+	Result               string     `json:"result,omitempty"`
+	ForfeitedBy          string     `json:"forfeitedBy,omitempty"`
+	LastMove             *MoveInfo  `json:"lastMove,omitempty"`
+	LastMoveAt           *time.Time `json:"lastMoveAt,omitempty"`
+	MoveCount            int        `json:"moveCount"`
+	Moves                []Move     `json:"moves,omitempty"` // only from GetStateWithHistory
+	TurnSecondsRemaining int        `json:"turnSecondsRemaining,omitempty"`
+	StateVersion         int        `json:"stateVersion"`
+	WinningCells         []MoveInfo `json:"winningCells,omitempty"`
+	WinType              WinType    `json:"winType,omitempty"`
+}
+
+// FirstPlayerUsername returns the username of the player who moves first
+func (s *GameState) FirstPlayerUsername() string {
+	if s.FirstPlayer == Player2 {
+		return s.Player2
+	}
+	return s.Player1
+}
+
+// ETag returns an entity tag identifying this exact version of the game state
+func (s *GameState) ETag() string {
+	return fmt.Sprintf("\"%s-%d\"", s.ID, s.StateVersion)
+}
+
+// ParseETag extracts the state version from an ETag produced by GameState.ETag
+// for the given game. ok is false if the tag is malformed or for another game.
+func ParseETag(gameID, etag string) (version int, ok bool) {
+	etag = strings.TrimPrefix(strings.TrimSpace(etag), "W/")
+	etag = strings.Trim(etag, "\"")
+	prefix := gameID + "-"
+	if !strings.HasPrefix(etag, prefix) {
+		return 0, false
+	}
+	version, err := strconv.Atoi(strings.TrimPrefix(etag, prefix))
+	if err != nil || version < 0 {
+		return 0, false
+	}
+	return version, true
 }
 
 // MoveInfo represents info about a move
@@ -321,10 +1161,21 @@ type MoveInfo struct {
 
 // Errors
 var (
-	ErrGameNotInProgress = &GameError{"game is not in progress"}
-	ErrNotYourTurn       = &GameError{"not your turn"}
-	ErrGameNotFound      = &GameError{"game not found"}
-	ErrPlayerNotFound    = &GameError{"player not found"}
+	ErrGameNotInProgress  = &GameError{"game is not in progress"}
+	ErrNotYourTurn        = &GameError{"not your turn"}
+	ErrGameNotFound       = &GameError{"game not found"}
+	ErrPlayerNotFound     = &GameError{"player not found"}
+	ErrVersionConflict    = &GameError{"game state has changed"}
+	ErrNoHintsLeft        = &GameError{"no hints left"}
+	ErrGameAlreadyStarted = &GameError{"game has already started"}
+	ErrTakebacksDisabled  = &GameError{"takebacks are only allowed in friendly games"}
+	ErrNoTakebacksLeft    = &GameError{"no takebacks left"}
+	ErrTakebackPending    = &GameError{"a takeback request is already pending"}
+	ErrNoTakebackPending  = &GameError{"no takeback request to answer"}
+	ErrNothingToTakeBack  = &GameError{"no move to take back"}
+	ErrDrawOffersDisabled = &GameError{"draw offers aren't allowed against the bot"}
+	ErrDrawOfferPending   = &GameError{"a draw offer is already pending"}
+	ErrNoDrawOffer        = &GameError{"no draw offer to answer"}
 )
 
 type GameError struct {
@@ -0,0 +1,502 @@
+package game
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newPlayingGame returns a game between alice and bob with Player1 to move
+func newPlayingGame(t *testing.T) *Game {
+	t.Helper()
+	g := NewGame("alice")
+	g.AddPlayer2("bob", false)
+	if err := g.SetFirstPlayer(Player1); err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+// expireTurnClock makes the current turn's clock run out
+func expireTurnClock(g *Game) {
+	g.mu.Lock()
+	g.TurnStartedAt = time.Now().Add(-2 * g.TurnTimeout)
+	g.mu.Unlock()
+}
+
+func TestForfeitIfStillOnTurn(t *testing.T) {
+	g := newPlayingGame(t)
+	if g.ForfeitIfStillOnTurn(Player1, 0) {
+		t.Fatal("forfeited with time left on the clock")
+	}
+
+	expireTurnClock(g)
+	if g.ForfeitIfStillOnTurn(Player2, 0) {
+		t.Fatal("forfeited the player not on turn")
+	}
+	if g.ForfeitIfStillOnTurn(Player1, 1) {
+		t.Fatal("forfeited with a different move count")
+	}
+	if !g.ForfeitIfStillOnTurn(Player1, 0) {
+		t.Fatal("player on turn with an expired clock was not forfeited")
+	}
+	state := g.GetState()
+	if state.Status != StatusFinished || state.Winner != "bob" || state.Result != string(ResultForfeit) {
+		t.Fatalf("after forfeit: status %s, winner %q, result %s", state.Status, state.Winner, state.Result)
+	}
+	if g.ForfeitIfStillOnTurn(Player1, 0) {
+		t.Fatal("finished game was forfeited again")
+	}
+}
+
+func TestForfeitIfStillOnTurnRacingMove(t *testing.T) {
+	for i := 0; i < 200; i++ {
+		g := newPlayingGame(t)
+		expireTurnClock(g)
+
+		var wg sync.WaitGroup
+		var forfeited bool
+		var moveErr error
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			forfeited = g.ForfeitIfStillOnTurn(Player1, 0)
+		}()
+		go func() {
+			defer wg.Done()
+			_, moveErr = g.MakeMove(Player1, 3)
+		}()
+		wg.Wait()
+
+		// Exactly one of them wins, and a move that landed is never forfeited
+		if forfeited == (moveErr == nil) {
+			t.Fatalf("forfeited = %v, move error = %v; want exactly one to succeed", forfeited, moveErr)
+		}
+		if moveErr == nil && g.GetState().Status == StatusFinished {
+			t.Fatal("game finished after the move landed")
+		}
+	}
+}
+
+// disconnectAt disconnects playerNum with the game's clock stopped at
+// start, and returns a function that moves the clock to d after it
+func disconnectAt(g *Game, playerNum int, start time.Time) func(d time.Duration) {
+	now := start
+	g.now = func() time.Time { return now }
+	g.PlayerDisconnected(playerNum)
+	return func(d time.Duration) { now = start.Add(d) }
+}
+
+func TestIsPlayerConnectedPerSeat(t *testing.T) {
+	g := newPlayingGame(t)
+	g.PlayerDisconnected(Player2)
+	if !g.IsPlayerConnected(Player1) {
+		t.Error("player 1 reported disconnected while only player 2 dropped")
+	}
+	if g.IsPlayerConnected(Player2) {
+		t.Error("disconnected player 2 reported connected")
+	}
+
+	if err := g.Resign(Player1); err != nil {
+		t.Fatalf("resign: %v", err)
+	}
+	if g.IsPlayerConnected(Player1) || g.IsPlayerConnected(Player2) {
+		t.Error("player reported connected to a finished game")
+	}
+}
+
+func TestReconnectDeadlineBoundary(t *testing.T) {
+	start := time.Now()
+
+	t.Run("reconnect at the deadline", func(t *testing.T) {
+		g := newPlayingGame(t)
+		setClock := disconnectAt(g, Player1, start)
+		deadline := g.ReconnectDeadline()
+		if want := start.Add(g.ReconnectWindow); !deadline.Equal(want) {
+			t.Fatalf("deadline = %v, want %v", deadline, want)
+		}
+
+		setClock(g.ReconnectWindow)
+		if g.ForfeitIfDisconnected(Player1, deadline) {
+			t.Fatal("forfeited exactly at the deadline")
+		}
+		if !g.PlayerReconnected(Player1) {
+			t.Fatal("reconnect exactly at the deadline refused")
+		}
+		if g.ForfeitIfDisconnected(Player1, deadline) {
+			t.Fatal("forfeited after reconnecting")
+		}
+	})
+
+	t.Run("after the deadline", func(t *testing.T) {
+		g := newPlayingGame(t)
+		setClock := disconnectAt(g, Player1, start)
+		deadline := g.ReconnectDeadline()
+
+		setClock(g.ReconnectWindow + time.Nanosecond)
+		if g.PlayerReconnected(Player1) {
+			t.Fatal("reconnect after the deadline accepted")
+		}
+		if g.ForfeitIfDisconnected(Player2, deadline) {
+			t.Fatal("forfeited the connected player")
+		}
+		if !g.ForfeitIfDisconnected(Player1, deadline) {
+			t.Fatal("not forfeited after the deadline")
+		}
+		if state := g.GetState(); state.Status != StatusFinished || state.Winner != "bob" {
+			t.Fatalf("status %s, winner %q; want bob winning", state.Status, state.Winner)
+		}
+	})
+
+	t.Run("earlier disconnect's deadline", func(t *testing.T) {
+		g := newPlayingGame(t)
+		setClock := disconnectAt(g, Player1, start)
+		first := g.ReconnectDeadline()
+		setClock(time.Second)
+		g.PlayerReconnected(Player1)
+
+		// A second disconnect starts a fresh window
+		second := start.Add(2 * time.Second)
+		disconnectAt(g, Player1, second)(g.ReconnectWindow + time.Second)
+		if g.ForfeitIfDisconnected(Player1, first) {
+			t.Fatal("forfeited on the first disconnect's deadline")
+		}
+		if !g.ForfeitIfDisconnected(Player1, g.ReconnectDeadline()) {
+			t.Fatal("not forfeited after the second window ran out")
+		}
+	})
+}
+
+// checkMovesMatchBoard fails unless the game's moves alternate between
+// the players and replaying them gives the game's board
+func checkMovesMatchBoard(t *testing.T, g *Game) {
+	t.Helper()
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	replayed := NewBoard()
+	for i, m := range g.Moves {
+		if i > 0 && m.PlayerNum == g.Moves[i-1].PlayerNum {
+			t.Fatalf("moves %d and %d were both played by player %d", i-1, i, m.PlayerNum)
+		}
+		row, err := replayed.DropDiscUnsafe(m.Column, m.PlayerNum)
+		if err != nil || row != m.Row {
+			t.Fatalf("move %d (column %d) lands on row %d (%v), recorded as row %d", i, m.Column, row, err, m.Row)
+		}
+	}
+	for row := 0; row < Rows; row++ {
+		for col := 0; col < Columns; col++ {
+			if got, want := g.Board.cells[row][col], replayed.cells[row][col]; got != want {
+				t.Fatalf("board cell %d,%d = %d, replaying the moves gives %d", row, col, got, want)
+			}
+		}
+	}
+}
+
+// TestConcurrentHumanAndBotMoves hammers one bot game with human moves,
+// bot moves, takebacks and state reads at once; run it with -race
+func TestConcurrentHumanAndBotMoves(t *testing.T) {
+	for round := 0; round < 20; round++ {
+		g := NewGame("alice")
+		g.AddBot(DifficultyEasy)
+
+		var wg sync.WaitGroup
+		for w := 0; w < 3; w++ {
+			wg.Add(4)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < 60; i++ {
+					g.MakeMove(Player1, (w+i)%Columns)
+				}
+			}(w)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 60; i++ {
+					g.MakeBotMove()
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 20; i++ {
+					g.UndoLastMoves(2)
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				for i := 0; i < 60; i++ {
+					g.GetState()
+				}
+			}()
+		}
+		wg.Wait()
+		checkMovesMatchBoard(t, g)
+	}
+}
+
+// pausedBotMove starts MakeBotMove for an easy bot drawing from rng and
+// holds the search at its first draw. The returned function lets the
+// search finish and returns MakeBotMove's result.
+func pausedBotMove(t *testing.T, g *Game, rng *Rand) func() (int, error) {
+	t.Helper()
+	rng.mu.Lock()
+	searches := BotSearchCount()
+	type result struct {
+		column int
+		err    error
+	}
+	done := make(chan result)
+	go func() {
+		col, _, err := g.MakeBotMove()
+		done <- result{col, err}
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for BotSearchCount() == searches {
+		if time.Now().After(deadline) {
+			rng.mu.Unlock()
+			t.Fatal("bot search never started")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return func() (int, error) {
+		rng.mu.Unlock()
+		res := <-done
+		return res.column, res.err
+	}
+}
+
+// newBotGame returns alice's game against an easy bot drawing from rng,
+// with alice to move
+func newBotGame(t *testing.T, rng *Rand) *Game {
+	t.Helper()
+	g := NewGame("alice")
+	g.AddBot(DifficultyEasy, WithRand(rng))
+	if err := g.SetFirstPlayer(Player1); err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+// TestBotMoveAfterTakebackAndNewMove changes the position under a bot
+// search without changing the move count: the bot's move must be refused
+func TestBotMoveAfterTakebackAndNewMove(t *testing.T) {
+	rng := NewRand(1)
+	g := newBotGame(t, rng)
+	if _, err := g.MakeMove(Player1, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// An easy bot draws from its source as the search starts, so the
+	// position can change while it is held there
+	finish := pausedBotMove(t, g, rng)
+	if err := g.UndoLastMoves(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.MakeMove(Player1, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if col, err := finish(); !errors.Is(err, ErrVersionConflict) {
+		t.Fatalf("bot move = column %d, %v; want ErrVersionConflict", col, err)
+	}
+	state := g.GetState()
+	if state.MoveCount != 1 || state.CurrentTurn != Player2 || state.Board[Rows-1][0] != Player1 {
+		t.Errorf("after the refused move: %d moves, turn %d; want alice's disc in column 0 and the bot to move",
+			state.MoveCount, state.CurrentTurn)
+	}
+	if _, _, err := g.MakeBotMove(); err != nil {
+		t.Errorf("bot move on the new position: %v", err)
+	}
+}
+
+func TestDebugSnapshotBotMove(t *testing.T) {
+	rng := NewRand(1)
+	g := newBotGame(t, rng)
+	if d := g.DebugSnapshot(); !d.HasBot || d.BotMovePending || d.BotSearching {
+		t.Errorf("alice to move: %+v, want a bot with no move pending", d)
+	}
+	if _, err := g.MakeMove(Player1, 3); err != nil {
+		t.Fatal(err)
+	}
+	if d := g.DebugSnapshot(); !d.BotMovePending || d.BotSearching {
+		t.Errorf("bot to move: pending %v, searching %v; want pending only", d.BotMovePending, d.BotSearching)
+	}
+
+	finish := pausedBotMove(t, g, rng)
+	if d := g.DebugSnapshot(); !d.BotMovePending || !d.BotSearching {
+		t.Errorf("during the search: pending %v, searching %v; want both", d.BotMovePending, d.BotSearching)
+	}
+	if _, err := finish(); err != nil {
+		t.Fatal(err)
+	}
+	if d := g.DebugSnapshot(); d.BotMovePending || d.BotSearching {
+		t.Errorf("after the bot moved: pending %v, searching %v; want neither", d.BotMovePending, d.BotSearching)
+	}
+
+	// A paused game has no bot move pending even on the bot's turn
+	g.MakeMove(Player1, 3)
+	g.PlayerDisconnected(Player1)
+	if d := g.DebugSnapshot(); d.BotMovePending {
+		t.Error("bot move pending while alice is disconnected")
+	}
+}
+
+// playOrder finds columns that fill target from an empty board, alternating
+// from Player1 and dropping each disc onto the one below it
+func playOrder(t *testing.T, target [][]int) []int {
+	t.Helper()
+	height := make([]int, Columns)
+	total := 0
+	for _, row := range target {
+		for _, cell := range row {
+			if cell != Empty {
+				total++
+			}
+		}
+	}
+	var order []int
+	var fill func(player int) bool
+	fill = func(player int) bool {
+		if len(order) == total {
+			return true
+		}
+		for col := 0; col < Columns; col++ {
+			row := Rows - 1 - height[col]
+			if row < 0 || target[row][col] != player {
+				continue
+			}
+			height[col]++
+			order = append(order, col)
+			if fill(3 - player) {
+				return true
+			}
+			order = order[:len(order)-1]
+			height[col]--
+		}
+		return false
+	}
+	if !fill(Player1) {
+		t.Fatal("no move order reaches the target")
+	}
+	return order
+}
+
+func TestMakeMoveEndsDeadDrawEarly(t *testing.T) {
+	g := newPlayingGame(t)
+	player := Player1
+	// The order may wall off the last open line before the fixture is
+	// reached, so play until the game ends
+	for i, col := range playOrder(t, parseGrid(t, drawnDead...)) {
+		if g.Status == StatusFinished {
+			break
+		}
+		if _, err := g.MakeMove(player, col); err != nil {
+			t.Fatalf("move %d in column %d: %v", i+1, col, err)
+		}
+		player = 3 - player
+	}
+
+	if g.Status != StatusFinished || g.Result != ResultDraw || g.DrawReason != DrawDead {
+		t.Fatalf("status %s, result %s, reason %q; want a dead draw", g.Status, g.Result, g.DrawReason)
+	}
+	if g.Board.IsFull() || len(g.Moves) > Rows*Columns-2 {
+		t.Errorf("game ended after %d moves, want at least two cells early", len(g.Moves))
+	}
+	if g.Winner != nil || g.Board.CheckWin(Player1) || g.Board.CheckWin(Player2) {
+		t.Error("dead draw has a winner")
+	}
+	if _, err := g.MakeMove(player, 0); err == nil {
+		t.Error("move accepted after the draw")
+	}
+}
+
+// TestTakebackInvariants plays random friendly games with takebacks mixed
+// in and checks the moves, board, bitboards and turn stay in step
+func TestTakebackInvariants(t *testing.T) {
+	rng := rand.New(rand.NewSource(6))
+	checkTurn := func(g *Game) {
+		t.Helper()
+		want := g.FirstPlayer
+		if n := len(g.Moves); n > 0 {
+			want = 3 - g.Moves[n-1].PlayerNum
+		}
+		if g.Status == StatusPlaying && g.CurrentTurn != want {
+			t.Fatalf("turn is %d after %d moves, want %d", g.CurrentTurn, len(g.Moves), want)
+		}
+	}
+
+	for round := 0; round < 200; round++ {
+		g := newPlayingGame(t)
+		g.Friendly = true
+		g.MaxTakebacks = 1000
+
+		for g.Status == StatusPlaying {
+			before := len(g.Moves)
+			if before > 0 && rng.Intn(4) == 0 {
+				requester := Player1 + rng.Intn(2)
+				if before == 1 {
+					requester = g.Moves[0].PlayerNum
+				}
+				n, err := g.RequestTakeback(requester)
+				if err != nil {
+					t.Fatalf("takeback by %d after %d moves: %v", requester, before, err)
+				}
+				if n < 1 || n > 2 || g.Moves[before-n].PlayerNum != requester {
+					t.Fatalf("takeback by %d would undo %d moves", requester, n)
+				}
+				accept := rng.Intn(2) == 0
+				if _, undone, err := g.AnswerTakeback(3-requester, accept); err != nil {
+					t.Fatal(err)
+				} else if accept && (undone != n || len(g.Moves) != before-n || g.CurrentTurn != requester) {
+					t.Fatalf("accepted takeback undid %d of %d moves, turn %d", undone, n, g.CurrentTurn)
+				} else if !accept && len(g.Moves) != before {
+					t.Fatal("declined takeback changed the moves")
+				}
+			} else {
+				valid := g.Board.GetValidColumns()
+				if _, err := g.MakeMove(g.CurrentTurn, valid[rng.Intn(len(valid))]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			checkMovesMatchBoard(t, g)
+			checkBitsMatchCells(t, g.Board)
+			checkTurn(g)
+		}
+
+		// Undoing the last move reopens a won or drawn game
+		if _, err := g.RequestTakeback(Player1); !errors.Is(err, ErrGameNotInProgress) {
+			t.Fatalf("takeback after the game ended: err = %v", err)
+		}
+		if err := g.UndoLastMoves(1); err != nil {
+			t.Fatal(err)
+		}
+		if g.Status != StatusPlaying || g.Winner != nil || g.Result != "" || g.WinningCells != nil {
+			t.Fatalf("undone game is %s, winner %v, result %q", g.Status, g.Winner, g.Result)
+		}
+		if g.Board.CheckWin(Player1) || g.Board.CheckWin(Player2) {
+			t.Fatal("reopened game still has a line on the board")
+		}
+		checkMovesMatchBoard(t, g)
+		checkBitsMatchCells(t, g.Board)
+		checkTurn(g)
+	}
+}
+
+func TestDurationExcludesWaiting(t *testing.T) {
+	g := NewGame("alice")
+	g.StartTime = g.StartTime.Add(-10 * time.Second) // queued for ten seconds
+	if !g.PlayStart().Equal(g.StartTime) {
+		t.Errorf("play start before the game began = %v, want the creation time %v", g.PlayStart(), g.StartTime)
+	}
+
+	g.AddPlayer2("bob", false)
+	if waited := g.PlayStart().Sub(g.StartTime); waited < 10*time.Second {
+		t.Fatalf("play started %v after creation, want at least the 10s wait", waited)
+	}
+	g.Forfeit(Player2)
+	g.EndTime = g.PlayStartedAt.Add(30 * time.Second)
+	if d := g.GetDuration(); d != 30 {
+		t.Errorf("duration = %ds, want 30s of play without the wait", d)
+	}
+}
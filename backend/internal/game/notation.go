@@ -0,0 +1,138 @@
+package game
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Notation format
+//
+// A game is written as the sequence of columns played, numbered 1-7 from the
+// left, with Player1 moving first. Whitespace is ignored, so "4453" and
+// "4 4 5 3" are the same game. Each move may be followed by the annotations
+// "!" or "?" (any combination), which are accepted and discarded. Everything
+// from a "#" to the end of the line is a comment.
+//
+// A finished game ends with a result suffix: "1-0" (Player1 won), "0-1"
+// (Player2 won) or "1/2" (draw). A game without a suffix is treated as
+// truncated and is returned as far as it was played.
+const (
+	NotationPlayer1Win = "1-0"
+	NotationPlayer2Win = "0-1"
+	NotationDraw       = "1/2"
+)
+
+// ErrInvalidNotation is returned when a notation string cannot be parsed
+var ErrInvalidNotation = &GameError{"invalid notation"}
+
+// NotationError describes where a notation string failed to parse
+type NotationError struct {
+	Pos int
+	Msg string
+}
+
+func (e *NotationError) Error() string {
+	return fmt.Sprintf("%s at position %d: %s", ErrInvalidNotation.msg, e.Pos, e.Msg)
+}
+
+// Unwrap allows errors.Is(err, ErrInvalidNotation)
+func (e *NotationError) Unwrap() error {
+	return ErrInvalidNotation
+}
+
+// ToNotation encodes a move list as a notation string with a result suffix
+// when the moves finish the game
+func ToNotation(moves []Move) string {
+	var sb strings.Builder
+	for _, m := range moves {
+		sb.WriteByte(byte('1' + m.Column))
+	}
+
+	board := NewBoard()
+	for _, m := range moves {
+		board.DropDiscUnsafe(m.Column, m.PlayerNum)
+	}
+
+	switch {
+	case board.checkWinUnsafe(Player1):
+		sb.WriteString(" " + NotationPlayer1Win)
+	case board.checkWinUnsafe(Player2):
+		sb.WriteString(" " + NotationPlayer2Win)
+	case board.isFullUnsafe():
+		sb.WriteString(" " + NotationDraw)
+	}
+
+	return sb.String()
+}
+
+// FromNotation parses a notation string into a validated move list
+func FromNotation(notation string) ([]Move, error) {
+	board := NewBoard()
+	moves := make([]Move, 0, Rows*Columns)
+	player := Player1
+	winner := 0
+	result := ""
+
+	runes := []rune(notation)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case r == '#':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case unicode.IsSpace(r), r == '!', r == '?':
+			// Ignored
+		case strings.HasPrefix(string(runes[i:]), NotationPlayer1Win),
+			strings.HasPrefix(string(runes[i:]), NotationPlayer2Win),
+			strings.HasPrefix(string(runes[i:]), NotationDraw):
+			if result != "" {
+				return nil, &NotationError{i, "duplicate result"}
+			}
+			result = string(runes[i : i+3])
+			i += 2
+		case r >= '1' && r <= '7':
+			if result != "" {
+				return nil, &NotationError{i, "move after result"}
+			}
+			if winner != 0 {
+				return nil, &NotationError{i, "move after game was won"}
+			}
+			column := int(r - '1')
+			row, err := board.DropDiscUnsafe(column, player)
+			if err != nil {
+				return nil, &NotationError{i, err.Error()}
+			}
+			moves = append(moves, Move{PlayerNum: player, Column: column, Row: row})
+			if board.checkWinUnsafe(player) {
+				winner = player
+			}
+			if player == Player1 {
+				player = Player2
+			} else {
+				player = Player1
+			}
+		default:
+			return nil, &NotationError{i, fmt.Sprintf("unexpected character %q", r)}
+		}
+	}
+
+	if result != "" {
+		expected := ""
+		switch {
+		case winner == Player1:
+			expected = NotationPlayer1Win
+		case winner == Player2:
+			expected = NotationPlayer2Win
+		case board.isFullUnsafe():
+			expected = NotationDraw
+		}
+		if result != expected {
+			return nil, &NotationError{len(runes), fmt.Sprintf("result %s does not match the moves", result)}
+		}
+	}
+
+	return moves, nil
+}
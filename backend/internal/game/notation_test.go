@@ -0,0 +1,113 @@
+package game
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+// randomGame plays random legal moves until the game is won, drawn or
+// maxMoves have been played
+func randomGame(rng *rand.Rand, maxMoves int) []Move {
+	board := NewBoard()
+	var moves []Move
+	player := Player1
+	for len(moves) < maxMoves {
+		valid := board.GetValidColumns()
+		if len(valid) == 0 {
+			break
+		}
+		col := valid[rng.Intn(len(valid))]
+		row, _ := board.DropDisc(col, player)
+		moves = append(moves, Move{PlayerNum: player, Column: col, Row: row})
+		if board.CheckWin(player) {
+			break
+		}
+		player = 3 - player
+	}
+	return moves
+}
+
+func TestNotationRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		moves := randomGame(rng, rng.Intn(Rows*Columns+1))
+		notation := ToNotation(moves)
+		got, err := FromNotation(notation)
+		if err != nil {
+			t.Fatalf("FromNotation(%q): %v", notation, err)
+		}
+		if len(got) != len(moves) {
+			t.Fatalf("FromNotation(%q) returned %d moves, want %d", notation, len(got), len(moves))
+		}
+		for j, m := range moves {
+			if got[j].PlayerNum != m.PlayerNum || got[j].Column != m.Column || got[j].Row != m.Row {
+				t.Fatalf("FromNotation(%q) move %d = %+v, want %+v", notation, j, got[j], m)
+			}
+		}
+		if again := ToNotation(got); again != notation {
+			t.Fatalf("ToNotation after round trip = %q, want %q", again, notation)
+		}
+	}
+}
+
+func TestToNotationResult(t *testing.T) {
+	tests := []struct {
+		notation string
+		want     string
+	}{
+		{"", ""},
+		{"4453", "4453"},
+		{"1212121", "1212121 1-0"},
+		{"31212121", "31212121 0-1"},
+	}
+	for _, tt := range tests {
+		moves, err := FromNotation(tt.notation)
+		if err != nil {
+			t.Fatalf("FromNotation(%q): %v", tt.notation, err)
+		}
+		if got := ToNotation(moves); got != tt.want {
+			t.Errorf("ToNotation(%q) = %q, want %q", tt.notation, got, tt.want)
+		}
+	}
+}
+
+func TestFromNotationIgnoresAnnotations(t *testing.T) {
+	moves, err := FromNotation("4! 4?? 5 # opening\n3!?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ToNotation(moves); got != "4453" {
+		t.Fatalf("moves = %q, want 4453", got)
+	}
+}
+
+func TestFromNotationMalformed(t *testing.T) {
+	tests := []struct {
+		name     string
+		notation string
+	}{
+		{"column out of range", "48"},
+		{"column zero", "40"},
+		{"unexpected character", "4a"},
+		{"full column", "1111111"},
+		{"move after win", "12121214"},
+		{"move after result", "1212121 1-0 3"},
+		{"duplicate result", "1212121 1-0 1-0"},
+		{"wrong winner", "1212121 0-1"},
+		{"result without win", "4453 1-0"},
+		{"draw that isn't", "4453 1/2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := FromNotation(tt.notation)
+			if !errors.Is(err, ErrInvalidNotation) {
+				t.Fatalf("FromNotation(%q) err = %v, want ErrInvalidNotation", tt.notation, err)
+			}
+			var nerr *NotationError
+			if !errors.As(err, &nerr) {
+				t.Fatalf("FromNotation(%q) err = %T, want *NotationError", tt.notation, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package game
+
+import "fmt"
+
+// ErrInvalidBoard is returned when a board can't arise from legal play
+var ErrInvalidBoard = &GameError{"invalid board"}
+
+// BoardError describes why a board failed validation
+type BoardError struct {
+	Msg string
+}
+
+func (e *BoardError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrInvalidBoard.msg, e.Msg)
+}
+
+// Unwrap allows errors.Is(err, ErrInvalidBoard)
+func (e *BoardError) Unwrap() error {
+	return ErrInvalidBoard
+}
+
+// NewBoardFromSlice builds a board from a Rows x Columns grid in the ToSlice
+// layout (row 0 is the top). It rejects grids that can't come from a real
+// game: wrong dimensions, unknown cell values, discs floating above an empty
+// cell, disc counts that don't alternate from Player1, or both players
+// having four in a row.
+func NewBoardFromSlice(cells [][]int) (*Board, error) {
+	if len(cells) != Rows {
+		return nil, &BoardError{fmt.Sprintf("expected %d rows, got %d", Rows, len(cells))}
+	}
+
+	b := NewBoard()
+	counts := [3]int{}
+	for row := 0; row < Rows; row++ {
+		if len(cells[row]) != Columns {
+			return nil, &BoardError{fmt.Sprintf("row %d: expected %d columns, got %d", row, Columns, len(cells[row]))}
+		}
+		for col := 0; col < Columns; col++ {
+			cell := cells[row][col]
+			if cell != Empty && cell != Player1 && cell != Player2 {
+				return nil, &BoardError{fmt.Sprintf("row %d column %d: unknown value %d", row, col, cell)}
+			}
+			b.setCellUnsafe(row, col, cell)
+			counts[cell]++
+		}
+	}
+
+	for row := 0; row < Rows-1; row++ {
+		for col := 0; col < Columns; col++ {
+			if b.cells[row][col] != Empty && b.cells[row+1][col] == Empty {
+				return nil, &BoardError{fmt.Sprintf("row %d column %d: disc is floating", row, col)}
+			}
+		}
+	}
+
+	if diff := counts[Player1] - counts[Player2]; diff != 0 && diff != 1 {
+		return nil, &BoardError{fmt.Sprintf("player 1 has %d discs and player 2 has %d", counts[Player1], counts[Player2])}
+	}
+
+	// Only the player who moved last can have won
+	p1Won, p2Won := b.checkWinUnsafe(Player1), b.checkWinUnsafe(Player2)
+	switch {
+	case p1Won && p2Won:
+		return nil, &BoardError{"both players have four in a row"}
+	case p1Won && counts[Player1] == counts[Player2]:
+		return nil, &BoardError{"player 2 moved after player 1 had won"}
+	case p2Won && counts[Player1] > counts[Player2]:
+		return nil, &BoardError{"player 1 moved after player 2 had won"}
+	}
+
+	return b, nil
+}
+
+// discCountUnsafe returns how many discs the player has on the board
+func (b *Board) discCountUnsafe(player int) int {
+	return b.bits[player].count()
+}
+
+// nextPlayerUnsafe returns whose turn it is from the disc counts, assuming
+// Player1 moved first
+func (b *Board) nextPlayerUnsafe() int {
+	if b.discCountUnsafe(Player1) == b.discCountUnsafe(Player2) {
+		return Player1
+	}
+	return Player2
+}
+
+// MoveCount returns how many discs have been played
+func (b *Board) MoveCount() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.discCountUnsafe(Player1) + b.discCountUnsafe(Player2)
+}
+
+// IsDeadDraw reports whether neither player can ever win: every line of
+// winLength cells holds discs of both colours. It is conservative, so a
+// position drawn only because of whose turn it is still counts as open.
+func (b *Board) IsDeadDraw() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.isDeadDrawUnsafe()
+}
+
+// isDeadDrawUnsafe checks for a dead draw without locking. A player can
+// still win somewhere their opponent has no disc, so each player's open
+// cells are searched for a line.
+func (b *Board) isDeadDrawUnsafe() bool {
+	return !b.hasLineUnsafe(b.all.andNot(b.bits[Player2])) &&
+		!b.hasLineUnsafe(b.all.andNot(b.bits[Player1]))
+}
+
+// NextPlayer returns whose turn it is on a validated board
+func (b *Board) NextPlayer() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.nextPlayerUnsafe()
+}
@@ -0,0 +1,256 @@
+package game
+
+import (
+	"errors"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// emptyGrid returns an empty standard grid in the ToSlice layout
+func emptyGrid() [][]int {
+	grid := make([][]int, Rows)
+	for row := range grid {
+		grid[row] = make([]int, Columns)
+	}
+	return grid
+}
+
+// gridOf plays columns alternately from Player1 and returns the grid
+func gridOf(columns ...int) [][]int {
+	b := NewBoard()
+	player := Player1
+	for _, col := range columns {
+		b.DropDisc(col, player)
+		player = 3 - player
+	}
+	return b.ToSlice()
+}
+
+// referenceValid decides whether a grid can come from legal play from the
+// rules alone, checking lines with arrayHasLine instead of the bitboards
+func referenceValid(grid [][]int) bool {
+	if len(grid) != Rows {
+		return false
+	}
+	b := NewBoard()
+	counts := [3]int{}
+	for row := range grid {
+		if len(grid[row]) != Columns {
+			return false
+		}
+		for col, cell := range grid[row] {
+			if cell < Empty || cell > Player2 {
+				return false
+			}
+			if cell != Empty && row+1 < Rows && grid[row+1][col] == Empty {
+				return false
+			}
+			b.cells[row][col] = cell
+			counts[cell]++
+		}
+	}
+	if counts[Player1] != counts[Player2] && counts[Player1] != counts[Player2]+1 {
+		return false
+	}
+	p1, p2 := arrayHasLine(b, Player1), arrayHasLine(b, Player2)
+	return !(p1 && p2) &&
+		!(p1 && counts[Player1] == counts[Player2]) &&
+		!(p2 && counts[Player1] > counts[Player2])
+}
+
+func TestNewBoardFromSliceRejects(t *testing.T) {
+	floating := emptyGrid()
+	floating[Rows-2][3] = Player1
+	unknown := gridOf(3)
+	unknown[Rows-1][3] = 3
+	negative := gridOf(3)
+	negative[Rows-1][3] = -1
+
+	tests := []struct {
+		name string
+		grid [][]int
+		msg  string
+	}{
+		{"no rows", nil, "expected 6 rows, got 0"},
+		{"too few rows", emptyGrid()[1:], "expected 6 rows, got 5"},
+		{"too many rows", append(emptyGrid(), make([]int, Columns)), "expected 6 rows, got 7"},
+		{"short row", append(emptyGrid()[:Rows-1], make([]int, Columns-1)), "row 5: expected 7 columns, got 6"},
+		{"long row", func() [][]int { g := emptyGrid(); g[2] = make([]int, Columns+1); return g }(), "row 2: expected 7 columns, got 8"},
+		{"unknown value", unknown, "unknown value 3"},
+		{"negative value", negative, "unknown value -1"},
+		{"floating disc", floating, "floating"},
+		{"player 2 ahead", func() [][]int { g := emptyGrid(); g[Rows-1][0] = Player2; return g }(), "player 1 has 0 discs and player 2 has 1"},
+		{"player 1 two ahead", func() [][]int { g := gridOf(0, 1, 2); g[Rows-1][6] = Player1; return g }(), "player 1 has 3 discs and player 2 has 1"},
+		{"player 2 moved after a win", gridOf(0, 1, 0, 1, 0, 1, 0, 6), "player 2 moved after player 1 had won"},
+		{"player 1 moved after a win", gridOf(0, 1, 0, 1, 2, 1, 3, 1, 0), "player 1 moved after player 2 had won"},
+		{"both won", func() [][]int {
+			g := emptyGrid()
+			for row := Rows - 4; row < Rows; row++ {
+				g[row][0], g[row][1] = Player1, Player2
+			}
+			return g
+		}(), "both players"},
+	}
+	for _, tt := range tests {
+		_, err := NewBoardFromSlice(tt.grid)
+		if !errors.Is(err, ErrInvalidBoard) {
+			t.Errorf("%s: err = %v, want ErrInvalidBoard", tt.name, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("%s: err = %q, want it to mention %q", tt.name, err, tt.msg)
+		}
+	}
+}
+
+func TestNewBoardFromSliceRoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	for i := 0; i < 1000; i++ {
+		moves := randomGame(rng, rng.Intn(Rows*Columns+1))
+		played := NewBoard()
+		for _, m := range moves {
+			played.DropDisc(m.Column, m.PlayerNum)
+		}
+
+		b, err := NewBoardFromSlice(played.ToSlice())
+		if err != nil {
+			t.Fatalf("%s: %v", ToNotation(moves), err)
+		}
+		if !reflect.DeepEqual(b.ToSlice(), played.ToSlice()) {
+			t.Fatalf("%s: grid changed in the round trip", ToNotation(moves))
+		}
+		checkBitsMatchCells(t, b)
+		if b.MoveCount() != len(moves) {
+			t.Errorf("%s: MoveCount = %d, want %d", ToNotation(moves), b.MoveCount(), len(moves))
+		}
+		if want := Player1 + len(moves)%2; b.NextPlayer() != want {
+			t.Errorf("%s: NextPlayer = %d, want %d", ToNotation(moves), b.NextPlayer(), want)
+		}
+		for _, p := range []int{Player1, Player2} {
+			if b.CheckWin(p) != played.CheckWin(p) {
+				t.Errorf("%s: CheckWin(%d) changed in the round trip", ToNotation(moves), p)
+			}
+		}
+	}
+}
+
+// TestNewBoardFromSliceExhaustive checks every position reachable in up to
+// four moves, and every single-cell change to each of them, against the
+// rules
+func TestNewBoardFromSliceExhaustive(t *testing.T) {
+	seen := make(map[string]bool)
+	var positions [][][]int
+	var visit func(columns []int)
+	visit = func(columns []int) {
+		grid := gridOf(columns...)
+		if key := gridKey(grid); !seen[key] {
+			seen[key] = true
+			positions = append(positions, grid)
+		}
+		if len(columns) == 4 {
+			return
+		}
+		for col := 0; col < Columns; col++ {
+			visit(append(columns[:len(columns):len(columns)], col))
+		}
+	}
+	visit(nil)
+
+	checked := 0
+	for _, grid := range positions {
+		b, err := NewBoardFromSlice(grid)
+		if err != nil {
+			t.Fatalf("reachable position %v rejected: %v", grid, err)
+		}
+		if b.NextPlayer() != Player1+b.MoveCount()%2 {
+			t.Fatalf("%v: NextPlayer %d after %d moves", grid, b.NextPlayer(), b.MoveCount())
+		}
+
+		for row := 0; row < Rows; row++ {
+			for col := 0; col < Columns; col++ {
+				original := grid[row][col]
+				for _, value := range []int{-1, Empty, Player1, Player2, 3} {
+					if value == original {
+						continue
+					}
+					grid[row][col] = value
+					_, err := NewBoardFromSlice(grid)
+					if want := referenceValid(grid); (err == nil) != want {
+						t.Fatalf("%v: accepted = %v, rules say %v (err %v)", grid, err == nil, want, err)
+					}
+					checked++
+				}
+				grid[row][col] = original
+			}
+		}
+	}
+	t.Logf("%d positions, %d changed grids checked", len(positions), checked)
+}
+
+// gridKey identifies a grid for deduplication
+func gridKey(grid [][]int) string {
+	var sb strings.Builder
+	for _, row := range grid {
+		for _, cell := range row {
+			sb.WriteByte(byte('0' + cell))
+		}
+	}
+	return sb.String()
+}
+
+// parseGrid reads a grid written top row first, with . for an empty cell
+func parseGrid(t *testing.T, rows ...string) [][]int {
+	t.Helper()
+	grid := make([][]int, len(rows))
+	for row, line := range rows {
+		for _, c := range line {
+			switch c {
+			case '.':
+				grid[row] = append(grid[row], Empty)
+			case '1', '2':
+				grid[row] = append(grid[row], int(c-'0'))
+			}
+		}
+	}
+	return grid
+}
+
+// Dead draw fixtures: the full board is drawn, the dead board leaves a
+// hole no line can pass through unmixed, and the open board has a window
+// on the top row that only player 2 holds
+var (
+	drawnFull = []string{
+		"1 2 1 2 1 2 1",
+		"1 2 1 2 1 2 1",
+		"2 1 2 1 2 1 2",
+		"2 1 2 1 2 1 2",
+		"1 2 1 2 1 2 1",
+		"2 1 2 1 2 1 2",
+	}
+	drawnDead = append([]string{". . 1 2 1 2 1"}, drawnFull[1:]...)
+	drawnOpen = append([]string{". . . 2 1 . 1"}, drawnFull[1:]...)
+)
+
+func TestIsDeadDraw(t *testing.T) {
+	tests := []struct {
+		name string
+		grid []string
+		want bool
+	}{
+		{"empty board", []string{".......", ".......", ".......", ".......", ".......", "......."}, false},
+		{"full board", drawnFull, true},
+		{"two cells left", drawnDead, true},
+		{"top row window open for player 2", drawnOpen, false},
+	}
+	for _, tt := range tests {
+		b, err := NewBoardFromSlice(parseGrid(t, tt.grid...))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got := b.IsDeadDraw(); got != tt.want {
+			t.Errorf("%s: IsDeadDraw = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
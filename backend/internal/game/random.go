@@ -0,0 +1,38 @@
+package game
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Rand is a random source that is safe for concurrent use. Bots and forced
+// moves draw from one, so seeding it makes their choices reproducible.
+type Rand struct {
+	r  *rand.Rand
+	mu sync.Mutex
+}
+
+// NewRand creates a random source with a fixed seed
+func NewRand(seed int64) *Rand {
+	return &Rand{r: rand.New(rand.NewSource(seed))}
+}
+
+// NewTimeSeededRand creates a random source seeded from the clock
+func NewTimeSeededRand() *Rand {
+	return NewRand(time.Now().UnixNano())
+}
+
+// Intn returns a random int in [0, n)
+func (r *Rand) Intn(n int) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.r.Intn(n)
+}
+
+// Float64 returns a random float in [0.0, 1.0)
+func (r *Rand) Float64() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.r.Float64()
+}
@@ -0,0 +1,89 @@
+package game
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// selfPlay plays two bots against each other and returns the columns played
+func selfPlay(p1, p2 *Bot) []int {
+	board := NewBoard()
+	bots := map[int]*Bot{Player1: p1, Player2: p2}
+	var columns []int
+	for player := Player1; !board.IsFull(); player = 3 - player {
+		col := bots[player].GetBestMove(board.Clone())
+		if _, err := board.DropDisc(col, player); err != nil {
+			break
+		}
+		columns = append(columns, col)
+		if board.CheckWin(player) {
+			break
+		}
+	}
+	return columns
+}
+
+func TestGetRandomValidMoveIsReproducible(t *testing.T) {
+	board := NewBoard()
+	for row := 0; row < Rows; row++ {
+		board.DropDisc(2, Player1+row%2)
+	}
+	draw := func(seed int64) []int {
+		rng := NewRand(seed)
+		cols := make([]int, 50)
+		for i := range cols {
+			cols[i] = GetRandomValidMove(board, rng)
+		}
+		return cols
+	}
+
+	first := draw(42)
+	if !reflect.DeepEqual(first, draw(42)) {
+		t.Fatal("the same seed drew different columns")
+	}
+	for _, col := range first {
+		if col == 2 || col < 0 || col >= Columns {
+			t.Fatalf("drew column %d, which isn't valid", col)
+		}
+	}
+	if reflect.DeepEqual(first, draw(43)) {
+		t.Error("different seeds drew the same 50 columns")
+	}
+}
+
+func TestSeededEasyBotsReplayTheSameGame(t *testing.T) {
+	games := make(map[string]bool)
+	for seed := int64(1); seed <= 5; seed++ {
+		first := selfPlay(NewBotWithSeed(Player1, DifficultyEasy, seed), NewBotWithSeed(Player2, DifficultyEasy, seed+100))
+		again := selfPlay(NewBotWithSeed(Player1, DifficultyEasy, seed), NewBotWithSeed(Player2, DifficultyEasy, seed+100))
+		if !reflect.DeepEqual(first, again) {
+			t.Fatalf("seed %d: games differ:\n%v\n%v", seed, first, again)
+		}
+		games[fmt.Sprint(first)] = true
+	}
+	// Easy bots play random columns some of the time, so the seeds should
+	// lead to different games
+	if len(games) < 2 {
+		t.Errorf("5 seeds played %d distinct games", len(games))
+	}
+}
+
+func TestRandIsSafeForConcurrentUse(t *testing.T) {
+	rng := NewRand(1)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				if n := rng.Intn(Columns); n < 0 || n >= Columns {
+					t.Errorf("Intn(%d) = %d", Columns, n)
+				}
+				rng.Float64()
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,52 @@
+package game
+
+import "fmt"
+
+// ReplayError reports the first invalid move in a recorded sequence
+type ReplayError struct {
+	Move int // 1-based move number
+	Msg  string
+}
+
+func (e *ReplayError) Error() string {
+	return fmt.Sprintf("move %d: %s", e.Move, e.Msg)
+}
+
+// ReplayMoves replays a recorded move list on an empty board of the given
+// size and returns the board after each move. It checks that players
+// alternate from whichever seat moved first, that each recorded row is where
+// the disc actually lands, and that nothing is played after the game was won.
+func ReplayMoves(size BoardSize, moves []Move) ([][][]int, error) {
+	board := NewBoardOfSize(size)
+	snapshots := make([][][]int, 0, len(moves))
+	expected := Player1
+	if len(moves) > 0 && moves[0].PlayerNum == Player2 {
+		expected = Player2
+	}
+
+	for i, m := range moves {
+		if m.PlayerNum != expected {
+			return nil, &ReplayError{i + 1, fmt.Sprintf("expected player %d, got %d", expected, m.PlayerNum)}
+		}
+		if i > 0 && board.CheckWin(moves[i-1].PlayerNum) {
+			return nil, &ReplayError{i + 1, "move after game was won"}
+		}
+
+		row, err := board.DropDisc(m.Column, m.PlayerNum)
+		if err != nil {
+			return nil, &ReplayError{i + 1, err.Error()}
+		}
+		if row != m.Row {
+			return nil, &ReplayError{i + 1, fmt.Sprintf("recorded row %d but disc lands on row %d", m.Row, row)}
+		}
+
+		snapshots = append(snapshots, board.ToSlice())
+		if expected == Player1 {
+			expected = Player2
+		} else {
+			expected = Player1
+		}
+	}
+
+	return snapshots, nil
+}
@@ -0,0 +1,188 @@
+package game
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// SnapshotVersion is bumped when Snapshot changes incompatibly; RestoreGame
+// refuses other versions
+const SnapshotVersion = 1
+
+// Snapshot is the serializable state of an unfinished game, enough to
+// rebuild it after a server restart. The board is replayed from Moves and
+// checked against Board.
+type Snapshot struct {
+	Version         int             `json:"version"`
+	ID              string          `json:"id"`
+	Player1         *PlayerSnapshot `json:"player1"`
+	Player2         *PlayerSnapshot `json:"player2"`
+	Board           [][]int         `json:"board"`
+	Size            BoardSize       `json:"size"` // zero in snapshots from before board variants: standard
+	CurrentTurn     int             `json:"currentTurn"`
+	FirstPlayer     int             `json:"firstPlayer,omitempty"` // zero in older snapshots: Player1
+	Status          GameStatus      `json:"status"`
+	Moves           []Move          `json:"moves"`
+	StartTime       time.Time       `json:"startTime"`
+	PlayStartedAt   time.Time       `json:"playStartedAt"`
+	TurnTimeout     time.Duration   `json:"turnTimeout"`
+	ReconnectWindow time.Duration   `json:"reconnectWindow"`
+	Bot             *BotSnapshot    `json:"bot,omitempty"`
+	HintsUsed       [3]int          `json:"hintsUsed"`
+	Friendly        bool            `json:"friendly,omitempty"`
+	MaxTakebacks    int             `json:"maxTakebacks,omitempty"`
+	TakebacksUsed   [3]int          `json:"takebacksUsed"`
+	Imported        bool            `json:"imported,omitempty"`
+	StateVersion    int             `json:"stateVersion"`
+}
+
+// PlayerSnapshot is a seated player in a Snapshot. Avatars aren't kept.
+type PlayerSnapshot struct {
+	Username  string `json:"username"`
+	IsBot     bool   `json:"isBot,omitempty"`
+	DiscEmoji string `json:"discEmoji,omitempty"`
+}
+
+// BotSnapshot is the bot's configuration in a Snapshot
+type BotSnapshot struct {
+	Difficulty Difficulty `json:"difficulty"`
+	Depth      int        `json:"depth"`
+}
+
+// Snapshot captures the game's state for RestoreGame
+func (g *Game) Snapshot() Snapshot {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	s := Snapshot{
+		Version:         SnapshotVersion,
+		ID:              g.ID,
+		Player1:         snapshotPlayer(g.Player1),
+		Player2:         snapshotPlayer(g.Player2),
+		Board:           g.Board.ToSlice(),
+		Size:            g.Board.Size(),
+		CurrentTurn:     g.CurrentTurn,
+		FirstPlayer:     g.FirstPlayer,
+		Status:          g.Status,
+		Moves:           slices.Clone(g.Moves),
+		StartTime:       g.StartTime,
+		PlayStartedAt:   g.PlayStartedAt,
+		TurnTimeout:     g.TurnTimeout,
+		ReconnectWindow: g.ReconnectWindow,
+		HintsUsed:       g.hintsUsed,
+		Friendly:        g.Friendly,
+		MaxTakebacks:    g.MaxTakebacks,
+		TakebacksUsed:   g.takebacksUsed,
+		Imported:        g.Imported,
+		StateVersion:    g.version,
+	}
+	if g.Bot != nil {
+		s.Bot = &BotSnapshot{Difficulty: g.Bot.Difficulty(), Depth: g.Bot.maxDepth}
+	}
+	return s
+}
+
+// snapshotPlayer copies the persisted fields of p
+func snapshotPlayer(p *Player) *PlayerSnapshot {
+	if p == nil {
+		return nil
+	}
+	return &PlayerSnapshot{Username: p.Username, IsBot: p.IsBot, DiscEmoji: p.DiscEmoji}
+}
+
+// RestoreGame rebuilds an unfinished game from a snapshot. Both human
+// players start disconnected, so neither reconnect window runs, and the
+// player to move gets a fresh turn clock since everyone has to reconnect
+// first. opts are applied to the restored bot.
+func RestoreGame(s Snapshot, opts ...BotOption) (*Game, error) {
+	if s.Version != SnapshotVersion {
+		return nil, fmt.Errorf("snapshot version %d, want %d", s.Version, SnapshotVersion)
+	}
+	if s.Status != StatusPlaying && s.Status != StatusDisconnect {
+		return nil, fmt.Errorf("game is %s, not in progress", s.Status)
+	}
+	if s.Player1 == nil || s.Player2 == nil {
+		return nil, fmt.Errorf("game has an empty seat")
+	}
+
+	// Replaying checks the move list; the position must match the board
+	// and still be open
+	size := s.Size.OrStandard()
+	if err := size.Validate(); err != nil {
+		return nil, err
+	}
+	if _, err := ReplayMoves(size, s.Moves); err != nil {
+		return nil, err
+	}
+	board := NewBoardOfSize(size)
+	for _, m := range s.Moves {
+		board.DropDisc(m.Column, m.PlayerNum)
+	}
+	if !slices.EqualFunc(board.ToSlice(), s.Board, slices.Equal[[]int]) {
+		return nil, fmt.Errorf("board does not match the move list")
+	}
+	if board.CheckWin(Player1) || board.CheckWin(Player2) || board.IsFull() {
+		return nil, fmt.Errorf("position is already decided")
+	}
+	first := s.FirstPlayer
+	if first == 0 {
+		first = Player1
+	}
+	if len(s.Moves) > 0 && s.Moves[0].PlayerNum != first {
+		return nil, fmt.Errorf("first player %d did not make the first move", first)
+	}
+	turn := first
+	if len(s.Moves)%2 == 1 {
+		turn = Player1 + Player2 - first
+	}
+	if s.CurrentTurn != turn {
+		return nil, fmt.Errorf("current turn %d does not follow the move list", s.CurrentTurn)
+	}
+
+	now := time.Now()
+	g := &Game{
+		ID:              s.ID,
+		Player1:         restorePlayer(s.Player1, Player1),
+		Player2:         restorePlayer(s.Player2, Player2),
+		Board:           board,
+		CurrentTurn:     turn,
+		FirstPlayer:     first,
+		Status:          StatusPlaying,
+		Moves:           slices.Clone(s.Moves),
+		StartTime:       s.StartTime,
+		PlayStartedAt:   s.PlayStartedAt,
+		Imported:        s.Imported,
+		TurnTimeout:     s.TurnTimeout,
+		TurnStartedAt:   now,
+		ReconnectWindow: s.ReconnectWindow,
+		hintsUsed:       s.HintsUsed,
+		Friendly:        s.Friendly,
+		MaxTakebacks:    s.MaxTakebacks,
+		takebacksUsed:   s.TakebacksUsed,
+		version:         s.StateVersion + 1,
+		now:             time.Now,
+	}
+	if seat := g.botPlayerLocked(); seat != 0 {
+		difficulty := DifficultyMedium
+		if s.Bot != nil {
+			difficulty = s.Bot.Difficulty
+		}
+		g.Bot = NewBot(seat, difficulty, opts...)
+		if s.Bot != nil && s.Bot.Depth > 0 {
+			g.Bot.maxDepth = min(s.Bot.Depth, MaxSearchDepth)
+		}
+	}
+	return g, nil
+}
+
+// restorePlayer rebuilds a seated player; humans start disconnected
+func restorePlayer(p *PlayerSnapshot, playerNum int) *Player {
+	return &Player{
+		Username:    p.Username,
+		PlayerNum:   playerNum,
+		IsBot:       p.IsBot,
+		IsConnected: p.IsBot,
+		DiscEmoji:   p.DiscEmoji,
+	}
+}
@@ -0,0 +1,35 @@
+package game
+
+// MoveAt returns the move with the given 0-based index
+func (g *Game) MoveAt(index int) (Move, bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	if index < 0 || index >= len(g.Moves) {
+		return Move{}, false
+	}
+	return g.Moves[index], true
+}
+
+// AverageThinkMs returns the average think time of a seat's moves in
+// milliseconds. ok is false when there is nothing to average: the seat
+// made no moves, or the game was imported and its moves aren't timed.
+func (g *Game) AverageThinkMs(playerNum int) (ms int64, ok bool) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	if g.Imported {
+		return 0, false
+	}
+	var total, moves int64
+	for _, m := range g.Moves {
+		if m.PlayerNum == playerNum {
+			total += m.ThinkMs
+			moves++
+		}
+	}
+	if moves == 0 {
+		return 0, false
+	}
+	return total / moves, true
+}
@@ -0,0 +1,75 @@
+package game
+
+// WinType is how a finished game was decided: the direction of the
+// winning line, or the reason there wasn't one
+type WinType string
+
+const (
+	WinHorizontal   WinType = "horizontal"
+	WinVertical     WinType = "vertical"
+	WinDiagonalUp   WinType = "diagonal_up"   // rising left to right
+	WinDiagonalDown WinType = "diagonal_down" // falling left to right
+	WinForfeit      WinType = "forfeit"       // forfeits, resignations, abandoned games and timeouts
+	WinDraw         WinType = "draw"
+)
+
+// ClassifyWin returns the direction of the first line in cells, as listed
+// by Board.WinningCells, or "" if cells don't start with a line. When a
+// move completed two lines at once, the first one in board scan order
+// counts. Every line is at least MinWinLength long, so that many cells
+// settle the direction.
+func ClassifyWin(cells []MoveInfo) WinType {
+	if len(cells) < MinWinLength {
+		return ""
+	}
+	dr, dc := cells[1].Row-cells[0].Row, cells[1].Column-cells[0].Column
+	for i := 2; i < MinWinLength; i++ {
+		if cells[i].Row-cells[i-1].Row != dr || cells[i].Column-cells[i-1].Column != dc {
+			return ""
+		}
+	}
+
+	// Row 0 is the top of the board
+	switch [2]int{dr, dc} {
+	case [2]int{0, 1}:
+		return WinHorizontal
+	case [2]int{1, 0}:
+		return WinVertical
+	case [2]int{1, 1}:
+		return WinDiagonalDown
+	case [2]int{-1, 1}:
+		return WinDiagonalUp
+	}
+	return ""
+}
+
+// WinTypeOfMoves replays a finished game's moves on a board of the given
+// size and classifies the line the last move completed, or returns "" if
+// it didn't complete one. It is meant for games stored before the win type
+// was recorded.
+func WinTypeOfMoves(size BoardSize, moves []Move) WinType {
+	if len(moves) == 0 {
+		return ""
+	}
+	board := NewBoardOfSize(size)
+	for _, m := range moves {
+		if _, err := board.DropDisc(m.Column, m.PlayerNum); err != nil {
+			return ""
+		}
+	}
+	return ClassifyWin(board.WinningCells(moves[len(moves)-1].PlayerNum))
+}
+
+// winTypeLocked classifies a finished game; "" while it is in progress
+// and for aborted games. Caller holds the lock.
+func (g *Game) winTypeLocked() WinType {
+	switch g.Result {
+	case ResultDraw:
+		return WinDraw
+	case ResultForfeit, ResultResign, ResultAbandoned:
+		return WinForfeit
+	case ResultWinPlayer1, ResultWinPlayer2:
+		return ClassifyWin(g.WinningCells)
+	}
+	return ""
+}
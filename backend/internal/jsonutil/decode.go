@@ -0,0 +1,106 @@
+package jsonutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DefaultMaxBodySize caps request bodies decoded with DecodeReader
+const DefaultMaxBodySize = 64 * 1024
+
+// ErrBodyTooLarge is returned when a payload exceeds the size limit
+var ErrBodyTooLarge = errors.New("payload too large")
+
+// Validator is implemented by every decodable message or request body
+type Validator interface {
+	Validate() error
+}
+
+// FieldError describes a single invalid field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every offending field in a payload
+type ValidationError struct {
+	Fields []FieldError `json:"fields"`
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		parts[i] = f.Field + ": " + f.Message
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+// Add records an invalid field
+func (e *ValidationError) Add(field, message string) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: message})
+}
+
+// ErrOrNil returns the error if any field was recorded, nil otherwise
+func (e *ValidationError) ErrOrNil() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Decode strictly decodes a single JSON value into v and validates it.
+// Unknown fields, trailing data and type mismatches are rejected.
+func Decode(data []byte, v Validator) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil {
+		return decodeError(err)
+	}
+	if dec.More() {
+		return &ValidationError{Fields: []FieldError{{Field: "", Message: "unexpected data after JSON value"}}}
+	}
+
+	return v.Validate()
+}
+
+// DecodeReader reads at most limit bytes from r and decodes them with Decode
+func DecodeReader(r io.Reader, limit int64, v Validator) error {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) > limit {
+		return ErrBodyTooLarge
+	}
+	return Decode(data, v)
+}
+
+// decodeError turns an encoding/json error into a ValidationError
+func decodeError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+
+	switch {
+	case errors.As(err, &typeErr):
+		return &ValidationError{Fields: []FieldError{{
+			Field:   typeErr.Field,
+			Message: fmt.Sprintf("must be a %s", typeErr.Type),
+		}}}
+	case errors.As(err, &syntaxErr):
+		return &ValidationError{Fields: []FieldError{{
+			Message: fmt.Sprintf("malformed JSON at offset %d", syntaxErr.Offset),
+		}}}
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return &ValidationError{Fields: []FieldError{{Message: "empty or truncated JSON"}}}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return &ValidationError{Fields: []FieldError{{Field: field, Message: "unknown field"}}}
+	}
+
+	return &ValidationError{Fields: []FieldError{{Message: err.Error()}}}
+}
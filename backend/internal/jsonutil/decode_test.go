@@ -0,0 +1,132 @@
+package jsonutil
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// point is a body with one required and one bounded field
+type point struct {
+	X *int `json:"x"`
+	Y int  `json:"y"`
+}
+
+func (p *point) Validate() error {
+	verr := &ValidationError{}
+	if p.X == nil {
+		verr.Add("x", "is required")
+	}
+	if p.Y < 0 {
+		verr.Add("y", "must not be negative")
+	}
+	return verr.ErrOrNil()
+}
+
+// fieldsOf returns the ValidationError fields of err
+func fieldsOf(t *testing.T, err error) []FieldError {
+	t.Helper()
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("err = %v, want a ValidationError", err)
+	}
+	return verr.Fields
+}
+
+func TestDecode(t *testing.T) {
+	var p point
+	if err := Decode([]byte(`{"x":1,"y":2}`), &p); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if *p.X != 1 || p.Y != 2 {
+		t.Errorf("decoded %+v", p)
+	}
+	if err := Decode([]byte(" {\"x\":1}\n"), &point{}); err != nil {
+		t.Errorf("surrounding whitespace: %v", err)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		field string
+		msg   string
+	}{
+		{"unknown field", `{"x":1,"z":3}`, "z", "unknown field"},
+		{"trailing value", `{"x":1} {"x":2}`, "", "unexpected data after JSON value"},
+		{"trailing garbage", `{"x":1}x`, "", "unexpected data after JSON value"},
+		{"type mismatch", `{"x":"1"}`, "x", "must be a int"},
+		{"malformed", `{"x":1,}`, "", "malformed JSON at offset 8"},
+		{"empty", ``, "", "empty or truncated JSON"},
+		{"truncated", `{"x":1`, "", "empty or truncated JSON"},
+		{"fails Validate", `{"y":-1}`, "x", "is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields := fieldsOf(t, Decode([]byte(tt.data), &point{}))
+			if len(fields) == 0 {
+				t.Fatal("no fields reported")
+			}
+			if fields[0].Field != tt.field || fields[0].Message != tt.msg {
+				t.Errorf("first field = %+v, want %q: %q", fields[0], tt.field, tt.msg)
+			}
+		})
+	}
+}
+
+func TestDecodeReaderLimit(t *testing.T) {
+	body := `{"x":1,"y":2}`
+	if err := DecodeReader(strings.NewReader(body), int64(len(body)), &point{}); err != nil {
+		t.Errorf("body at the limit: %v", err)
+	}
+	if err := DecodeReader(strings.NewReader(body), int64(len(body)-1), &point{}); !errors.Is(err, ErrBodyTooLarge) {
+		t.Errorf("body one byte over the limit: err = %v, want ErrBodyTooLarge", err)
+	}
+
+	// Only limit+1 bytes are read from an oversized body
+	r := strings.NewReader(strings.Repeat(" ", 1<<20))
+	if err := DecodeReader(r, 10, &point{}); !errors.Is(err, ErrBodyTooLarge) {
+		t.Errorf("err = %v, want ErrBodyTooLarge", err)
+	}
+	if read := 1<<20 - r.Len(); read != 11 {
+		t.Errorf("read %d bytes of an oversized body, want 11", read)
+	}
+
+	// Under the limit the body is decoded strictly
+	if err := DecodeReader(strings.NewReader(`{"x":1,"q":0}`), DefaultMaxBodySize, &point{}); fieldsOf(t, err)[0].Field != "q" {
+		t.Errorf("unknown field under the limit: err = %v", err)
+	}
+}
+
+func TestValidationErrorFields(t *testing.T) {
+	verr := &ValidationError{}
+	if verr.ErrOrNil() != nil {
+		t.Error("ErrOrNil with no fields is not nil")
+	}
+	verr.Add("x", "is required")
+	verr.Add("y", "must not be negative")
+	verr.Add("x", "must be odd")
+
+	want := []FieldError{{"x", "is required"}, {"y", "must not be negative"}, {"x", "must be odd"}}
+	if len(verr.Fields) != len(want) {
+		t.Fatalf("fields = %+v, want %+v", verr.Fields, want)
+	}
+	for i := range want {
+		if verr.Fields[i] != want[i] {
+			t.Errorf("field %d = %+v, want %+v", i, verr.Fields[i], want[i])
+		}
+	}
+	if err := verr.ErrOrNil(); err != verr {
+		t.Errorf("ErrOrNil = %v, want the error itself", err)
+	}
+	if msg := verr.Error(); msg != "validation failed: x: is required; y: must not be negative; x: must be odd" {
+		t.Errorf("Error() = %q", msg)
+	}
+
+	// Every problem Validate finds is reported, in order
+	fields := fieldsOf(t, Decode([]byte(`{"y":-1}`), &point{}))
+	if len(fields) != 2 || fields[0].Field != "x" || fields[1].Field != "y" {
+		t.Errorf("fields = %+v, want x then y", fields)
+	}
+}
@@ -0,0 +1,522 @@
+package kafka
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/connect-four/internal/game"
+)
+
+// Bounds on the in-memory metrics so a long-running consumer doesn't grow
+// without limit
+const (
+	// hourlyRetention and dailyRetention are how far back the games per
+	// hour and per day buckets go
+	hourlyRetention = 7 * 24 * time.Hour
+	dailyRetention  = 90 * 24 * time.Hour
+
+	// maxTrackedPlayers caps PlayerStats; beyond it the least recently
+	// active players are dropped, down to playerEvictTarget so eviction
+	// doesn't run on every new player
+	maxTrackedPlayers = 10000
+	playerEvictTarget = maxTrackedPlayers * 9 / 10
+)
+
+// Bucket key layouts; they sort chronologically as strings
+const (
+	hourKeyLayout = "2006-01-02-15"
+	dayKeyLayout  = "2006-01-02"
+)
+
+// AnalyticsMetrics holds aggregated analytics data
+type AnalyticsMetrics struct {
+	TotalGames    int64          `json:"totalGames"`
+	TotalMoves    int64          `json:"totalMoves"`
+	BotGames      int64          `json:"botGames"`
+	TotalDuration int64          `json:"totalDuration"`
+	WinCounts     map[string]int `json:"winCounts"`
+
+	// Connection quality
+	Disconnects      int64 `json:"disconnects"`
+	Reconnects       int64 `json:"reconnects"`
+	FailedReconnects int64 `json:"failedReconnects"`
+	BotFallbacks     int64 `json:"botFallbacks"` // bot games after the matchmaking timeout
+
+	// Moves per column, over all moves and over opening moves only
+	ColumnMoves    map[int]int64 `json:"columnMoves"`
+	OpeningColumns map[int]int64 `json:"openingColumns"`
+
+	// Games won or drawn where the first mover is known, and how many of
+	// those the first mover won
+	FirstMoverGames int64 `json:"firstMoverGames"`
+	FirstMoverWins  int64 `json:"firstMoverWins"`
+
+	// Finished games by how they were decided, see game.WinType
+	WinTypes map[string]int64 `json:"winTypes"`
+
+	// Think time of human moves by move number, and of all bot moves
+	HumanThinkByMove map[int]ThinkTime `json:"humanThinkByMove"`
+	BotThink         ThinkTime         `json:"botThink"`
+
+	GamesPerHour map[string]int            `json:"gamesPerHour"`
+	GamesPerDay  map[string]int            `json:"gamesPerDay"`
+	PlayerStats  map[string]*PlayerMetrics `json:"playerStats"`
+	mu           sync.RWMutex
+}
+
+// PlayerMetrics holds per-player analytics
+type PlayerMetrics struct {
+	Wins        int   `json:"wins"`
+	Losses      int   `json:"losses"`
+	Draws       int   `json:"draws"`
+	TotalGames  int   `json:"totalGames"`
+	TotalMoves  int64 `json:"totalMoves"`
+	AvgDuration int64 `json:"avgDuration"` // seconds, over the player's ended games
+
+	Disconnects      int     `json:"disconnects"`
+	Reconnects       int     `json:"reconnects"`
+	FailedReconnects int     `json:"failedReconnects"`
+	DisconnectRate   float64 `json:"disconnectRate"` // disconnects per game
+
+	// Running totals behind AvgDuration
+	endedGames    int
+	totalDuration int64
+
+	lastSeen time.Time // for evicting inactive players
+}
+
+// ThinkTime totals the think time of a set of moves
+type ThinkTime struct {
+	Moves   int64 `json:"moves"`
+	TotalMs int64 `json:"totalMs"`
+}
+
+// add counts a move that took ms
+func (t *ThinkTime) add(ms int64) {
+	t.Moves++
+	t.TotalMs += ms
+}
+
+// averageMs returns the average think time, 0 without moves
+func (t ThinkTime) averageMs() float64 {
+	if t.Moves == 0 {
+		return 0
+	}
+	return float64(t.TotalMs) / float64(t.Moves)
+}
+
+// aggregator folds game events into AnalyticsMetrics. The Consumer feeds
+// it from the topic and LocalAnalytics straight from the emit calls.
+type aggregator struct {
+	metrics *AnalyticsMetrics
+}
+
+// newAggregator returns an aggregator with empty metrics
+func newAggregator() *aggregator {
+	return &aggregator{
+		metrics: &AnalyticsMetrics{
+			WinCounts:    make(map[string]int),
+			GamesPerHour: make(map[string]int),
+			GamesPerDay:  make(map[string]int),
+			PlayerStats:  make(map[string]*PlayerMetrics),
+
+			ColumnMoves:    make(map[int]int64),
+			OpeningColumns: make(map[int]int64),
+			WinTypes:       make(map[string]int64),
+
+			HumanThinkByMove: make(map[int]ThinkTime),
+		},
+	}
+}
+
+// applyLocked adds an event, whose Data holds the typed payload, to the
+// metrics; caller holds the lock
+func (a *aggregator) applyLocked(event GameEvent) {
+	if event.Simulated {
+		// Simulation runs must not skew player or game analytics
+		return
+	}
+
+	switch data := event.Data.(type) {
+	case GameStartData:
+		a.handleGameStart(event.Timestamp, data)
+	case MoveData:
+		a.handleMove(data)
+	case GameEndData:
+		a.handleGameEnd(data)
+	case DisconnectData:
+		a.handleDisconnect(data)
+	case ReconnectData:
+		a.handleReconnect(data)
+	case FallbackData:
+		a.metrics.BotFallbacks++
+	}
+}
+
+// ConnectionStats summarizes disconnects and reconnects across all games
+type ConnectionStats struct {
+	Disconnects          int64   `json:"disconnects"`
+	DisconnectsPerGame   float64 `json:"disconnectsPerGame"`
+	Reconnects           int64   `json:"reconnects"`
+	FailedReconnects     int64   `json:"failedReconnects"`
+	ReconnectSuccessRate float64 `json:"reconnectSuccessRate"` // of reconnect windows that closed, 0 if none did
+	BotFallbacks         int64   `json:"botFallbacks"`
+}
+
+// MoveStats summarizes where players move and how much moving first helps
+type MoveStats struct {
+	ColumnShare       map[int]float64 `json:"columnShare"`  // fraction of all moves per column
+	OpeningShare      map[int]float64 `json:"openingShare"` // fraction of opening moves per column
+	FirstMoverWinRate float64         `json:"firstMoverWinRate"`
+	FirstMoverGames   int64           `json:"firstMoverGames"` // games the win rate is over, draws included
+
+	// Average think times in milliseconds: of human moves overall and by
+	// move number, and of bot moves
+	AvgHumanThinkMs       float64         `json:"avgHumanThinkMs"`
+	AvgHumanThinkMsByMove map[int]float64 `json:"avgHumanThinkMsByMove"`
+	AvgBotThinkMs         float64         `json:"avgBotThinkMs"`
+}
+
+// GetMoveStats returns column popularity and the first mover's win rate
+func (a *aggregator) GetMoveStats() MoveStats {
+	a.metrics.mu.RLock()
+	defer a.metrics.mu.RUnlock()
+
+	stats := MoveStats{
+		ColumnShare:     shares(a.metrics.ColumnMoves),
+		OpeningShare:    shares(a.metrics.OpeningColumns),
+		FirstMoverGames: a.metrics.FirstMoverGames,
+	}
+	if stats.FirstMoverGames > 0 {
+		stats.FirstMoverWinRate = float64(a.metrics.FirstMoverWins) / float64(stats.FirstMoverGames)
+	}
+
+	var human ThinkTime
+	stats.AvgHumanThinkMsByMove = make(map[int]float64, len(a.metrics.HumanThinkByMove))
+	for moveNum, t := range a.metrics.HumanThinkByMove {
+		stats.AvgHumanThinkMsByMove[moveNum] = t.averageMs()
+		human.Moves += t.Moves
+		human.TotalMs += t.TotalMs
+	}
+	stats.AvgHumanThinkMs = human.averageMs()
+	stats.AvgBotThinkMs = a.metrics.BotThink.averageMs()
+	return stats
+}
+
+// shares turns per-column counts into fractions of their total
+func shares(counts map[int]int64) map[int]float64 {
+	var total int64
+	for _, n := range counts {
+		total += n
+	}
+	result := make(map[int]float64, len(counts))
+	for column, n := range counts {
+		result[column] = float64(n) / float64(total)
+	}
+	return result
+}
+
+// GetConnectionStats returns the disconnect and reconnect totals and rates
+func (a *aggregator) GetConnectionStats() ConnectionStats {
+	a.metrics.mu.RLock()
+	defer a.metrics.mu.RUnlock()
+
+	stats := ConnectionStats{
+		Disconnects:      a.metrics.Disconnects,
+		Reconnects:       a.metrics.Reconnects,
+		FailedReconnects: a.metrics.FailedReconnects,
+		BotFallbacks:     a.metrics.BotFallbacks,
+	}
+	if a.metrics.TotalGames > 0 {
+		stats.DisconnectsPerGame = float64(stats.Disconnects) / float64(a.metrics.TotalGames)
+	}
+	if closed := stats.Reconnects + stats.FailedReconnects; closed > 0 {
+		stats.ReconnectSuccessRate = float64(stats.Reconnects) / float64(closed)
+	}
+	return stats
+}
+
+// playerLocked returns a player's metrics, creating them on first sight.
+// The bot has no player metrics, so it gets nil. Caller holds the lock.
+func (a *aggregator) playerLocked(username string) *PlayerMetrics {
+	if username == "" || username == "BOT" {
+		return nil
+	}
+	stats := a.metrics.PlayerStats[username]
+	if stats != nil {
+		stats.lastSeen = time.Now()
+		return stats
+	}
+
+	stats = &PlayerMetrics{lastSeen: time.Now()}
+	a.metrics.PlayerStats[username] = stats
+	if len(a.metrics.PlayerStats) > maxTrackedPlayers {
+		a.evictPlayersLocked()
+	}
+	return stats
+}
+
+// evictPlayersLocked drops the least recently active players, and their
+// win counts, until playerEvictTarget remain; caller holds the lock
+func (a *aggregator) evictPlayersLocked() {
+	names := make([]string, 0, len(a.metrics.PlayerStats))
+	for name := range a.metrics.PlayerStats {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return a.metrics.PlayerStats[names[i]].lastSeen.Before(a.metrics.PlayerStats[names[j]].lastSeen)
+	})
+	for _, name := range names[:len(names)-playerEvictTarget] {
+		delete(a.metrics.PlayerStats, name)
+		delete(a.metrics.WinCounts, name)
+	}
+}
+
+// pruneBucketsLocked drops hourly and daily buckets older than their
+// retention; caller holds the lock
+func (a *aggregator) pruneBucketsLocked(now time.Time) {
+	oldestHour := now.Add(-hourlyRetention).Format(hourKeyLayout)
+	for key := range a.metrics.GamesPerHour {
+		if key < oldestHour {
+			delete(a.metrics.GamesPerHour, key)
+		}
+	}
+	oldestDay := now.Add(-dailyRetention).Format(dayKeyLayout)
+	for key := range a.metrics.GamesPerDay {
+		if key < oldestDay {
+			delete(a.metrics.GamesPerDay, key)
+		}
+	}
+}
+
+// handleGameStart processes game start events; caller holds the lock
+func (a *aggregator) handleGameStart(at time.Time, data GameStartData) {
+	a.metrics.TotalGames++
+
+	if data.IsVsBot {
+		a.metrics.BotGames++
+	}
+
+	// Track games per hour and day. Pruning runs when an hour first gets
+	// a game, and events replayed from beyond the retention are skipped.
+	now := time.Now()
+	hourKey := at.Format(hourKeyLayout)
+	if _, ok := a.metrics.GamesPerHour[hourKey]; !ok {
+		a.pruneBucketsLocked(now)
+	}
+	if at.After(now.Add(-hourlyRetention)) {
+		a.metrics.GamesPerHour[hourKey]++
+	}
+	if at.After(now.Add(-dailyRetention)) {
+		a.metrics.GamesPerDay[at.Format(dayKeyLayout)]++
+	}
+
+	for _, player := range []string{data.Player1, data.Player2} {
+		if stats := a.playerLocked(player); stats != nil {
+			stats.TotalGames++
+			stats.updateDisconnectRate()
+		}
+	}
+}
+
+// handleMove processes move events; caller holds the lock
+func (a *aggregator) handleMove(data MoveData) {
+	a.metrics.TotalMoves++
+	a.metrics.ColumnMoves[data.Column]++
+	if data.MoveNum == 1 {
+		a.metrics.OpeningColumns[data.Column]++
+	}
+	if data.Bot {
+		a.metrics.BotThink.add(data.ThinkMs)
+	} else {
+		t := a.metrics.HumanThinkByMove[data.MoveNum]
+		t.add(data.ThinkMs)
+		a.metrics.HumanThinkByMove[data.MoveNum] = t
+	}
+
+	if stats := a.playerLocked(data.Player); stats != nil {
+		stats.TotalMoves++
+	}
+}
+
+// handleDisconnect processes player disconnect events; caller holds the lock
+func (a *aggregator) handleDisconnect(data DisconnectData) {
+	a.metrics.Disconnects++
+
+	if stats := a.playerLocked(data.Player); stats != nil {
+		stats.Disconnects++
+		stats.updateDisconnectRate()
+	}
+}
+
+// handleReconnect processes player reconnect events; caller holds the lock
+func (a *aggregator) handleReconnect(data ReconnectData) {
+	stats := a.playerLocked(data.Player)
+	if data.Success {
+		a.metrics.Reconnects++
+		if stats != nil {
+			stats.Reconnects++
+		}
+	} else {
+		a.metrics.FailedReconnects++
+		if stats != nil {
+			stats.FailedReconnects++
+		}
+	}
+}
+
+// updateDisconnectRate recomputes DisconnectRate after either of its
+// counts changed
+func (p *PlayerMetrics) updateDisconnectRate() {
+	if p.TotalGames > 0 {
+		p.DisconnectRate = float64(p.Disconnects) / float64(p.TotalGames)
+	}
+}
+
+// handleGameEnd processes game end events: the winner gets a win and the
+// other player a loss, a draw counts for both, and an aborted game only
+// feeds the durations. Caller holds the lock.
+func (a *aggregator) handleGameEnd(data GameEndData) {
+	if data.Winner != "" {
+		a.metrics.WinCounts[data.Winner]++
+	}
+
+	a.metrics.TotalDuration += int64(data.DurationSeconds)
+	if data.WinType != "" {
+		a.metrics.WinTypes[data.WinType]++
+	}
+
+	// Aborted games have no result to credit the first mover with
+	if data.FirstMover != "" && (data.Winner != "" || data.Result == string(game.ResultDraw)) {
+		a.metrics.FirstMoverGames++
+		if data.Winner == data.FirstMover {
+			a.metrics.FirstMoverWins++
+		}
+	}
+
+	for _, player := range []string{data.Player1, data.Player2} {
+		stats := a.playerLocked(player)
+		if stats == nil {
+			continue
+		}
+		switch {
+		case data.Result == string(game.ResultDraw):
+			stats.Draws++
+		case data.Winner == "":
+			// Aborted, no result to attribute
+		case data.Winner == player:
+			stats.Wins++
+		default:
+			stats.Losses++
+		}
+
+		stats.endedGames++
+		stats.totalDuration += int64(data.DurationSeconds)
+		stats.AvgDuration = stats.totalDuration / int64(stats.endedGames)
+	}
+}
+
+// GetMetrics returns a copy of the current metrics
+func (a *aggregator) GetMetrics() *AnalyticsMetrics {
+	a.metrics.mu.RLock()
+	defer a.metrics.mu.RUnlock()
+
+	// Return a copy to avoid race conditions
+	copy := &AnalyticsMetrics{
+		TotalGames:    a.metrics.TotalGames,
+		TotalMoves:    a.metrics.TotalMoves,
+		BotGames:      a.metrics.BotGames,
+		TotalDuration: a.metrics.TotalDuration,
+		WinCounts:     make(map[string]int),
+		GamesPerHour:  make(map[string]int),
+		GamesPerDay:   make(map[string]int),
+		PlayerStats:   make(map[string]*PlayerMetrics),
+
+		Disconnects:      a.metrics.Disconnects,
+		Reconnects:       a.metrics.Reconnects,
+		FailedReconnects: a.metrics.FailedReconnects,
+		BotFallbacks:     a.metrics.BotFallbacks,
+
+		ColumnMoves:     make(map[int]int64, len(a.metrics.ColumnMoves)),
+		OpeningColumns:  make(map[int]int64, len(a.metrics.OpeningColumns)),
+		FirstMoverGames: a.metrics.FirstMoverGames,
+		FirstMoverWins:  a.metrics.FirstMoverWins,
+		WinTypes:        make(map[string]int64, len(a.metrics.WinTypes)),
+
+		HumanThinkByMove: make(map[int]ThinkTime, len(a.metrics.HumanThinkByMove)),
+		BotThink:         a.metrics.BotThink,
+	}
+
+	for k, v := range a.metrics.WinCounts {
+		copy.WinCounts[k] = v
+	}
+	for k, v := range a.metrics.GamesPerHour {
+		copy.GamesPerHour[k] = v
+	}
+	for k, v := range a.metrics.GamesPerDay {
+		copy.GamesPerDay[k] = v
+	}
+	for k, v := range a.metrics.ColumnMoves {
+		copy.ColumnMoves[k] = v
+	}
+	for k, v := range a.metrics.OpeningColumns {
+		copy.OpeningColumns[k] = v
+	}
+	for k, v := range a.metrics.WinTypes {
+		copy.WinTypes[k] = v
+	}
+	for k, v := range a.metrics.HumanThinkByMove {
+		copy.HumanThinkByMove[k] = v
+	}
+	for k, v := range a.metrics.PlayerStats {
+		stats := *v
+		copy.PlayerStats[k] = &stats
+	}
+
+	return copy
+}
+
+// GetAverageGameDuration returns the average game duration in seconds
+func (a *aggregator) GetAverageGameDuration() float64 {
+	a.metrics.mu.RLock()
+	defer a.metrics.mu.RUnlock()
+
+	if a.metrics.TotalGames == 0 {
+		return 0
+	}
+	return float64(a.metrics.TotalDuration) / float64(a.metrics.TotalGames)
+}
+
+// GetMostFrequentWinner returns the player with most wins
+func (a *aggregator) GetMostFrequentWinner() string {
+	a.metrics.mu.RLock()
+	defer a.metrics.mu.RUnlock()
+
+	maxWins := 0
+	winner := ""
+	for player, wins := range a.metrics.WinCounts {
+		if wins > maxWins {
+			maxWins = wins
+			winner = player
+		}
+	}
+	return winner
+}
+
+// GetGamesPerHour returns games played in the last 24 hours by hour
+func (a *aggregator) GetGamesPerHour() map[string]int {
+	a.metrics.mu.RLock()
+	defer a.metrics.mu.RUnlock()
+
+	now := time.Now()
+	result := make(map[string]int)
+
+	for i := 0; i < 24; i++ {
+		t := now.Add(-time.Duration(i) * time.Hour)
+		key := t.Format(hourKeyLayout)
+		result[key] = a.metrics.GamesPerHour[key]
+	}
+
+	return result
+}
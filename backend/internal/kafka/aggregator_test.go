@@ -0,0 +1,201 @@
+package kafka
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/connect-four/internal/game"
+)
+
+// eventFeed plays synthetic events into a consumer as consecutive
+// messages on partition 0
+type eventFeed struct {
+	t      *testing.T
+	c      *Consumer
+	offset int64
+}
+
+func newEventFeed(t *testing.T) *eventFeed {
+	return &eventFeed{t: t, c: newTestConsumer(t, StartPosition{Mode: StartOldest})}
+}
+
+// send processes one event of the given type carrying data
+func (f *eventFeed) send(eventType EventType, data any) {
+	f.t.Helper()
+	f.c.processMessage(eventMessage(f.t, 0, f.offset, GameEvent{
+		Version:   EventVersion,
+		Type:      eventType,
+		GameID:    "game",
+		Timestamp: time.Now(),
+		Data:      data,
+	}))
+	f.offset++
+}
+
+// game sends the start and end events of a game between player1 and
+// player2 ending with end
+func (f *eventFeed) game(player1, player2 string, end GameEndData) {
+	f.t.Helper()
+	end.Player1, end.Player2 = player1, player2
+	f.send(EventGameStart, GameStartData{Player1: player1, Player2: player2, IsVsBot: end.IsVsBot})
+	f.send(EventGameEnd, end)
+}
+
+func TestConsumerPlayerResults(t *testing.T) {
+	f := newEventFeed(t)
+	p1, p2, draw := string(game.ResultWinPlayer1), string(game.ResultWinPlayer2), string(game.ResultDraw)
+	f.game("alice", "bob", GameEndData{Winner: "alice", Result: p1, DurationSeconds: 60})
+	f.game("bob", "alice", GameEndData{Winner: "bob", Result: p1, DurationSeconds: 30})
+	f.game("alice", "bob", GameEndData{Result: draw, DurationSeconds: 150})
+	f.game("alice", "carol", GameEndData{Winner: "carol", Result: string(game.ResultForfeit), ForfeitedBy: "alice", DurationSeconds: 10})
+	f.game("carol", "BOT", GameEndData{Winner: "BOT", Result: p2, IsVsBot: true, DurationSeconds: 20})
+	// Aborted before anyone won: counts toward durations only
+	f.game("bob", "carol", GameEndData{Result: string(game.ResultAborted), DurationSeconds: 5})
+
+	metrics := f.c.GetMetrics()
+	tests := []struct {
+		player                     string
+		wins, losses, draws, games int
+		avgDuration                int64
+	}{
+		{"alice", 1, 2, 1, 4, (60 + 30 + 150 + 10) / 4},
+		{"bob", 1, 1, 1, 4, (60 + 30 + 150 + 5) / 4},
+		{"carol", 1, 1, 0, 3, (10 + 20 + 5) / 3},
+	}
+	for _, tt := range tests {
+		stats := metrics.PlayerStats[tt.player]
+		if stats == nil {
+			t.Errorf("%s has no stats", tt.player)
+			continue
+		}
+		if stats.Wins != tt.wins || stats.Losses != tt.losses || stats.Draws != tt.draws {
+			t.Errorf("%s: %d-%d-%d, want %d-%d-%d", tt.player, stats.Wins, stats.Losses, stats.Draws, tt.wins, tt.losses, tt.draws)
+		}
+		if stats.TotalGames != tt.games {
+			t.Errorf("%s: %d games, want %d", tt.player, stats.TotalGames, tt.games)
+		}
+		if stats.AvgDuration != tt.avgDuration {
+			t.Errorf("%s: average duration %ds, want %ds", tt.player, stats.AvgDuration, tt.avgDuration)
+		}
+	}
+	if _, ok := metrics.PlayerStats["BOT"]; ok {
+		t.Error("the bot has player stats")
+	}
+
+	if metrics.WinCounts["alice"] != 1 || metrics.WinCounts["BOT"] != 1 {
+		t.Errorf("win counts = %v", metrics.WinCounts)
+	}
+	if metrics.TotalGames != 6 || metrics.BotGames != 1 {
+		t.Errorf("%d games, %d against the bot; want 6 and 1", metrics.TotalGames, metrics.BotGames)
+	}
+	if metrics.TotalDuration != 60+30+150+10+20+5 {
+		t.Errorf("total duration = %ds", metrics.TotalDuration)
+	}
+}
+
+// TestConsumerDecodesTypedData checks a payload that arrives as raw JSON
+// reaches the aggregation as its typed struct, whole numbers and all
+func TestConsumerDecodesTypedData(t *testing.T) {
+	f := newEventFeed(t)
+	raw := `{"version":1,"type":"game_end","gameId":"g","timestamp":"2026-01-02T03:04:05Z",` +
+		`"data":{"player1":"alice","player2":"bob","winner":"bob","result":"player2_win","durationSeconds":42,"totalMoves":17}}`
+	f.c.processMessage(rawMessage(0, 0, raw))
+
+	metrics := f.c.GetMetrics()
+	if s := metrics.PlayerStats["alice"]; s == nil || s.Losses != 1 || s.AvgDuration != 42 {
+		t.Errorf("alice = %+v, want a 42s loss", s)
+	}
+	if s := metrics.PlayerStats["bob"]; s == nil || s.Wins != 1 || s.AvgDuration != 42 {
+		t.Errorf("bob = %+v, want a 42s win", s)
+	}
+
+	// A payload of the wrong shape is skipped rather than half applied
+	f.c.processMessage(rawMessage(0, 1, `{"version":1,"type":"game_end","data":{"durationSeconds":"long"}}`))
+	if got := f.c.GetReplayProgress().EventsSkipped; got != 1 {
+		t.Errorf("skipped %d events, want 1", got)
+	}
+	if got := f.c.GetMetrics().TotalDuration; got != 42 {
+		t.Errorf("total duration = %ds after a malformed event, want 42s", got)
+	}
+}
+
+// moves sends move events for columns, played alternately from first
+func (f *eventFeed) moves(first, second string, columns ...int) {
+	f.t.Helper()
+	players := [2]string{first, second}
+	for i, col := range columns {
+		f.send(EventMove, MoveData{Player: players[i%2], Column: col, MoveNum: i + 1, ThinkMs: int64(100 * (i + 1))})
+	}
+}
+
+// approx reports whether got is want to within rounding
+func approx(got, want float64) bool {
+	return math.Abs(got-want) < 1e-9
+}
+
+func TestConsumerMoveStats(t *testing.T) {
+	f := newEventFeed(t)
+	p1, p2 := string(game.ResultWinPlayer1), string(game.ResultWinPlayer2)
+
+	// The first mover wins
+	f.moves("alice", "bob", 3, 3, 4, 4, 5, 5, 6)
+	f.game("alice", "bob", GameEndData{Winner: "alice", Result: p1, FirstMover: "alice"})
+	// Player 2 moved first and won
+	f.moves("dave", "carol", 3, 2)
+	f.game("carol", "dave", GameEndData{Winner: "dave", Result: p2, FirstMover: "dave"})
+	// The first mover loses
+	f.moves("erin", "frank", 0, 1)
+	f.game("erin", "frank", GameEndData{Winner: "frank", Result: p2, FirstMover: "erin"})
+	// A draw counts as a game the first mover didn't win
+	f.moves("grace", "heidi", 6, 6)
+	f.game("grace", "heidi", GameEndData{Result: string(game.ResultDraw), FirstMover: "grace"})
+	// Aborted games and ones without a first mover have no say
+	f.moves("ivan", "judy", 3)
+	f.game("ivan", "judy", GameEndData{Result: string(game.ResultAborted), FirstMover: "ivan"})
+	f.game("ivan", "judy", GameEndData{Winner: "ivan", Result: p1})
+
+	stats := f.c.GetMoveStats()
+
+	// 14 moves: column 3 four times, 6 three times, 4 and 5 twice, the
+	// rest once
+	wantColumns := map[int]float64{0: 1.0 / 14, 1: 1.0 / 14, 2: 1.0 / 14, 3: 4.0 / 14, 4: 2.0 / 14, 5: 2.0 / 14, 6: 3.0 / 14}
+	if len(stats.ColumnShare) != len(wantColumns) {
+		t.Errorf("column share = %v, want %v", stats.ColumnShare, wantColumns)
+	}
+	for col, want := range wantColumns {
+		if !approx(stats.ColumnShare[col], want) {
+			t.Errorf("column %d share = %.4f, want %.4f", col, stats.ColumnShare[col], want)
+		}
+	}
+
+	// Five openings: three in column 3
+	wantOpenings := map[int]float64{0: 0.2, 3: 0.6, 6: 0.2}
+	if len(stats.OpeningShare) != len(wantOpenings) {
+		t.Errorf("opening share = %v, want %v", stats.OpeningShare, wantOpenings)
+	}
+	for col, want := range wantOpenings {
+		if !approx(stats.OpeningShare[col], want) {
+			t.Errorf("column %d opening share = %.4f, want %.4f", col, stats.OpeningShare[col], want)
+		}
+	}
+
+	if stats.FirstMoverGames != 4 || !approx(stats.FirstMoverWinRate, 0.5) {
+		t.Errorf("first mover won %.2f of %d games, want 0.50 of 4", stats.FirstMoverWinRate, stats.FirstMoverGames)
+	}
+
+	metrics := f.c.GetMetrics()
+	if metrics.ColumnMoves[3] != 4 || metrics.OpeningColumns[3] != 3 {
+		t.Errorf("column 3 counts = %d moves, %d openings; want 4 and 3", metrics.ColumnMoves[3], metrics.OpeningColumns[3])
+	}
+	if metrics.FirstMoverWins != 2 {
+		t.Errorf("first mover wins = %d, want 2", metrics.FirstMoverWins)
+	}
+}
+
+func TestMoveStatsEmpty(t *testing.T) {
+	stats := newEventFeed(t).c.GetMoveStats()
+	if len(stats.ColumnShare) != 0 || len(stats.OpeningShare) != 0 || stats.FirstMoverGames != 0 || stats.FirstMoverWinRate != 0 {
+		t.Errorf("move stats before any event = %+v", stats)
+	}
+}
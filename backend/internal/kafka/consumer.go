@@ -3,290 +3,440 @@ package kafka
 import (
 	"context"
 	"encoding/json"
-	"log"
-	"os"
+	"errors"
+	"fmt"
+	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/IBM/sarama"
 )
 
-// AnalyticsMetrics holds aggregated analytics data
-type AnalyticsMetrics struct {
-	TotalGames       int64              `json:"totalGames"`
-	TotalMoves       int64              `json:"totalMoves"`
-	BotGames         int64              `json:"botGames"`
-	TotalDuration    int64              `json:"totalDuration"`
-	WinCounts        map[string]int     `json:"winCounts"`
-	GamesPerHour     map[string]int     `json:"gamesPerHour"`
-	GamesPerDay      map[string]int     `json:"gamesPerDay"`
-	PlayerStats      map[string]*PlayerMetrics `json:"playerStats"`
-	mu               sync.RWMutex
+// StartMode selects where the consumer begins reading when it starts
+type StartMode string
+
+const (
+	// StartOldest replays the whole topic into the in-memory metrics
+	StartOldest StartMode = "oldest"
+
+	// StartNewest only aggregates events produced after startup
+	StartNewest StartMode = "newest"
+
+	// StartTimestamp replays events produced at or after a timestamp
+	StartTimestamp StartMode = "timestamp"
+)
+
+// StartPosition is the parsed KAFKA_CONSUME_FROM setting
+type StartPosition struct {
+	Mode StartMode
+	From time.Time // only for StartTimestamp
+}
+
+// ParseStartPosition parses "oldest", "newest" or an RFC3339 timestamp.
+// An empty value keeps the historical default of replaying from the oldest offset.
+func ParseStartPosition(value string) (StartPosition, error) {
+	switch value {
+	case "", string(StartOldest):
+		return StartPosition{Mode: StartOldest}, nil
+	case string(StartNewest):
+		return StartPosition{Mode: StartNewest}, nil
+	}
+
+	from, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return StartPosition{}, fmt.Errorf("KAFKA_CONSUME_FROM must be oldest, newest or an RFC3339 timestamp: %w", err)
+	}
+	return StartPosition{Mode: StartTimestamp, From: from}, nil
+}
+
+// String describes the start position
+func (p StartPosition) String() string {
+	if p.Mode == StartTimestamp {
+		return string(p.Mode) + ":" + p.From.Format(time.RFC3339)
+	}
+	return string(p.Mode)
 }
 
-// PlayerMetrics holds per-player analytics
-type PlayerMetrics struct {
-	Wins        int   `json:"wins"`
-	Losses      int   `json:"losses"`
-	Draws       int   `json:"draws"`
-	TotalGames  int   `json:"totalGames"`
-	TotalMoves  int64 `json:"totalMoves"`
-	AvgDuration int64 `json:"avgDuration"`
+// ReplayProgress reports how far the consumer is through the backlog
+type ReplayProgress struct {
+	StartMode          string `json:"startMode"`
+	EventsProcessed    int64  `json:"eventsProcessed"`
+	EventsSkipped      int64  `json:"eventsSkipped"`      // not counted in the metrics
+	EventsDeduplicated int64  `json:"eventsDeduplicated"` // replayed events the restored snapshot already counted
+	DeadLettered       int64  `json:"deadLettered"`       // skipped events sent to the dead-letter topic
+	EstimatedRemaining int64  `json:"estimatedRemaining"`
+	CaughtUp           bool   `json:"caughtUp"`
+	Connected          bool   `json:"connected"`
 }
 
 // Consumer handles Kafka event consumption for analytics
 type Consumer struct {
+	brokers []string
+	config  *sarama.Config
+
+	// Set once connected; read by Stop under connMu
+	client   sarama.Client
 	consumer sarama.ConsumerGroup
-	metrics  *AnalyticsMetrics
-	ctx      context.Context
-	cancel   context.CancelFunc
+	connMu   sync.Mutex
+
+	*aggregator
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	start         StartPosition
+	startApplied  atomic.Bool
+	processed     atomic.Int64
+	skipped       atomic.Int64    // undecodable or from a newer event version
+	deduplicated  atomic.Int64    // replayed events already in the restored snapshot
+	deadLettered  atomic.Int64    // skipped messages handed to deadLetters
+	remaining     map[int32]int64 // partition -> messages behind the high-water mark
+	assigned      bool
+	progressMutex sync.Mutex
+
+	// Next offset to read per partition, and the newest event timestamp
+	// counted per partition, guarded by metrics.mu so they always match the
+	// events the metrics include
+	offsets    map[int32]int64
+	eventTimes map[int32]time.Time
+
+	// Offsets restored from a snapshot, used instead of start on the first session
+	resumeFrom map[int32]int64
+
+	// When replaying from a timestamp over a restored snapshot, the newest
+	// event timestamp the snapshot counted per partition; events up to it
+	// are not counted again. Set before Start and only read after.
+	dedupeThrough map[int32]time.Time
+
+	snapshots        SnapshotStore
+	snapshotInterval time.Duration
+
+	// Where skipped messages are published, nil to only log them
+	deadLetters *Producer
 }
 
-// NewConsumer creates a new Kafka consumer
-func NewConsumer() (*Consumer, error) {
-	brokers := os.Getenv("KAFKA_BROKERS")
-	if brokers == "" {
-		brokers = "localhost:9092"
+// NewConsumer creates a new Kafka consumer reading from brokers, starting
+// at start when the group has no committed offsets. It connects on Start.
+func NewConsumer(brokers []string, start StartPosition) (*Consumer, error) {
+	if len(brokers) == 0 {
+		return nil, errors.New("no Kafka brokers configured")
 	}
 
 	config := sarama.NewConfig()
 	config.Consumer.Group.Rebalance.Strategy = sarama.NewBalanceStrategyRoundRobin()
 	config.Consumer.Offsets.Initial = sarama.OffsetOldest
-
-	consumer, err := sarama.NewConsumerGroup([]string{brokers}, "analytics-consumer", config)
-	if err != nil {
-		return nil, err
+	if start.Mode == StartNewest {
+		config.Consumer.Offsets.Initial = sarama.OffsetNewest
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 
 	c := &Consumer{
-		consumer: consumer,
-		metrics: &AnalyticsMetrics{
-			WinCounts:    make(map[string]int),
-			GamesPerHour: make(map[string]int),
-			GamesPerDay:  make(map[string]int),
-			PlayerStats:  make(map[string]*PlayerMetrics),
-		},
-		ctx:    ctx,
-		cancel: cancel,
+		brokers:    brokers,
+		config:     config,
+		start:      start,
+		remaining:  make(map[int32]int64),
+		offsets:    make(map[int32]int64),
+		eventTimes: make(map[int32]time.Time),
+		aggregator: newAggregator(),
+		ctx:        ctx,
+		cancel:     cancel,
 	}
 
 	return c, nil
 }
 
+// SetDeadLetters makes the consumer publish messages it skips, because they
+// can't be decoded or are from a newer event version, to TopicDeadLetters
+// through p
+func (c *Consumer) SetDeadLetters(p *Producer) {
+	c.deadLetters = p
+}
+
 // Start begins consuming events
 func (c *Consumer) Start() {
+	if c.snapshots != nil {
+		go c.snapshotLoop()
+	}
+
 	go func() {
+		// Keep trying until Kafka is reachable; the metrics stay available
+		// (restored or empty) meanwhile
+		if !retryConnect(c.ctx, "consumer", c.brokers, c.connect) {
+			return
+		}
+		slog.Info("Kafka consumer connected", "brokers", c.brokers)
+
 		for {
 			if err := c.consumer.Consume(c.ctx, []string{TopicGameEvents}, c); err != nil {
-				log.Printf("Consumer error: %v", err)
+				slog.Error("Kafka consumer error", "error", err)
 			}
 			if c.ctx.Err() != nil {
 				return
 			}
 		}
 	}()
-	log.Println("Kafka consumer started")
+	slog.Info("Kafka consumer started")
 }
 
-// Setup is called at the beginning of a new session
-func (c *Consumer) Setup(sarama.ConsumerGroupSession) error {
-	return nil
-}
+// connect creates the client and consumer group
+func (c *Consumer) connect() error {
+	client, err := sarama.NewClient(c.brokers, c.config)
+	if err != nil {
+		return err
+	}
 
-// Cleanup is called at the end of a session
-func (c *Consumer) Cleanup(sarama.ConsumerGroupSession) error {
-	return nil
-}
+	consumer, err := sarama.NewConsumerGroupFromClient("analytics-consumer", client)
+	if err != nil {
+		client.Close()
+		return err
+	}
 
-// ConsumeClaim processes messages from a partition
-func (c *Consumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
-	for msg := range claim.Messages() {
-		c.processMessage(msg)
-		session.MarkMessage(msg, "")
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+	if c.ctx.Err() != nil {
+		// Stopped while connecting
+		consumer.Close()
+		client.Close()
+		return nil
 	}
+	c.client, c.consumer = client, consumer
 	return nil
 }
 
-// processMessage handles a single event message
-func (c *Consumer) processMessage(msg *sarama.ConsumerMessage) {
-	var event GameEvent
-	if err := json.Unmarshal(msg.Value, &event); err != nil {
-		log.Printf("Error unmarshaling event: %v", err)
-		return
-	}
-
-	c.metrics.mu.Lock()
-	defer c.metrics.mu.Unlock()
+// IsConnected reports whether the consumer has reached Kafka
+func (c *Consumer) IsConnected() bool {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+	return c.client != nil
+}
 
-	switch event.Type {
-	case EventGameStart:
-		c.handleGameStart(event)
-	case EventMove:
-		c.handleMove(event)
-	case EventGameEnd:
-		c.handleGameEnd(event)
+// Setup is called at the beginning of a new session. On the first session it
+// moves the group's offsets to the restored snapshot's offsets or else the
+// configured start position, so a restart doesn't silently resume from
+// wherever the group last committed.
+func (c *Consumer) Setup(session sarama.ConsumerGroupSession) error {
+	if !c.startApplied.CompareAndSwap(false, true) {
+		return nil
 	}
-}
 
-// handleGameStart processes game start events
-func (c *Consumer) handleGameStart(event GameEvent) {
-	data, ok := event.Data.(map[string]interface{})
-	if !ok {
-		return
+	for topic, partitions := range session.Claims() {
+		for _, partition := range partitions {
+			offset, err := c.startOffset(topic, partition)
+			if err != nil {
+				slog.Error("resolving start offset failed", "start", c.startMode(), "partition", partition, "error", err)
+				continue
+			}
+			// ResetOffset only moves backwards and MarkOffset only forwards
+			session.ResetOffset(topic, partition, offset, "")
+			session.MarkOffset(topic, partition, offset, "")
+		}
 	}
 
-	c.metrics.TotalGames++
+	slog.Info("Kafka consumer starting", "from", c.startMode())
+	return nil
+}
 
-	if isVsBot, ok := data["isVsBot"].(bool); ok && isVsBot {
-		c.metrics.BotGames++
+// startOffset resolves where the first session reads a partition from
+func (c *Consumer) startOffset(topic string, partition int32) (int64, error) {
+	oldest, err := c.client.GetOffset(topic, partition, sarama.OffsetOldest)
+	if err != nil {
+		return 0, err
 	}
 
-	// Track games per hour and day
-	hourKey := event.Timestamp.Format("2006-01-02-15")
-	dayKey := event.Timestamp.Format("2006-01-02")
-	c.metrics.GamesPerHour[hourKey]++
-	c.metrics.GamesPerDay[dayKey]++
-
-	// Initialize player stats
-	if player1, ok := data["player1"].(string); ok {
-		if c.metrics.PlayerStats[player1] == nil {
-			c.metrics.PlayerStats[player1] = &PlayerMetrics{}
+	if c.resumeFrom != nil {
+		offset, ok := c.resumeFrom[partition]
+		if !ok {
+			return oldest, nil // not in the snapshot, so nothing of it was counted
+		}
+		if offset < oldest {
+			slog.Warn("topic retention dropped events after the analytics snapshot; they are missing from the metrics",
+				"partition", partition, "snapshotOffset", offset, "oldestOffset", oldest)
+			return oldest, nil
 		}
-		c.metrics.PlayerStats[player1].TotalGames++
+		return offset, nil
 	}
-	if player2, ok := data["player2"].(string); ok && player2 != "BOT" {
-		if c.metrics.PlayerStats[player2] == nil {
-			c.metrics.PlayerStats[player2] = &PlayerMetrics{}
+
+	switch c.start.Mode {
+	case StartNewest:
+		return c.client.GetOffset(topic, partition, sarama.OffsetNewest)
+	case StartTimestamp:
+		offset, err := c.client.GetOffset(topic, partition, c.start.From.UnixMilli())
+		if err == nil && offset == sarama.OffsetNewest {
+			// No message at or after the timestamp
+			return c.client.GetOffset(topic, partition, sarama.OffsetNewest)
 		}
-		c.metrics.PlayerStats[player2].TotalGames++
+		return offset, err
+	default:
+		return oldest, nil
 	}
 }
 
-// handleMove processes move events
-func (c *Consumer) handleMove(event GameEvent) {
-	data, ok := event.Data.(map[string]interface{})
-	if !ok {
-		return
+// startMode describes where the consumer started
+func (c *Consumer) startMode() string {
+	if c.resumeFrom != nil {
+		return "snapshot"
+	}
+	if c.dedupeThrough != nil {
+		return c.start.String() + "+snapshot"
 	}
+	return c.start.String()
+}
 
-	c.metrics.TotalMoves++
+// Cleanup is called at the end of a session
+func (c *Consumer) Cleanup(sarama.ConsumerGroupSession) error {
+	return nil
+}
 
-	if player, ok := data["player"].(string); ok {
-		if c.metrics.PlayerStats[player] == nil {
-			c.metrics.PlayerStats[player] = &PlayerMetrics{}
-		}
-		c.metrics.PlayerStats[player].TotalMoves++
+// ConsumeClaim processes messages from a partition
+func (c *Consumer) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for msg := range claim.Messages() {
+		c.processMessage(msg)
+		session.MarkMessage(msg, "")
+		c.recordProgress(claim.Partition(), claim.HighWaterMarkOffset()-msg.Offset-1)
 	}
+	return nil
 }
 
-// handleGameEnd processes game end events
-func (c *Consumer) handleGameEnd(event GameEvent) {
-	data, ok := event.Data.(map[string]interface{})
-	if !ok {
-		return
+// recordProgress tracks how far a partition is behind its high-water mark
+func (c *Consumer) recordProgress(partition int32, behind int64) {
+	c.processed.Add(1)
+	if behind < 0 {
+		behind = 0
 	}
 
-	// Track winner
-	if winner, ok := data["winner"].(string); ok && winner != "" {
-		c.metrics.WinCounts[winner]++
-		if c.metrics.PlayerStats[winner] != nil {
-			c.metrics.PlayerStats[winner].Wins++
-		}
+	c.progressMutex.Lock()
+	c.remaining[partition] = behind
+	c.assigned = true
+	c.progressMutex.Unlock()
+}
+
+// GetReplayProgress returns the start mode and catch-up progress
+func (c *Consumer) GetReplayProgress() ReplayProgress {
+	progress := ReplayProgress{
+		StartMode:          c.startMode(),
+		EventsProcessed:    c.processed.Load(),
+		EventsSkipped:      c.skipped.Load(),
+		EventsDeduplicated: c.deduplicated.Load(),
+		DeadLettered:       c.deadLettered.Load(),
 	}
 
-	// Track duration
-	if duration, ok := data["durationSeconds"].(float64); ok {
-		c.metrics.TotalDuration += int64(duration)
+	c.progressMutex.Lock()
+	for _, behind := range c.remaining {
+		progress.EstimatedRemaining += behind
 	}
+	c.progressMutex.Unlock()
+
+	// With no messages yet there is nothing to catch up on, but an
+	// unconnected consumer can't know that
+	progress.Connected = c.IsConnected()
+	progress.CaughtUp = progress.Connected && progress.EstimatedRemaining == 0
+	return progress
 }
 
-// GetMetrics returns a copy of the current metrics
-func (c *Consumer) GetMetrics() *AnalyticsMetrics {
-	c.metrics.mu.RLock()
-	defer c.metrics.mu.RUnlock()
-
-	// Return a copy to avoid race conditions
-	copy := &AnalyticsMetrics{
-		TotalGames:    c.metrics.TotalGames,
-		TotalMoves:    c.metrics.TotalMoves,
-		BotGames:      c.metrics.BotGames,
-		TotalDuration: c.metrics.TotalDuration,
-		WinCounts:     make(map[string]int),
-		GamesPerHour:  make(map[string]int),
-		GamesPerDay:   make(map[string]int),
-		PlayerStats:   make(map[string]*PlayerMetrics),
-	}
+// incomingEvent is a GameEvent whose payload is kept raw until the event
+// type says which Data struct to decode it into
+type incomingEvent struct {
+	GameEvent
+	Data json.RawMessage `json:"data"`
+}
 
-	for k, v := range c.metrics.WinCounts {
-		copy.WinCounts[k] = v
-	}
-	for k, v := range c.metrics.GamesPerHour {
-		copy.GamesPerHour[k] = v
-	}
-	for k, v := range c.metrics.GamesPerDay {
-		copy.GamesPerDay[k] = v
+// processMessage handles a single event message
+func (c *Consumer) processMessage(msg *sarama.ConsumerMessage) {
+	var event incomingEvent
+	err := json.Unmarshal(msg.Value, &event)
+	if event.Version == 0 {
+		event.Version = 1 // written before events were versioned
 	}
-	for k, v := range c.metrics.PlayerStats {
-		copy.PlayerStats[k] = &PlayerMetrics{
-			Wins:       v.Wins,
-			Losses:     v.Losses,
-			Draws:      v.Draws,
-			TotalGames: v.TotalGames,
-			TotalMoves: v.TotalMoves,
+	if err == nil {
+		if event.Version > EventVersion {
+			// Written by newer code; guessing at its format could corrupt the metrics
+			err = fmt.Errorf("unknown event version %d", event.Version)
+		} else {
+			event.GameEvent.Data, err = decodeData(event.Type, event.Data)
 		}
 	}
+	if err != nil {
+		c.skip(msg, event.GameEvent, err)
+	}
 
-	return copy
-}
+	c.metrics.mu.Lock()
+	defer c.metrics.mu.Unlock()
 
-// GetAverageGameDuration returns the average game duration in seconds
-func (c *Consumer) GetAverageGameDuration() float64 {
-	c.metrics.mu.RLock()
-	defer c.metrics.mu.RUnlock()
+	// Skipped messages advance the offset too, so a snapshot restore
+	// doesn't read them again
+	c.offsets[msg.Partition] = msg.Offset + 1
 
-	if c.metrics.TotalGames == 0 {
-		return 0
+	if err != nil {
+		return
+	}
+	if through, ok := c.dedupeThrough[msg.Partition]; ok && !event.Timestamp.After(through) {
+		c.deduplicated.Add(1)
+		return
+	}
+	c.applyLocked(event.GameEvent)
+	if event.Timestamp.After(c.eventTimes[msg.Partition]) {
+		c.eventTimes[msg.Partition] = event.Timestamp
 	}
-	return float64(c.metrics.TotalDuration) / float64(c.metrics.TotalGames)
 }
 
-// GetMostFrequentWinner returns the player with most wins
-func (c *Consumer) GetMostFrequentWinner() string {
-	c.metrics.mu.RLock()
-	defer c.metrics.mu.RUnlock()
-
-	maxWins := 0
-	winner := ""
-	for player, wins := range c.metrics.WinCounts {
-		if wins > maxWins {
-			maxWins = wins
-			winner = player
-		}
+// skip counts a message left out of the metrics and dead-letters it
+func (c *Consumer) skip(msg *sarama.ConsumerMessage, event GameEvent, reason error) {
+	c.skipped.Add(1)
+	slog.Warn("skipping event", "type", event.Type, "gameID", event.GameID,
+		"partition", msg.Partition, "offset", msg.Offset, "error", reason)
+
+	if c.deadLetters != nil {
+		c.deadLetters.SendDeadLetter(msg, reason)
+		c.deadLettered.Add(1)
 	}
-	return winner
 }
 
-// GetGamesPerHour returns games played in the last 24 hours by hour
-func (c *Consumer) GetGamesPerHour() map[string]int {
-	c.metrics.mu.RLock()
-	defer c.metrics.mu.RUnlock()
-
-	now := time.Now()
-	result := make(map[string]int)
-	
-	for i := 0; i < 24; i++ {
-		t := now.Add(-time.Duration(i) * time.Hour)
-		key := t.Format("2006-01-02-15")
-		result[key] = c.metrics.GamesPerHour[key]
+// decodeData decodes an event payload into the Data struct for its type.
+// Unknown types decode to nil, which the aggregation ignores.
+func decodeData(eventType EventType, raw json.RawMessage) (any, error) {
+	var data any
+	var err error
+	switch eventType {
+	case EventGameStart:
+		var start GameStartData
+		err = json.Unmarshal(raw, &start)
+		data = start
+	case EventMove:
+		var move MoveData
+		err = json.Unmarshal(raw, &move)
+		data = move
+	case EventGameEnd:
+		var end GameEndData
+		err = json.Unmarshal(raw, &end)
+		data = end
+	case EventPlayerDisconnect:
+		var disconnect DisconnectData
+		err = json.Unmarshal(raw, &disconnect)
+		data = disconnect
+	case EventPlayerReconnect:
+		var reconnect ReconnectData
+		err = json.Unmarshal(raw, &reconnect)
+		data = reconnect
+	case EventMatchmakingFallback:
+		var fallback FallbackData
+		err = json.Unmarshal(raw, &fallback)
+		data = fallback
 	}
-	
-	return result
+	return data, err
 }
 
 // Stop stops the consumer
 func (c *Consumer) Stop() {
 	c.cancel()
-	c.consumer.Close()
+	if c.snapshots != nil {
+		c.saveSnapshot()
+	}
+
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+	if c.consumer != nil {
+		c.consumer.Close()
+		c.client.Close()
+	}
 }
@@ -0,0 +1,260 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+)
+
+// fakeClient answers GetOffset from fixed offsets; the consumer calls
+// nothing else while resolving where to start
+type fakeClient struct {
+	sarama.Client
+	oldest, newest int64
+	byTime         map[int64]int64 // timestamp in ms -> first offset at or after it
+}
+
+func (c *fakeClient) GetOffset(topic string, partition int32, at int64) (int64, error) {
+	switch at {
+	case sarama.OffsetOldest:
+		return c.oldest, nil
+	case sarama.OffsetNewest:
+		return c.newest, nil
+	}
+	if offset, ok := c.byTime[at]; ok {
+		return offset, nil
+	}
+	return sarama.OffsetNewest, nil
+}
+
+// memorySnapshots keeps the latest snapshot in memory
+type memorySnapshots struct {
+	data []byte
+}
+
+func (s *memorySnapshots) SaveAnalyticsSnapshot(ctx context.Context, data []byte) error {
+	s.data = data
+	return nil
+}
+
+func (s *memorySnapshots) LatestAnalyticsSnapshot(ctx context.Context) ([]byte, error) {
+	return s.data, nil
+}
+
+func newTestConsumer(t *testing.T, start StartPosition) *Consumer {
+	t.Helper()
+	c, err := NewConsumer([]string{"localhost:9092"}, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(c.cancel)
+	return c
+}
+
+// eventMessage is event encoded as a message on partition at offset
+func eventMessage(t *testing.T, partition int32, offset int64, event GameEvent) *sarama.ConsumerMessage {
+	t.Helper()
+	value, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &sarama.ConsumerMessage{Topic: TopicGameEvents, Partition: partition, Offset: offset, Value: value}
+}
+
+// rawMessage is a message on partition at offset with value as written
+func rawMessage(partition int32, offset int64, value string) *sarama.ConsumerMessage {
+	return &sarama.ConsumerMessage{Topic: TopicGameEvents, Partition: partition, Offset: offset, Value: []byte(value)}
+}
+
+// gameStart is a game_start message on partition at offset, stamped at
+func gameStart(t *testing.T, partition int32, offset int64, at time.Time) *sarama.ConsumerMessage {
+	t.Helper()
+	return eventMessage(t, partition, offset, GameEvent{
+		Version:   EventVersion,
+		Type:      EventGameStart,
+		GameID:    "game",
+		Timestamp: at,
+		Data:      GameStartData{Player1: "alice", Player2: "bob"},
+	})
+}
+
+func TestParseStartPosition(t *testing.T) {
+	from := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  StartPosition
+	}{
+		{"", StartPosition{Mode: StartOldest}},
+		{"oldest", StartPosition{Mode: StartOldest}},
+		{"newest", StartPosition{Mode: StartNewest}},
+		{"2026-03-04T05:06:07Z", StartPosition{Mode: StartTimestamp, From: from}},
+	}
+	for _, tt := range tests {
+		got, err := ParseStartPosition(tt.value)
+		if err != nil || got.Mode != tt.want.Mode || !got.From.Equal(tt.want.From) {
+			t.Errorf("ParseStartPosition(%q) = %+v, %v; want %+v", tt.value, got, err, tt.want)
+		}
+	}
+
+	for _, value := range []string{"latest", "2026-03-04", "yesterday"} {
+		if _, err := ParseStartPosition(value); err == nil {
+			t.Errorf("ParseStartPosition(%q) succeeded", value)
+		}
+	}
+}
+
+func TestStartOffset(t *testing.T) {
+	from := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	client := &fakeClient{oldest: 10, newest: 100, byTime: map[int64]int64{from.UnixMilli(): 40}}
+	tests := []struct {
+		name       string
+		start      StartPosition
+		resumeFrom map[int32]int64
+		want       int64
+	}{
+		{"oldest", StartPosition{Mode: StartOldest}, nil, 10},
+		{"newest", StartPosition{Mode: StartNewest}, nil, 100},
+		{"timestamp", StartPosition{Mode: StartTimestamp, From: from}, nil, 40},
+		{"timestamp after every message", StartPosition{Mode: StartTimestamp, From: from.Add(time.Hour)}, nil, 100},
+		{"snapshot", StartPosition{Mode: StartOldest}, map[int32]int64{0: 60}, 60},
+		{"snapshot without the partition", StartPosition{Mode: StartOldest}, map[int32]int64{1: 60}, 10},
+		{"snapshot behind retention", StartPosition{Mode: StartOldest}, map[int32]int64{0: 5}, 10},
+	}
+	for _, tt := range tests {
+		c := newTestConsumer(t, tt.start)
+		c.client = client
+		c.resumeFrom = tt.resumeFrom
+		got, err := c.startOffset(TopicGameEvents, 0)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: startOffset = %d, %v; want %d", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestTimestampReplayDedupesSnapshot(t *testing.T) {
+	base := time.Now().Add(-time.Hour).Truncate(time.Second)
+	store := &memorySnapshots{}
+
+	// The first run counts four games over two partitions and snapshots them
+	first := newTestConsumer(t, StartPosition{Mode: StartOldest})
+	first.SetSnapshots(store, time.Minute)
+	first.processMessage(gameStart(t, 0, 0, base))
+	first.processMessage(gameStart(t, 0, 1, base.Add(2*time.Minute)))
+	first.processMessage(gameStart(t, 1, 0, base.Add(time.Minute)))
+	first.processMessage(gameStart(t, 1, 1, base.Add(3*time.Minute)))
+	first.saveSnapshot()
+
+	// The second replays from before the snapshot: events the snapshot
+	// counted overlap with new ones on both partitions
+	from := StartPosition{Mode: StartTimestamp, From: base}
+	second := newTestConsumer(t, from)
+	second.SetSnapshots(store, time.Minute)
+	if ok, err := second.RestoreSnapshot(context.Background()); !ok || err != nil {
+		t.Fatalf("RestoreSnapshot = %v, %v", ok, err)
+	}
+	if second.resumeFrom != nil {
+		t.Error("a timestamp start resumes from the snapshot offsets instead of the timestamp")
+	}
+	second.processMessage(gameStart(t, 0, 1, base.Add(2*time.Minute)))
+	second.processMessage(gameStart(t, 0, 2, base.Add(4*time.Minute)))
+	second.processMessage(gameStart(t, 1, 0, base.Add(time.Minute)))
+	second.processMessage(gameStart(t, 1, 1, base.Add(3*time.Minute)))
+	second.processMessage(gameStart(t, 1, 2, base.Add(5*time.Minute)))
+	// A partition the snapshot never saw has nothing to dedupe against
+	second.processMessage(gameStart(t, 2, 0, base))
+
+	if got := second.GetMetrics().TotalGames; got != 7 {
+		t.Errorf("total games = %d, want 4 restored + 3 new", got)
+	}
+	progress := second.GetReplayProgress()
+	if progress.EventsDeduplicated != 3 {
+		t.Errorf("deduplicated %d events, want 3", progress.EventsDeduplicated)
+	}
+	if progress.StartMode != from.String()+"+snapshot" {
+		t.Errorf("start mode = %q", progress.StartMode)
+	}
+
+	// The next snapshot carries the newer event times forward
+	second.saveSnapshot()
+	third := newTestConsumer(t, from)
+	third.SetSnapshots(store, time.Minute)
+	if _, err := third.RestoreSnapshot(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	third.processMessage(gameStart(t, 0, 2, base.Add(4*time.Minute)))
+	third.processMessage(gameStart(t, 2, 0, base))
+	if got := third.GetMetrics().TotalGames; got != 7 {
+		t.Errorf("after a second restart total games = %d, want 7", got)
+	}
+}
+
+func TestOldestRestoreResumesFromOffsets(t *testing.T) {
+	store := &memorySnapshots{}
+	first := newTestConsumer(t, StartPosition{Mode: StartOldest})
+	first.SetSnapshots(store, time.Minute)
+	first.processMessage(gameStart(t, 0, 7, time.Now()))
+	first.saveSnapshot()
+
+	second := newTestConsumer(t, StartPosition{Mode: StartOldest})
+	second.SetSnapshots(store, time.Minute)
+	if ok, err := second.RestoreSnapshot(context.Background()); !ok || err != nil {
+		t.Fatalf("RestoreSnapshot = %v, %v", ok, err)
+	}
+	if second.resumeFrom[0] != 8 || second.dedupeThrough != nil {
+		t.Errorf("resumeFrom = %v, dedupeThrough = %v; want offset 8 and no dedupe", second.resumeFrom, second.dedupeThrough)
+	}
+	if got := second.GetReplayProgress().StartMode; got != "snapshot" {
+		t.Errorf("start mode = %q, want snapshot", got)
+	}
+}
+
+func TestConsumerEventVersions(t *testing.T) {
+	start := `"type":"game_start","gameId":"g","timestamp":"2026-01-02T03:04:05Z","data":{"player1":"alice","player2":"bob"}`
+	tests := []struct {
+		name    string
+		value   string
+		counted bool
+	}{
+		{"current version", `{"version":1,` + start + `}`, true},
+		{"unversioned", `{` + start + `}`, true},
+		{"version zero", `{"version":0,` + start + `}`, true},
+		{"unknown fields", `{"version":1,"region":"eu",` + start[:len(start)-1] + `,"rated":true}}`, true},
+		{"newer version", `{"version":2,` + start + `}`, false},
+		{"much newer version", `{"version":99,` + start + `}`, false},
+		{"not JSON", `game_start alice bob`, false},
+		{"payload of the wrong type", `{"version":1,"type":"game_start","data":{"player1":7}}`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestConsumer(t, StartPosition{Mode: StartOldest})
+			c.processMessage(rawMessage(0, 4, tt.value))
+
+			counted := c.GetMetrics().TotalGames == 1
+			skipped := c.GetReplayProgress().EventsSkipped
+			if counted != tt.counted || (skipped == 1) == tt.counted {
+				t.Errorf("counted = %v with %d skipped, want counted = %v", counted, skipped, tt.counted)
+			}
+			if stats := c.GetMetrics().PlayerStats["alice"]; tt.counted && (stats == nil || stats.TotalGames != 1) {
+				t.Errorf("alice = %+v", stats)
+			}
+			// Skipped or not, the message is behind the consumer
+			if c.offsets[0] != 5 {
+				t.Errorf("offset = %d, want 5", c.offsets[0])
+			}
+		})
+	}
+}
+
+func TestConsumerIgnoresUnknownEventTypes(t *testing.T) {
+	c := newTestConsumer(t, StartPosition{Mode: StartOldest})
+	c.processMessage(rawMessage(0, 0, `{"version":1,"type":"spectator_joined","gameId":"g","data":{"spectator":"carol"}}`))
+	if got := c.GetReplayProgress().EventsSkipped; got != 0 {
+		t.Errorf("an unknown type was skipped %d times, want ignored", got)
+	}
+	if m := c.GetMetrics(); m.TotalGames != 0 || len(m.PlayerStats) != 0 {
+		t.Errorf("an unknown type changed the metrics: %+v", m)
+	}
+}
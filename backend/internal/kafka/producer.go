@@ -1,9 +1,12 @@
 package kafka
 
 import (
+	"context"
 	"encoding/json"
-	"log"
-	"os"
+	"log/slog"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/IBM/sarama"
@@ -12,6 +15,18 @@ import (
 
 const (
 	TopicGameEvents = "game-events"
+
+	// TopicDeadLetters receives consumed messages the consumer couldn't
+	// use, unchanged, with where they came from and why in headers
+	TopicDeadLetters = "game-events-dlq"
+)
+
+// Headers on dead-lettered messages
+const (
+	headerDeadLetterError     = "dlq-error"
+	headerDeadLetterTopic     = "dlq-topic"
+	headerDeadLetterPartition = "dlq-partition"
+	headerDeadLetterOffset    = "dlq-offset"
 )
 
 // EventType represents the type of game event
@@ -21,21 +36,46 @@ const (
 	EventGameStart EventType = "game_start"
 	EventMove      EventType = "move"
 	EventGameEnd   EventType = "game_end"
+
+	EventPlayerDisconnect    EventType = "player_disconnect"
+	EventPlayerReconnect     EventType = "player_reconnect"
+	EventMatchmakingFallback EventType = "matchmaking_fallback"
 )
 
+// EventVersion is the version of the event format written by this code.
+//
+// The format is the JSON encoding of GameEvent, with Data holding the
+// payload struct for Type (GameStartData for game_start and so on), and is
+// a contract with every consumer of the topic, including older servers
+// still replaying it:
+//   - Fields may be added to GameEvent or a payload; readers ignore
+//     fields they don't know and give missing ones their zero value.
+//   - New event types may be added; readers skip types they don't know.
+//   - Renaming or removing a field, or changing its meaning or type,
+//     needs a new version. Readers skip events with a version newer than
+//     theirs and count them, rather than misreading them.
+//
+// Events from before versioning have no version field and are version 1.
+const EventVersion = 1
+
 // GameEvent represents a game event for analytics
 type GameEvent struct {
+	Version   int       `json:"version"`
 	Type      EventType `json:"type"`
 	GameID    string    `json:"gameId"`
 	Timestamp time.Time `json:"timestamp"`
+	Simulated bool      `json:"simulated,omitempty"` // from a bot-vs-bot simulation run
 	Data      any       `json:"data"`
 }
 
 // GameStartData contains data for game start events
 type GameStartData struct {
-	Player1 string `json:"player1"`
-	Player2 string `json:"player2"`
-	IsVsBot bool   `json:"isVsBot"`
+	Player1       string `json:"player1"`
+	Player2       string `json:"player2"`
+	IsVsBot       bool   `json:"isVsBot"`
+	BotDifficulty string `json:"botDifficulty,omitempty"`
+	BotVersion    string `json:"botVersion,omitempty"`
+	FirstPlayer   string `json:"firstPlayer"` // who moves first
 }
 
 // MoveData contains data for move events
@@ -44,139 +84,435 @@ type MoveData struct {
 	Column  int    `json:"column"`
 	Row     int    `json:"row"`
 	MoveNum int    `json:"moveNum"`
+	ThinkMs int64  `json:"thinkMs"`       // since the previous move, or play start
+	Bot     bool   `json:"bot,omitempty"` // the bot's move, to leave out of human analysis
 }
 
 // GameEndData contains data for game end events
 type GameEndData struct {
+	Player1         string `json:"player1"`
+	Player2         string `json:"player2"`
 	Winner          string `json:"winner"`
 	Result          string `json:"result"`
+	ForfeitedBy     string `json:"forfeitedBy,omitempty"`
 	DurationSeconds int    `json:"durationSeconds"`
 	TotalMoves      int    `json:"totalMoves"`
 	IsVsBot         bool   `json:"isVsBot"`
+	BotVersion      string `json:"botVersion,omitempty"`
+	FirstMover      string `json:"firstMover,omitempty"` // who made the first move
+	WinType         string `json:"winType,omitempty"`    // see game.WinType
+}
+
+// DisconnectData contains data for player disconnect events
+type DisconnectData struct {
+	Player  string `json:"player"`
+	MoveNum int    `json:"moveNum"` // moves played when the connection dropped
+}
+
+// ReconnectData contains data for player reconnect events
+type ReconnectData struct {
+	Player  string `json:"player"`
+	Success bool   `json:"success"` // false when the reconnect window ran out
 }
 
-// Producer handles Kafka event production
+// FallbackData contains data for matchmaking fallback events, sent when a
+// player waited out the matchmaking timeout and got a bot
+type FallbackData struct {
+	Player        string `json:"player"`
+	WaitedSeconds int    `json:"waitedSeconds"`
+	BotDifficulty string `json:"botDifficulty"`
+}
+
+const (
+	// maxBufferedEvents caps the events kept while Kafka is unreachable;
+	// past it the oldest are dropped
+	maxBufferedEvents = 1000
+
+	// producerQueueSize is how many events may wait for delivery once
+	// connected; events emitted while the queue is full are dropped
+	producerQueueSize = 1024
+)
+
+// Producer handles Kafka event production. Sends are asynchronous, so
+// emitting never waits on the network; delivery failures are logged and
+// counted. Until it has connected it keeps retrying in the background and
+// buffers events, which are sent in order once the connection is up.
 type Producer struct {
-	producer sarama.SyncProducer
-	enabled  bool
+	brokers []string
+	enabled atomic.Bool // connected and the buffer flushed
+	ctx     context.Context
+	cancel  context.CancelFunc
+	reports sync.WaitGroup // delivery report readers, done once Close has drained
+
+	mu        sync.Mutex
+	producer  sarama.AsyncProducer      // set once connected
+	closed    bool                      // no more sends after Close
+	buffer    []*sarama.ProducerMessage // events waiting for the connection
+	lastErr   error                     // error from the most recent delivery, nil once one succeeds
+	lastErrAt time.Time
+
+	sent    atomic.Int64
+	failed  atomic.Int64
+	dropped atomic.Int64
+}
+
+// ProducerStats counts what happened to emitted events since startup
+type ProducerStats struct {
+	Sent     int64 `json:"sent"`
+	Failed   int64 `json:"failed"`   // delivery failed after sarama's retries
+	Dropped  int64 `json:"dropped"`  // the offline buffer or the send queue was full
+	Buffered int   `json:"buffered"` // waiting for the connection
 }
 
-// NewProducer creates a new Kafka producer
-func NewProducer() (*Producer, error) {
-	brokers := os.Getenv("KAFKA_BROKERS")
-	if brokers == "" {
-		brokers = "localhost:9092"
+// NewProducer creates a new Kafka producer for brokers. If they can't be
+// reached it keeps retrying in the background until Close.
+func NewProducer(brokers []string) (*Producer, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Producer{brokers: brokers, ctx: ctx, cancel: cancel}
+
+	if err := p.connect(); err != nil {
+		// The retry loop logs the failure when its own first attempt fails
+		go func() {
+			if retryConnect(ctx, "producer", brokers, p.connect) {
+				p.flush()
+			}
+		}()
+		return p, nil
 	}
 
+	p.flush()
+	slog.Info("Kafka producer connected", "brokers", brokers)
+	return p, nil
+}
+
+// connect opens the underlying async producer and starts reading its
+// delivery reports
+func (p *Producer) connect() error {
 	config := sarama.NewConfig()
 	config.Producer.Return.Successes = true
+	config.Producer.Return.Errors = true
 	config.Producer.RequiredAcks = sarama.WaitForAll
 	config.Producer.Retry.Max = 3
+	config.ChannelBufferSize = producerQueueSize
 
-	producer, err := sarama.NewSyncProducer([]string{brokers}, config)
+	producer, err := sarama.NewAsyncProducer(p.brokers, config)
 	if err != nil {
-		log.Printf("Kafka producer not available: %v (analytics disabled)", err)
-		return &Producer{enabled: false}, nil
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		producer.Close() // closed while connecting
+		return nil
 	}
+	p.producer = producer
 
-	log.Println("Kafka producer connected")
-	return &Producer{producer: producer, enabled: true}, nil
+	p.reports.Add(2)
+	go func() {
+		defer p.reports.Done()
+		for range producer.Successes() {
+			p.sent.Add(1)
+			p.recordDelivery(nil)
+		}
+	}()
+	go func() {
+		defer p.reports.Done()
+		for perr := range producer.Errors() {
+			p.failed.Add(1)
+			slog.Error("delivering event to Kafka failed", "topic", perr.Msg.Topic, "key", messageKey(perr.Msg), "error", perr.Err)
+			p.recordDelivery(perr.Err)
+		}
+	}()
+	return nil
 }
 
-// EmitGameStart emits a game start event
-func (p *Producer) EmitGameStart(g *game.Game) {
-	if !p.enabled {
+// flush queues the buffered events, then switches to sending directly
+func (p *Producer) flush() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.producer == nil || p.closed {
 		return
 	}
 
-	state := g.GetState()
-	event := GameEvent{
-		Type:      EventGameStart,
-		GameID:    g.ID,
-		Timestamp: time.Now(),
-		Data: GameStartData{
-			Player1: state.Player1,
-			Player2: state.Player2,
-			IsVsBot: state.IsVsBot,
-		},
+	// Blocking is fine here: the producer's dispatcher drains the queue,
+	// and emitters wait on the lock just this once
+	for _, msg := range p.buffer {
+		p.producer.Input() <- msg
 	}
+	p.buffer = nil
+	p.enabled.Store(true)
+}
 
-	p.send(event)
+// EmitGameStart emits a game start event
+func (p *Producer) EmitGameStart(g *game.Game) {
+	if p.ctx.Err() != nil {
+		return // closed
+	}
+	p.send(gameStartEvent(g))
 }
 
 // EmitMove emits a move event
 func (p *Producer) EmitMove(g *game.Game, player string, column, row, moveNum int) {
-	if !p.enabled {
-		return
+	if p.ctx.Err() != nil {
+		return // closed
 	}
+	p.send(moveEvent(g, player, column, row, moveNum))
+}
 
-	event := GameEvent{
-		Type:      EventMove,
-		GameID:    g.ID,
-		Timestamp: time.Now(),
-		Data: MoveData{
-			Player:  player,
-			Column:  column,
-			Row:     row,
-			MoveNum: moveNum,
-		},
+// EmitGameEnd emits a game end event
+func (p *Producer) EmitGameEnd(g *game.Game) {
+	if p.ctx.Err() != nil {
+		return // closed
 	}
+	p.send(gameEndEvent(g))
+}
 
-	p.send(event)
+// EmitDisconnect emits a player disconnect event
+func (p *Producer) EmitDisconnect(g *game.Game, player string) {
+	if p.ctx.Err() != nil {
+		return // closed
+	}
+	p.send(disconnectEvent(g, player))
 }
 
-// EmitGameEnd emits a game end event
-func (p *Producer) EmitGameEnd(g *game.Game) {
-	if !p.enabled {
-		return
+// EmitReconnect emits a player reconnect event; success is false when the
+// player didn't make it back in time
+func (p *Producer) EmitReconnect(g *game.Game, player string, success bool) {
+	if p.ctx.Err() != nil {
+		return // closed
 	}
+	p.send(reconnectEvent(g, player, success))
+}
 
-	state := g.GetState()
-	event := GameEvent{
-		Type:      EventGameEnd,
+// EmitMatchmakingFallback emits a matchmaking fallback event
+func (p *Producer) EmitMatchmakingFallback(g *game.Game, player string, waited time.Duration) {
+	if p.ctx.Err() != nil {
+		return // closed
+	}
+	p.send(fallbackEvent(g, player, waited))
+}
+
+// newEvent builds an event of the current version about g
+func newEvent(eventType EventType, g *game.Game, data any) GameEvent {
+	return GameEvent{
+		Version:   EventVersion,
+		Type:      eventType,
 		GameID:    g.ID,
 		Timestamp: time.Now(),
-		Data: GameEndData{
-			Winner:          state.Winner,
-			Result:          state.Result,
-			DurationSeconds: g.GetDuration(),
-			TotalMoves:      state.MoveCount,
-			IsVsBot:         state.IsVsBot,
-		},
+		Simulated: g.Simulated,
+		Data:      data,
 	}
+}
+
+// gameStartEvent builds the game start event for g
+func gameStartEvent(g *game.Game) GameEvent {
+	state := g.GetState()
+	return newEvent(EventGameStart, g, GameStartData{
+		Player1:       state.Player1,
+		Player2:       state.Player2,
+		IsVsBot:       state.IsVsBot,
+		BotDifficulty: state.BotDifficulty,
+		BotVersion:    state.BotVersion,
+		FirstPlayer:   state.FirstPlayerUsername(),
+	})
+}
 
-	p.send(event)
+// moveEvent builds the event for a move in g
+func moveEvent(g *game.Game, player string, column, row, moveNum int) GameEvent {
+	move, _ := g.MoveAt(moveNum - 1)
+	return newEvent(EventMove, g, MoveData{
+		Player:  player,
+		Column:  column,
+		Row:     row,
+		MoveNum: moveNum,
+		ThinkMs: move.ThinkMs,
+		Bot:     move.Bot,
+	})
+}
+
+// gameEndEvent builds the game end event for g
+func gameEndEvent(g *game.Game) GameEvent {
+	state := g.GetState()
+	return newEvent(EventGameEnd, g, GameEndData{
+		Player1:         state.Player1,
+		Player2:         state.Player2,
+		Winner:          state.Winner,
+		Result:          state.Result,
+		ForfeitedBy:     state.ForfeitedBy,
+		DurationSeconds: g.GetDuration(),
+		TotalMoves:      state.MoveCount,
+		IsVsBot:         state.IsVsBot,
+		BotVersion:      state.BotVersion,
+		FirstMover:      g.FirstMover(),
+		WinType:         string(state.WinType),
+	})
+}
+
+// disconnectEvent builds the event for player dropping out of g
+func disconnectEvent(g *game.Game, player string) GameEvent {
+	return newEvent(EventPlayerDisconnect, g, DisconnectData{
+		Player:  player,
+		MoveNum: g.GetState().MoveCount,
+	})
+}
+
+// reconnectEvent builds the event for player returning to g, or failing to
+func reconnectEvent(g *game.Game, player string, success bool) GameEvent {
+	return newEvent(EventPlayerReconnect, g, ReconnectData{
+		Player:  player,
+		Success: success,
+	})
+}
+
+// fallbackEvent builds the event for player getting the bot game g after
+// waiting in the queue
+func fallbackEvent(g *game.Game, player string, waited time.Duration) GameEvent {
+	return newEvent(EventMatchmakingFallback, g, FallbackData{
+		Player:        player,
+		WaitedSeconds: int(waited.Seconds()),
+		BotDifficulty: g.GetState().BotDifficulty,
+	})
 }
 
 // send sends an event to Kafka
 func (p *Producer) send(event GameEvent) {
 	data, err := json.Marshal(event)
 	if err != nil {
-		log.Printf("Error marshaling event: %v", err)
+		slog.Error("marshaling event failed", "gameID", event.GameID, "eventType", event.Type, "error", err)
 		return
 	}
 
-	msg := &sarama.ProducerMessage{
+	p.enqueue(&sarama.ProducerMessage{
 		Topic: TopicGameEvents,
 		Key:   sarama.StringEncoder(event.GameID),
 		Value: sarama.ByteEncoder(data),
+	})
+}
+
+// SendDeadLetter publishes a consumed message the consumer couldn't use to
+// the dead-letter topic, with reason and its original position in the
+// headers. Like emitting events it never blocks, so a dead-letter topic
+// that can't be reached never holds up consumption.
+func (p *Producer) SendDeadLetter(msg *sarama.ConsumerMessage, reason error) {
+	if p.ctx.Err() != nil {
+		return // closed
+	}
+
+	dead := &sarama.ProducerMessage{
+		Topic: TopicDeadLetters,
+		Value: sarama.ByteEncoder(msg.Value),
+		Headers: []sarama.RecordHeader{
+			{Key: []byte(headerDeadLetterError), Value: []byte(reason.Error())},
+			{Key: []byte(headerDeadLetterTopic), Value: []byte(msg.Topic)},
+			{Key: []byte(headerDeadLetterPartition), Value: []byte(strconv.Itoa(int(msg.Partition)))},
+			{Key: []byte(headerDeadLetterOffset), Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+		},
+	}
+	if len(msg.Key) > 0 {
+		dead.Key = sarama.ByteEncoder(msg.Key)
+	}
+	p.enqueue(dead)
+}
+
+// enqueue hands msg to the producer, or buffers it until connected
+func (p *Producer) enqueue(msg *sarama.ProducerMessage) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch {
+	case p.closed:
+	case !p.enabled.Load():
+		if len(p.buffer) == maxBufferedEvents {
+			p.dropMessage(p.buffer[0], "offline buffer full")
+			p.buffer = p.buffer[1:]
+		}
+		p.buffer = append(p.buffer, msg)
+	default:
+		select {
+		case p.producer.Input() <- msg:
+		default:
+			p.dropMessage(msg, "send queue full")
+		}
+	}
+}
+
+// dropMessage counts a message that couldn't be queued. Only every
+// hundredth drop is logged so sustained backpressure doesn't flood the log.
+func (p *Producer) dropMessage(msg *sarama.ProducerMessage, reason string) {
+	if n := p.dropped.Add(1); n == 1 || n%100 == 0 {
+		slog.Warn("Kafka message dropped", "reason", reason, "topic", msg.Topic, "key", messageKey(msg), "droppedTotal", n)
+	}
+}
+
+// messageKey returns msg's key for logging; for events it's the game ID
+func messageKey(msg *sarama.ProducerMessage) string {
+	if msg.Key == nil {
+		return ""
 	}
+	key, err := msg.Key.Encode()
+	if err != nil {
+		return ""
+	}
+	return string(key)
+}
 
-	_, _, err = p.producer.SendMessage(msg)
+// recordDelivery remembers the outcome of the latest delivery for LastError
+func (p *Producer) recordDelivery(err error) {
+	p.mu.Lock()
+	p.lastErr = err
 	if err != nil {
-		log.Printf("Error sending event to Kafka: %v", err)
+		p.lastErrAt = time.Now()
 	}
+	p.mu.Unlock()
 }
 
-// Close closes the producer
+// Close stops any connection retry and closes the producer, waiting until
+// queued events are delivered or have failed. Events still buffered for
+// the connection are lost.
 func (p *Producer) Close() error {
-	if p.producer != nil {
-		return p.producer.Close()
+	p.cancel()
+
+	p.mu.Lock()
+	p.closed = true
+	p.enabled.Store(false)
+	producer := p.producer
+	p.mu.Unlock()
+
+	if producer != nil {
+		producer.AsyncClose()
+		p.reports.Wait()
 	}
 	return nil
 }
 
-// IsEnabled returns whether Kafka is enabled
+// Stats returns the producer's delivery counters
+func (p *Producer) Stats() ProducerStats {
+	p.mu.Lock()
+	buffered := len(p.buffer)
+	p.mu.Unlock()
+	return ProducerStats{
+		Sent:     p.sent.Load(),
+		Failed:   p.failed.Load(),
+		Dropped:  p.dropped.Load(),
+		Buffered: buffered,
+	}
+}
+
+// IsEnabled returns whether the producer is connected and sending events
 func (p *Producer) IsEnabled() bool {
-	return p.enabled
+	return p.enabled.Load()
+}
+
+// IsConnecting reports whether the producer is still trying to reach
+// Kafka, buffering events meanwhile
+func (p *Producer) IsConnecting() bool {
+	return !p.enabled.Load() && p.ctx.Err() == nil
+}
+
+// LastError returns when the most recent delivery failed and its error;
+// the error is nil if that delivery succeeded or nothing was sent yet
+func (p *Producer) LastError() (time.Time, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lastErrAt, p.lastErr
 }
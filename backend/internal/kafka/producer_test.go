@@ -0,0 +1,101 @@
+package kafka
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/connect-four/internal/game"
+)
+
+// TestEventWireFormat fixes the JSON of each event type. A change here
+// breaks consumers still reading the topic; see EventVersion before
+// updating it.
+func TestEventWireFormat(t *testing.T) {
+	at := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	envelope := `{"version":1,"type":%q,"gameId":"g1","timestamp":"2026-03-04T05:06:07Z","data":%s}`
+	tests := []struct {
+		eventType EventType
+		data      any
+		want      string
+	}{
+		{EventGameStart, GameStartData{Player1: "alice", Player2: "BOT", IsVsBot: true, BotDifficulty: "hard", BotVersion: "v3", FirstPlayer: "alice"},
+			`{"player1":"alice","player2":"BOT","isVsBot":true,"botDifficulty":"hard","botVersion":"v3","firstPlayer":"alice"}`},
+		{EventGameStart, GameStartData{Player1: "alice", Player2: "bob"},
+			`{"player1":"alice","player2":"bob","isVsBot":false,"firstPlayer":""}`},
+		{EventMove, MoveData{Player: "alice", Column: 3, Row: 5, MoveNum: 1, ThinkMs: 1500},
+			`{"player":"alice","column":3,"row":5,"moveNum":1,"thinkMs":1500}`},
+		{EventMove, MoveData{Player: "BOT", Column: 4, Row: 5, MoveNum: 2, ThinkMs: 20, Bot: true},
+			`{"player":"BOT","column":4,"row":5,"moveNum":2,"thinkMs":20,"bot":true}`},
+		{EventGameEnd, GameEndData{Player1: "alice", Player2: "bob", Winner: "bob", Result: "forfeit", ForfeitedBy: "alice",
+			DurationSeconds: 75, TotalMoves: 9, FirstMover: "alice", WinType: "forfeit"},
+			`{"player1":"alice","player2":"bob","winner":"bob","result":"forfeit","forfeitedBy":"alice","durationSeconds":75,` +
+				`"totalMoves":9,"isVsBot":false,"firstMover":"alice","winType":"forfeit"}`},
+		{EventGameEnd, GameEndData{Player1: "alice", Player2: "bob", Result: "draw", DurationSeconds: 300, TotalMoves: 42},
+			`{"player1":"alice","player2":"bob","winner":"","result":"draw","durationSeconds":300,"totalMoves":42,"isVsBot":false}`},
+		{EventPlayerDisconnect, DisconnectData{Player: "alice", MoveNum: 7},
+			`{"player":"alice","moveNum":7}`},
+		{EventPlayerReconnect, ReconnectData{Player: "alice", Success: false},
+			`{"player":"alice","success":false}`},
+		{EventMatchmakingFallback, FallbackData{Player: "alice", WaitedSeconds: 10, BotDifficulty: "medium"},
+			`{"player":"alice","waitedSeconds":10,"botDifficulty":"medium"}`},
+	}
+	for _, tt := range tests {
+		event := GameEvent{Version: EventVersion, Type: tt.eventType, GameID: "g1", Timestamp: at, Data: tt.data}
+		got, err := json.Marshal(event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := fmt.Sprintf(envelope, tt.eventType, tt.want)
+		if string(got) != want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.eventType, got, want)
+		}
+
+		// And reading it back gives the same event
+		var decoded incomingEvent
+		if err := json.Unmarshal(got, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		data, err := decodeData(decoded.Type, decoded.Data)
+		if err != nil || !reflect.DeepEqual(data, tt.data) {
+			t.Errorf("%s: decoded %+v, %v; want %+v", tt.eventType, data, err, tt.data)
+		}
+	}
+
+	simulated, _ := json.Marshal(GameEvent{Version: EventVersion, Type: EventMove, Timestamp: at, Simulated: true, Data: MoveData{}})
+	var fields map[string]any
+	json.Unmarshal(simulated, &fields)
+	if fields["simulated"] != true {
+		t.Errorf("simulated event encodes as %s", simulated)
+	}
+}
+
+func TestNewEventIsVersioned(t *testing.T) {
+	g := game.NewGame("alice")
+	g.AddPlayer2("bob", false)
+	event := gameStartEvent(g)
+	if event.Version != EventVersion || event.Type != EventGameStart || event.GameID != g.ID {
+		t.Errorf("game start event = %+v", event)
+	}
+	if data, ok := event.Data.(GameStartData); !ok || data.Player1 != "alice" || data.Player2 != "bob" {
+		t.Errorf("game start data = %+v", event.Data)
+	}
+}
+
+func TestGameEndDurationExcludesWaiting(t *testing.T) {
+	g := game.NewGame("alice")
+	g.StartTime = g.StartTime.Add(-time.Minute) // a minute in the queue
+	g.AddPlayer2("bob", false)
+	g.Forfeit(game.Player1)
+	g.EndTime = g.PlayStartedAt.Add(45 * time.Second)
+
+	data, ok := gameEndEvent(g).Data.(GameEndData)
+	if !ok {
+		t.Fatalf("game end data is %T", gameEndEvent(g).Data)
+	}
+	if data.DurationSeconds != 45 {
+		t.Errorf("duration = %ds, want 45s of play without the queue time", data.DurationSeconds)
+	}
+}
@@ -0,0 +1,43 @@
+package kafka
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Backoff between connection attempts while Kafka is unreachable
+const (
+	retryInitialDelay = time.Second
+	retryMaxDelay     = time.Minute
+)
+
+// retryConnect calls connect until it succeeds, waiting retryInitialDelay
+// after the first failure and doubling up to retryMaxDelay. It returns
+// false if ctx ends first. Only the first failure is logged as a warning.
+func retryConnect(ctx context.Context, what string, brokers []string, connect func() error) bool {
+	delay := retryInitialDelay
+	for attempt := 1; ; attempt++ {
+		err := connect()
+		if err == nil {
+			if attempt > 1 {
+				slog.Info("Kafka "+what+" connected after retrying", "brokers", brokers, "attempts", attempt)
+			}
+			return true
+		}
+		if attempt == 1 {
+			slog.Warn("Kafka "+what+" not available, retrying in the background", "brokers", brokers, "error", err)
+		} else {
+			slog.Debug("Kafka "+what+" still not available", "brokers", brokers, "attempt", attempt, "error", err)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		}
+		delay = min(delay*2, retryMaxDelay)
+	}
+}
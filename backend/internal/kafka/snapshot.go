@@ -0,0 +1,271 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// snapshotVersion changes whenever the aggregation logic does, so a
+// snapshot built by older code is ignored and the topic replayed instead
+const snapshotVersion = 3
+
+// snapshotSaveTimeout bounds a single snapshot write
+const snapshotSaveTimeout = 10 * time.Second
+
+// SnapshotStore persists serialized analytics snapshots
+type SnapshotStore interface {
+	SaveAnalyticsSnapshot(ctx context.Context, data []byte) error
+	LatestAnalyticsSnapshot(ctx context.Context) ([]byte, error)
+}
+
+// metricsSnapshot is the persisted form of the consumer's metrics, with
+// the offsets of the next message to read on each partition so nothing
+// is counted twice or skipped after a restore. EventTimes, the newest
+// event timestamp counted on each partition, does the same for a replay
+// from a timestamp; snapshots taken before it was added fall back to
+// TakenAt.
+type metricsSnapshot struct {
+	Version    int                 `json:"version"`
+	TakenAt    time.Time           `json:"takenAt"`
+	Offsets    map[int32]int64     `json:"offsets"`
+	EventTimes map[int32]time.Time `json:"eventTimes,omitempty"`
+
+	TotalGames    int64                     `json:"totalGames"`
+	TotalMoves    int64                     `json:"totalMoves"`
+	BotGames      int64                     `json:"botGames"`
+	TotalDuration int64                     `json:"totalDuration"`
+	WinCounts     map[string]int            `json:"winCounts"`
+	GamesPerHour  map[string]int            `json:"gamesPerHour"`
+	GamesPerDay   map[string]int            `json:"gamesPerDay"`
+	Players       map[string]playerSnapshot `json:"players"`
+
+	Disconnects      int64 `json:"disconnects"`
+	Reconnects       int64 `json:"reconnects"`
+	FailedReconnects int64 `json:"failedReconnects"`
+	BotFallbacks     int64 `json:"botFallbacks"`
+
+	ColumnMoves     map[int]int64 `json:"columnMoves"`
+	OpeningColumns  map[int]int64 `json:"openingColumns"`
+	FirstMoverGames int64         `json:"firstMoverGames"`
+	FirstMoverWins  int64         `json:"firstMoverWins"`
+
+	WinTypes map[string]int64 `json:"winTypes"`
+
+	HumanThinkByMove map[int]ThinkTime `json:"humanThinkByMove"`
+	BotThink         ThinkTime         `json:"botThink"`
+}
+
+// playerSnapshot is PlayerMetrics including the running totals
+type playerSnapshot struct {
+	PlayerMetrics
+	EndedGames    int       `json:"endedGames"`
+	TotalDuration int64     `json:"totalDuration"`
+	LastSeen      time.Time `json:"lastSeen"`
+}
+
+// SetSnapshots makes the consumer save its metrics to store every interval
+// and once more on Stop
+func (c *Consumer) SetSnapshots(store SnapshotStore, interval time.Duration) {
+	c.snapshots = store
+	c.snapshotInterval = interval
+}
+
+// RestoreSnapshot loads the latest snapshot into the metrics and makes
+// the consumer resume from the offsets it was taken at, instead of its
+// start position. Starting from a timestamp, the consumer instead replays
+// from there and skips events at or before the newest one the snapshot
+// counted on their partition. It must be called before Start, and reports
+// false if there was no usable snapshot.
+func (c *Consumer) RestoreSnapshot(ctx context.Context) (bool, error) {
+	data, err := c.snapshots.LatestAnalyticsSnapshot(ctx)
+	if err != nil || data == nil {
+		return false, err
+	}
+
+	var snap metricsSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return false, fmt.Errorf("decoding analytics snapshot: %w", err)
+	}
+	if snap.Version != snapshotVersion {
+		slog.Info("ignoring analytics snapshot from another version", "version", snap.Version, "want", snapshotVersion)
+		return false, nil
+	}
+
+	c.metrics.mu.Lock()
+	defer c.metrics.mu.Unlock()
+
+	c.metrics.TotalGames = snap.TotalGames
+	c.metrics.TotalMoves = snap.TotalMoves
+	c.metrics.BotGames = snap.BotGames
+	c.metrics.TotalDuration = snap.TotalDuration
+	c.metrics.Disconnects = snap.Disconnects
+	c.metrics.Reconnects = snap.Reconnects
+	c.metrics.FailedReconnects = snap.FailedReconnects
+	c.metrics.BotFallbacks = snap.BotFallbacks
+	c.metrics.FirstMoverGames = snap.FirstMoverGames
+	c.metrics.FirstMoverWins = snap.FirstMoverWins
+	c.metrics.BotThink = snap.BotThink
+	for k, v := range snap.WinCounts {
+		c.metrics.WinCounts[k] = v
+	}
+	for k, v := range snap.GamesPerHour {
+		c.metrics.GamesPerHour[k] = v
+	}
+	for k, v := range snap.GamesPerDay {
+		c.metrics.GamesPerDay[k] = v
+	}
+	for k, v := range snap.ColumnMoves {
+		c.metrics.ColumnMoves[k] = v
+	}
+	for k, v := range snap.OpeningColumns {
+		c.metrics.OpeningColumns[k] = v
+	}
+	for k, v := range snap.WinTypes {
+		c.metrics.WinTypes[k] = v
+	}
+	for k, v := range snap.HumanThinkByMove {
+		c.metrics.HumanThinkByMove[k] = v
+	}
+	for name, p := range snap.Players {
+		stats := p.PlayerMetrics
+		stats.endedGames = p.EndedGames
+		stats.totalDuration = p.TotalDuration
+		stats.lastSeen = p.LastSeen
+		c.metrics.PlayerStats[name] = &stats
+	}
+	c.pruneBucketsLocked(time.Now())
+
+	c.offsets = snap.Offsets
+	if c.offsets == nil {
+		c.offsets = make(map[int32]int64)
+	}
+	for partition, at := range snap.EventTimes {
+		c.eventTimes[partition] = at
+	}
+
+	if c.start.Mode == StartTimestamp {
+		// A partition missing from the offsets had nothing counted
+		c.dedupeThrough = make(map[int32]time.Time, len(c.offsets))
+		for partition := range c.offsets {
+			through, ok := snap.EventTimes[partition]
+			if !ok {
+				through = snap.TakenAt
+			}
+			c.dedupeThrough[partition] = through
+		}
+	} else {
+		c.resumeFrom = make(map[int32]int64, len(c.offsets))
+		for partition, offset := range c.offsets {
+			c.resumeFrom[partition] = offset
+		}
+	}
+
+	slog.Info("analytics restored from snapshot", "takenAt", snap.TakenAt, "totalGames", snap.TotalGames)
+	return true, nil
+}
+
+// snapshotLoop saves a snapshot every interval until the consumer stops
+func (c *Consumer) snapshotLoop() {
+	ticker := time.NewTicker(c.snapshotInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.saveSnapshot()
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+// saveSnapshot writes the current metrics and offsets to the store
+func (c *Consumer) saveSnapshot() {
+	data, err := json.Marshal(c.takeSnapshot())
+	if err != nil {
+		slog.Error("encoding analytics snapshot failed", "error", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), snapshotSaveTimeout)
+	defer cancel()
+	if err := c.snapshots.SaveAnalyticsSnapshot(ctx, data); err != nil {
+		slog.Error("saving analytics snapshot failed", "error", err)
+	}
+}
+
+// takeSnapshot copies the metrics and offsets under the metrics lock, so
+// the offsets match exactly the events the metrics include
+func (c *Consumer) takeSnapshot() *metricsSnapshot {
+	c.metrics.mu.RLock()
+	defer c.metrics.mu.RUnlock()
+
+	snap := &metricsSnapshot{
+		Version:       snapshotVersion,
+		TakenAt:       time.Now(),
+		Offsets:       make(map[int32]int64, len(c.offsets)),
+		EventTimes:    make(map[int32]time.Time, len(c.eventTimes)),
+		TotalGames:    c.metrics.TotalGames,
+		TotalMoves:    c.metrics.TotalMoves,
+		BotGames:      c.metrics.BotGames,
+		TotalDuration: c.metrics.TotalDuration,
+		WinCounts:     make(map[string]int, len(c.metrics.WinCounts)),
+		GamesPerHour:  make(map[string]int, len(c.metrics.GamesPerHour)),
+		GamesPerDay:   make(map[string]int, len(c.metrics.GamesPerDay)),
+		Players:       make(map[string]playerSnapshot, len(c.metrics.PlayerStats)),
+
+		Disconnects:      c.metrics.Disconnects,
+		Reconnects:       c.metrics.Reconnects,
+		FailedReconnects: c.metrics.FailedReconnects,
+		BotFallbacks:     c.metrics.BotFallbacks,
+
+		ColumnMoves:     make(map[int]int64, len(c.metrics.ColumnMoves)),
+		OpeningColumns:  make(map[int]int64, len(c.metrics.OpeningColumns)),
+		FirstMoverGames: c.metrics.FirstMoverGames,
+		FirstMoverWins:  c.metrics.FirstMoverWins,
+
+		WinTypes: make(map[string]int64, len(c.metrics.WinTypes)),
+
+		HumanThinkByMove: make(map[int]ThinkTime, len(c.metrics.HumanThinkByMove)),
+		BotThink:         c.metrics.BotThink,
+	}
+	for partition, offset := range c.offsets {
+		snap.Offsets[partition] = offset
+	}
+	for partition, at := range c.eventTimes {
+		snap.EventTimes[partition] = at
+	}
+	for k, v := range c.metrics.WinCounts {
+		snap.WinCounts[k] = v
+	}
+	for k, v := range c.metrics.GamesPerHour {
+		snap.GamesPerHour[k] = v
+	}
+	for k, v := range c.metrics.GamesPerDay {
+		snap.GamesPerDay[k] = v
+	}
+	for k, v := range c.metrics.ColumnMoves {
+		snap.ColumnMoves[k] = v
+	}
+	for k, v := range c.metrics.OpeningColumns {
+		snap.OpeningColumns[k] = v
+	}
+	for k, v := range c.metrics.WinTypes {
+		snap.WinTypes[k] = v
+	}
+	for k, v := range c.metrics.HumanThinkByMove {
+		snap.HumanThinkByMove[k] = v
+	}
+	for name, stats := range c.metrics.PlayerStats {
+		snap.Players[name] = playerSnapshot{
+			PlayerMetrics: *stats,
+			EndedGames:    stats.endedGames,
+			TotalDuration: stats.totalDuration,
+			LastSeen:      stats.lastSeen,
+		}
+	}
+	return snap
+}
@@ -0,0 +1,157 @@
+package loadhistory
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// SampleInterval is how often a sample is taken
+	SampleInterval = time.Minute
+
+	// Retention is how far back samples are kept
+	Retention = 48 * time.Hour
+
+	// Capacity is the fixed number of samples held in memory.
+	// At 48h x 1/min that is 2880 samples of ~60 bytes each, so the
+	// whole history stays under 200KB regardless of traffic.
+	Capacity = int(Retention / SampleInterval)
+)
+
+// Sample is a single point-in-time reading of server load
+type Sample struct {
+	Time                 time.Time `json:"time"`
+	ConnectedClients     int       `json:"connectedClients"`
+	ActiveGames          int       `json:"activeGames"`
+	QueueDepth           int       `json:"queueDepth"`
+	BroadcastsPerMinute  int64     `json:"broadcastsPerMinute"`
+	BotSearchesPerMinute int64     `json:"botSearchesPerMinute"`
+	APIRequestsPerMinute int64     `json:"apiRequestsPerMinute"`
+}
+
+// Source provides the counters the recorder samples.
+// Gauges report the current value; counters are cumulative totals
+// and the recorder turns them into per-interval rates.
+type Source struct {
+	ConnectedClients func() int
+	ActiveGames      func() int
+	QueueDepth       func() int
+	Broadcasts       func() int64
+	BotSearches      func() int64
+	APIRequests      func() int64
+}
+
+// Recorder keeps a bounded ring buffer of load samples
+type Recorder struct {
+	source  Source
+	samples []Sample
+	next    int
+	count   int
+
+	lastBroadcasts  int64
+	lastBotSearches int64
+	lastAPIRequests int64
+
+	stop chan struct{}
+	mu   sync.RWMutex
+}
+
+// NewRecorder creates a new recorder reading from the given source
+func NewRecorder(source Source) *Recorder {
+	r := &Recorder{
+		source:  source,
+		samples: make([]Sample, Capacity),
+		stop:    make(chan struct{}),
+	}
+	r.lastBroadcasts = read(source.Broadcasts)
+	r.lastBotSearches = read(source.BotSearches)
+	r.lastAPIRequests = read(source.APIRequests)
+	return r
+}
+
+// Start begins sampling on a ticker
+func (r *Recorder) Start() {
+	go func() {
+		ticker := time.NewTicker(SampleInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C:
+				r.Record(now)
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the sampling ticker
+func (r *Recorder) Stop() {
+	close(r.stop)
+}
+
+// Record takes a sample from the source and stores it
+func (r *Recorder) Record(now time.Time) {
+	broadcasts := read(r.source.Broadcasts)
+	botSearches := read(r.source.BotSearches)
+	apiRequests := read(r.source.APIRequests)
+
+	sample := Sample{
+		Time:             now,
+		ConnectedClients: readInt(r.source.ConnectedClients),
+		ActiveGames:      readInt(r.source.ActiveGames),
+		QueueDepth:       readInt(r.source.QueueDepth),
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	sample.BroadcastsPerMinute = broadcasts - r.lastBroadcasts
+	sample.BotSearchesPerMinute = botSearches - r.lastBotSearches
+	sample.APIRequestsPerMinute = apiRequests - r.lastAPIRequests
+	r.lastBroadcasts = broadcasts
+	r.lastBotSearches = botSearches
+	r.lastAPIRequests = apiRequests
+
+	r.samples[r.next] = sample
+	r.next = (r.next + 1) % len(r.samples)
+	if r.count < len(r.samples) {
+		r.count++
+	}
+}
+
+// Since returns the samples taken at or after t, oldest first
+func (r *Recorder) Since(t time.Time) []Sample {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make([]Sample, 0, r.count)
+	start := r.next - r.count
+	if start < 0 {
+		start += len(r.samples)
+	}
+	for i := 0; i < r.count; i++ {
+		s := r.samples[(start+i)%len(r.samples)]
+		if !s.Time.Before(t) {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// read calls a counter accessor if it is set
+func read(f func() int64) int64 {
+	if f == nil {
+		return 0
+	}
+	return f()
+}
+
+// readInt calls a gauge accessor if it is set
+func readInt(f func() int) int {
+	if f == nil {
+		return 0
+	}
+	return f()
+}
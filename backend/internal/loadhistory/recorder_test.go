@@ -0,0 +1,124 @@
+package loadhistory
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var t0 = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// minute returns the time of the i-th sample after t0
+func minute(i int) time.Time {
+	return t0.Add(time.Duration(i) * SampleInterval)
+}
+
+func TestRecordRates(t *testing.T) {
+	var broadcasts int64 = 100
+	r := NewRecorder(Source{
+		ConnectedClients: func() int { return 3 },
+		Broadcasts:       func() int64 { return broadcasts },
+	})
+
+	broadcasts = 130
+	r.Record(minute(0))
+	broadcasts = 135
+	r.Record(minute(1))
+
+	got := r.Since(time.Time{})
+	if len(got) != 2 {
+		t.Fatalf("got %d samples, want 2", len(got))
+	}
+	if got[0].BroadcastsPerMinute != 30 || got[1].BroadcastsPerMinute != 5 {
+		t.Errorf("broadcast rates = %d, %d, want 30, 5", got[0].BroadcastsPerMinute, got[1].BroadcastsPerMinute)
+	}
+	if got[0].ConnectedClients != 3 || got[0].ActiveGames != 0 {
+		t.Errorf("gauges = %+v, want 3 clients and an unset source read as 0", got[0])
+	}
+}
+
+func TestRingWraparound(t *testing.T) {
+	r := NewRecorder(Source{})
+	const extra = 10
+	for i := 0; i < Capacity+extra; i++ {
+		r.Record(minute(i))
+	}
+
+	got := r.Since(time.Time{})
+	if len(got) != Capacity {
+		t.Fatalf("got %d samples, want Capacity (%d)", len(got), Capacity)
+	}
+	for i, s := range got {
+		if want := minute(i + extra); !s.Time.Equal(want) {
+			t.Fatalf("sample %d at %v, want %v: the oldest should have been overwritten in order", i, s.Time, want)
+		}
+	}
+}
+
+func TestSinceWindow(t *testing.T) {
+	r := NewRecorder(Source{})
+	if got := r.Since(time.Time{}); len(got) != 0 {
+		t.Fatalf("empty recorder returned %d samples", len(got))
+	}
+	for i := 0; i < 10; i++ {
+		r.Record(minute(i))
+	}
+
+	tests := []struct {
+		since time.Time
+		want  int
+	}{
+		{time.Time{}, 10},
+		{minute(7), 3}, // inclusive at the boundary
+		{minute(7).Add(time.Second), 2},
+		{minute(9), 1},
+		{minute(10), 0},
+	}
+	for _, tt := range tests {
+		got := r.Since(tt.since)
+		if len(got) != tt.want {
+			t.Errorf("Since(%v) returned %d samples, want %d", tt.since, len(got), tt.want)
+			continue
+		}
+		for _, s := range got {
+			if s.Time.Before(tt.since) {
+				t.Errorf("Since(%v) returned a sample from %v", tt.since, s.Time)
+			}
+		}
+	}
+}
+
+func TestConcurrentRecordAndSince(t *testing.T) {
+	var requests atomic.Int64
+	r := NewRecorder(Source{APIRequests: requests.Load})
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < Capacity/2; i++ {
+				requests.Add(1)
+				r.Record(minute(w*Capacity + i))
+			}
+		}(w)
+	}
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				if n := len(r.Since(time.Time{})); n > Capacity {
+					t.Errorf("Since returned %d samples, over Capacity", n)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := len(r.Since(time.Time{})); n != Capacity {
+		t.Fatalf("after %d records, got %d samples, want Capacity (%d)", 2*Capacity, n, Capacity)
+	}
+}
@@ -0,0 +1,68 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// ParseLevel returns the slog level for a LOG_LEVEL value (debug, info,
+// warn or error, case-insensitive). ok is false for anything else.
+func ParseLevel(name string) (level slog.Level, ok bool) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "debug":
+		return slog.LevelDebug, true
+	case "", "info":
+		return slog.LevelInfo, true
+	case "warn", "warning":
+		return slog.LevelWarn, true
+	case "error":
+		return slog.LevelError, true
+	}
+	return slog.LevelInfo, false
+}
+
+// New creates a JSON logger writing to stderr at the given level and makes
+// it the default, so the standard log package and slog's package-level
+// functions go through it too
+func New(level slog.Level) *slog.Logger {
+	logger := slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+	slog.SetDefault(logger)
+	return logger
+}
+
+// OrDefault returns logger, or slog's default logger if it is nil, so
+// components handed a nil logger keep working
+func OrDefault(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return slog.Default()
+	}
+	return logger
+}
+
+// Middleware logs one structured line per HTTP request, replacing chi's
+// text access log
+func Middleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	logger = OrDefault(logger)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			start := time.Now()
+			defer func() {
+				logger.Info("http request",
+					"method", r.Method,
+					"path", r.URL.Path,
+					"status", ww.Status(),
+					"bytes", ww.BytesWritten(),
+					"durationMs", time.Since(start).Milliseconds(),
+					"remoteAddr", r.RemoteAddr,
+				)
+			}()
+			next.ServeHTTP(ww, r)
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package matchmaker
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrServerBusy is returned for joins while the waiting queue is full, and
+// for bot games that can't wait while the server is at its game limit
+var ErrServerBusy = errors.New("server is busy, try again later")
+
+// Capacity is the matchmaker's usage against its limits; a zero limit is
+// unlimited
+type Capacity struct {
+	ActiveGames   int   `json:"activeGames"`
+	MaxGames      int   `json:"maxGames"`
+	Waiting       int   `json:"waiting"`
+	MaxWaiting    int   `json:"maxWaiting"`
+	QueueRefusals int64 `json:"queueRefusals"` // joins refused with the queue full
+	GamesDeferred int64 `json:"gamesDeferred"` // games held back at the game limit
+}
+
+// SetLimits caps the waiting queue and the number of active games; zero
+// leaves either unlimited. At the game limit joiners wait in the queue
+// until a game ends.
+func (m *Matchmaker) SetLimits(maxWaiting, maxGames int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maxWaiting = maxWaiting
+	m.maxGames = maxGames
+}
+
+// Capacity returns the current usage against the limits
+func (m *Matchmaker) Capacity() Capacity {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return Capacity{
+		ActiveGames:   len(m.activeGames),
+		MaxGames:      m.maxGames,
+		Waiting:       len(m.waitingQueue),
+		MaxWaiting:    m.maxWaiting,
+		QueueRefusals: m.queueRefusals,
+		GamesDeferred: m.gamesDeferred,
+	}
+}
+
+// gamesFullLocked reports whether the game limit rules out another game;
+// caller holds the lock
+func (m *Matchmaker) gamesFullLocked() bool {
+	return m.maxGames > 0 && len(m.activeGames) >= m.maxGames
+}
+
+// queueFullLocked reports whether the waiting queue has no room for
+// another player; caller holds the lock
+func (m *Matchmaker) queueFullLocked() bool {
+	return m.maxWaiting > 0 && len(m.waitingQueue) >= m.maxWaiting
+}
+
+// refuseJoinLocked counts and logs a join refused by the queue limit;
+// caller holds the lock
+func (m *Matchmaker) refuseJoinLocked(username string) error {
+	m.queueRefusals++
+	m.logger.Warn("waiting queue full, join refused", "username", username, "waiting", len(m.waitingQueue), "maxWaiting", m.maxWaiting)
+	return ErrServerBusy
+}
+
+// deferGameLocked counts and logs a game held back by the game limit;
+// caller holds the lock
+func (m *Matchmaker) deferGameLocked(username string) {
+	m.gamesDeferred++
+	m.logger.Warn("game limit reached, player kept waiting", "username", username, "activeGames", len(m.activeGames), "maxGames", m.maxGames)
+}
+
+// startWaitingLocked starts games for waiting players while there is room
+// under the game limit; caller holds the lock
+func (m *Matchmaker) startWaitingLocked() {
+	if m.closed {
+		return
+	}
+	m.dropCancelledLocked()
+	for !m.gamesFullLocked() {
+		if !m.startNextWaitingLocked() {
+			return
+		}
+	}
+}
+
+// startNextWaitingLocked starts one game for the first waiting player who
+// can have one: the bot game they are owed, or a match with the first
+// opponent wanting the same kind of game. It reports whether a game
+// started. Caller holds the lock.
+func (m *Matchmaker) startNextWaitingLocked() bool {
+	for _, w := range m.waitingQueue {
+		if w.botPending {
+			m.removeWaitingLocked(w)
+			close(w.done)
+			m.startBotForWaitingLocked(w)
+			return true
+		}
+		opponent := m.pickOpponentLocked(w.Username, w.Options, w, time.Since(w.JoinedAt) >= rematchAfter)
+		if opponent == nil {
+			continue
+		}
+		m.removeWaitingLocked(w)
+		close(w.done)
+		m.recordWaitLocked(time.Since(w.JoinedAt))
+		w.MatchChan <- m.startHumanGameLocked(opponent, w.Username, w.Options)
+		return true
+	}
+	return false
+}
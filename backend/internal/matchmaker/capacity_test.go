@@ -0,0 +1,142 @@
+package matchmaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/connect-four/internal/game"
+)
+
+// receive waits for the game sent on ch
+func receive(t *testing.T, username string, ch <-chan *game.Game) *game.Game {
+	t.Helper()
+	select {
+	case g := <-ch:
+		if g == nil {
+			t.Fatalf("%s's match channel closed without a game", username)
+		}
+		return g
+	case <-time.After(time.Second):
+		t.Fatalf("%s got no game", username)
+		return nil
+	}
+}
+
+func TestQueueLimitRefusesJoins(t *testing.T) {
+	m := NewMatchmaker()
+	m.SetLimits(2, 0)
+	wide := game.BoardSize{Rows: 7, Columns: 8, WinLength: 4}
+
+	// Two players who can't be paired fill the queue
+	if _, err := m.JoinQueue("alice", JoinOptions{NoBotFallback: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.JoinQueue("bob", JoinOptions{NoBotFallback: true, Size: wide}); err != nil {
+		t.Fatal(err)
+	}
+
+	friendly := JoinOptions{NoBotFallback: true, Friendly: true}
+	if _, err := m.JoinQueue("carol", friendly); !errors.Is(err, ErrServerBusy) {
+		t.Fatalf("join with the queue full: err = %v, want ErrServerBusy", err)
+	}
+	if c := m.Capacity(); c.Waiting != 2 || c.MaxWaiting != 2 || c.QueueRefusals != 1 {
+		t.Errorf("capacity = %+v, want 2 of 2 waiting and 1 refusal", c)
+	}
+
+	// A joiner who is matched straight away never needs a queue slot
+	ch, err := m.JoinQueue("dave", JoinOptions{NoBotFallback: true})
+	if err != nil {
+		t.Fatalf("join matching a waiting player: %v", err)
+	}
+	if g := receive(t, "dave", ch); g.GetState().Player1 != "alice" {
+		t.Errorf("dave matched with %s, want alice", g.GetState().Player1)
+	}
+
+	// Nor does a bot game
+	if _, err := m.JoinQueue("erin", JoinOptions{VsBot: true}); err != nil {
+		t.Errorf("bot game with the queue full: %v", err)
+	}
+
+	// With a slot free again the refused player gets in
+	if _, err := m.JoinQueue("carol", friendly); err != nil {
+		t.Errorf("join after a slot freed: %v", err)
+	}
+	if c := m.Capacity(); c.Waiting != 2 || c.QueueRefusals != 1 {
+		t.Errorf("capacity = %+v, want 2 waiting and still 1 refusal", c)
+	}
+}
+
+func TestGameLimitDefersJoiners(t *testing.T) {
+	m := NewMatchmaker()
+	m.SetLimits(0, 1)
+	first := startHumanGame(t, m, "alice", "bob")
+
+	carol, err := m.JoinQueue("carol", JoinOptions{NoBotFallback: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dave, err := m.JoinQueue("dave", JoinOptions{NoBotFallback: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case g := <-dave:
+		t.Fatalf("dave matched into %v beyond the game limit", g)
+	default:
+	}
+	if c := m.Capacity(); c.ActiveGames != 1 || c.MaxGames != 1 || c.Waiting != 2 || c.GamesDeferred != 2 {
+		t.Errorf("capacity = %+v, want 1 of 1 games, 2 waiting and 2 deferred", c)
+	}
+
+	// A bot game can't wait its turn when asked for outside the queue
+	if _, err := m.StartBotGame("erin", JoinOptions{}); !errors.Is(err, ErrServerBusy) {
+		t.Errorf("StartBotGame at the game limit: err = %v, want ErrServerBusy", err)
+	}
+
+	// The game ending lets the waiting pair start theirs
+	first.Forfeit(game.Player1)
+	m.RemoveGame(first.ID)
+	g := receive(t, "carol", carol)
+	if g != receive(t, "dave", dave) {
+		t.Fatal("carol and dave got different games")
+	}
+	if pair(g) != [2]string{"carol", "dave"} {
+		t.Errorf("started %v, want carol and dave", pair(g))
+	}
+	if c := m.Capacity(); c.ActiveGames != 1 || c.Waiting != 0 {
+		t.Errorf("capacity = %+v, want 1 game and nobody waiting", c)
+	}
+}
+
+func TestGameLimitQueuesBotGames(t *testing.T) {
+	m := NewMatchmaker()
+	m.SetLimits(0, 1)
+	first := startHumanGame(t, m, "alice", "bob")
+
+	ch, err := m.JoinQueue("erin", JoinOptions{VsBot: true, BotDifficulty: game.DifficultyEasy})
+	if err != nil {
+		t.Fatalf("bot join at the game limit: %v", err)
+	}
+	select {
+	case g := <-ch:
+		t.Fatalf("bot game %v started beyond the game limit", g)
+	default:
+	}
+
+	first.Forfeit(game.Player1)
+	m.RemoveGame(first.ID)
+	if g := receive(t, "erin", ch); !g.GetState().IsVsBot {
+		t.Errorf("erin got %+v, want her bot game", g.GetState())
+	}
+}
+
+func TestNoLimitsByDefault(t *testing.T) {
+	m := NewMatchmaker()
+	for _, p := range [][2]string{{"a", "b"}, {"c", "d"}, {"e", "f"}} {
+		startHumanGame(t, m, p[0], p[1])
+	}
+	if c := m.Capacity(); c.ActiveGames != 3 || c.MaxGames != 0 || c.MaxWaiting != 0 || c.GamesDeferred != 0 {
+		t.Errorf("capacity = %+v, want 3 unlimited games", c)
+	}
+}
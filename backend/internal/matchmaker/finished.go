@@ -0,0 +1,91 @@
+package matchmaker
+
+import (
+	"time"
+
+	"github.com/connect-four/internal/game"
+)
+
+// DefaultFinishedGrace is how long a finished game stays viewable after it
+// is removed from the active games
+const DefaultFinishedGrace = 2 * time.Minute
+
+// finishedGame is a removed game kept for players reconnecting to see
+// the result
+type finishedGame struct {
+	game  *game.Game
+	until time.Time
+}
+
+// SetFinishedGrace sets how long finished games stay viewable after they
+// are removed; zero forgets them at once
+func (m *Matchmaker) SetFinishedGrace(grace time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.finishedGrace = grace
+}
+
+// FinishedGrace returns how long finished games stay viewable after they
+// are removed
+func (m *Matchmaker) FinishedGrace() time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.finishedGrace
+}
+
+// keepFinishedLocked moves a finished game being removed into the
+// recently finished games, keeping its tokens so players can still prove
+// their seat. It returns false if the game isn't kept. Caller holds the
+// lock.
+func (m *Matchmaker) keepFinishedLocked(g *game.Game, now time.Time) bool {
+	if m.finishedGrace <= 0 || g.GetState().Status != game.StatusFinished {
+		return false
+	}
+	m.finishedGames[g.ID] = finishedGame{game: g, until: now.Add(m.finishedGrace)}
+	return true
+}
+
+// GetFinishedGame returns a game that finished and was removed within the
+// grace window, or nil
+func (m *Matchmaker) GetFinishedGame(gameID string) *game.Game {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, ok := m.finishedGames[gameID]
+	if !ok || !time.Now().Before(f.until) {
+		return nil
+	}
+	return f.game
+}
+
+// GetFinishedGameByPlayer returns the player's most recently finished game
+// still within the grace window, or nil
+func (m *Matchmaker) GetFinishedGameByPlayer(username string) *game.Game {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var latest finishedGame
+	for _, f := range m.finishedGames {
+		if !now.Before(f.until) || f.game.GetPlayerByUsername(username) == 0 {
+			continue
+		}
+		if f.until.After(latest.until) {
+			latest = f
+		}
+	}
+	return latest.game
+}
+
+// forgetFinished drops recently finished games whose window has passed
+func (m *Matchmaker) forgetFinished(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, f := range m.finishedGames {
+		if !now.Before(f.until) {
+			delete(m.finishedGames, id)
+			delete(m.tokens, id)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package matchmaker
+
+import (
+	"time"
+
+	"github.com/connect-four/internal/game"
+)
+
+// FirstPlayerPolicy selects which seat moves first in new games
+type FirstPlayerPolicy string
+
+const (
+	// FirstPlayerRandom tosses a coin for each game, except that two
+	// players meeting again within recentOpponentTTL take turns going first
+	FirstPlayerRandom FirstPlayerPolicy = "random"
+
+	// FirstPlayerPlayer1 always starts with Player1: the player who queued
+	// first, or the human in a bot game
+	FirstPlayerPlayer1 FirstPlayerPolicy = "player1"
+)
+
+// SetFirstPlayerPolicy sets how new games pick who moves first
+func (m *Matchmaker) SetFirstPlayerPolicy(policy FirstPlayerPolicy) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.firstPlayer = policy
+}
+
+// firstPlayerLocked picks the seat to move first in a game between
+// player1 and player2, which is empty for the bot; caller holds the lock
+func (m *Matchmaker) firstPlayerLocked(player1, player2 string) int {
+	if m.firstPlayer != FirstPlayerRandom {
+		return game.Player1
+	}
+	if movedFirst, ok := m.lastMovedFirstLocked(player1, player2, time.Now()); ok {
+		if movedFirst {
+			return game.Player2
+		}
+		return game.Player1
+	}
+	if m.rng.Intn(2) == 0 {
+		return game.Player1
+	}
+	return game.Player2
+}
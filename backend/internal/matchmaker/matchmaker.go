@@ -1,107 +1,339 @@
 package matchmaker
 
 import (
-	"log"
+	"errors"
+	"log/slog"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/connect-four/internal/game"
+	"github.com/connect-four/internal/logging"
+	"github.com/connect-four/internal/safego"
 )
 
+// MatchmakingTimeout is the default wait before a lone player gets a bot
 const MatchmakingTimeout = 10 * time.Second
 
+// recentWaitsKept is how many human-match wait times feed the wait estimate
+const recentWaitsKept = 20
+
+// ErrAlreadyQueued is returned when a player joins while already waiting
+var ErrAlreadyQueued = errors.New("already waiting for a match")
+
+// ErrAlreadyInGame is returned when a player starts a game while already in one
+var ErrAlreadyInGame = errors.New("already in a game")
+
+// ErrNotQueued is returned when a player leaves a queue they aren't in
+var ErrNotQueued = errors.New("not waiting for a match")
+
+// ErrShuttingDown is returned for new games once StopAccepting was called
+var ErrShuttingDown = errors.New("server is shutting down")
+
+// JoinOptions are the per-player preferences sent with a join request
+type JoinOptions struct {
+	BotDifficulty game.Difficulty // used if the player falls back to a bot game
+	BotFirst      bool            // in a bot game, seat the bot as Player1 so it moves first, whatever the first-player policy
+	NoBotFallback bool            // wait for a human opponent indefinitely
+	VsBot         bool            // skip the queue and start a bot game right away
+	DiscEmoji     string          // already validated by the caller
+	AvatarURL     string          // already validated by the caller
+	Size          game.BoardSize  // already validated; zero for the standard board
+	Friendly      bool            // an unrated game with takebacks, against another friendly player
+
+	// Cancel is closed when the player's connection goes away; a cancelled
+	// player is dropped from the queue and never gets a game
+	Cancel <-chan struct{}
+}
+
 // WaitingPlayer represents a player waiting for a match
 type WaitingPlayer struct {
 	Username  string
 	JoinedAt  time.Time
 	MatchChan chan *game.Game
+	Options   JoinOptions
+
+	// done is closed when the player leaves the queue for any reason, which
+	// stops their bot-fallback timer
+	done chan struct{}
+
+	// fallbackAt is when the bot fallback starts, zero if opted out
+	fallbackAt time.Time
+
+	// Set when the player is owed a bot game held back by the game limit,
+	// see startWaitingLocked
+	botPending bool
 }
 
 // Matchmaker handles player matching
 type Matchmaker struct {
 	waitingQueue []*WaitingPlayer
-	activeGames  map[string]*game.Game       // gameID -> game
-	playerGames  map[string]string           // username -> gameID
+	activeGames  map[string]*game.Game // gameID -> game
+	playerGames  map[string]string     // username -> gameID
 	mu           sync.Mutex
 	onGameStart  func(g *game.Game)
+	onFallback   func(g *game.Game, username string, waited time.Duration)
+	tokens       map[string]gameTokens // gameID -> seat tokens
+	turnTimeout  time.Duration
+	reconnect    time.Duration
+	rng          *game.Rand // shared by bots in new games
+	firstPlayer  FirstPlayerPolicy
+	takebacks    int // takebacks per player in new friendly games
+	matchTimeout time.Duration
+	recentWaits  []time.Duration // how long recent human matches waited, oldest first
+
+	recentOpponents map[string][]recentOpponent // username -> last human opponents, newest last
+
+	logger *slog.Logger
+
+	// Set by StopAccepting; no new games are started
+	closed bool
+
+	// Limits on the queue and active games, see capacity.go
+	maxWaiting    int
+	maxGames      int
+	queueRefusals int64
+	gamesDeferred int64
+
+	// Stuck-game sweep, see reaper.go
+	onGameEnd func(g *game.Game)
+	attended  func(gameID string) bool
+	reaped    atomic.Int64
+
+	// Games kept viewable after they finish, see finished.go
+	finishedGames map[string]finishedGame
+	finishedGrace time.Duration
+
+	// Checkpoints of unfinished games, see persist.go
+	gameStore     GameStore
+	savedVersions map[string]int // gameID -> StateVersion last saved
 }
 
 // NewMatchmaker creates a new matchmaker instance
 func NewMatchmaker() *Matchmaker {
 	return &Matchmaker{
-		waitingQueue: make([]*WaitingPlayer, 0),
-		activeGames:  make(map[string]*game.Game),
-		playerGames:  make(map[string]string),
+		waitingQueue:  make([]*WaitingPlayer, 0),
+		activeGames:   make(map[string]*game.Game),
+		playerGames:   make(map[string]string),
+		tokens:        make(map[string]gameTokens),
+		finishedGames: make(map[string]finishedGame),
+		finishedGrace: DefaultFinishedGrace,
+		turnTimeout:   game.DefaultTurnTimeout,
+		reconnect:     game.DefaultReconnectWindow,
+		rng:           game.NewTimeSeededRand(),
+		firstPlayer:   FirstPlayerRandom,
+		takebacks:     game.DefaultMaxTakebacks,
+		matchTimeout:  MatchmakingTimeout,
+		logger:        slog.Default(),
+
+		recentOpponents: make(map[string][]recentOpponent),
 	}
 }
 
+// SetLogger sets the matchmaker's logger; nil falls back to slog's default logger
+func (m *Matchmaker) SetLogger(logger *slog.Logger) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.logger = logging.OrDefault(logger)
+}
+
+// SetMatchmakingTimeout sets how long a player waits before the bot fallback
+func (m *Matchmaker) SetMatchmakingTimeout(timeout time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.matchTimeout = timeout
+}
+
+// SetRand sets the random source handed to bots in new games
+func (m *Matchmaker) SetRand(rng *game.Rand) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rng = rng
+}
+
+// SetTurnTimeout sets the per-move clock for new games (zero disables it)
+func (m *Matchmaker) SetTurnTimeout(timeout time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.turnTimeout = timeout
+}
+
+// SetReconnectWindow sets how long disconnected players in new games have to come back
+func (m *Matchmaker) SetReconnectWindow(window time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reconnect = window
+}
+
+// SetMaxTakebacks sets how many takebacks each player may have accepted in
+// new friendly games (zero disables them)
+func (m *Matchmaker) SetMaxTakebacks(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.takebacks = n
+}
+
+// newGame creates a game with the matchmaker's game settings; caller holds the lock
+func (m *Matchmaker) newGame(player1Username string, size game.BoardSize) *game.Game {
+	g := game.NewGameOfSize(player1Username, size)
+	g.TurnTimeout = m.turnTimeout
+	g.ReconnectWindow = m.reconnect
+	g.MaxTakebacks = m.takebacks
+	return g
+}
+
 // SetOnGameStart sets the callback for when a game starts
 func (m *Matchmaker) SetOnGameStart(callback func(g *game.Game)) {
 	m.onGameStart = callback
 }
 
+// SetOnFallback sets the callback for when a player who waited out the
+// matchmaking timeout is given a bot game
+func (m *Matchmaker) SetOnFallback(callback func(g *game.Game, username string, waited time.Duration)) {
+	m.onFallback = callback
+}
+
 // JoinQueue adds a player to the matchmaking queue
 // Returns a channel that will receive the game when matched
-func (m *Matchmaker) JoinQueue(username string) (<-chan *game.Game, error) {
+func (m *Matchmaker) JoinQueue(username string, opts JoinOptions) (<-chan *game.Game, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	// Check if player is already in a game
-	if gameID, exists := m.playerGames[username]; exists {
-		if g, ok := m.activeGames[gameID]; ok {
-			// Return existing game for reconnection
-			ch := make(chan *game.Game, 1)
-			ch <- g
-			return ch, nil
-		}
+	// Reconnecting is the handler's job, which checks the seat token; a
+	// finished game waiting to be removed doesn't hold the player back
+	if m.inGameLocked(username) {
+		return nil, ErrAlreadyInGame
 	}
 
-	// Check if there's a waiting player to match with
-	if len(m.waitingQueue) > 0 {
-		// Match with the first waiting player
-		opponent := m.waitingQueue[0]
-		m.waitingQueue = m.waitingQueue[1:]
-
-		// Create new game
-		g := game.NewGame(opponent.Username)
-		g.AddPlayer2(username, false)
-
-		// Register the game
-		m.activeGames[g.ID] = g
-		m.playerGames[opponent.Username] = g.ID
-		m.playerGames[username] = g.ID
+	if m.closed {
+		return nil, ErrShuttingDown
+	}
+	m.dropCancelledLocked()
+	opts.Size = opts.Size.OrStandard()
 
-		// Notify the waiting player
-		opponent.MatchChan <- g
+	// A player can only be queued once, which also rules out self-matches
+	for _, w := range m.waitingQueue {
+		if w.Username == username {
+			return nil, ErrAlreadyQueued
+		}
+	}
 
-		// Return the game to the joining player
+	full := m.gamesFullLocked()
+	if opts.VsBot && !full {
 		ch := make(chan *game.Game, 1)
-		ch <- g
+		ch <- m.startBotGameLocked(username, opts)
+		return ch, nil
+	}
 
-		if m.onGameStart != nil {
-			go m.onGameStart(g)
+	// Match with the first waiting player on the same board who isn't a
+	// recent opponent
+	if !full && !opts.VsBot {
+		if opponent := m.pickOpponentLocked(username, opts, nil, false); opponent != nil {
+			// Return the game to the joining player
+			ch := make(chan *game.Game, 1)
+			ch <- m.startHumanGameLocked(opponent, username, opts)
+			return ch, nil
 		}
-
-		return ch, nil
 	}
 
-	// No opponent available, add to queue
+	// No opponent available or no room for a game, add to queue
+	if m.queueFullLocked() {
+		return nil, m.refuseJoinLocked(username)
+	}
+	if full {
+		m.deferGameLocked(username)
+	}
 	waiting := &WaitingPlayer{
-		Username:  username,
-		JoinedAt:  time.Now(),
-		MatchChan: make(chan *game.Game, 1),
+		Username:   username,
+		JoinedAt:   time.Now(),
+		MatchChan:  make(chan *game.Game, 1),
+		Options:    opts,
+		done:       make(chan struct{}),
+		botPending: opts.VsBot,
 	}
 	m.waitingQueue = append(m.waitingQueue, waiting)
+	if opts.VsBot {
+		return waiting.MatchChan, nil
+	}
+	if !opts.NoBotFallback {
+		waiting.fallbackAt = waiting.JoinedAt.Add(m.matchTimeout)
+	}
 
 	// Start timeout goroutine
-	go m.handleMatchmakingTimeout(waiting)
+	safego.Go(m.logger.With("username", username), "matchmaking timeout", func() {
+		m.handleMatchmakingTimeout(waiting, m.matchTimeout)
+	}, nil)
 
 	return waiting.MatchChan, nil
 }
 
-// handleMatchmakingTimeout handles the 10-second timeout for matchmaking
-func (m *Matchmaker) handleMatchmakingTimeout(waiting *WaitingPlayer) {
-	time.Sleep(MatchmakingTimeout)
+// inGameLocked reports whether a player is in a game that hasn't finished;
+// caller holds the lock
+func (m *Matchmaker) inGameLocked(username string) bool {
+	g, ok := m.activeGames[m.playerGames[username]]
+	return ok && g.Summary().Status != game.StatusFinished
+}
+
+// StartBotGame starts a bot game for a player right away, without going
+// through the queue
+func (m *Matchmaker) StartBotGame(username string, opts JoinOptions) (*game.Game, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.inGameLocked(username) {
+		return nil, ErrAlreadyInGame
+	}
+	if m.closed {
+		return nil, ErrShuttingDown
+	}
+	m.dropCancelledLocked()
+	for _, w := range m.waitingQueue {
+		if w.Username == username {
+			return nil, ErrAlreadyQueued
+		}
+	}
+	if m.gamesFullLocked() {
+		m.logger.Warn("game limit reached, bot game refused", "username", username, "activeGames", len(m.activeGames), "maxGames", m.maxGames)
+		return nil, ErrServerBusy
+	}
+
+	return m.startBotGameLocked(username, opts), nil
+}
+
+// handleMatchmakingTimeout starts a bot game for a player still waiting
+// after timeout, unless they opted out of the bot fallback. Before that,
+// once they have waited rematchAfter, they may be paired with a recent
+// opponent who is also waiting.
+func (m *Matchmaker) handleMatchmakingTimeout(waiting *WaitingPlayer, timeout time.Duration) {
+	// A nil channel never fires, so opted-out players just wait
+	var fallback <-chan time.Time
+	rematchDelay := rematchAfter
+	if !waiting.Options.NoBotFallback {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		fallback = timer.C
+		rematchDelay = min(rematchDelay, timeout/2)
+	}
+	rematch := time.NewTimer(rematchDelay)
+	defer rematch.Stop()
+
+	for fallen := false; !fallen; {
+		select {
+		case <-waiting.done:
+			return // matched or left the queue
+		case <-waiting.Options.Cancel:
+			m.mu.Lock()
+			m.dropCancelledLocked()
+			m.mu.Unlock()
+			return
+		case <-rematch.C:
+			if m.rematchWaiting(waiting) {
+				return
+			}
+		case <-fallback:
+			fallen = true
+		}
+	}
 
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -109,31 +341,225 @@ func (m *Matchmaker) handleMatchmakingTimeout(waiting *WaitingPlayer) {
 	// Check if still in queue
 	for i, w := range m.waitingQueue {
 		if w == waiting {
+			// At the game limit the player keeps their place and gets the
+			// bot game once there is room
+			if m.gamesFullLocked() {
+				w.botPending = true
+				m.deferGameLocked(w.Username)
+				return
+			}
+
 			// Remove from queue
 			m.waitingQueue = append(m.waitingQueue[:i], m.waitingQueue[i+1:]...)
+			m.startBotForWaitingLocked(w)
+			return
+		}
+	}
+
+	// Player was already matched, do nothing
+}
+
+// startBotForWaitingLocked creates a bot game for a player taken off the
+// queue and notifies them; caller holds the lock
+func (m *Matchmaker) startBotForWaitingLocked(waiting *WaitingPlayer) {
+	g := m.startBotGameLocked(waiting.Username, waiting.Options)
+	waiting.MatchChan <- g
+	if m.onFallback != nil && !waiting.Options.VsBot {
+		wait := time.Since(waiting.JoinedAt)
+		safego.Go(m.logger.With("gameID", g.ID), "fallback callback", func() {
+			m.onFallback(g, waiting.Username, wait)
+		}, nil)
+	}
+}
+
+// rematchWaiting pairs a player who has waited long enough with anyone else
+// in the queue, recent opponents included. It returns true if the player is
+// no longer waiting.
+func (m *Matchmaker) rematchWaiting(waiting *WaitingPlayer) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.dropCancelledLocked()
+	queued := false
+	for _, w := range m.waitingQueue {
+		queued = queued || w == waiting
+	}
+	if !queued {
+		return true
+	}
+	if m.gamesFullLocked() {
+		return false // startWaitingLocked pairs them once there is room
+	}
+
+	opponent := m.pickOpponentLocked(waiting.Username, waiting.Options, waiting, true)
+	if opponent == nil {
+		return false // nobody to pair with yet
+	}
+
+	m.removeWaitingLocked(waiting)
+	close(waiting.done)
+	m.recordWaitLocked(time.Since(waiting.JoinedAt))
+	waiting.MatchChan <- m.startHumanGameLocked(opponent, waiting.Username, waiting.Options)
+	return true
+}
+
+// removeWaitingLocked takes a player out of the queue, reporting whether
+// they were in it; caller holds the lock
+func (m *Matchmaker) removeWaitingLocked(waiting *WaitingPlayer) bool {
+	for i, w := range m.waitingQueue {
+		if w == waiting {
+			m.waitingQueue = append(m.waitingQueue[:i], m.waitingQueue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
 
-			// Create game with bot
-			log.Printf("[Matchmaker] Creating bot game for player: %s", waiting.Username)
-			g := game.NewGame(waiting.Username)
-			g.AddPlayer2("BOT", true)
-			log.Printf("[Matchmaker] Bot game created: ID=%s, Player2IsBot=%v", g.ID, g.Player2.IsBot)
+// startHumanGameLocked matches a waiting player, who becomes Player1, with
+// username and notifies the waiting player; caller holds the lock
+func (m *Matchmaker) startHumanGameLocked(opponent *WaitingPlayer, username string, opts JoinOptions) *game.Game {
+	m.removeWaitingLocked(opponent)
+	close(opponent.done)
+	m.recordWaitLocked(time.Since(opponent.JoinedAt))
+	first := m.firstPlayerLocked(opponent.Username, username)
+	m.recordOpponentsLocked(opponent.Username, username, first == game.Player1)
+
+	// Create new game
+	g := m.newGame(opponent.Username, opponent.Options.Size)
+	g.AddPlayer2(username, false)
+	g.SetFirstPlayer(first)
+	g.Friendly = opts.Friendly
+	g.SetCosmetics(game.Player1, opponent.Options.DiscEmoji, opponent.Options.AvatarURL)
+	g.SetCosmetics(game.Player2, opts.DiscEmoji, opts.AvatarURL)
+
+	// Register the game
+	m.registerGameLocked(g)
+
+	// Notify the waiting player
+	opponent.MatchChan <- g
+
+	if m.onGameStart != nil {
+		m.goGameStart(g)
+	}
+	return g
+}
 
-			// Register the game
-			m.activeGames[g.ID] = g
-			m.playerGames[waiting.Username] = g.ID
+// goGameStart runs the game start callback on a new goroutine
+func (m *Matchmaker) goGameStart(g *game.Game) {
+	safego.Go(m.logger.With("gameID", g.ID), "game start callback", func() {
+		m.onGameStart(g)
+	}, nil)
+}
 
-			// Notify the player
-			waiting.MatchChan <- g
+// startBotGameLocked creates and registers a bot game for a player; caller holds the lock
+func (m *Matchmaker) startBotGameLocked(username string, opts JoinOptions) *game.Game {
+	g := m.newGame(username, opts.Size)
+	if opts.BotFirst {
+		g.AddBotAsPlayer1(opts.BotDifficulty, game.WithRand(m.rng))
+	} else {
+		g.AddBot(opts.BotDifficulty, game.WithRand(m.rng))
+		g.SetFirstPlayer(m.firstPlayerLocked(username, ""))
+	}
+	g.SetCosmetics(g.GetPlayerByUsername(username), opts.DiscEmoji, opts.AvatarURL)
+	m.logger.Info("bot game created", "gameID", g.ID, "username", username, "botPlayer", g.BotPlayer(), "firstPlayer", g.FirstPlayer, "difficulty", g.Bot.Difficulty())
 
-			if m.onGameStart != nil {
-				go m.onGameStart(g)
-			}
+	m.registerGameLocked(g)
 
-			return
+	if m.onGameStart != nil {
+		m.goGameStart(g)
+	}
+	return g
+}
+
+// recordWaitLocked remembers how long a matched player waited; caller holds the lock
+func (m *Matchmaker) recordWaitLocked(wait time.Duration) {
+	m.recentWaits = append(m.recentWaits, wait)
+	if len(m.recentWaits) > recentWaitsKept {
+		m.recentWaits = m.recentWaits[len(m.recentWaits)-recentWaitsKept:]
+	}
+}
+
+// averageWaitLocked returns the average wait of recent human matches, or
+// zero without any; caller holds the lock
+func (m *Matchmaker) averageWaitLocked() time.Duration {
+	if len(m.recentWaits) == 0 {
+		return 0
+	}
+	var total time.Duration
+	for _, wait := range m.recentWaits {
+		total += wait
+	}
+	return total / time.Duration(len(m.recentWaits))
+}
+
+// AverageMatchWait returns how long the last few human matches waited on
+// average, and how many matches that covers
+func (m *Matchmaker) AverageMatchWait() (time.Duration, int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.averageWaitLocked(), len(m.recentWaits)
+}
+
+// QueueStatus is a waiting player's view of the queue
+type QueueStatus struct {
+	Position      int           // 1-based place in the queue
+	Waiting       int           // players currently waiting
+	BotFallbackIn time.Duration // until the bot fallback, zero if opted out
+	EstimatedWait time.Duration // average wait of recent human matches, zero if unknown
+	NoBotFallback bool
+}
+
+// QueueStatus returns a snapshot of the queue for a waiting player; ok is
+// false if they aren't waiting
+func (m *Matchmaker) QueueStatus(username string) (status QueueStatus, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, w := range m.waitingQueue {
+		if w.Username != username {
+			continue
+		}
+		status = QueueStatus{
+			Position:      i + 1,
+			Waiting:       len(m.waitingQueue),
+			NoBotFallback: w.Options.NoBotFallback,
 		}
+		if !w.fallbackAt.IsZero() {
+			status.BotFallbackIn = max(time.Until(w.fallbackAt), 0)
+		}
+		status.EstimatedWait = m.averageWaitLocked()
+		return status, true
 	}
+	return QueueStatus{}, false
+}
 
-	// Player was already matched, do nothing
+// dropCancelledLocked removes waiting players whose connection has gone
+// away; caller holds the lock
+func (m *Matchmaker) dropCancelledLocked() {
+	kept := m.waitingQueue[:0]
+	for _, w := range m.waitingQueue {
+		select {
+		case <-w.Options.Cancel:
+			m.logger.Info("dropping disconnected waiting player", "username", w.Username)
+			close(w.done)
+			close(w.MatchChan)
+		default:
+			kept = append(kept, w)
+		}
+	}
+	m.waitingQueue = kept
+}
+
+// ListGames returns a snapshot of all active games
+func (m *Matchmaker) ListGames() []*game.Game {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	games := make([]*game.Game, 0, len(m.activeGames))
+	for _, g := range m.activeGames {
+		games = append(games, g)
+	}
+	return games
 }
 
 // GetGame returns a game by ID
@@ -160,26 +586,51 @@ func (m *Matchmaker) RemoveGame(gameID string) {
 	defer m.mu.Unlock()
 
 	if g, exists := m.activeGames[gameID]; exists {
-		delete(m.playerGames, g.Player1.Username)
-		if g.Player2 != nil && !g.Player2.IsBot {
-			delete(m.playerGames, g.Player2.Username)
+		for _, p := range []*game.Player{g.Player1, g.Player2} {
+			// The player may have moved on to a new game already
+			if p != nil && !p.IsBot && m.playerGames[p.Username] == gameID {
+				delete(m.playerGames, p.Username)
+			}
 		}
 		delete(m.activeGames, gameID)
+		if !m.keepFinishedLocked(g, time.Now()) {
+			delete(m.tokens, gameID)
+		}
+		m.dropCheckpointLocked(gameID)
+		m.startWaitingLocked()
 	}
 }
 
-// LeaveQueue removes a player from the waiting queue
-func (m *Matchmaker) LeaveQueue(username string) {
+// LeaveQueue removes a player from the waiting queue, stopping their bot
+// fallback and closing their match channel
+func (m *Matchmaker) LeaveQueue(username string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	for i, w := range m.waitingQueue {
 		if w.Username == username {
 			m.waitingQueue = append(m.waitingQueue[:i], m.waitingQueue[i+1:]...)
+			close(w.done)
 			close(w.MatchChan)
-			return
+			return nil
 		}
 	}
+	return ErrNotQueued
+}
+
+// StopAccepting refuses new games from now on and empties the queue,
+// closing every waiting player's match channel. Games in progress and
+// reconnects to them are unaffected.
+func (m *Matchmaker) StopAccepting() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.closed = true
+	for _, w := range m.waitingQueue {
+		close(w.done)
+		close(w.MatchChan)
+	}
+	m.waitingQueue = nil
 }
 
 // GetActiveGameCount returns the number of active games
@@ -195,3 +646,58 @@ func (m *Matchmaker) GetWaitingCount() int {
 	defer m.mu.Unlock()
 	return len(m.waitingQueue)
 }
+
+// WaitingDebug describes a queued player in a debug snapshot
+type WaitingDebug struct {
+	Username       string  `json:"username"`
+	WaitingSeconds float64 `json:"waitingSeconds"`
+	BotDifficulty  string  `json:"botDifficulty,omitempty"`
+}
+
+// DebugSnapshot is the matchmaker's in-memory state for debugging
+type DebugSnapshot struct {
+	Games          []game.GameDebug  `json:"games"`
+	GamesTruncated bool              `json:"gamesTruncated"`
+	TotalGames     int               `json:"totalGames"`
+	Queue          []WaitingDebug    `json:"queue"`
+	PlayerGames    map[string]string `json:"playerGames"`
+}
+
+// DebugSnapshot copies the matchmaker state, including at most maxGames games.
+// The matchmaker lock is released before the games are snapshotted.
+func (m *Matchmaker) DebugSnapshot(maxGames int) DebugSnapshot {
+	m.mu.Lock()
+	games := make([]*game.Game, 0, len(m.activeGames))
+	for _, g := range m.activeGames {
+		games = append(games, g)
+	}
+	queue := make([]WaitingDebug, 0, len(m.waitingQueue))
+	for _, w := range m.waitingQueue {
+		queue = append(queue, WaitingDebug{
+			Username:       w.Username,
+			WaitingSeconds: time.Since(w.JoinedAt).Seconds(),
+			BotDifficulty:  string(w.Options.BotDifficulty),
+		})
+	}
+	playerGames := make(map[string]string, len(m.playerGames))
+	for username, gameID := range m.playerGames {
+		playerGames[username] = gameID
+	}
+	m.mu.Unlock()
+
+	snapshot := DebugSnapshot{
+		TotalGames:  len(games),
+		Queue:       queue,
+		PlayerGames: playerGames,
+	}
+	if maxGames >= 0 && len(games) > maxGames {
+		games = games[:maxGames]
+		snapshot.GamesTruncated = true
+	}
+	snapshot.Games = make([]game.GameDebug, 0, len(games))
+	for _, g := range games {
+		snapshot.Games = append(snapshot.Games, g.DebugSnapshot())
+	}
+
+	return snapshot
+}
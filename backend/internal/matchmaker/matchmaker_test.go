@@ -0,0 +1,131 @@
+package matchmaker
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/connect-four/internal/game"
+)
+
+// startHumanGame pairs a and b through the queue and returns their game
+func startHumanGame(t *testing.T, m *Matchmaker, a, b string) *game.Game {
+	t.Helper()
+	if _, err := m.JoinQueue(a, JoinOptions{NoBotFallback: true}); err != nil {
+		t.Fatalf("JoinQueue(%s): %v", a, err)
+	}
+	ch, err := m.JoinQueue(b, JoinOptions{NoBotFallback: true})
+	if err != nil {
+		t.Fatalf("JoinQueue(%s): %v", b, err)
+	}
+	select {
+	case g := <-ch:
+		return g
+	case <-time.After(time.Second):
+		t.Fatalf("%s and %s were not matched", a, b)
+		return nil
+	}
+}
+
+func TestJoinQueueWhileInGame(t *testing.T) {
+	m := NewMatchmaker()
+	startHumanGame(t, m, "alice", "bob")
+
+	if _, err := m.JoinQueue("alice", JoinOptions{}); !errors.Is(err, ErrAlreadyInGame) {
+		t.Fatalf("JoinQueue during a game: err = %v, want ErrAlreadyInGame", err)
+	}
+}
+
+func TestJoinQueueAfterFinishedGameNotRemoved(t *testing.T) {
+	m := NewMatchmaker()
+	old := startHumanGame(t, m, "alice", "bob")
+	if err := old.Resign(game.Player1); err != nil {
+		t.Fatal(err)
+	}
+
+	// The finished game is still registered, but must not be handed back
+	g := startHumanGame(t, m, "alice", "carol")
+	if g.ID == old.ID {
+		t.Fatal("JoinQueue returned the finished game")
+	}
+
+	// Removing the old game leaves the new one's bookkeeping alone
+	m.RemoveGame(old.ID)
+	if got := m.GetGameByPlayer("alice"); got != g {
+		t.Fatalf("GetGameByPlayer after removing the old game = %v, want %s", got, g.ID)
+	}
+}
+
+func TestSeededMatchmakerIsReproducible(t *testing.T) {
+	// run starts bot games with a fixed seed and records who moved first
+	// and where the bot played
+	run := func(seed int64) []int {
+		m := NewMatchmaker()
+		m.SetRand(game.NewRand(seed))
+		var picks []int
+		for i := 0; i < 20; i++ {
+			g, err := m.StartBotGame(fmt.Sprintf("player%d", i), JoinOptions{BotDifficulty: game.DifficultyEasy})
+			if err != nil {
+				t.Fatal(err)
+			}
+			state := g.GetState()
+			picks = append(picks, state.FirstPlayer)
+			if state.CurrentTurn != state.BotPlayer {
+				if _, err := g.MakeMove(state.CurrentTurn, 3); err != nil {
+					t.Fatal(err)
+				}
+			}
+			col, _, err := g.MakeBotMove()
+			if err != nil {
+				t.Fatal(err)
+			}
+			picks = append(picks, col)
+		}
+		return picks
+	}
+
+	first := run(7)
+	if again := run(7); !reflect.DeepEqual(first, again) {
+		t.Fatalf("the same seed gave\n%v\n%v", first, again)
+	}
+	if reflect.DeepEqual(first, run(8)) {
+		t.Error("different seeds gave the same first players and bot moves")
+	}
+}
+
+func TestJoinQueueNeverMatchesAPlayerWithThemselves(t *testing.T) {
+	m := NewMatchmaker()
+	ch, err := m.JoinQueue("alice", JoinOptions{NoBotFallback: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.JoinQueue("alice", JoinOptions{NoBotFallback: true}); !errors.Is(err, ErrAlreadyQueued) {
+		t.Fatalf("second join: err = %v, want ErrAlreadyQueued", err)
+	}
+	if _, err := m.StartBotGame("alice", JoinOptions{}); !errors.Is(err, ErrAlreadyQueued) {
+		t.Fatalf("bot game while queued: err = %v, want ErrAlreadyQueued", err)
+	}
+	if n := m.GetWaitingCount(); n != 1 {
+		t.Fatalf("%d players waiting, want alice once", n)
+	}
+	select {
+	case g := <-ch:
+		t.Fatalf("alice was matched into %v", g.GetState())
+	default:
+	}
+
+	// The queued entry still pairs with someone else
+	if _, err := m.JoinQueue("bob", JoinOptions{NoBotFallback: true}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case g := <-ch:
+		if state := g.GetState(); state.Player1 == state.Player2 {
+			t.Fatalf("matched %s against themselves", state.Player1)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("alice was not matched with bob")
+	}
+}
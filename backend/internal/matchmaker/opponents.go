@@ -0,0 +1,103 @@
+package matchmaker
+
+import "time"
+
+const (
+	// recentOpponentsKept is how many past opponents are remembered per player
+	recentOpponentsKept = 3
+
+	// recentOpponentTTL is how long a past opponent counts as recent
+	recentOpponentTTL = 10 * time.Minute
+
+	// rematchAfter is how long a player waits before being paired with a
+	// recent opponent anyway
+	rematchAfter = 5 * time.Second
+
+	// recentSweepThreshold is the number of tracked players above which
+	// expired entries are swept on every record
+	recentSweepThreshold = 1000
+)
+
+// recentOpponent is one past human opponent
+type recentOpponent struct {
+	username   string
+	at         time.Time
+	movedFirst bool // the player, not the opponent, moved first
+}
+
+// recordOpponentsLocked remembers that two players were just matched and
+// whether a moved first; caller holds the lock
+func (m *Matchmaker) recordOpponentsLocked(a, b string, aFirst bool) {
+	now := time.Now()
+	m.recentOpponents[a] = appendRecent(m.recentOpponents[a], recentOpponent{username: b, at: now, movedFirst: aFirst})
+	m.recentOpponents[b] = appendRecent(m.recentOpponents[b], recentOpponent{username: a, at: now, movedFirst: !aFirst})
+
+	if len(m.recentOpponents) > recentSweepThreshold {
+		for username, list := range m.recentOpponents {
+			if now.Sub(list[len(list)-1].at) > recentOpponentTTL {
+				delete(m.recentOpponents, username)
+			}
+		}
+	}
+}
+
+// appendRecent adds an opponent to a list, keeping the newest
+// recentOpponentsKept entries, newest last
+func appendRecent(list []recentOpponent, opponent recentOpponent) []recentOpponent {
+	list = append(list, opponent)
+	if len(list) > recentOpponentsKept {
+		list = list[len(list)-recentOpponentsKept:]
+	}
+	return list
+}
+
+// isRecentOpponentLocked reports whether two players met within the TTL;
+// caller holds the lock
+func (m *Matchmaker) isRecentOpponentLocked(a, b string, now time.Time) bool {
+	for _, r := range m.recentOpponents[a] {
+		if r.username == b && now.Sub(r.at) <= recentOpponentTTL {
+			return true
+		}
+	}
+	return false
+}
+
+// lastMovedFirstLocked reports whether a moved first the last time a and b
+// met, if that was within the TTL; caller holds the lock
+func (m *Matchmaker) lastMovedFirstLocked(a, b string, now time.Time) (movedFirst, ok bool) {
+	list := m.recentOpponents[a]
+	for i := len(list) - 1; i >= 0; i-- {
+		if r := list[i]; r.username == b && now.Sub(r.at) <= recentOpponentTTL {
+			return r.movedFirst, true
+		}
+	}
+	return false, false
+}
+
+// sameKindOfGame reports whether two players want games that can be
+// played together: the same board, and both friendly or both rated
+func sameKindOfGame(a, b JoinOptions) bool {
+	return a.Size == b.Size && a.Friendly == b.Friendly
+}
+
+// pickOpponentLocked returns the first waiting player wanting the same kind
+// of game as opts, other than exclude or a player owed a bot game, who
+// isn't a recent opponent of username. A recent opponent is only returned
+// when nobody else is waiting and one of the pair has waited at least
+// rematchAfter; waitedLong says the requester has. Caller holds the lock.
+func (m *Matchmaker) pickOpponentLocked(username string, opts JoinOptions, exclude *WaitingPlayer, waitedLong bool) *WaitingPlayer {
+	now := time.Now()
+	var rematch *WaitingPlayer
+	for _, w := range m.waitingQueue {
+		if w == exclude || w.botPending || w.Username == username || !sameKindOfGame(w.Options, opts) {
+			continue
+		}
+		if !m.isRecentOpponentLocked(username, w.Username, now) {
+			return w
+		}
+		if rematch == nil && (waitedLong || now.Sub(w.JoinedAt) >= rematchAfter) {
+			rematch = w
+		}
+	}
+	return rematch
+}
@@ -0,0 +1,116 @@
+package matchmaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/connect-four/internal/game"
+)
+
+// pair names the two players of a game in a fixed order
+func pair(g *game.Game) [2]string {
+	state := g.GetState()
+	if state.Player1 < state.Player2 {
+		return [2]string{state.Player1, state.Player2}
+	}
+	return [2]string{state.Player2, state.Player1}
+}
+
+func TestThreePlayersAlternateOpponents(t *testing.T) {
+	m := NewMatchmaker()
+	chans := make(map[string]<-chan *game.Game)
+	join := func(username string) {
+		t.Helper()
+		ch, err := m.JoinQueue(username, JoinOptions{NoBotFallback: true})
+		if err != nil {
+			t.Fatalf("JoinQueue(%s): %v", username, err)
+		}
+		chans[username] = ch
+	}
+
+	join("alice")
+	join("bob")
+	join("carol")
+	current := <-chans["alice"]
+
+	seen := make(map[[2]string]int)
+	last := pair(current)
+	seen[last]++
+	for round := 0; round < 6; round++ {
+		// The two who just played finish and queue again, taking turns to
+		// go first, joining the one who sat out. Games take a while, so
+		// only the one just played is still within the TTL.
+		expireOpponentsExcept(m, last)
+		current.Forfeit(game.Player1)
+		m.RemoveGame(current.ID)
+		first, second := last[0], last[1]
+		if round%2 == 1 {
+			first, second = second, first
+		}
+		join(first)
+		join(second)
+
+		var next *game.Game
+		for _, name := range []string{"alice", "bob", "carol"} {
+			select {
+			case g := <-chans[name]:
+				next = g
+			default:
+			}
+		}
+		if next == nil {
+			t.Fatalf("round %d: nobody was matched", round)
+		}
+		p := pair(next)
+		if p == last {
+			t.Fatalf("round %d: %v were paired again while %d others waited", round, p, m.GetWaitingCount())
+		}
+		seen[p]++
+		current, last = next, p
+	}
+	if len(seen) != 3 {
+		t.Errorf("pairings %v, want all three pairs to play", seen)
+	}
+}
+
+// expireOpponentsExcept ages every remembered opponent past the TTL but
+// the pair p
+func expireOpponentsExcept(m *Matchmaker, p [2]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	old := time.Now().Add(-2 * recentOpponentTTL)
+	for username, list := range m.recentOpponents {
+		for i := range list {
+			if pair := [2]string{username, list[i].username}; pair != p && pair != [2]string{p[1], p[0]} {
+				list[i].at = old
+			}
+		}
+	}
+}
+
+func TestRecentOpponentAfterWaitingLong(t *testing.T) {
+	m := NewMatchmaker()
+	m.mu.Lock()
+	m.recordOpponentsLocked("alice", "bob", true)
+	bob := &WaitingPlayer{Username: "bob", JoinedAt: time.Now(), done: make(chan struct{})}
+	m.waitingQueue = append(m.waitingQueue, bob)
+	defer m.mu.Unlock()
+
+	if w := m.pickOpponentLocked("alice", JoinOptions{}, nil, false); w != nil {
+		t.Errorf("recent opponent %s picked straight away", w.Username)
+	}
+	if w := m.pickOpponentLocked("alice", JoinOptions{}, nil, true); w != bob {
+		t.Errorf("waited-long requester got %v, want bob", w)
+	}
+	bob.JoinedAt = time.Now().Add(-rematchAfter)
+	if w := m.pickOpponentLocked("alice", JoinOptions{}, nil, false); w != bob {
+		t.Errorf("bob waited %v and got %v, want bob", rematchAfter, w)
+	}
+
+	// Anyone else waiting still comes first
+	carol := &WaitingPlayer{Username: "carol", JoinedAt: time.Now(), done: make(chan struct{})}
+	m.waitingQueue = append(m.waitingQueue, carol)
+	if w := m.pickOpponentLocked("alice", JoinOptions{}, nil, true); w != carol {
+		t.Errorf("got %v, want carol ahead of the recent opponent", w)
+	}
+}
@@ -0,0 +1,212 @@
+package matchmaker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/connect-four/internal/game"
+)
+
+// checkpointInterval is how often unfinished games are saved for recovery
+const checkpointInterval = 5 * time.Second
+
+// GameStore keeps snapshots of unfinished games across restarts
+type GameStore interface {
+	SaveActiveGame(ctx context.Context, gameID string, data []byte) error
+	DeleteActiveGame(ctx context.Context, gameID string) error
+	LoadActiveGames(ctx context.Context) ([][]byte, error)
+}
+
+// savedGame is what is stored per unfinished game. The seat tokens are kept
+// so players can reconnect with the ones they already have.
+type savedGame struct {
+	Game   game.Snapshot `json:"game"`
+	Tokens gameTokens    `json:"tokens"`
+}
+
+// SetGameStore sets where unfinished games are checkpointed; nil turns
+// checkpointing off
+func (m *Matchmaker) SetGameStore(store GameStore) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gameStore = store
+	m.savedVersions = make(map[string]int)
+}
+
+// dropCheckpointLocked deletes a removed game's snapshot in the
+// background. Saved games already lost theirs with the games row; this
+// covers games that ended without one. Caller holds the lock.
+func (m *Matchmaker) dropCheckpointLocked(gameID string) {
+	if m.gameStore == nil {
+		return
+	}
+	delete(m.savedVersions, gameID)
+
+	store, logger := m.gameStore, m.logger
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := store.DeleteActiveGame(ctx, gameID); err != nil {
+			logger.Warn("failed to delete game snapshot", "gameID", gameID, "error", err)
+		}
+	}()
+}
+
+// Checkpoint saves every unfinished game that changed since its last save
+// and returns how many were written
+func (m *Matchmaker) Checkpoint(ctx context.Context) int {
+	type pending struct {
+		g      *game.Game
+		tokens gameTokens
+		saved  int
+		known  bool
+	}
+
+	m.mu.Lock()
+	store := m.gameStore
+	games := make([]pending, 0, len(m.activeGames))
+	for id, g := range m.activeGames {
+		saved, known := m.savedVersions[id]
+		games = append(games, pending{g: g, tokens: m.tokens[id], saved: saved, known: known})
+	}
+	m.mu.Unlock()
+
+	if store == nil {
+		return 0
+	}
+
+	written := 0
+	for _, p := range games {
+		snap := p.g.Snapshot()
+		if snap.Status == game.StatusFinished || snap.Status == game.StatusWaiting {
+			continue
+		}
+		if p.known && p.saved == snap.StateVersion {
+			continue
+		}
+
+		data, err := json.Marshal(savedGame{Game: snap, Tokens: p.tokens})
+		if err != nil {
+			m.logger.Error("failed to encode game snapshot", "gameID", snap.ID, "error", err)
+			continue
+		}
+		if err := store.SaveActiveGame(ctx, snap.ID, data); err != nil {
+			m.logger.Warn("failed to checkpoint game", "gameID", snap.ID, "error", err)
+			continue
+		}
+
+		m.mu.Lock()
+		if _, active := m.activeGames[snap.ID]; active {
+			m.savedVersions[snap.ID] = snap.StateVersion
+		} else {
+			// Removed while the write was in flight, so its delete may
+			// have run first
+			m.dropCheckpointLocked(snap.ID)
+		}
+		m.mu.Unlock()
+		written++
+	}
+	return written
+}
+
+// RunCheckpoints saves changed games every checkpointInterval until ctx is
+// cancelled
+func (m *Matchmaker) RunCheckpoints(ctx context.Context) {
+	ticker := time.NewTicker(checkpointInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Checkpoint(ctx)
+		}
+	}
+}
+
+// RecoverGames loads the checkpointed games and registers them as active,
+// with their original seat tokens, so the usual reconnect flow finds them.
+// Snapshots that can't be restored are logged and deleted.
+func (m *Matchmaker) RecoverGames(ctx context.Context) ([]*game.Game, error) {
+	m.mu.Lock()
+	store, rng := m.gameStore, m.rng
+	m.mu.Unlock()
+
+	if store == nil {
+		return nil, nil
+	}
+
+	stored, err := store.LoadActiveGames(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error loading active games: %w", err)
+	}
+
+	var restored []*game.Game
+	for _, data := range stored {
+		var saved savedGame
+		g, err := decodeSavedGame(data, &saved, rng)
+		if err != nil {
+			m.logger.Warn("discarding unrecoverable game", "gameID", saved.Game.ID, "error", err)
+			if saved.Game.ID != "" {
+				if err := store.DeleteActiveGame(ctx, saved.Game.ID); err != nil {
+					m.logger.Warn("failed to delete game snapshot", "gameID", saved.Game.ID, "error", err)
+				}
+			}
+			continue
+		}
+
+		if m.restoreGame(g, saved) {
+			restored = append(restored, g)
+		}
+	}
+
+	if len(restored) > 0 {
+		m.logger.Info("recovered in-progress games", "count", len(restored))
+	}
+	return restored, nil
+}
+
+// decodeSavedGame parses a stored snapshot into saved and rebuilds its game
+func decodeSavedGame(data []byte, saved *savedGame, rng *game.Rand) (*game.Game, error) {
+	if err := json.Unmarshal(data, saved); err != nil {
+		return nil, err
+	}
+	return game.RestoreGame(saved.Game, game.WithRand(rng))
+}
+
+// restoreGame registers a recovered game unless it or one of its players
+// is already active
+func (m *Matchmaker) restoreGame(g *game.Game, saved savedGame) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.activeGames[g.ID]; exists {
+		return false
+	}
+	players := []*game.Player{g.Player1, g.Player2}
+	for _, p := range players {
+		if !p.IsBot {
+			if _, busy := m.playerGames[p.Username]; busy {
+				m.logger.Warn("not recovering game, player is in another one", "gameID", g.ID, "username", p.Username)
+				return false
+			}
+		}
+	}
+
+	m.activeGames[g.ID] = g
+	for _, p := range players {
+		if !p.IsBot {
+			m.playerGames[p.Username] = g.ID
+			// A seat saved without a token gets a fresh one
+			if saved.Tokens[p.PlayerNum] == "" {
+				saved.Tokens[p.PlayerNum] = newToken()
+			}
+		}
+	}
+	m.tokens[g.ID] = saved.Tokens
+	m.savedVersions[g.ID] = saved.Game.StateVersion
+	return true
+}
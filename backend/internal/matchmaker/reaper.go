@@ -0,0 +1,84 @@
+package matchmaker
+
+import (
+	"time"
+
+	"github.com/connect-four/internal/game"
+)
+
+// staleSweepInterval is how often the matchmaker looks for stuck games
+const staleSweepInterval = time.Minute
+
+// SetOnGameEnd sets the callback for games the matchmaker ends itself, so
+// they are persisted like any other finished game
+func (m *Matchmaker) SetOnGameEnd(callback func(g *game.Game)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onGameEnd = callback
+}
+
+// SetAttendanceCheck sets how the sweep asks whether a game still has a
+// live connection. Without one it trusts the players' connected flags,
+// which stay set if a client vanished before the disconnect flow ran.
+func (m *Matchmaker) SetAttendanceCheck(attended func(gameID string) bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.attended = attended
+}
+
+// ReapedCount returns how many stuck games the sweep has removed
+func (m *Matchmaker) ReapedCount() int64 {
+	return m.reaped.Load()
+}
+
+// RunReaper periodically ends games with no live connection and no move for
+// longer than staleAfter, drops finished games nobody removed and forgets
+// finished games past their grace window. It never returns.
+func (m *Matchmaker) RunReaper(staleAfter time.Duration) {
+	ticker := time.NewTicker(staleSweepInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		m.sweepStale(time.Now(), staleAfter)
+		m.forgetFinished(time.Now())
+	}
+}
+
+// sweepStale ends and removes games idle since before now-staleAfter
+func (m *Matchmaker) sweepStale(now time.Time, staleAfter time.Duration) {
+	m.mu.Lock()
+	attended, onGameEnd := m.attended, m.onGameEnd
+	games := make([]*game.Game, 0, len(m.activeGames))
+	for _, g := range m.activeGames {
+		games = append(games, g)
+	}
+	m.mu.Unlock()
+
+	for _, g := range games {
+		if now.Sub(g.LastActivity()) <= staleAfter {
+			continue
+		}
+
+		if g.GetState().Status != game.StatusFinished {
+			if attended != nil && attended(g.ID) {
+				continue
+			}
+			if attended == nil && g.HasConnectedHuman() {
+				continue
+			}
+
+			if loser := g.ForfeitStalled(); loser != 0 {
+				m.logger.Info("reaped stuck game", "gameID", g.ID, "forfeited", loser)
+				if onGameEnd != nil {
+					onGameEnd(g)
+				}
+			}
+		} else {
+			// Ended normally, so already persisted, but never cleaned up
+			m.logger.Info("reaped finished game left in active games", "gameID", g.ID)
+		}
+
+		m.RemoveGame(g.ID)
+		m.reaped.Add(1)
+	}
+}
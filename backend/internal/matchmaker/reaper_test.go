@@ -0,0 +1,55 @@
+package matchmaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/connect-four/internal/game"
+)
+
+func TestSweepStale(t *testing.T) {
+	m := NewMatchmaker()
+	attended := map[string]bool{}
+	m.SetAttendanceCheck(func(gameID string) bool { return attended[gameID] })
+	var ended []string
+	m.SetOnGameEnd(func(g *game.Game) { ended = append(ended, g.ID) })
+
+	stale := startHumanGame(t, m, "alice", "bob")
+	watched := startHumanGame(t, m, "carol", "dave")
+	attended[watched.ID] = true
+	finished := startHumanGame(t, m, "erin", "frank")
+	if err := finished.Resign(game.Player1); err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing is idle long enough yet
+	m.sweepStale(time.Now(), time.Minute)
+	if n := m.ReapedCount(); n != 0 {
+		t.Fatalf("reaped %d games that were active a moment ago", n)
+	}
+
+	m.sweepStale(time.Now().Add(2*time.Minute), time.Minute)
+
+	if got := m.ReapedCount(); got != 2 {
+		t.Fatalf("ReapedCount = %d, want 2", got)
+	}
+	if len(ended) != 1 || ended[0] != stale.ID {
+		t.Fatalf("game end callback ran for %v, want only the stale game %s", ended, stale.ID)
+	}
+	if state := stale.GetState(); state.Status != game.StatusFinished {
+		t.Fatalf("stale game status = %s, want finished", state.Status)
+	}
+	for _, g := range []*game.Game{stale, finished} {
+		if m.GetGame(g.ID) != nil {
+			t.Errorf("game %s is still active after the sweep", g.ID)
+		}
+	}
+	if m.GetGame(watched.ID) == nil {
+		t.Error("attended game was reaped")
+	}
+
+	// The reaped players are free to play again
+	if g := startHumanGame(t, m, "alice", "ivan"); g.ID == stale.ID {
+		t.Fatal("rejoining returned the reaped game")
+	}
+}
@@ -0,0 +1,61 @@
+package matchmaker
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+
+	"github.com/connect-four/internal/game"
+)
+
+// ErrInvalidToken is returned when a game token is missing or wrong
+var ErrInvalidToken = errors.New("invalid or missing game token")
+
+// gameTokens holds the per-seat tokens of one game, indexed by player number
+type gameTokens [3]string
+
+// newToken returns a random 128-bit token
+func newToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("matchmaker: crypto/rand failed: " + err.Error())
+	}
+	return hex.EncodeToString(b)
+}
+
+// registerGameLocked adds a game and issues tokens for its human seats;
+// caller holds the lock
+func (m *Matchmaker) registerGameLocked(g *game.Game) {
+	m.activeGames[g.ID] = g
+
+	var tokens gameTokens
+	for _, p := range []*game.Player{g.Player1, g.Player2} {
+		if p != nil && !p.IsBot {
+			m.playerGames[p.Username] = g.ID
+			tokens[p.PlayerNum] = newToken()
+		}
+	}
+	m.tokens[g.ID] = tokens
+}
+
+// PlayerToken returns the token controlling playerNum's seat in a game, or
+// "" if the game is unknown or the seat is the bot's
+func (m *Matchmaker) PlayerToken(gameID string, playerNum int) string {
+	if playerNum != game.Player1 && playerNum != game.Player2 {
+		return ""
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.tokens[gameID][playerNum]
+}
+
+// ValidateToken checks token against playerNum's seat in a game
+func (m *Matchmaker) ValidateToken(gameID string, playerNum int, token string) error {
+	expected := m.PlayerToken(gameID, playerNum)
+	if expected == "" || subtle.ConstantTimeCompare([]byte(expected), []byte(token)) != 1 {
+		return ErrInvalidToken
+	}
+	return nil
+}
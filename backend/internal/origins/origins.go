@@ -0,0 +1,87 @@
+// Package origins decides which browser origins may call the API and open
+// WebSocket connections, so both apply the same ALLOWED_ORIGINS list.
+package origins
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Matcher checks origins against a list of allowed origins. An entry is
+// either an exact origin, like https://play.example.com, or a wildcard
+// subdomain pattern, like https://*.example.com, matching any subdomain
+// (but not example.com itself) with that scheme and port.
+type Matcher struct {
+	exact    map[string]bool
+	wildcard []pattern
+}
+
+// pattern is a wildcard entry split around its "*"
+type pattern struct {
+	prefix string // scheme and "://"
+	suffix string // "." and the parent domain, with any port
+}
+
+// NewMatcher creates a matcher for the allowed origins; with none, every
+// origin is allowed. Entries are compared case-insensitively.
+func NewMatcher(allowed []string) *Matcher {
+	m := &Matcher{exact: make(map[string]bool)}
+	for _, origin := range allowed {
+		origin = strings.ToLower(strings.TrimRight(origin, "/"))
+		if prefix, suffix, ok := strings.Cut(origin, "://*."); ok {
+			m.wildcard = append(m.wildcard, pattern{prefix: prefix + "://", suffix: "." + suffix})
+			continue
+		}
+		m.exact[origin] = true
+	}
+	return m
+}
+
+// Permissive reports whether every origin is allowed, because the list
+// is empty
+func (m *Matcher) Permissive() bool {
+	return len(m.exact) == 0 && len(m.wildcard) == 0
+}
+
+// Allowed reports whether origin may make requests
+func (m *Matcher) Allowed(origin string) bool {
+	if m.Permissive() {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if m.exact[origin] {
+		return true
+	}
+	for _, p := range m.wildcard {
+		host, ok := strings.CutPrefix(origin, p.prefix)
+		if !ok {
+			continue
+		}
+		sub, ok := strings.CutSuffix(host, p.suffix)
+		if ok && sub != "" && !strings.ContainsAny(sub, ":/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that entry is an http or https origin or wildcard
+// pattern: a scheme and host, with an optional port and nothing after it
+func Validate(entry string) error {
+	u, err := url.Parse(entry)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an http or https origin", entry)
+	}
+	if (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return fmt.Errorf("%q must be only a scheme and host, like https://example.com", entry)
+	}
+	host, wildcard := strings.CutPrefix(u.Hostname(), "*.")
+	if strings.Contains(host, "*") {
+		return fmt.Errorf("%q may only use * as the first label, like https://*.example.com", entry)
+	}
+	if wildcard && !strings.Contains(host, ".") {
+		return fmt.Errorf("%q matches a whole top-level domain", entry)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package origins
+
+import "testing"
+
+func TestMatcherAllowed(t *testing.T) {
+	m := NewMatcher([]string{"https://play.example.com", "https://*.example.org", "http://localhost:5173/", "HTTPS://Mixed.Example.net"})
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://play.example.com", true},
+		{"https://PLAY.example.com", true},
+		{"http://play.example.com", false},
+		{"https://play.example.com:8443", false},
+		{"https://other.example.com", false},
+		{"https://evilplay.example.com", false},
+		{"https://play.example.com.evil.com", false},
+
+		{"https://a.example.org", true},
+		{"https://a.b.example.org", true},
+		{"https://example.org", false},
+		{"https://.example.org", false},
+		{"http://a.example.org", false},
+		{"https://a.example.org:8443", false},
+		{"https://aexample.org", false},
+		{"https://a.example.org.evil.com", false},
+
+		{"http://localhost:5173", true},
+		{"http://localhost:3000", false},
+		{"https://mixed.example.net", true},
+		{"", false},
+		{"null", false},
+	}
+	for _, tt := range tests {
+		if got := m.Allowed(tt.origin); got != tt.want {
+			t.Errorf("Allowed(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+	if m.Permissive() {
+		t.Error("a matcher with entries is permissive")
+	}
+}
+
+func TestMatcherWildcardWithPort(t *testing.T) {
+	m := NewMatcher([]string{"http://*.dev.test:8080"})
+	for origin, want := range map[string]bool{
+		"http://app.dev.test:8080": true,
+		"http://app.dev.test":      false,
+		"http://app.dev.test:9090": false,
+	} {
+		if got := m.Allowed(origin); got != want {
+			t.Errorf("Allowed(%q) = %v, want %v", origin, got, want)
+		}
+	}
+}
+
+func TestEmptyMatcherIsPermissive(t *testing.T) {
+	for _, m := range []*Matcher{NewMatcher(nil), NewMatcher([]string{})} {
+		if !m.Permissive() || !m.Allowed("https://anything.example") {
+			t.Error("an empty matcher refuses origins")
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, entry := range []string{
+		"https://example.com",
+		"http://localhost:5173",
+		"https://example.com/",
+		"https://*.example.com",
+		"https://*.example.com:8443",
+	} {
+		if err := Validate(entry); err != nil {
+			t.Errorf("Validate(%q) = %v", entry, err)
+		}
+	}
+	for _, entry := range []string{
+		"",
+		"*",
+		"example.com",
+		"ftp://example.com",
+		"https://",
+		"https://example.com/app",
+		"https://example.com?x=1",
+		"https://user@example.com",
+		"https://*.com",
+		"https://a.*.example.com",
+		"https://*example.com",
+	} {
+		if err := Validate(entry); err == nil {
+			t.Errorf("Validate(%q) accepted it", entry)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Bucket is a token bucket refilled at a fixed rate up to its burst size.
+// It is safe for concurrent use.
+type Bucket struct {
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+	mu     sync.Mutex
+}
+
+// NewBucket creates a full bucket allowing rate events per second with
+// bursts of up to burst events
+func NewBucket(rate float64, burst int) *Bucket {
+	return &Bucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Allow takes a token if one is available
+func (b *Bucket) Allow() bool {
+	return b.allowAt(time.Now())
+}
+
+// allowAt takes a token at now if one is available
+func (b *Bucket) allowAt(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// idleSince reports when the bucket was last used
+func (b *Bucket) idleSince() time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.last
+}
+
+// Limiter keeps one bucket per key, e.g. per client IP. Buckets unused for
+// longer than the idle timeout are swept so the map stays bounded by the
+// number of recently active keys.
+type Limiter struct {
+	rate    float64
+	burst   int
+	idle    time.Duration
+	buckets map[string]*Bucket
+	mu      sync.Mutex
+	stop    chan struct{}
+}
+
+// NewLimiter creates a limiter giving every key its own rate and burst,
+// and starts sweeping buckets idle for longer than idle. idle is raised to
+// at least the time an empty bucket takes to refill. With a rate of zero
+// buckets never refill, so they are never swept either.
+func NewLimiter(rate float64, burst int, idle time.Duration) *Limiter {
+	if rate > 0 {
+		if refill := time.Duration(float64(burst) / rate * float64(time.Second)); idle < refill {
+			idle = refill
+		}
+	}
+	l := &Limiter{
+		rate:    rate,
+		burst:   burst,
+		idle:    idle,
+		buckets: make(map[string]*Bucket),
+		stop:    make(chan struct{}),
+	}
+	if rate > 0 {
+		go l.sweepLoop()
+	}
+	return l
+}
+
+// Allow takes a token from key's bucket if one is available
+func (l *Limiter) Allow(key string) bool {
+	l.mu.Lock()
+	b := l.buckets[key]
+	if b == nil {
+		b = NewBucket(l.rate, l.burst)
+		l.buckets[key] = b
+	}
+	l.mu.Unlock()
+	return b.Allow()
+}
+
+// Len returns the number of tracked keys
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
+
+// Stop ends the background sweep
+func (l *Limiter) Stop() {
+	close(l.stop)
+}
+
+// sweepLoop periodically drops idle buckets until Stop is called
+func (l *Limiter) sweepLoop() {
+	ticker := time.NewTicker(l.idle)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case now := <-ticker.C:
+			l.sweep(now)
+		case <-l.stop:
+			return
+		}
+	}
+}
+
+// sweep drops buckets unused since before now-idle. A dropped bucket had
+// long since refilled, so recreating it full loses nothing.
+func (l *Limiter) sweep(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate <= 0 {
+		return
+	}
+
+	for key, b := range l.buckets {
+		if now.Sub(b.idleSince()) > l.idle {
+			delete(l.buckets, key)
+		}
+	}
+}
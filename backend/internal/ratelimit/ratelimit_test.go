@@ -0,0 +1,120 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// take calls allowAt n times at now and counts the tokens taken
+func take(b *Bucket, now time.Time, n int) int {
+	taken := 0
+	for i := 0; i < n; i++ {
+		if b.allowAt(now) {
+			taken++
+		}
+	}
+	return taken
+}
+
+func TestBucketBurst(t *testing.T) {
+	b := NewBucket(2, 5)
+	b.last = start
+
+	if got := take(b, start, 10); got != 5 {
+		t.Fatalf("full bucket gave %d tokens at once, want the burst of 5", got)
+	}
+	if b.allowAt(start) {
+		t.Error("empty bucket gave a token without time passing")
+	}
+}
+
+func TestBucketRefill(t *testing.T) {
+	tests := []struct {
+		name  string
+		after time.Duration
+		want  int
+	}{
+		{"under one token", 400 * time.Millisecond, 0},
+		{"one token", 500 * time.Millisecond, 1},
+		{"partial tokens round down", 1700 * time.Millisecond, 3},
+		{"capped at the burst", time.Hour, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBucket(2, 5)
+			b.last = start
+			take(b, start, 5)
+
+			if got := take(b, start.Add(tt.after), 10); got != tt.want {
+				t.Errorf("%d tokens %v after emptying, want %d", got, tt.after, tt.want)
+			}
+		})
+	}
+}
+
+func TestBucketClockGoingBack(t *testing.T) {
+	b := NewBucket(1, 1)
+	b.last = start
+	take(b, start, 1)
+
+	if b.allowAt(start.Add(-time.Hour)) {
+		t.Error("bucket refilled when the clock went back")
+	}
+	if !b.allowAt(start.Add(-time.Hour + time.Second)) {
+		t.Error("bucket didn't refill from the earlier time")
+	}
+}
+
+func TestLimiterSweep(t *testing.T) {
+	l := NewLimiter(1, 1, time.Minute)
+	defer l.Stop()
+
+	use := func(key string, at time.Time) {
+		b := NewBucket(l.rate, l.burst)
+		b.allowAt(at)
+		l.mu.Lock()
+		l.buckets[key] = b
+		l.mu.Unlock()
+	}
+	use("stale", start)
+	use("at the limit", start.Add(time.Second))
+	use("recent", start.Add(50*time.Second))
+
+	l.sweep(start.Add(time.Minute + time.Second))
+	if _, ok := l.buckets["stale"]; ok {
+		t.Error("bucket idle for longer than idle wasn't swept")
+	}
+	for _, key := range []string{"at the limit", "recent"} {
+		if _, ok := l.buckets[key]; !ok {
+			t.Errorf("bucket %q swept before it was idle for longer than idle", key)
+		}
+	}
+}
+
+func TestLimiterIdleCoversRefill(t *testing.T) {
+	l := NewLimiter(0.5, 30, time.Second)
+	defer l.Stop()
+	if l.idle != time.Minute {
+		t.Errorf("idle = %v, want the minute an empty bucket takes to refill", l.idle)
+	}
+}
+
+func TestLimiterZeroRate(t *testing.T) {
+	l := NewLimiter(0, 2, time.Minute)
+	defer l.Stop()
+	if l.idle != time.Minute {
+		t.Errorf("idle = %v, want it left at a minute", l.idle)
+	}
+
+	if !l.Allow("a") || !l.Allow("a") || l.Allow("a") {
+		t.Fatal("zero rate limiter didn't allow exactly its burst")
+	}
+
+	// The spent bucket never refills, so sweeping it would hand back the burst
+	l.sweep(time.Now().Add(24 * time.Hour))
+	if l.Len() != 1 || l.Allow("a") {
+		t.Error("zero rate bucket was swept and refilled")
+	}
+}
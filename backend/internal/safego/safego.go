@@ -0,0 +1,48 @@
+// Package safego runs background work that recovers from panics. chi's
+// Recoverer only covers HTTP handlers, so an unrecovered panic on any other
+// goroutine would take the whole server down with it.
+package safego
+
+import (
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync/atomic"
+
+	"github.com/connect-four/internal/logging"
+)
+
+// panics counts every panic recovered by Go and Run
+var panics atomic.Int64
+
+// PanicCount returns the total number of recovered panics
+func PanicCount() int64 {
+	return panics.Load()
+}
+
+// Go runs fn on a new goroutine, see Run
+func Go(logger *slog.Logger, name string, fn func(), onPanic func()) {
+	go Run(logger, name, fn, onPanic)
+}
+
+// Run calls fn, recovering a panic in it. The panic is logged to logger
+// with its stack under name and counted, then onPanic, if not nil, runs to
+// clean up. It is for work already on its own goroutine, such as a
+// time.AfterFunc callback.
+func Run(logger *slog.Logger, name string, fn func(), onPanic func()) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		panics.Add(1)
+		logging.OrDefault(logger).Error("recovered panic in background goroutine",
+			"goroutine", name,
+			"panic", fmt.Sprint(r),
+			"stack", string(debug.Stack()))
+		if onPanic != nil {
+			Run(logger, name+" cleanup", onPanic, nil)
+		}
+	}()
+	fn()
+}
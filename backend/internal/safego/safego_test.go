@@ -0,0 +1,102 @@
+package safego
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// bufferLogger returns a logger writing text records to a buffer
+func bufferLogger() (*slog.Logger, *syncBuffer) {
+	buf := &syncBuffer{}
+	return slog.New(slog.NewTextHandler(buf, nil)), buf
+}
+
+// syncBuffer is a bytes.Buffer safe for the logger's goroutine and the test
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestRunRecoversPanic(t *testing.T) {
+	logger, buf := bufferLogger()
+	before := PanicCount()
+	cleanedUp := false
+
+	Run(logger, "worker", func() { panic("boom") }, func() { cleanedUp = true })
+
+	if got := PanicCount(); got != before+1 {
+		t.Errorf("PanicCount = %d, want %d", got, before+1)
+	}
+	if !cleanedUp {
+		t.Error("onPanic didn't run")
+	}
+	out := buf.String()
+	for _, want := range []string{"level=ERROR", "recovered panic in background goroutine", "goroutine=worker", "panic=boom", "safego_test.go"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log is missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRunWithoutPanic(t *testing.T) {
+	logger, buf := bufferLogger()
+	before := PanicCount()
+	ran := false
+
+	Run(logger, "worker", func() { ran = true }, func() { t.Error("onPanic ran without a panic") })
+
+	if !ran {
+		t.Error("fn didn't run")
+	}
+	if PanicCount() != before {
+		t.Errorf("PanicCount moved from %d to %d", before, PanicCount())
+	}
+	if buf.String() != "" {
+		t.Errorf("logged without a panic: %s", buf)
+	}
+}
+
+func TestRunRecoversPanicInCleanup(t *testing.T) {
+	logger, buf := bufferLogger()
+	before := PanicCount()
+
+	Run(logger, "worker", func() { panic("first") }, func() { panic("second") })
+
+	if got := PanicCount(); got != before+2 {
+		t.Errorf("PanicCount = %d, want %d", got, before+2)
+	}
+	if out := buf.String(); !strings.Contains(out, "goroutine=\"worker cleanup\"") || !strings.Contains(out, "panic=second") {
+		t.Errorf("cleanup panic not logged:\n%s", out)
+	}
+}
+
+func TestGoRecoversPanic(t *testing.T) {
+	logger, buf := bufferLogger()
+	before := PanicCount()
+	done := make(chan struct{})
+
+	Go(logger, "background", func() { panic(42) }, func() { close(done) })
+	<-done
+
+	if got := PanicCount(); got != before+1 {
+		t.Errorf("PanicCount = %d, want %d", got, before+1)
+	}
+	if out := buf.String(); !strings.Contains(out, "goroutine=background") || !strings.Contains(out, "panic=42") {
+		t.Errorf("panic not logged:\n%s", out)
+	}
+}
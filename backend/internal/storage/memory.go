@@ -0,0 +1,611 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/connect-four/internal/game"
+)
+
+// MemoryStore keeps finished games in memory when no database is available.
+// Nothing survives a restart, but the API behaves the same as with Postgres.
+type MemoryStore struct {
+	games   []CompletedGame
+	ids     map[string]bool
+	ratings map[string]int
+	history map[string][]RatingChange // newest last
+	mu      sync.RWMutex
+
+	snapshot []byte // latest analytics snapshot
+	active   map[string][]byte
+}
+
+// NewMemoryStore creates a new empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		games:   make([]CompletedGame, 0),
+		ids:     make(map[string]bool),
+		ratings: make(map[string]int),
+		history: make(map[string][]RatingChange),
+		active:  make(map[string][]byte),
+	}
+}
+
+// SaveGame stores a completed game
+func (s *MemoryStore) SaveGame(ctx context.Context, g *game.Game) error {
+	if g.Simulated {
+		return nil
+	}
+	state := g.GetState()
+
+	movesJSON, err := json.Marshal(g.Moves)
+	if err != nil {
+		movesJSON = []byte("[]")
+	}
+
+	isDraw := state.Result == string(game.ResultDraw)
+	winner := state.Winner
+	if isDraw {
+		winner = ""
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.active, g.ID)
+	if s.ids[g.ID] {
+		return nil
+	}
+	s.ids[g.ID] = true
+
+	s.games = append(s.games, CompletedGame{
+		ID:              g.ID,
+		Player1:         g.Player1.Username,
+		Player2:         g.Player2.Username,
+		Winner:          winner,
+		IsForfeit:       state.Result == string(game.ResultForfeit),
+		IsDraw:          isDraw,
+		Result:          state.Result,
+		ForfeitedBy:     state.ForfeitedBy,
+		DurationSeconds: g.GetDuration(),
+		MoveCount:       len(g.Moves),
+		Moves:           string(movesJSON),
+		CreatedAt:       g.StartTime,
+		StartedAt:       g.PlayStart(),
+		EndedAt:         g.EndTime,
+		Imported:        g.Imported,
+		BotDifficulty:   state.BotDifficulty,
+		BotVersion:      state.BotVersion,
+		Player1Emoji:    state.Player1DiscEmoji,
+		Player2Emoji:    state.Player2DiscEmoji,
+		WinType:         string(state.WinType),
+		AvgThinkMsP1:    averageThinkMs(g, game.Player1),
+		AvgThinkMsP2:    averageThinkMs(g, game.Player2),
+		BoardSize:       state.BoardSize,
+	})
+
+	if isRated(g) {
+		s.applyRatingsLocked(g.ID, state, g.EndTime)
+	}
+
+	return nil
+}
+
+// applyRatingsLocked updates both players' ratings for a game; caller holds the lock
+func (s *MemoryStore) applyRatingsLocked(gameID string, state *game.GameState, at time.Time) {
+	before1, before2 := s.ratingLocked(state.Player1), s.ratingLocked(state.Player2)
+	after1, after2 := eloUpdate(before1, before2, player1Score(state))
+
+	s.ratings[state.Player1] = after1
+	s.ratings[state.Player2] = after2
+	s.history[state.Player1] = append(s.history[state.Player1], RatingChange{GameID: gameID, Before: before1, After: after1, Opponent: state.Player2, At: at})
+	s.history[state.Player2] = append(s.history[state.Player2], RatingChange{GameID: gameID, Before: before2, After: after2, Opponent: state.Player1, At: at})
+}
+
+// ratingLocked returns a player's rating; caller holds the lock
+func (s *MemoryStore) ratingLocked(username string) int {
+	if rating, ok := s.ratings[username]; ok {
+		return rating
+	}
+	return DefaultRating
+}
+
+// GetRating returns a player's current Elo rating
+func (s *MemoryStore) GetRating(ctx context.Context, username string) (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ratingLocked(username), nil
+}
+
+// GetGameByID returns a completed game with its parsed move list
+func (s *MemoryStore) GetGameByID(ctx context.Context, id string) (*CompletedGame, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, cg := range s.games {
+		if cg.ID != id {
+			continue
+		}
+		found := cg
+		if err := json.Unmarshal([]byte(cg.Moves), &found.MoveList); err != nil {
+			return nil, err
+		}
+		return &found, nil
+	}
+	return nil, ErrGameNotFound
+}
+
+// GetRecentGames returns a page of completed games, newest first
+func (s *MemoryStore) GetRecentGames(ctx context.Context, opts RecentGamesOptions) ([]RecentGame, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var games []RecentGame
+	for _, cg := range s.games {
+		if !opts.Before.IsZero() && !recentGameBefore(cg, opts) {
+			continue
+		}
+		if !opts.IncludeBots && (cg.Player1 == "BOT" || cg.Player2 == "BOT") {
+			continue
+		}
+		games = append(games, RecentGame{
+			ID:              cg.ID,
+			Player1:         cg.Player1,
+			Player2:         cg.Player2,
+			Winner:          cg.Winner,
+			Result:          cg.Result,
+			WinType:         cg.WinType,
+			DurationSeconds: cg.DurationSeconds,
+			MoveCount:       cg.MoveCount,
+			CreatedAt:       cg.CreatedAt,
+			EndedAt:         cg.EndedAt,
+			BotDifficulty:   cg.BotDifficulty,
+		})
+	}
+	sort.Slice(games, func(i, j int) bool {
+		if !games[i].CreatedAt.Equal(games[j].CreatedAt) {
+			return games[i].CreatedAt.After(games[j].CreatedAt)
+		}
+		return games[i].ID > games[j].ID
+	})
+
+	return games[:min(len(games), opts.Limit)], nil
+}
+
+// recentGameBefore reports whether cg comes after the cursor in opts in the
+// recent games order
+func recentGameBefore(cg CompletedGame, opts RecentGamesOptions) bool {
+	if cg.CreatedAt.Equal(opts.Before) {
+		return cg.ID < opts.BeforeID
+	}
+	return cg.CreatedAt.Before(opts.Before)
+}
+
+// GetLeaderboard returns the top players by wins
+func (s *MemoryStore) GetLeaderboard(ctx context.Context, opts LeaderboardOptions) ([]LeaderboardEntry, error) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+
+	s.mu.RLock()
+	byPlayer := make(map[string]*LeaderboardEntry)
+	record := func(username string, cg CompletedGame) {
+		entry := byPlayer[username]
+		if entry == nil {
+			entry = &LeaderboardEntry{Username: username}
+			byPlayer[username] = entry
+		}
+		entry.Games++
+		switch {
+		case cg.IsDraw || cg.Winner == "":
+			entry.Draws++
+		case cg.Winner == username:
+			entry.Wins++
+		default:
+			entry.Losses++
+		}
+	}
+	for _, cg := range s.games {
+		if cg.Imported || cg.Result == string(game.ResultAborted) || cg.EndedAt.Before(opts.Since) {
+			continue
+		}
+		if !opts.IncludeBots && (cg.Player1 == "BOT" || cg.Player2 == "BOT") {
+			continue
+		}
+		for _, name := range []string{cg.Player1, cg.Player2} {
+			if name != "BOT" {
+				record(name, cg)
+			}
+		}
+	}
+	for username, entry := range byPlayer {
+		entry.Rating = s.ratingLocked(username)
+	}
+	s.mu.RUnlock()
+
+	entries := make([]LeaderboardEntry, 0, len(byPlayer))
+	for _, entry := range byPlayer {
+		entry.WinRate = math.Round(float64(entry.Wins)/float64(entry.Games)*1000) / 10
+		entries = append(entries, *entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if opts.Sort == SortByRating && entries[i].Rating != entries[j].Rating {
+			return entries[i].Rating > entries[j].Rating
+		}
+		if entries[i].Wins != entries[j].Wins {
+			return entries[i].Wins > entries[j].Wins
+		}
+		return entries[i].WinRate > entries[j].WinRate
+	})
+
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	for i := range entries {
+		entries[i].Rank = i + 1
+	}
+
+	return entries, nil
+}
+
+// GetPlayerStats returns detailed statistics for a player
+func (s *MemoryStore) GetPlayerStats(ctx context.Context, username string) (*PlayerStats, error) {
+	stats := &PlayerStats{Username: username}
+	totalDuration := 0
+	var totalThinkMs, thinkGames int64
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, cg := range s.games {
+		if cg.Player1 != username && cg.Player2 != username {
+			continue
+		}
+		// Aborted games have no result to count for or against anyone
+		if cg.Result == string(game.ResultAborted) {
+			continue
+		}
+		opponent := cg.Player1
+		if cg.Player1 == username {
+			opponent = cg.Player2
+		}
+
+		stats.TotalGames++
+		totalDuration += cg.DurationSeconds
+		thinkMs := cg.AvgThinkMsP2
+		if cg.Player1 == username {
+			thinkMs = cg.AvgThinkMsP1
+		}
+		if thinkMs != nil {
+			totalThinkMs += *thinkMs
+			thinkGames++
+		}
+		if cg.ForfeitedBy == username {
+			stats.Forfeits++
+		}
+
+		switch {
+		case cg.IsDraw || cg.Winner == "":
+			stats.Draws++
+		case cg.Winner == username:
+			stats.Wins++
+			if opponent == "BOT" {
+				stats.BotWins++
+			}
+		default:
+			stats.Losses++
+			if opponent == "BOT" {
+				stats.BotLosses++
+			}
+		}
+	}
+
+	if stats.TotalGames > 0 {
+		stats.WinRate = float64(stats.Wins) / float64(stats.TotalGames) * 100
+		stats.AvgGameLength = float64(totalDuration) / float64(stats.TotalGames)
+	}
+	if thinkGames > 0 {
+		stats.AvgThinkMs = float64(totalThinkMs) / float64(thinkGames)
+	}
+
+	stats.Rating = s.ratingLocked(username)
+	history := s.history[username]
+	stats.RatingHistory = make([]RatingChange, 0, ratingHistoryLimit)
+	for i := len(history) - 1; i >= 0 && len(stats.RatingHistory) < ratingHistoryLimit; i-- {
+		stats.RatingHistory = append(stats.RatingHistory, history[i])
+	}
+
+	return stats, nil
+}
+
+// GetHeadToHead returns the record between two players
+func (s *MemoryStore) GetHeadToHead(ctx context.Context, player, opponent string) (*HeadToHead, error) {
+	h2h := newHeadToHead(player, opponent)
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// Games are appended as they end, so walk backwards for newest first
+	for i := len(s.games) - 1; i >= 0; i-- {
+		cg := s.games[i]
+		if (cg.Player1 == player && cg.Player2 == opponent) || (cg.Player1 == opponent && cg.Player2 == player) {
+			h2h.add(cg.Winner, cg.IsDraw, cg.DurationSeconds)
+		}
+	}
+
+	return h2h, nil
+}
+
+// GetAnalytics returns aggregated game analytics
+func (s *MemoryStore) GetAnalytics(ctx context.Context) (*GameAnalytics, error) {
+	now := time.Now()
+	today := now.Truncate(24 * time.Hour)
+	thisHour := now.Truncate(time.Hour)
+
+	analytics := &GameAnalytics{}
+	players := make(map[string]bool)
+	wins := make(map[string]int)
+	winTypes := make(map[string]int)
+	totalDuration := 0
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, cg := range s.games {
+		analytics.TotalGames++
+		totalDuration += cg.DurationSeconds
+		for _, name := range []string{cg.Player1, cg.Player2} {
+			if name == "BOT" {
+				analytics.BotGamesPlayed++
+			} else {
+				players[name] = true
+			}
+		}
+		if !cg.CreatedAt.Before(today) {
+			analytics.GamesToday++
+		}
+		if !cg.CreatedAt.Before(thisHour) {
+			analytics.GamesThisHour++
+		}
+		if cg.Winner != "" {
+			wins[cg.Winner]++
+		}
+		if cg.WinType != "" {
+			winTypes[cg.WinType]++
+		}
+	}
+
+	analytics.TotalPlayers = len(players)
+	if analytics.TotalGames > 0 {
+		analytics.AvgGameDuration = float64(totalDuration) / float64(analytics.TotalGames)
+	}
+
+	maxWins := 0
+	for player, count := range wins {
+		if count > maxWins {
+			maxWins = count
+			analytics.MostFrequentWinner = player
+		}
+	}
+
+	analytics.WinTypes = make([]WinTypeShare, 0, len(winTypes))
+	for winType, games := range winTypes {
+		analytics.WinTypes = append(analytics.WinTypes, WinTypeShare{WinType: winType, Games: games})
+	}
+	sort.Slice(analytics.WinTypes, func(i, j int) bool {
+		a, b := analytics.WinTypes[i], analytics.WinTypes[j]
+		if a.Games != b.Games {
+			return a.Games > b.Games
+		}
+		return a.WinType < b.WinType
+	})
+	computeWinTypePercents(analytics.WinTypes)
+
+	return analytics, nil
+}
+
+// BackfillWinTypes classifies games stored without a win type
+func (s *MemoryStore) BackfillWinTypes(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var updated int64
+	for i := range s.games {
+		cg := &s.games[i]
+		if cg.WinType != "" {
+			continue
+		}
+		if winType := storedWinType(cg.BoardSize, []byte(cg.Moves), cg.IsDraw, cg.IsForfeit, cg.Result); winType != "" {
+			cg.WinType = string(winType)
+			updated++
+		}
+	}
+	return updated, nil
+}
+
+// RollupAnalytics is a no-op; the memory store computes hourly analytics
+// from its games on every read
+func (s *MemoryStore) RollupAnalytics(ctx context.Context, now time.Time) error {
+	return nil
+}
+
+// CompareRollup returns ErrNoRollup; the memory store keeps no buckets
+func (s *MemoryStore) CompareRollup(ctx context.Context, since time.Time) ([]RollupMismatch, error) {
+	return nil, ErrNoRollup
+}
+
+// GetHourlyAnalytics returns games played per hour from since to now
+func (s *MemoryStore) GetHourlyAnalytics(ctx context.Context, since, now time.Time) ([]HourlyAnalytics, error) {
+	first := since.Truncate(time.Hour)
+	var result []HourlyAnalytics
+	for hour := first; !hour.After(now); hour = hour.Add(time.Hour) {
+		result = append(result, HourlyAnalytics{Hour: hour})
+	}
+
+	durations := make([]int, len(result))
+	s.mu.RLock()
+	for _, cg := range s.games {
+		i := int(cg.CreatedAt.Sub(first) / time.Hour)
+		if cg.CreatedAt.Before(first) || i >= len(result) {
+			continue
+		}
+		result[i].GamesPlayed++
+		durations[i] += cg.DurationSeconds
+	}
+	s.mu.RUnlock()
+
+	for i := range result {
+		if result[i].GamesPlayed > 0 {
+			result[i].AvgDurationSeconds = float64(durations[i]) / float64(result[i].GamesPlayed)
+		}
+	}
+	return result, nil
+}
+
+// GetBotVersionStats returns bot game results grouped by bot engine version
+func (s *MemoryStore) GetBotVersionStats(ctx context.Context) ([]BotVersionStats, error) {
+	byVersion := make(map[string]*BotVersionStats)
+
+	s.mu.RLock()
+	for _, cg := range s.games {
+		if cg.Player1 != "BOT" && cg.Player2 != "BOT" {
+			continue
+		}
+		version := cg.BotVersion
+		if version == "" {
+			version = "unknown"
+		}
+		v := byVersion[version]
+		if v == nil {
+			v = &BotVersionStats{Version: version}
+			byVersion[version] = v
+		}
+		v.Games++
+		switch {
+		case cg.IsDraw || cg.Winner == "":
+			v.Draws++
+		case cg.Winner == "BOT":
+			v.BotWins++
+		default:
+			v.HumanWins++
+		}
+	}
+	s.mu.RUnlock()
+
+	result := make([]BotVersionStats, 0, len(byVersion))
+	for _, v := range byVersion {
+		v.computeRates()
+		result = append(result, *v)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Version < result[j].Version })
+
+	return result, nil
+}
+
+// SaveActiveGame stores an unfinished game's snapshot
+func (s *MemoryStore) SaveActiveGame(ctx context.Context, gameID string, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.ids[gameID] {
+		s.active[gameID] = data
+	}
+	return nil
+}
+
+// DeleteActiveGame drops an unfinished game's snapshot
+func (s *MemoryStore) DeleteActiveGame(ctx context.Context, gameID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.active, gameID)
+	return nil
+}
+
+// LoadActiveGames returns the stored snapshots, in no particular order
+func (s *MemoryStore) LoadActiveGames(ctx context.Context) ([][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([][]byte, 0, len(s.active))
+	for _, data := range s.active {
+		result = append(result, data)
+	}
+	return result, nil
+}
+
+// ClearAllGames deletes all games and returns how many were deleted
+func (s *MemoryStore) ClearAllGames(ctx context.Context) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleted := int64(len(s.games))
+	s.games = make([]CompletedGame, 0)
+	s.ids = make(map[string]bool)
+	s.ratings = make(map[string]int)
+	s.history = make(map[string][]RatingChange)
+	return deleted, nil
+}
+
+// ResolveUsername returns the canonical username of an existing player
+func (s *MemoryStore) ResolveUsername(ctx context.Context, username string) (string, error) {
+	canonical := CanonicalUsername(username)
+	if canonical == "" {
+		return "", ErrUnknownPlayer
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	folded := foldUsername(canonical)
+	suggestion := ""
+	for _, cg := range s.games {
+		for _, name := range []string{cg.Player1, cg.Player2} {
+			if name == canonical {
+				return canonical, nil
+			}
+			if suggestion == "" && foldUsername(name) == folded {
+				suggestion = name
+			}
+		}
+	}
+
+	if suggestion != "" {
+		return "", &NearCollisionError{Requested: canonical, Suggestion: suggestion}
+	}
+	return "", ErrUnknownPlayer
+}
+
+// Backend returns the storage backend name
+func (s *MemoryStore) Backend() string {
+	return "memory"
+}
+
+// Ping always succeeds for the in-memory store
+func (s *MemoryStore) Ping(ctx context.Context) error {
+	return nil
+}
+
+// SaveAnalyticsSnapshot keeps the snapshot until the process exits
+func (s *MemoryStore) SaveAnalyticsSnapshot(ctx context.Context, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshot = append([]byte(nil), data...)
+	return nil
+}
+
+// LatestAnalyticsSnapshot returns the last snapshot saved, nil if none
+func (s *MemoryStore) LatestAnalyticsSnapshot(ctx context.Context) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.snapshot, nil
+}
+
+// Close is a no-op for the in-memory store
+func (s *MemoryStore) Close() {}
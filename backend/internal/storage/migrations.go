@@ -0,0 +1,361 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// migration is one step of the schema, written once per SQL dialect.
+// Versions are applied in order and recorded in schema_migrations; once
+// released, a migration's SQL must not change, so fixes go in a new
+// migration.
+type migration struct {
+	version  int
+	name     string
+	postgres string
+	sqlite   string
+}
+
+// migrations lists every schema change in the order it is applied.
+// Migration 1 is the schema as it stood before versioning; it keeps its
+// IF NOT EXISTS guards so databases created by older builds adopt it
+// without changes.
+var migrations = []migration{
+	{
+		version: 1,
+		name:    "initial schema",
+		postgres: `
+		CREATE TABLE IF NOT EXISTS games (
+			id UUID PRIMARY KEY,
+			player1 VARCHAR(50) NOT NULL,
+			player2 VARCHAR(50) NOT NULL,
+			winner VARCHAR(50),
+			is_forfeit BOOLEAN DEFAULT FALSE,
+			is_draw BOOLEAN DEFAULT FALSE,
+			duration_seconds INTEGER,
+			move_count INTEGER,
+			moves JSONB,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			ended_at TIMESTAMP,
+			imported BOOLEAN DEFAULT FALSE,
+			bot_difficulty VARCHAR(10),
+			bot_version VARCHAR(100),
+			player1_emoji VARCHAR(32),
+			player2_emoji VARCHAR(32),
+			result VARCHAR(20),
+			forfeited_by VARCHAR(50),
+			started_at TIMESTAMP
+		);
+
+		ALTER TABLE games ADD COLUMN IF NOT EXISTS imported BOOLEAN DEFAULT FALSE;
+		ALTER TABLE games ADD COLUMN IF NOT EXISTS bot_difficulty VARCHAR(10);
+		ALTER TABLE games ADD COLUMN IF NOT EXISTS bot_version VARCHAR(100);
+		ALTER TABLE games ADD COLUMN IF NOT EXISTS player1_emoji VARCHAR(32);
+		ALTER TABLE games ADD COLUMN IF NOT EXISTS player2_emoji VARCHAR(32);
+		ALTER TABLE games ADD COLUMN IF NOT EXISTS result VARCHAR(20);
+		ALTER TABLE games ADD COLUMN IF NOT EXISTS forfeited_by VARCHAR(50);
+
+		-- Start of play. Rows saved before this column existed have it NULL,
+		-- and their duration_seconds counts from created_at, so it may include
+		-- time spent waiting for an opponent.
+		ALTER TABLE games ADD COLUMN IF NOT EXISTS started_at TIMESTAMP;
+
+		CREATE INDEX IF NOT EXISTS idx_games_player1 ON games(player1);
+		CREATE INDEX IF NOT EXISTS idx_games_player2 ON games(player2);
+		CREATE INDEX IF NOT EXISTS idx_games_winner ON games(winner);
+		CREATE INDEX IF NOT EXISTS idx_games_created_at ON games(created_at);
+
+		CREATE TABLE IF NOT EXISTS ratings (
+			username VARCHAR(50) PRIMARY KEY,
+			rating INTEGER NOT NULL DEFAULT 1200,
+			games INTEGER NOT NULL DEFAULT 0,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS rating_history (
+			id SERIAL PRIMARY KEY,
+			username VARCHAR(50) NOT NULL,
+			game_id UUID NOT NULL,
+			opponent VARCHAR(50) NOT NULL,
+			rating_before INTEGER NOT NULL,
+			rating_after INTEGER NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_rating_history_username ON rating_history(username, created_at DESC);
+
+		CREATE TABLE IF NOT EXISTS game_analytics (
+			id SERIAL PRIMARY KEY,
+			date DATE NOT NULL,
+			hour INTEGER,
+			games_played INTEGER DEFAULT 0,
+			avg_duration_seconds NUMERIC(10,2),
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(date, hour)
+		);
+
+		CREATE TABLE IF NOT EXISTS analytics_snapshots (
+			id SERIAL PRIMARY KEY,
+			data JSONB NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+		`,
+		sqlite: `
+		CREATE TABLE IF NOT EXISTS games (
+			id TEXT PRIMARY KEY,
+			player1 TEXT NOT NULL,
+			player2 TEXT NOT NULL,
+			winner TEXT,
+			is_forfeit BOOLEAN DEFAULT FALSE,
+			is_draw BOOLEAN DEFAULT FALSE,
+			duration_seconds INTEGER,
+			move_count INTEGER,
+			moves TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			ended_at TIMESTAMP,
+			imported BOOLEAN DEFAULT FALSE,
+			bot_difficulty TEXT,
+			bot_version TEXT,
+			player1_emoji TEXT,
+			player2_emoji TEXT,
+			result TEXT,
+			forfeited_by TEXT,
+			started_at TIMESTAMP
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_games_player1 ON games(player1);
+		CREATE INDEX IF NOT EXISTS idx_games_player2 ON games(player2);
+		CREATE INDEX IF NOT EXISTS idx_games_winner ON games(winner);
+		CREATE INDEX IF NOT EXISTS idx_games_created_at ON games(created_at);
+
+		CREATE TABLE IF NOT EXISTS ratings (
+			username TEXT PRIMARY KEY,
+			rating INTEGER NOT NULL DEFAULT 1200,
+			games INTEGER NOT NULL DEFAULT 0,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS rating_history (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			username TEXT NOT NULL,
+			game_id TEXT NOT NULL,
+			opponent TEXT NOT NULL,
+			rating_before INTEGER NOT NULL,
+			rating_after INTEGER NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+
+		CREATE INDEX IF NOT EXISTS idx_rating_history_username ON rating_history(username, created_at DESC);
+
+		CREATE TABLE IF NOT EXISTS game_analytics (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			date TEXT NOT NULL,
+			hour INTEGER,
+			games_played INTEGER DEFAULT 0,
+			avg_duration_seconds REAL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(date, hour)
+		);
+
+		CREATE TABLE IF NOT EXISTS analytics_snapshots (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			data BLOB NOT NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		);
+		`,
+	},
+	{
+		version: 2,
+		name:    "null winner for draws",
+		postgres: `
+		-- Rows written before draws were stored with a NULL winner have ''
+		UPDATE games SET winner = NULL WHERE winner = '';
+		`,
+		sqlite: `UPDATE games SET winner = NULL WHERE winner = '';`,
+	},
+	{
+		version: 3,
+		name:    "bot games in game_analytics",
+		postgres: `
+		ALTER TABLE game_analytics ADD COLUMN IF NOT EXISTS bot_games INTEGER DEFAULT 0;
+		`,
+		sqlite: `ALTER TABLE game_analytics ADD COLUMN bot_games INTEGER DEFAULT 0;`,
+	},
+	{
+		version: 4,
+		name:    "active games",
+		postgres: `
+		CREATE TABLE IF NOT EXISTS active_games (
+			id UUID PRIMARY KEY,
+			data JSONB NOT NULL,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+		`,
+		sqlite: `
+		CREATE TABLE IF NOT EXISTS active_games (
+			id TEXT PRIMARY KEY,
+			data BLOB NOT NULL,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		);
+		`,
+	},
+	{
+		version:  5,
+		name:     "win type",
+		postgres: `ALTER TABLE games ADD COLUMN IF NOT EXISTS win_type VARCHAR(16);`,
+		sqlite:   `ALTER TABLE games ADD COLUMN win_type TEXT;`,
+	},
+	{
+		version: 6,
+		name:    "think time",
+		postgres: `
+		ALTER TABLE games ADD COLUMN IF NOT EXISTS avg_think_ms_p1 INTEGER;
+		ALTER TABLE games ADD COLUMN IF NOT EXISTS avg_think_ms_p2 INTEGER;
+		`,
+		sqlite: `
+		ALTER TABLE games ADD COLUMN avg_think_ms_p1 INTEGER;
+		ALTER TABLE games ADD COLUMN avg_think_ms_p2 INTEGER;
+		`,
+	},
+	{
+		version: 7,
+		name:    "board size",
+		postgres: `
+		ALTER TABLE games ADD COLUMN IF NOT EXISTS board_rows INTEGER NOT NULL DEFAULT 6;
+		ALTER TABLE games ADD COLUMN IF NOT EXISTS board_cols INTEGER NOT NULL DEFAULT 7;
+		ALTER TABLE games ADD COLUMN IF NOT EXISTS win_length INTEGER NOT NULL DEFAULT 4;
+		`,
+		sqlite: `
+		ALTER TABLE games ADD COLUMN board_rows INTEGER NOT NULL DEFAULT 6;
+		ALTER TABLE games ADD COLUMN board_cols INTEGER NOT NULL DEFAULT 7;
+		ALTER TABLE games ADD COLUMN win_length INTEGER NOT NULL DEFAULT 4;
+		`,
+	},
+}
+
+// schemaMigrationsTable records applied migrations; the DDL is valid in
+// both dialects
+const schemaMigrationsTable = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)
+`
+
+// migrationTarget is a database the migrations can be applied to
+type migrationTarget interface {
+	// currentVersion creates schema_migrations if needed and returns the
+	// highest applied version, 0 for a new database
+	currentVersion(ctx context.Context) (int, error)
+
+	// apply runs m and records it in one transaction, so a failed
+	// migration leaves nothing behind
+	apply(ctx context.Context, m migration) error
+}
+
+// runMigrations applies the migrations target is missing, in order, and
+// returns how many it applied
+func runMigrations(ctx context.Context, target migrationTarget) (int, error) {
+	current, err := target.currentVersion(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error reading schema version: %w", err)
+	}
+	if latest := migrations[len(migrations)-1].version; current > latest {
+		return 0, fmt.Errorf("database schema is at version %d, newer than this build's %d", current, latest)
+	}
+
+	applied := 0
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := target.apply(ctx, m); err != nil {
+			return applied, fmt.Errorf("migration %03d (%s): %w", m.version, m.name, err)
+		}
+		slog.Info("applied database migration", "version", m.version, "name", m.name)
+		applied++
+	}
+	return applied, nil
+}
+
+// migrationLockID is the advisory lock held while migrating PostgreSQL, so
+// servers starting together don't apply the same migration twice
+const migrationLockID = 7_401_120_001
+
+// migrate applies the pending migrations to a PostgreSQL database
+func migrate(ctx context.Context, pool *pgxpool.Pool) (int, error) {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("error acquiring connection: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID); err != nil {
+		return 0, fmt.Errorf("error locking schema_migrations: %w", err)
+	}
+	defer conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, migrationLockID)
+
+	return runMigrations(ctx, postgresMigrator{conn.Conn()})
+}
+
+// postgresMigrator applies migrations over one PostgreSQL connection
+type postgresMigrator struct {
+	conn *pgx.Conn
+}
+
+func (p postgresMigrator) currentVersion(ctx context.Context) (int, error) {
+	if _, err := p.conn.Exec(ctx, schemaMigrationsTable); err != nil {
+		return 0, err
+	}
+	var current int
+	err := p.conn.QueryRow(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current)
+	return current, err
+}
+
+func (p postgresMigrator) apply(ctx context.Context, m migration) error {
+	tx, err := p.conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, m.postgres); err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.version, m.name)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// Migrate connects to dbURL, applies any pending migrations and
+// disconnects, returning how many were applied. It lets migrations run
+// out-of-band before a deploy instead of at server startup.
+func Migrate(ctx context.Context, dbURL string) (int, error) {
+	if dbURL == MemoryURL {
+		return 0, nil
+	}
+	if path, ok := sqlitePath(dbURL); ok {
+		db, err := openSQLite(path)
+		if err != nil {
+			return 0, err
+		}
+		defer db.Close()
+
+		return runMigrations(ctx, sqliteMigrator{db})
+	}
+
+	pool, err := pgxpool.New(ctx, dbURL)
+	if err != nil {
+		return 0, fmt.Errorf("error connecting to database: %w", err)
+	}
+	defer pool.Close()
+
+	return migrate(ctx, pool)
+}
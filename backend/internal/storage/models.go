@@ -2,46 +2,89 @@ package storage
 
 import (
 	"time"
+
+	"github.com/connect-four/internal/game"
 )
 
 // CompletedGame represents a finished game stored in the database
 type CompletedGame struct {
+	ID              string      `json:"id"`
+	Player1         string      `json:"player1"`
+	Player2         string      `json:"player2"`
+	Winner          string      `json:"winner"`
+	IsForfeit       bool        `json:"isForfeit"`
+	IsDraw          bool        `json:"isDraw"`
+	Result          string      `json:"result"`
+	ForfeitedBy     string      `json:"forfeitedBy,omitempty"`
+	DurationSeconds int         `json:"durationSeconds"`
+	MoveCount       int         `json:"moveCount"`
+	Moves           string      `json:"moves"` // JSON string
+	MoveList        []game.Move `json:"-"`     // parsed Moves, filled by GetGameByID
+	CreatedAt       time.Time   `json:"createdAt"`
+	StartedAt       time.Time   `json:"startedAt"` // start of play; DurationSeconds counts from here
+	EndedAt         time.Time   `json:"endedAt"`
+	Imported        bool        `json:"imported"`
+	BotDifficulty   string      `json:"botDifficulty,omitempty"`
+	BotVersion      string      `json:"botVersion,omitempty"`
+	Player1Emoji    string      `json:"player1Emoji,omitempty"`
+	Player2Emoji    string      `json:"player2Emoji,omitempty"`
+	WinType         string      `json:"winType,omitempty"`      // see game.WinType; empty for aborted and unclassified games
+	AvgThinkMsP1    *int64      `json:"avgThinkMsP1,omitempty"` // nil without timed moves, as for imports
+	AvgThinkMsP2    *int64      `json:"avgThinkMsP2,omitempty"`
+	game.BoardSize              // rows, columns and winLength the game was played with
+}
+
+// RecentGame is a completed game in the recent games feed
+type RecentGame struct {
 	ID              string    `json:"id"`
 	Player1         string    `json:"player1"`
 	Player2         string    `json:"player2"`
 	Winner          string    `json:"winner"`
-	IsForfeit       bool      `json:"isForfeit"`
-	IsDraw          bool      `json:"isDraw"`
+	Result          string    `json:"result"`
+	WinType         string    `json:"winType,omitempty"`
 	DurationSeconds int       `json:"durationSeconds"`
 	MoveCount       int       `json:"moveCount"`
-	Moves           string    `json:"moves"` // JSON string
 	CreatedAt       time.Time `json:"createdAt"`
 	EndedAt         time.Time `json:"endedAt"`
+	BotDifficulty   string    `json:"botDifficulty,omitempty"`
+}
+
+// RecentGamesOptions selects a page of the recent games feed
+type RecentGamesOptions struct {
+	Limit       int
+	Before      time.Time // only games created before this; zero for the newest
+	BeforeID    string    // with Before, also games created at Before with a smaller ID
+	IncludeBots bool
 }
 
 // LeaderboardEntry represents a player's ranking
 type LeaderboardEntry struct {
-	Rank     int    `json:"rank"`
-	Username string `json:"username"`
-	Wins     int    `json:"wins"`
-	Losses   int    `json:"losses"`
-	Draws    int    `json:"draws"`
-	Games    int    `json:"games"`
+	Rank     int     `json:"rank"`
+	Username string  `json:"username"`
+	Wins     int     `json:"wins"`
+	Losses   int     `json:"losses"`
+	Draws    int     `json:"draws"`
+	Games    int     `json:"games"`
 	WinRate  float64 `json:"winRate"`
+	Rating   int     `json:"rating"`
 }
 
 // PlayerStats represents detailed player statistics
 type PlayerStats struct {
-	Username       string  `json:"username"`
-	Wins           int     `json:"wins"`
-	Losses         int     `json:"losses"`
-	Draws          int     `json:"draws"`
-	TotalGames     int     `json:"totalGames"`
-	WinRate        float64 `json:"winRate"`
-	BotWins        int     `json:"botWins"`
-	BotLosses      int     `json:"botLosses"`
-	AvgGameLength  float64 `json:"avgGameLength"`
-	CurrentStreak  int     `json:"currentStreak"`
+	Username      string         `json:"username"`
+	Wins          int            `json:"wins"`
+	Losses        int            `json:"losses"`
+	Draws         int            `json:"draws"`
+	TotalGames    int            `json:"totalGames"`
+	WinRate       float64        `json:"winRate"`
+	BotWins       int            `json:"botWins"`
+	BotLosses     int            `json:"botLosses"`
+	Forfeits      int            `json:"forfeits"`
+	AvgGameLength float64        `json:"avgGameLength"`
+	AvgThinkMs    float64        `json:"avgThinkMs"` // mean of the per-game averages, over games with timed moves
+	CurrentStreak int            `json:"currentStreak"`
+	Rating        int            `json:"rating"`
+	RatingHistory []RatingChange `json:"ratingHistory"`
 }
 
 // GameAnalytics represents aggregated game analytics
@@ -53,4 +96,90 @@ type GameAnalytics struct {
 	GamesToday         int     `json:"gamesToday"`
 	GamesThisHour      int     `json:"gamesThisHour"`
 	MostFrequentWinner string  `json:"mostFrequentWinner"`
+
+	// How games were decided, most common first
+	WinTypes []WinTypeShare `json:"winTypes"`
+}
+
+// WinTypeShare is how many finished games were decided one way
+type WinTypeShare struct {
+	WinType string  `json:"winType"`
+	Games   int     `json:"games"`
+	Percent float64 `json:"percent"` // of games with a known win type
+}
+
+// HourlyAnalytics is the games started in one hour
+type HourlyAnalytics struct {
+	Hour               time.Time `json:"hour"`
+	GamesPlayed        int       `json:"gamesPlayed"`
+	AvgDurationSeconds float64   `json:"avgDurationSeconds"`
+}
+
+// BotVersionStats represents bot game results for one bot engine version
+type BotVersionStats struct {
+	Version      string  `json:"version"`
+	Games        int     `json:"games"`
+	BotWins      int     `json:"botWins"`
+	HumanWins    int     `json:"humanWins"`
+	Draws        int     `json:"draws"`
+	BotWinRate   float64 `json:"botWinRate"`
+	HumanWinRate float64 `json:"humanWinRate"`
+}
+
+// computeRates fills the win rate percentages from the counts
+func (v *BotVersionStats) computeRates() {
+	if v.Games > 0 {
+		v.BotWinRate = float64(v.BotWins) / float64(v.Games) * 100
+		v.HumanWinRate = float64(v.HumanWins) / float64(v.Games) * 100
+	}
+}
+
+// HeadToHead summarizes every game between two players
+type HeadToHead struct {
+	Player          string  `json:"player"`
+	Opponent        string  `json:"opponent"`
+	Games           int     `json:"games"`
+	PlayerWins      int     `json:"playerWins"`
+	OpponentWins    int     `json:"opponentWins"`
+	Draws           int     `json:"draws"`
+	AvgGameDuration float64 `json:"avgGameDuration"`
+	StreakHolder    string  `json:"streakHolder,omitempty"` // who won the most recent games in a row
+	StreakLength    int     `json:"streakLength"`
+
+	totalDuration int
+	streakOpen    bool
+}
+
+// newHeadToHead starts an empty summary; results must then be added newest first
+func newHeadToHead(player, opponent string) *HeadToHead {
+	return &HeadToHead{Player: player, Opponent: opponent, streakOpen: true}
+}
+
+// add records one game between the pair; games must be added newest first
+// so the current streak can be counted
+func (h *HeadToHead) add(winner string, isDraw bool, durationSeconds int) {
+	h.Games++
+	h.totalDuration += durationSeconds
+
+	if isDraw || winner == "" {
+		h.Draws++
+		h.streakOpen = false
+	} else {
+		if winner == h.Player {
+			h.PlayerWins++
+		} else {
+			h.OpponentWins++
+		}
+		switch {
+		case h.StreakLength == 0 && h.streakOpen:
+			h.StreakHolder = winner
+			h.StreakLength = 1
+		case h.streakOpen && winner == h.StreakHolder:
+			h.StreakLength++
+		default:
+			h.streakOpen = false
+		}
+	}
+
+	h.AvgGameDuration = float64(h.totalDuration) / float64(h.Games)
 }
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/connect-four/internal/usernames"
+)
+
+// ErrUnknownPlayer is returned when a username has never played a game
+var ErrUnknownPlayer = errors.New("player not found")
+
+// ErrGameNotFound is returned when no completed game has the requested ID
+var ErrGameNotFound = errors.New("game not found")
+
+// NearCollisionError is returned when a username does not exist exactly but
+// matches an existing player once case and compatibility forms are folded
+type NearCollisionError struct {
+	Requested  string
+	Suggestion string
+}
+
+func (e *NearCollisionError) Error() string {
+	return fmt.Sprintf("player %q not found, did you mean %q", e.Requested, e.Suggestion)
+}
+
+// Unwrap allows errors.Is(err, ErrUnknownPlayer)
+func (e *NearCollisionError) Unwrap() error {
+	return ErrUnknownPlayer
+}
+
+// CanonicalUsername trims a username and puts it in Unicode NFC form
+func CanonicalUsername(username string) string {
+	return usernames.Canonical(username)
+}
+
+// foldUsername returns the key used to detect lookalike usernames
+func foldUsername(username string) string {
+	return usernames.Fold(username)
+}
+
+// ResolveUsername returns the canonical username of an existing player.
+// Unknown names return ErrUnknownPlayer; a name that only matches an
+// existing player case-insensitively returns a *NearCollisionError.
+func (s *PostgresStore) ResolveUsername(ctx context.Context, username string) (string, error) {
+	canonical := CanonicalUsername(username)
+	if canonical == "" {
+		return "", ErrUnknownPlayer
+	}
+
+	var exists bool
+	err := s.pool.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM games WHERE player1 = $1 OR player2 = $1)`,
+		canonical,
+	).Scan(&exists)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return canonical, nil
+	}
+
+	query := `
+		SELECT username FROM (
+			SELECT player1 as username FROM games
+			UNION
+			SELECT player2 as username FROM games
+		) names
+		WHERE lower(normalize(username, NFKC)) = $1
+		LIMIT 1
+	`
+
+	var suggestion string
+	err = s.pool.QueryRow(ctx, query, foldUsername(canonical)).Scan(&suggestion)
+	if err == nil {
+		return "", &NearCollisionError{Requested: canonical, Suggestion: suggestion}
+	}
+	if isNoRows(err) {
+		return "", ErrUnknownPlayer
+	}
+	return "", err
+}
@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"math"
+	"time"
+
+	"github.com/connect-four/internal/game"
+)
+
+const (
+	// DefaultRating is the Elo rating of a player with no rated games
+	DefaultRating = 1200
+
+	// EloK is the Elo K-factor applied to every rated game
+	EloK = 32
+
+	// ratingHistoryLimit caps the rating changes returned with player stats
+	ratingHistoryLimit = 20
+)
+
+// RatingChange is one rated game's effect on a player's rating
+type RatingChange struct {
+	GameID   string    `json:"gameId"`
+	Before   int       `json:"before"`
+	After    int       `json:"after"`
+	Opponent string    `json:"opponent"`
+	At       time.Time `json:"at"`
+}
+
+// LeaderboardSort selects the leaderboard ordering
+type LeaderboardSort string
+
+const (
+	SortByWins   LeaderboardSort = "wins"
+	SortByRating LeaderboardSort = "rating"
+)
+
+// LeaderboardPeriod is the time window a leaderboard covers
+type LeaderboardPeriod string
+
+const (
+	PeriodDay   LeaderboardPeriod = "day"
+	PeriodWeek  LeaderboardPeriod = "week"
+	PeriodMonth LeaderboardPeriod = "month"
+	PeriodAll   LeaderboardPeriod = "all"
+)
+
+// WindowStart returns the start of the rolling window ending at now, or the
+// zero time for PeriodAll. ok is false for an unknown period.
+func (p LeaderboardPeriod) WindowStart(now time.Time) (start time.Time, ok bool) {
+	switch p {
+	case PeriodDay:
+		return now.Add(-24 * time.Hour), true
+	case PeriodWeek:
+		return now.AddDate(0, 0, -7), true
+	case PeriodMonth:
+		return now.AddDate(0, -1, 0), true
+	case PeriodAll:
+		return time.Time{}, true
+	}
+	return time.Time{}, false
+}
+
+// LeaderboardOptions filter and order the leaderboard
+type LeaderboardOptions struct {
+	Limit int
+	Sort  LeaderboardSort
+	Since time.Time // only games that ended at or after this; zero for all time
+
+	// IncludeBots counts games against the bot; by default only games
+	// between two people rank players
+	IncludeBots bool
+}
+
+// isRated reports whether a finished game changes ratings: bot, friendly
+// and imported games don't count
+func isRated(g *game.Game) bool {
+	return !g.Imported && !g.Friendly && g.Player2 != nil && g.BotPlayer() == 0 &&
+		g.GetState().Result != string(game.ResultAborted)
+}
+
+// eloUpdate returns both players' new ratings after a game where scoreA is
+// 1 for a win by A, 0.5 for a draw and 0 for a loss
+func eloUpdate(ratingA, ratingB int, scoreA float64) (int, int) {
+	expectedA := 1 / (1 + math.Pow(10, float64(ratingB-ratingA)/400))
+	delta := EloK * (scoreA - expectedA)
+	return int(math.Round(float64(ratingA) + delta)), int(math.Round(float64(ratingB) - delta))
+}
+
+// player1Score returns Player1's Elo score for a finished game
+func player1Score(state *game.GameState) float64 {
+	switch {
+	case state.Result == string(game.ResultDraw) || state.Winner == "":
+		return 0.5
+	case state.Winner == state.Player1:
+		return 1
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,29 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLeaderboardPeriodWindowStart(t *testing.T) {
+	now := time.Date(2026, 3, 31, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		period LeaderboardPeriod
+		want   time.Time
+		ok     bool
+	}{
+		{PeriodDay, time.Date(2026, 3, 30, 12, 0, 0, 0, time.UTC), true},
+		{PeriodWeek, time.Date(2026, 3, 24, 12, 0, 0, 0, time.UTC), true},
+		// March 31st less a month normalizes past February's end
+		{PeriodMonth, time.Date(2026, 3, 3, 12, 0, 0, 0, time.UTC), true},
+		{PeriodAll, time.Time{}, true},
+		{"year", time.Time{}, false},
+		{"", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := tt.period.WindowStart(now)
+		if ok != tt.ok || !got.Equal(tt.want) {
+			t.Errorf("%q: WindowStart = %v, %v; want %v, %v", tt.period, got, ok, tt.want, tt.ok)
+		}
+	}
+}
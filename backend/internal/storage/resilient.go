@@ -0,0 +1,382 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/connect-four/internal/game"
+	"github.com/connect-four/internal/logging"
+)
+
+// ErrStoreUnavailable is returned by ResilientStore while the database
+// can't be reached
+var ErrStoreUnavailable = errors.New("database unavailable")
+
+// Connection handling for ResilientStore
+const (
+	// storeHealthInterval is how often the database is pinged, or a
+	// reconnect attempted while it is down
+	storeHealthInterval = 5 * time.Second
+
+	// storeHealthTimeout bounds each ping and connection attempt
+	storeHealthTimeout = 5 * time.Second
+
+	// Backoff between connection attempts at startup
+	connectInitialDelay = time.Second
+	connectMaxDelay     = 30 * time.Second
+)
+
+// StoreState is ResilientStore's view of the database for /api/status
+type StoreState struct {
+	Available bool      `json:"available"`
+	Since     time.Time `json:"since"`           // when it last became available or unavailable
+	LastError string    `json:"error,omitempty"` // why it is unavailable
+}
+
+// ResilientStore wraps the PostgreSQL or SQLite store named by a database
+// URL and keeps working through outages. Until the first connection
+// succeeds, and whenever the health check's ping fails, every call returns
+// ErrStoreUnavailable instead of waiting on the database; Run reconnects
+// in the background and the first successful ping restores it.
+type ResilientStore struct {
+	dbURL   string
+	backend string
+	logger  *slog.Logger
+
+	mu    sync.RWMutex
+	store Store // nil until connected
+	state StoreState
+}
+
+// OpenResilient connects to the database at dbURL, making up to attempts
+// tries with backoff. If none succeeds the store starts out unavailable
+// and Run keeps trying; the error is the last attempt's.
+func OpenResilient(ctx context.Context, dbURL string, attempts int, logger *slog.Logger) (*ResilientStore, error) {
+	s := &ResilientStore{
+		dbURL:   dbURL,
+		backend: "postgres",
+		logger:  logging.OrDefault(logger),
+		state:   StoreState{Since: time.Now()},
+	}
+	if _, ok := sqlitePath(dbURL); ok {
+		s.backend = "sqlite"
+	}
+
+	delay := connectInitialDelay
+	var err error
+	for attempt := 1; attempt <= max(attempts, 1); attempt++ {
+		if err = s.connect(ctx); err == nil {
+			return s, nil
+		}
+		if attempt == max(attempts, 1) {
+			break
+		}
+		s.logger.Warn("database not available, retrying", "attempt", attempt, "of", attempts, "retryIn", delay, "error", err)
+		if !sleepCtx(ctx, delay) {
+			break
+		}
+		delay = min(delay*2, connectMaxDelay)
+	}
+	return s, err
+}
+
+// connect opens the underlying store and marks it available
+func (s *ResilientStore) connect(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, storeHealthTimeout)
+	defer cancel()
+
+	store, err := Open(ctx, s.dbURL)
+	if err != nil {
+		s.setAvailable(false, err)
+		return err
+	}
+
+	s.mu.Lock()
+	s.store = store
+	s.mu.Unlock()
+	s.setAvailable(true, nil)
+	return nil
+}
+
+// setAvailable records a change in availability, logging transitions
+func (s *ResilientStore) setAvailable(available bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err != nil {
+		s.state.LastError = err.Error()
+	} else {
+		s.state.LastError = ""
+	}
+	if s.state.Available == available {
+		return
+	}
+	s.state.Available = available
+	s.state.Since = time.Now()
+	if available {
+		s.logger.Info("database available", "backend", s.backend)
+	} else {
+		s.logger.Error("database unavailable", "backend", s.backend, "error", err)
+	}
+}
+
+// Run checks the database every storeHealthInterval until ctx is
+// cancelled: it reconnects if the store never opened and otherwise pings
+// it, flipping availability with the result
+func (s *ResilientStore) Run(ctx context.Context) {
+	ticker := time.NewTicker(storeHealthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.check(ctx)
+		}
+	}
+}
+
+// check runs one health check
+func (s *ResilientStore) check(ctx context.Context) {
+	s.mu.RLock()
+	store := s.store
+	s.mu.RUnlock()
+
+	if store == nil {
+		s.connect(ctx)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, storeHealthTimeout)
+	defer cancel()
+	err := store.Ping(ctx)
+	s.setAvailable(err == nil, err)
+}
+
+// State reports whether the database is currently usable
+func (s *ResilientStore) State() StoreState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.state
+}
+
+// current returns the underlying store, or ErrStoreUnavailable
+func (s *ResilientStore) current() (Store, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.store == nil || !s.state.Available {
+		return nil, ErrStoreUnavailable
+	}
+	return s.store, nil
+}
+
+// SaveGame stores a completed game
+func (s *ResilientStore) SaveGame(ctx context.Context, g *game.Game) error {
+	store, err := s.current()
+	if err != nil {
+		return err
+	}
+	return store.SaveGame(ctx, g)
+}
+
+// SaveActiveGame stores an unfinished game's snapshot
+func (s *ResilientStore) SaveActiveGame(ctx context.Context, gameID string, data []byte) error {
+	store, err := s.current()
+	if err != nil {
+		return err
+	}
+	return store.SaveActiveGame(ctx, gameID, data)
+}
+
+// DeleteActiveGame drops an unfinished game's snapshot
+func (s *ResilientStore) DeleteActiveGame(ctx context.Context, gameID string) error {
+	store, err := s.current()
+	if err != nil {
+		return err
+	}
+	return store.DeleteActiveGame(ctx, gameID)
+}
+
+// LoadActiveGames returns the snapshots of unfinished games
+func (s *ResilientStore) LoadActiveGames(ctx context.Context) ([][]byte, error) {
+	store, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	return store.LoadActiveGames(ctx)
+}
+
+// GetGameByID returns a completed game
+func (s *ResilientStore) GetGameByID(ctx context.Context, id string) (*CompletedGame, error) {
+	store, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	return store.GetGameByID(ctx, id)
+}
+
+// GetRecentGames returns a page of completed games
+func (s *ResilientStore) GetRecentGames(ctx context.Context, opts RecentGamesOptions) ([]RecentGame, error) {
+	store, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	return store.GetRecentGames(ctx, opts)
+}
+
+// GetLeaderboard returns the top players
+func (s *ResilientStore) GetLeaderboard(ctx context.Context, opts LeaderboardOptions) ([]LeaderboardEntry, error) {
+	store, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	return store.GetLeaderboard(ctx, opts)
+}
+
+// GetRating returns a player's current Elo rating
+func (s *ResilientStore) GetRating(ctx context.Context, username string) (int, error) {
+	store, err := s.current()
+	if err != nil {
+		return 0, err
+	}
+	return store.GetRating(ctx, username)
+}
+
+// GetPlayerStats returns statistics for a player
+func (s *ResilientStore) GetPlayerStats(ctx context.Context, username string) (*PlayerStats, error) {
+	store, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	return store.GetPlayerStats(ctx, username)
+}
+
+// GetHeadToHead returns the record between two players
+func (s *ResilientStore) GetHeadToHead(ctx context.Context, player, opponent string) (*HeadToHead, error) {
+	store, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	return store.GetHeadToHead(ctx, player, opponent)
+}
+
+// GetAnalytics returns analytics data
+func (s *ResilientStore) GetAnalytics(ctx context.Context) (*GameAnalytics, error) {
+	store, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	return store.GetAnalytics(ctx)
+}
+
+// RollupAnalytics summarizes finished hours into game_analytics
+func (s *ResilientStore) RollupAnalytics(ctx context.Context, now time.Time) error {
+	store, err := s.current()
+	if err != nil {
+		return err
+	}
+	return store.RollupAnalytics(ctx, now)
+}
+
+// GetHourlyAnalytics returns games per hour
+func (s *ResilientStore) GetHourlyAnalytics(ctx context.Context, since, now time.Time) ([]HourlyAnalytics, error) {
+	store, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	return store.GetHourlyAnalytics(ctx, since, now)
+}
+
+// CompareRollup returns hours whose bucket disagrees with the games table
+func (s *ResilientStore) CompareRollup(ctx context.Context, since time.Time) ([]RollupMismatch, error) {
+	store, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	return store.CompareRollup(ctx, since)
+}
+
+// GetBotVersionStats returns bot results per engine version
+func (s *ResilientStore) GetBotVersionStats(ctx context.Context) ([]BotVersionStats, error) {
+	store, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	return store.GetBotVersionStats(ctx)
+}
+
+// BackfillWinTypes classifies games saved without a win type
+func (s *ResilientStore) BackfillWinTypes(ctx context.Context) (int64, error) {
+	store, err := s.current()
+	if err != nil {
+		return 0, err
+	}
+	return store.BackfillWinTypes(ctx)
+}
+
+// ClearAllGames deletes all games
+func (s *ResilientStore) ClearAllGames(ctx context.Context) (int64, error) {
+	store, err := s.current()
+	if err != nil {
+		return 0, err
+	}
+	return store.ClearAllGames(ctx)
+}
+
+// ResolveUsername returns the canonical username of an existing player
+func (s *ResilientStore) ResolveUsername(ctx context.Context, username string) (string, error) {
+	store, err := s.current()
+	if err != nil {
+		return "", err
+	}
+	return store.ResolveUsername(ctx, username)
+}
+
+// SaveAnalyticsSnapshot stores the latest analytics snapshot
+func (s *ResilientStore) SaveAnalyticsSnapshot(ctx context.Context, data []byte) error {
+	store, err := s.current()
+	if err != nil {
+		return err
+	}
+	return store.SaveAnalyticsSnapshot(ctx, data)
+}
+
+// LatestAnalyticsSnapshot returns the stored analytics snapshot
+func (s *ResilientStore) LatestAnalyticsSnapshot(ctx context.Context) ([]byte, error) {
+	store, err := s.current()
+	if err != nil {
+		return nil, err
+	}
+	return store.LatestAnalyticsSnapshot(ctx)
+}
+
+// Ping checks the database directly, even while it is marked unavailable
+func (s *ResilientStore) Ping(ctx context.Context) error {
+	s.mu.RLock()
+	store := s.store
+	s.mu.RUnlock()
+
+	if store == nil {
+		return ErrStoreUnavailable
+	}
+	return store.Ping(ctx)
+}
+
+// Backend returns the configured backend, "postgres" or "sqlite"
+func (s *ResilientStore) Backend() string {
+	return s.backend
+}
+
+// Close closes the underlying store, if it was ever opened
+func (s *ResilientStore) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.store != nil {
+		s.store.Close()
+	}
+}
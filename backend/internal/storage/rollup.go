@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+const (
+	// analyticsRollupInterval is how often RunAnalyticsRollup runs
+	analyticsRollupInterval = time.Hour
+
+	// analyticsRollupLookback is how far before the newest bucket each
+	// rollup recomputes, to pick up games saved after their hour was
+	// rolled up (a game counts in the hour it started)
+	analyticsRollupLookback = 24 * time.Hour
+
+	// analyticsRollupTimeout bounds a single rollup
+	analyticsRollupTimeout = time.Minute
+)
+
+// ErrNoRollup is returned by stores that keep no hourly analytics buckets
+// and so have no rollup to compare
+var ErrNoRollup = errors.New("store keeps no analytics rollup")
+
+// RollupMismatch is a rolled up hour whose bucket disagrees with the games
+// table
+type RollupMismatch struct {
+	Hour   time.Time `json:"hour"`
+	Rolled int       `json:"rolled"` // games_played in the bucket
+	Stored int       `json:"stored"` // games in the games table for the hour
+}
+
+// scanRollupMismatches reads hour, bucket count and games count rows
+func scanRollupMismatches(rows rowsScanner) ([]RollupMismatch, error) {
+	var result []RollupMismatch
+	for rows.Next() {
+		var m RollupMismatch
+		if err := rows.Scan(&m.Hour, &m.Rolled, &m.Stored); err != nil {
+			return nil, err
+		}
+		result = append(result, m)
+	}
+	return result, rows.Err()
+}
+
+// RunAnalyticsRollup rolls finished hours into the hourly analytics
+// buckets right away and then every hour, until ctx is done
+func RunAnalyticsRollup(ctx context.Context, store Store) {
+	ticker := time.NewTicker(analyticsRollupInterval)
+	defer ticker.Stop()
+
+	for {
+		rollupCtx, cancel := context.WithTimeout(ctx, analyticsRollupTimeout)
+		if err := store.RollupAnalytics(rollupCtx, time.Now()); err != nil {
+			slog.Error("rolling up hourly analytics failed", "error", err)
+		}
+		cancel()
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStoreHourlyAnalytics(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		now := testEpoch.Add(4*time.Hour + 30*time.Minute)
+		saveFinished(t, s, "alice", "bob", player1Wins, testEpoch.Add(10*time.Minute), time.Minute)
+		saveFinished(t, s, "alice", "bob", player1Wins, testEpoch.Add(20*time.Minute), 3*time.Minute)
+		saveFinished(t, s, "carol", "dave", player1Wins, testEpoch.Add(2*time.Hour), 5*time.Minute)
+		// In the hour still in progress, which the rollup leaves to the raw games
+		saveFinished(t, s, "carol", "dave", player1Wins, testEpoch.Add(4*time.Hour+5*time.Minute), time.Minute)
+
+		check := func(when string) {
+			t.Helper()
+			hours, err := s.GetHourlyAnalytics(ctx, testEpoch, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []struct {
+				games int
+				avg   float64
+			}{{2, 120}, {0, 0}, {1, 300}, {0, 0}, {1, 60}}
+			if len(hours) != len(want) {
+				t.Fatalf("%s: %d hours, want %d", when, len(hours), len(want))
+			}
+			for i, w := range want {
+				h := hours[i]
+				if !h.Hour.Equal(testEpoch.Add(time.Duration(i) * time.Hour)) {
+					t.Errorf("%s: hour %d starts %v", when, i, h.Hour)
+				}
+				if h.GamesPlayed != w.games || h.AvgDurationSeconds != w.avg {
+					t.Errorf("%s: hour %d = %d games avg %v, want %d avg %v", when, i, h.GamesPlayed, h.AvgDurationSeconds, w.games, w.avg)
+				}
+			}
+		}
+
+		check("before rollup")
+		if err := s.RollupAnalytics(ctx, now); err != nil {
+			t.Fatal(err)
+		}
+		check("after rollup")
+		// A second rollup over the same hours must not double count
+		if err := s.RollupAnalytics(ctx, now); err != nil {
+			t.Fatal(err)
+		}
+		check("after second rollup")
+
+		a, err := s.GetAnalytics(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.TotalGames != 4 || a.TotalPlayers != 4 {
+			t.Errorf("analytics = %d games, %d players; want 4 and 4", a.TotalGames, a.TotalPlayers)
+		}
+	})
+}
+
+func TestStoreCompareRollup(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		if _, err := s.CompareRollup(ctx, testEpoch); errors.Is(err, ErrNoRollup) {
+			if s.Backend() != "memory" {
+				t.Errorf("%s store has no rollup to compare", s.Backend())
+			}
+			return
+		}
+
+		saveFinished(t, s, "alice", "bob", player1Wins, testEpoch.Add(10*time.Minute), time.Minute)
+		saveFinished(t, s, "carol", "dave", player1Wins, testEpoch.Add(time.Hour+10*time.Minute), time.Minute)
+		if err := s.RollupAnalytics(ctx, testEpoch.Add(3*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		mismatches, err := s.CompareRollup(ctx, testEpoch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(mismatches) != 0 {
+			t.Fatalf("fresh rollup mismatches %+v", mismatches)
+		}
+
+		// A game saved after its hour was rolled up
+		saveFinished(t, s, "alice", "carol", player1Wins, testEpoch.Add(20*time.Minute), time.Minute)
+		mismatches, err = s.CompareRollup(ctx, testEpoch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(mismatches) != 1 {
+			t.Fatalf("mismatches = %+v, want the first hour only", mismatches)
+		}
+		if m := mismatches[0]; !m.Hour.Equal(testEpoch) || m.Rolled != 1 || m.Stored != 2 {
+			t.Errorf("mismatch = %+v, want hour %v rolled 1 stored 2", m, testEpoch)
+		}
+
+		// Hours before since are not compared
+		if mismatches, err := s.CompareRollup(ctx, testEpoch.Add(time.Hour)); err != nil || len(mismatches) != 0 {
+			t.Errorf("from the second hour: mismatches = %+v, %v; want none", mismatches, err)
+		}
+
+		if err := s.RollupAnalytics(ctx, testEpoch.Add(3*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		if mismatches, err := s.CompareRollup(ctx, testEpoch); err != nil || len(mismatches) != 0 {
+			t.Errorf("after the next rollup: mismatches = %+v, %v; want none", mismatches, err)
+		}
+	})
+}
@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/connect-four/internal/game"
+	"github.com/connect-four/internal/logging"
+)
+
+// Retry settings for saving finished games
+const (
+	// saveAttempts is how many times Save tries before queueing a game,
+	// waiting saveRetryDelay after the first failure and doubling
+	saveAttempts   = 3
+	saveRetryDelay = 100 * time.Millisecond
+
+	// maxQueuedSaves bounds the retry queue; beyond it the oldest game is
+	// dropped
+	maxQueuedSaves = 500
+
+	// Backoff between passes over the queue while saves keep failing
+	queueRetryInitialDelay = time.Second
+	queueRetryMaxDelay     = time.Minute
+)
+
+// SaveQueueStats reports the retry queue for /api/status
+type SaveQueueStats struct {
+	Queued    int   `json:"queued"`    // games waiting to be retried
+	Recovered int64 `json:"recovered"` // queued games saved on a later attempt
+	Dropped   int64 `json:"dropped"`   // games lost because the queue was full
+}
+
+// SaveQueue saves finished games, retrying through transient database
+// errors. Games that still fail after a few attempts wait in a bounded
+// queue that Run keeps retrying. SaveGame ignores games already stored, so
+// a game saved twice is harmless.
+type SaveQueue struct {
+	store  Store
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	pending []*game.Game // oldest first
+	wake    chan struct{}
+
+	recovered atomic.Int64
+	dropped   atomic.Int64
+}
+
+// NewSaveQueue creates a retry queue in front of store
+func NewSaveQueue(store Store) *SaveQueue {
+	return &SaveQueue{
+		store:  store,
+		logger: slog.Default(),
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// SetLogger sets the queue's logger; nil falls back to slog's default logger
+func (q *SaveQueue) SetLogger(logger *slog.Logger) {
+	q.logger = logging.OrDefault(logger)
+}
+
+// Save stores a finished game, trying saveAttempts times with backoff and
+// queueing it for Run if all of them fail
+func (q *SaveQueue) Save(ctx context.Context, g *game.Game) {
+	delay := saveRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := q.store.SaveGame(ctx, g)
+		if err == nil {
+			return
+		}
+		if attempt == saveAttempts || !sleepCtx(ctx, delay) {
+			q.logger.Warn("saving game failed, queued for retry", "gameID", g.ID, "attempts", attempt, "error", err)
+			q.enqueue(g)
+			return
+		}
+		delay *= 2
+	}
+}
+
+// enqueue adds a game to the retry queue, dropping the oldest one when full
+func (q *SaveQueue) enqueue(g *game.Game) {
+	q.mu.Lock()
+	if len(q.pending) >= maxQueuedSaves {
+		q.logger.Error("save retry queue full, dropping game", "gameID", q.pending[0].ID)
+		q.pending = q.pending[1:]
+		q.dropped.Add(1)
+	}
+	q.pending = append(q.pending, g)
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run retries queued games until ctx is cancelled, backing off from
+// queueRetryInitialDelay to queueRetryMaxDelay while they keep failing
+func (q *SaveQueue) Run(ctx context.Context) {
+	delay := queueRetryInitialDelay
+	for {
+		if q.Stats().Queued == 0 {
+			delay = queueRetryInitialDelay
+			select {
+			case <-q.wake:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if !sleepCtx(ctx, delay) {
+			return
+		}
+		if q.retry(ctx) > 0 {
+			delay = min(delay*2, queueRetryMaxDelay)
+		} else {
+			delay = queueRetryInitialDelay
+		}
+	}
+}
+
+// Flush makes a last attempt to save every queued game, for shutdown, and
+// returns how many are still unsaved
+func (q *SaveQueue) Flush(ctx context.Context) int {
+	left := q.retry(ctx)
+	if left > 0 {
+		q.logger.Error("unsaved games lost at shutdown", "count", left)
+	}
+	return left
+}
+
+// retry saves queued games oldest first, stopping at the first failure
+// since the database is most likely still unavailable, and returns how
+// many remain queued
+func (q *SaveQueue) retry(ctx context.Context) int {
+	q.mu.Lock()
+	games := slices.Clone(q.pending)
+	q.mu.Unlock()
+
+	for _, g := range games {
+		if err := q.store.SaveGame(ctx, g); err != nil {
+			q.logger.Debug("queued game still not saved", "gameID", g.ID, "error", err)
+			break
+		}
+		q.recovered.Add(1)
+		q.logger.Info("saved queued game", "gameID", g.ID)
+
+		q.mu.Lock()
+		if i := slices.Index(q.pending, g); i >= 0 {
+			q.pending = slices.Delete(q.pending, i, i+1)
+		}
+		q.mu.Unlock()
+	}
+	return q.Stats().Queued
+}
+
+// Stats returns the queue depth and counters
+func (q *SaveQueue) Stats() SaveQueueStats {
+	q.mu.Lock()
+	queued := len(q.pending)
+	q.mu.Unlock()
+
+	return SaveQueueStats{
+		Queued:    queued,
+		Recovered: q.recovered.Load(),
+		Dropped:   q.dropped.Load(),
+	}
+}
+
+// sleepCtx waits for d, returning false if ctx ends first
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,200 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/connect-four/internal/game"
+)
+
+var errDatabaseDown = errors.New("database down")
+
+// flakyStore fails SaveGame the given number of times, then saves to
+// memory
+type flakyStore struct {
+	*MemoryStore
+
+	mu       sync.Mutex
+	failures int // remaining; negative fails forever
+	calls    int
+}
+
+func newFlakyStore(failures int) *flakyStore {
+	return &flakyStore{MemoryStore: NewMemoryStore(), failures: failures}
+}
+
+func (s *flakyStore) SaveGame(ctx context.Context, g *game.Game) error {
+	s.mu.Lock()
+	s.calls++
+	failing := s.failures != 0
+	if s.failures > 0 {
+		s.failures--
+	}
+	s.mu.Unlock()
+
+	if failing {
+		return errDatabaseDown
+	}
+	return s.MemoryStore.SaveGame(ctx, g)
+}
+
+// setFailures changes how many more saves fail
+func (s *flakyStore) setFailures(n int) {
+	s.mu.Lock()
+	s.failures = n
+	s.mu.Unlock()
+}
+
+func (s *flakyStore) callCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls
+}
+
+// stored reports whether g reached the store
+func (s *flakyStore) stored(t *testing.T, g *game.Game) bool {
+	t.Helper()
+	_, err := s.MemoryStore.GetGameByID(context.Background(), g.ID)
+	if err != nil && !errors.Is(err, ErrGameNotFound) {
+		t.Fatal(err)
+	}
+	return err == nil
+}
+
+// finishedGame is a game player 1 won, not yet saved
+func finishedGame(t *testing.T) *game.Game {
+	t.Helper()
+	moves, err := game.FromNotation(player1Wins)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := game.NewImportedGame("alice", "bob", moves)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Imported = false
+	return g
+}
+
+func TestSaveQueueRetriesTransientErrors(t *testing.T) {
+	store := newFlakyStore(saveAttempts - 1)
+	q := NewSaveQueue(store)
+	g := finishedGame(t)
+
+	q.Save(context.Background(), g)
+	if !store.stored(t, g) {
+		t.Fatal("game not saved on the last attempt")
+	}
+	if store.callCount() != saveAttempts {
+		t.Errorf("%d attempts, want %d", store.callCount(), saveAttempts)
+	}
+	if stats := q.Stats(); stats != (SaveQueueStats{}) {
+		t.Errorf("stats = %+v, want nothing queued", stats)
+	}
+}
+
+func TestSaveQueueQueuesAndRecovers(t *testing.T) {
+	store := newFlakyStore(-1)
+	q := NewSaveQueue(store)
+	first, second := finishedGame(t), finishedGame(t)
+
+	q.Save(context.Background(), first)
+	q.Save(context.Background(), second)
+	if store.callCount() != 2*saveAttempts {
+		t.Errorf("%d attempts, want %d", store.callCount(), 2*saveAttempts)
+	}
+	if stats := q.Stats(); stats.Queued != 2 || stats.Recovered != 0 {
+		t.Fatalf("stats = %+v, want 2 queued", stats)
+	}
+
+	// A pass while the database is still down stops at the first failure
+	if left := q.retry(context.Background()); left != 2 {
+		t.Errorf("%d left after a failing pass, want 2", left)
+	}
+
+	store.setFailures(0)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		q.Run(ctx)
+		close(done)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for q.Stats().Queued > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if stats := q.Stats(); stats.Queued != 0 || stats.Recovered != 2 {
+		t.Errorf("stats = %+v, want both queued games recovered", stats)
+	}
+	if !store.stored(t, first) || !store.stored(t, second) {
+		t.Error("queued games not saved once the database came back")
+	}
+}
+
+func TestSaveQueueDropsOldestWhenFull(t *testing.T) {
+	q := NewSaveQueue(newFlakyStore(-1))
+	games := make([]*game.Game, maxQueuedSaves+2)
+	for i := range games {
+		games[i] = finishedGame(t)
+		q.enqueue(games[i])
+	}
+
+	if stats := q.Stats(); stats.Queued != maxQueuedSaves || stats.Dropped != 2 {
+		t.Errorf("stats = %+v, want %d queued and 2 dropped", stats, maxQueuedSaves)
+	}
+	if q.pending[0] != games[2] || q.pending[len(q.pending)-1] != games[len(games)-1] {
+		t.Error("the queue did not drop the oldest games")
+	}
+}
+
+func TestSaveQueueFlush(t *testing.T) {
+	store := newFlakyStore(-1)
+	q := NewSaveQueue(store)
+	games := []*game.Game{finishedGame(t), finishedGame(t), finishedGame(t)}
+	for _, g := range games {
+		q.enqueue(g)
+	}
+
+	// Still down at shutdown: everything is reported lost
+	if left := q.Flush(context.Background()); left != 3 {
+		t.Errorf("Flush with the database down left %d, want 3", left)
+	}
+
+	// Back for the final flush: the queue empties oldest first
+	store.setFailures(0)
+	if left := q.Flush(context.Background()); left != 0 {
+		t.Errorf("Flush left %d, want 0", left)
+	}
+	for i, g := range games {
+		if !store.stored(t, g) {
+			t.Errorf("game %d not saved by the flush", i)
+		}
+	}
+	if stats := q.Stats(); stats.Recovered != 3 {
+		t.Errorf("stats = %+v, want 3 recovered", stats)
+	}
+}
+
+func TestSaveQueueCancelledContextQueues(t *testing.T) {
+	store := newFlakyStore(-1)
+	q := NewSaveQueue(store)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Shutting down: no backoff, the game goes straight to the queue for
+	// the final flush
+	start := time.Now()
+	q.Save(ctx, finishedGame(t))
+	if elapsed := time.Since(start); elapsed >= saveRetryDelay {
+		t.Errorf("Save took %v with a cancelled context", elapsed)
+	}
+	if store.callCount() != 1 || q.Stats().Queued != 1 {
+		t.Errorf("%d attempts, %d queued; want 1 and 1", store.callCount(), q.Stats().Queued)
+	}
+}
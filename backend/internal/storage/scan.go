@@ -0,0 +1,258 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/connect-four/internal/game"
+	"github.com/jackc/pgx/v5"
+)
+
+// rowScanner is one result row; pgx and database/sql rows both satisfy it,
+// so the SQL backends share their scanning code
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// rowsScanner is a result set from pgx or database/sql
+type rowsScanner interface {
+	rowScanner
+	Next() bool
+	Err() error
+}
+
+// isNoRows reports whether err means a query returned no rows
+func isNoRows(err error) bool {
+	return errors.Is(err, pgx.ErrNoRows) || errors.Is(err, sql.ErrNoRows)
+}
+
+// scanCompletedGame reads a games row in CompletedGame field order and
+// parses its moves, returning ErrGameNotFound when there is no row
+func scanCompletedGame(row rowScanner) (*CompletedGame, error) {
+	var cg CompletedGame
+	var movesJSON []byte
+	err := row.Scan(
+		&cg.ID, &cg.Player1, &cg.Player2, &cg.Winner,
+		&cg.IsForfeit, &cg.IsDraw,
+		&cg.DurationSeconds, &cg.MoveCount, &movesJSON,
+		&cg.CreatedAt, &cg.EndedAt, &cg.Imported,
+		&cg.BotDifficulty, &cg.BotVersion,
+		&cg.Player1Emoji, &cg.Player2Emoji,
+		&cg.Result, &cg.ForfeitedBy,
+		&cg.StartedAt, &cg.WinType,
+		&cg.Rows, &cg.Columns, &cg.WinLength,
+	)
+	if isNoRows(err) {
+		return nil, ErrGameNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cg.Moves = string(movesJSON)
+	if err := json.Unmarshal(movesJSON, &cg.MoveList); err != nil {
+		return nil, fmt.Errorf("game %s has unreadable moves: %w", cg.ID, err)
+	}
+	return &cg, nil
+}
+
+// scanRecentGames reads games rows in RecentGame field order
+func scanRecentGames(rows rowsScanner) ([]RecentGame, error) {
+	var games []RecentGame
+	for rows.Next() {
+		var rg RecentGame
+		err := rows.Scan(&rg.ID, &rg.Player1, &rg.Player2, &rg.Winner, &rg.Result, &rg.WinType,
+			&rg.DurationSeconds, &rg.MoveCount, &rg.CreatedAt, &rg.EndedAt, &rg.BotDifficulty)
+		if err != nil {
+			return nil, err
+		}
+		games = append(games, rg)
+	}
+	return games, rows.Err()
+}
+
+// scanLeaderboard reads ranked leaderboard rows, best first
+func scanLeaderboard(rows rowsScanner) ([]LeaderboardEntry, error) {
+	var entries []LeaderboardEntry
+	for rows.Next() {
+		var entry LeaderboardEntry
+		err := rows.Scan(&entry.Username, &entry.Wins, &entry.Losses, &entry.Draws, &entry.Games, &entry.WinRate, &entry.Rating)
+		if err != nil {
+			return nil, err
+		}
+		entry.Rank = len(entries) + 1
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// scanPlayerStats reads a player's result counts; rating and history are
+// filled by the caller
+func scanPlayerStats(row rowScanner, username string) (*PlayerStats, error) {
+	stats := PlayerStats{Username: username}
+	err := row.Scan(
+		&stats.Wins,
+		&stats.Draws,
+		&stats.Losses,
+		&stats.TotalGames,
+		&stats.BotWins,
+		&stats.BotLosses,
+		&stats.Forfeits,
+		&stats.AvgGameLength,
+		&stats.AvgThinkMs,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if stats.TotalGames > 0 {
+		stats.WinRate = float64(stats.Wins) / float64(stats.TotalGames) * 100
+	}
+	return &stats, nil
+}
+
+// scanRatingHistory reads rating_history rows, newest first
+func scanRatingHistory(rows rowsScanner) ([]RatingChange, error) {
+	history := make([]RatingChange, 0, ratingHistoryLimit)
+	for rows.Next() {
+		var c RatingChange
+		if err := rows.Scan(&c.GameID, &c.Before, &c.After, &c.Opponent, &c.At); err != nil {
+			return nil, err
+		}
+		history = append(history, c)
+	}
+	return history, rows.Err()
+}
+
+// scanRatings reads (username, rating) rows into a map
+func scanRatings(rows rowsScanner) (map[string]int, error) {
+	current := make(map[string]int, 2)
+	for rows.Next() {
+		var username string
+		var rating int
+		if err := rows.Scan(&username, &rating); err != nil {
+			return nil, err
+		}
+		current[username] = rating
+	}
+	return current, rows.Err()
+}
+
+// scanHeadToHead reads (winner, is_draw, duration) rows, newest first
+func scanHeadToHead(rows rowsScanner, player, opponent string) (*HeadToHead, error) {
+	h2h := newHeadToHead(player, opponent)
+	for rows.Next() {
+		var winner string
+		var isDraw bool
+		var duration int
+		if err := rows.Scan(&winner, &isDraw, &duration); err != nil {
+			return nil, err
+		}
+		h2h.add(winner, isDraw, duration)
+	}
+	return h2h, rows.Err()
+}
+
+// scanAnalytics reads the single GetAnalytics row
+func scanAnalytics(row rowScanner) (*GameAnalytics, error) {
+	var analytics GameAnalytics
+	var mostFrequentWinner *string
+
+	err := row.Scan(
+		&analytics.TotalGames,
+		&analytics.TotalPlayers,
+		&analytics.AvgGameDuration,
+		&analytics.BotGamesPlayed,
+		&analytics.GamesToday,
+		&analytics.GamesThisHour,
+		&mostFrequentWinner,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if mostFrequentWinner != nil {
+		analytics.MostFrequentWinner = *mostFrequentWinner
+	}
+	return &analytics, nil
+}
+
+// scanWinTypeShares reads (win type, games) rows and computes the shares
+func scanWinTypeShares(rows rowsScanner) ([]WinTypeShare, error) {
+	shares := make([]WinTypeShare, 0)
+	for rows.Next() {
+		var share WinTypeShare
+		if err := rows.Scan(&share.WinType, &share.Games); err != nil {
+			return nil, err
+		}
+		shares = append(shares, share)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	computeWinTypePercents(shares)
+	return shares, nil
+}
+
+// scanWinTypeBackfill reads (id, moves, is_draw, is_forfeit, result,
+// board_rows, board_cols, win_length) rows
+// of unclassified games and returns the win type of each one that can be
+// classified, by game ID
+func scanWinTypeBackfill(rows rowsScanner) (map[string]game.WinType, error) {
+	classified := make(map[string]game.WinType)
+	for rows.Next() {
+		var id, result string
+		var movesJSON []byte
+		var isDraw, isForfeit bool
+		var size game.BoardSize
+		if err := rows.Scan(&id, &movesJSON, &isDraw, &isForfeit, &result, &size.Rows, &size.Columns, &size.WinLength); err != nil {
+			return nil, err
+		}
+		if winType := storedWinType(size, movesJSON, isDraw, isForfeit, result); winType != "" {
+			classified[id] = winType
+		}
+	}
+	return classified, rows.Err()
+}
+
+// scanHourlyAnalytics reads (hour, games, avg duration) rows
+func scanHourlyAnalytics(rows rowsScanner) ([]HourlyAnalytics, error) {
+	var result []HourlyAnalytics
+	for rows.Next() {
+		var b HourlyAnalytics
+		if err := rows.Scan(&b.Hour, &b.GamesPlayed, &b.AvgDurationSeconds); err != nil {
+			return nil, err
+		}
+		result = append(result, b)
+	}
+	return result, rows.Err()
+}
+
+// scanActiveGames reads active_games snapshot rows
+func scanActiveGames(rows rowsScanner) ([][]byte, error) {
+	var result [][]byte
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		result = append(result, data)
+	}
+	return result, rows.Err()
+}
+
+// scanBotVersionStats reads per-version bot results and computes the rates
+func scanBotVersionStats(rows rowsScanner) ([]BotVersionStats, error) {
+	var result []BotVersionStats
+	for rows.Next() {
+		var v BotVersionStats
+		if err := rows.Scan(&v.Version, &v.Games, &v.BotWins, &v.HumanWins, &v.Draws); err != nil {
+			return nil, err
+		}
+		v.computeRates()
+		result = append(result, v)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,854 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/connect-four/internal/game"
+	"github.com/google/uuid"
+	_ "modernc.org/sqlite" // registers the "sqlite" database/sql driver
+)
+
+// SQLiteStore keeps games in a local SQLite file, for development and demos
+// without a PostgreSQL server. It has the same schema and queries as
+// PostgresStore, adjusted for the SQLite dialect.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// sqliteTimeFormat is how timestamps are written: UTC with fixed-width
+// milliseconds, so comparing the text orders them and SQLite's date
+// functions can read them
+const sqliteTimeFormat = "2006-01-02 15:04:05.000"
+
+// sqliteTime formats t for a SQLite timestamp column or comparison
+func sqliteTime(t time.Time) string {
+	return t.UTC().Format(sqliteTimeFormat)
+}
+
+// sqlitePath returns the file named by a sqlite: database URL, e.g.
+// sqlite:connect_four.db or sqlite:///var/lib/connect-four/games.db
+func sqlitePath(dbURL string) (string, bool) {
+	rest, ok := strings.CutPrefix(dbURL, "sqlite:")
+	if !ok {
+		return "", false
+	}
+	return strings.TrimPrefix(rest, "//"), true
+}
+
+// openSQLite opens the database file at path, creating it if needed
+func openSQLite(path string) (*sql.DB, error) {
+	dsn := "file:" + path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_txlock=immediate"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("error opening SQLite database: %w", err)
+	}
+	// SQLite has one writer at a time; a single connection queues writes
+	// here instead of failing them with SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+	return db, nil
+}
+
+// NewSQLiteStore opens or creates the SQLite database at path and brings
+// its schema up to date
+func NewSQLiteStore(ctx context.Context, path string) (*SQLiteStore, error) {
+	db, err := openSQLite(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error opening SQLite database: %w", err)
+	}
+
+	if _, err := runMigrations(ctx, sqliteMigrator{db}); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error migrating schema: %w", err)
+	}
+
+	slog.Info("using SQLite database", "path", path)
+	return &SQLiteStore{db: db}, nil
+}
+
+// sqliteMigrator applies migrations to a SQLite database
+type sqliteMigrator struct {
+	db *sql.DB
+}
+
+func (m sqliteMigrator) currentVersion(ctx context.Context) (int, error) {
+	if _, err := m.db.ExecContext(ctx, schemaMigrationsTable); err != nil {
+		return 0, err
+	}
+	var current int
+	err := m.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current)
+	return current, err
+}
+
+func (m sqliteMigrator) apply(ctx context.Context, mig migration) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, mig.sqlite); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name) VALUES (?, ?)`, mig.version, mig.name)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// sqliteTimeDest lets a *time.Time scan the text SQLite returns for
+// computed timestamps; only plain TIMESTAMP columns come back as time.Time
+type sqliteTimeDest struct {
+	t *time.Time
+}
+
+func (d sqliteTimeDest) Scan(src any) error {
+	switch v := src.(type) {
+	case time.Time:
+		*d.t = v
+		return nil
+	case string:
+		for _, layout := range []string{"2006-01-02 15:04:05.999999999", "2006-01-02T15:04:05.999999999Z07:00", "2006-01-02"} {
+			if t, err := time.Parse(layout, v); err == nil {
+				*d.t = t
+				return nil
+			}
+		}
+		return fmt.Errorf("unreadable SQLite timestamp %q", v)
+	case nil:
+		*d.t = time.Time{}
+		return nil
+	}
+	return fmt.Errorf("unsupported SQLite timestamp type %T", src)
+}
+
+// sqliteRow wraps a row so the shared scanners can read timestamps
+type sqliteRow struct {
+	rowScanner
+}
+
+func (r sqliteRow) Scan(dest ...any) error {
+	for i, d := range dest {
+		if t, ok := d.(*time.Time); ok {
+			dest[i] = sqliteTimeDest{t}
+		}
+	}
+	return r.rowScanner.Scan(dest...)
+}
+
+// sqliteRows wraps a result set like sqliteRow
+type sqliteRows struct {
+	*sql.Rows
+}
+
+func (r sqliteRows) Scan(dest ...any) error {
+	return sqliteRow{r.Rows}.Scan(dest...)
+}
+
+// SaveGame stores a completed game
+func (s *SQLiteStore) SaveGame(ctx context.Context, g *game.Game) error {
+	if g.Simulated {
+		return nil // simulation games never reach the games table
+	}
+	state := g.GetState()
+
+	movesJSON, err := json.Marshal(g.Moves)
+	if err != nil {
+		movesJSON = []byte("[]")
+	}
+
+	isDraw := state.Result == string(game.ResultDraw)
+	isForfeit := state.Result == string(game.ResultForfeit)
+
+	// Draws have no winner; store NULL so the stats queries can tell them apart
+	var winner *string
+	if state.Winner != "" && !isDraw {
+		winner = &state.Winner
+	}
+
+	query := `
+		INSERT INTO games (id, player1, player2, winner, is_forfeit, is_draw,
+		                   duration_seconds, move_count, moves, created_at, ended_at, imported,
+		                   bot_difficulty, bot_version, player1_emoji, player2_emoji, result, forfeited_by,
+		                   started_at, win_type, avg_think_ms_p1, avg_think_ms_p2, board_rows, board_cols, win_length)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO NOTHING
+	`
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, query,
+		g.ID,
+		g.Player1.Username,
+		g.Player2.Username,
+		winner,
+		isForfeit,
+		isDraw,
+		g.GetDuration(),
+		len(g.Moves),
+		string(movesJSON),
+		sqliteTime(g.StartTime),
+		sqliteTime(g.EndTime),
+		g.Imported,
+		nullIfEmpty(state.BotDifficulty),
+		nullIfEmpty(state.BotVersion),
+		nullIfEmpty(state.Player1DiscEmoji),
+		nullIfEmpty(state.Player2DiscEmoji),
+		nullIfEmpty(state.Result),
+		nullIfEmpty(state.ForfeitedBy),
+		sqliteTime(g.PlayStart()),
+		nullIfEmpty(string(state.WinType)),
+		averageThinkMs(g, game.Player1),
+		averageThinkMs(g, game.Player2),
+		state.Rows,
+		state.Columns,
+		state.WinLength,
+	)
+	if err != nil {
+		return err
+	}
+
+	// Only rate the first save of a game so retries don't double-count
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 1 && isRated(g) {
+		if err := sqliteApplyRatings(ctx, tx, g.ID, state, g.EndTime); err != nil {
+			return err
+		}
+	}
+
+	// The finished row replaces the in-progress snapshot
+	if _, err := tx.ExecContext(ctx, `DELETE FROM active_games WHERE id = ?`, g.ID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// SaveActiveGame upserts an unfinished game's snapshot
+func (s *SQLiteStore) SaveActiveGame(ctx context.Context, gameID string, data []byte) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO active_games (id, data, updated_at) VALUES (?1, ?2, ?3)
+		ON CONFLICT (id) DO UPDATE SET data = excluded.data, updated_at = excluded.updated_at
+	`, gameID, data, sqliteTime(time.Now()))
+	return err
+}
+
+// DeleteActiveGame drops an unfinished game's snapshot
+func (s *SQLiteStore) DeleteActiveGame(ctx context.Context, gameID string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM active_games WHERE id = ?`, gameID)
+	return err
+}
+
+// LoadActiveGames returns the snapshots of games not yet in the games table
+func (s *SQLiteStore) LoadActiveGames(ctx context.Context) ([][]byte, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT a.data FROM active_games a
+		WHERE NOT EXISTS (SELECT 1 FROM games g WHERE g.id = a.id)
+		ORDER BY a.updated_at
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanActiveGames(rows)
+}
+
+// sqliteApplyRatings updates both players' Elo ratings and history within
+// tx. The transaction holds SQLite's write lock, so unlike PostgreSQL no
+// row locks are needed.
+func sqliteApplyRatings(ctx context.Context, tx *sql.Tx, gameID string, state *game.GameState, at time.Time) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO ratings (username, rating) VALUES (?1, ?3), (?2, ?3)
+		ON CONFLICT (username) DO NOTHING
+	`, state.Player1, state.Player2, DefaultRating)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT username, rating FROM ratings WHERE username IN (?, ?)`,
+		state.Player1, state.Player2)
+	if err != nil {
+		return err
+	}
+	current, err := scanRatings(rows)
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	before1, before2 := current[state.Player1], current[state.Player2]
+	after1, after2 := eloUpdate(before1, before2, player1Score(state))
+
+	for _, c := range []struct {
+		username, opponent string
+		before, after      int
+	}{
+		{state.Player1, state.Player2, before1, after1},
+		{state.Player2, state.Player1, before2, after2},
+	} {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE ratings SET rating = ?2, games = games + 1, updated_at = CURRENT_TIMESTAMP
+			WHERE username = ?1
+		`, c.username, c.after); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO rating_history (username, game_id, opponent, rating_before, rating_after, created_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, c.username, gameID, c.opponent, c.before, c.after, sqliteTime(at)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetRating returns a player's current Elo rating
+func (s *SQLiteStore) GetRating(ctx context.Context, username string) (int, error) {
+	var rating int
+	err := s.db.QueryRowContext(ctx, "SELECT rating FROM ratings WHERE username = ?", username).Scan(&rating)
+	if isNoRows(err) {
+		return DefaultRating, nil
+	}
+	return rating, err
+}
+
+// getRatingHistory returns a player's most recent rating changes, newest first
+func (s *SQLiteStore) getRatingHistory(ctx context.Context, username string) ([]RatingChange, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT game_id, rating_before, rating_after, opponent, created_at
+		FROM rating_history
+		WHERE username = ?
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?
+	`, username, ratingHistoryLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanRatingHistory(sqliteRows{rows})
+}
+
+// GetGameByID returns a completed game with its parsed move list
+func (s *SQLiteStore) GetGameByID(ctx context.Context, id string) (*CompletedGame, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrGameNotFound
+	}
+
+	query := `
+		SELECT id, player1, player2, COALESCE(winner, ''),
+		       COALESCE(is_forfeit, FALSE), COALESCE(is_draw, FALSE),
+		       COALESCE(duration_seconds, 0), COALESCE(move_count, 0), COALESCE(moves, '[]'),
+		       created_at, COALESCE(ended_at, created_at), COALESCE(imported, FALSE),
+		       COALESCE(bot_difficulty, ''), COALESCE(bot_version, ''),
+		       COALESCE(player1_emoji, ''), COALESCE(player2_emoji, ''),
+		       COALESCE(result, ''), COALESCE(forfeited_by, ''),
+		       COALESCE(started_at, created_at), COALESCE(win_type, ''),
+		       board_rows, board_cols, win_length
+		FROM games
+		WHERE id = ?
+	`
+
+	return scanCompletedGame(sqliteRow{s.db.QueryRowContext(ctx, query, id)})
+}
+
+// GetRecentGames returns a page of completed games, newest first
+func (s *SQLiteStore) GetRecentGames(ctx context.Context, opts RecentGamesOptions) ([]RecentGame, error) {
+	var before *string
+	if !opts.Before.IsZero() {
+		t := sqliteTime(opts.Before)
+		before = &t
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, player1, player2, COALESCE(winner, ''), COALESCE(result, ''), COALESCE(win_type, ''),
+		       COALESCE(duration_seconds, 0), COALESCE(move_count, 0),
+		       created_at, COALESCE(ended_at, created_at), COALESCE(bot_difficulty, '')
+		FROM games
+		WHERE (?1 IS NULL OR created_at < ?1 OR (created_at = ?1 AND id < ?4))
+		  AND (?2 OR 'BOT' NOT IN (player1, player2))
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?3
+	`, before, opts.IncludeBots, opts.Limit, opts.BeforeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanRecentGames(sqliteRows{rows})
+}
+
+// GetLeaderboard returns the top players by wins
+func (s *SQLiteStore) GetLeaderboard(ctx context.Context, opts LeaderboardOptions) ([]LeaderboardEntry, error) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+
+	orderBy := "wins DESC, win_rate DESC"
+	if opts.Sort == SortByRating {
+		orderBy = "rating DESC, wins DESC, win_rate DESC"
+	}
+
+	query := `
+		WITH player_stats AS (
+			SELECT
+				username,
+				COUNT(*) FILTER (WHERE winner = username) as wins,
+				COUNT(*) FILTER (WHERE is_draw OR winner IS NULL) as draws,
+				COUNT(*) FILTER (WHERE winner != username AND NOT is_draw) as losses,
+				COUNT(*) as games
+			FROM (
+				SELECT player1 as username, NULLIF(winner, '') as winner, COALESCE(is_draw, FALSE) as is_draw
+				FROM games WHERE player1 != 'BOT' AND NOT imported AND COALESCE(result, '') != 'aborted' AND (?2 IS NULL OR ended_at >= ?2)
+				  AND (?3 OR player2 != 'BOT')
+				UNION ALL
+				SELECT player2 as username, NULLIF(winner, '') as winner, COALESCE(is_draw, FALSE) as is_draw
+				FROM games WHERE player2 != 'BOT' AND NOT imported AND COALESCE(result, '') != 'aborted' AND (?2 IS NULL OR ended_at >= ?2)
+				  AND (?3 OR player1 != 'BOT')
+			) subq
+			GROUP BY username
+		)
+		SELECT
+			ps.username, wins, losses, draws, ps.games,
+			CASE WHEN ps.games > 0 THEN ROUND(wins * 100.0 / ps.games, 1) ELSE 0 END as win_rate,
+			COALESCE(r.rating, ` + fmt.Sprint(DefaultRating) + `) as rating
+		FROM player_stats ps
+		LEFT JOIN ratings r ON r.username = ps.username
+		ORDER BY ` + orderBy + `
+		LIMIT ?1
+	`
+
+	var since *string
+	if !opts.Since.IsZero() {
+		t := sqliteTime(opts.Since)
+		since = &t
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, limit, since, opts.IncludeBots)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanLeaderboard(rows)
+}
+
+// GetPlayerStats returns detailed statistics for a player
+func (s *SQLiteStore) GetPlayerStats(ctx context.Context, username string) (*PlayerStats, error) {
+	query := `
+		WITH player_games AS (
+			SELECT
+				g.id,
+				NULLIF(g.winner, '') as winner,
+				COALESCE(g.is_draw, FALSE) as is_draw,
+				g.duration_seconds,
+				g.forfeited_by,
+				CASE
+					WHEN g.player1 = ?1 THEN g.player2
+					ELSE g.player1
+				END as opponent,
+				CASE
+					WHEN g.player1 = ?1 THEN g.avg_think_ms_p1
+					ELSE g.avg_think_ms_p2
+				END as think_ms
+			FROM games g
+			WHERE (g.player1 = ?1 OR g.player2 = ?1) AND COALESCE(g.result, '') != 'aborted'
+		)
+		SELECT
+			COUNT(*) FILTER (WHERE winner = ?1) as wins,
+			COUNT(*) FILTER (WHERE is_draw OR winner IS NULL) as draws,
+			COUNT(*) FILTER (WHERE winner != ?1 AND NOT is_draw) as losses,
+			COUNT(*) as total_games,
+			COUNT(*) FILTER (WHERE opponent = 'BOT' AND winner = ?1) as bot_wins,
+			COUNT(*) FILTER (WHERE opponent = 'BOT' AND winner != ?1 AND NOT is_draw) as bot_losses,
+			COUNT(*) FILTER (WHERE forfeited_by = ?1) as forfeits,
+			COALESCE(AVG(duration_seconds), 0) as avg_game_length,
+			COALESCE(AVG(think_ms), 0) as avg_think_ms
+		FROM player_games
+	`
+
+	stats, err := scanPlayerStats(s.db.QueryRowContext(ctx, query, username), username)
+	if err != nil {
+		return nil, err
+	}
+
+	if stats.Rating, err = s.GetRating(ctx, username); err != nil {
+		return nil, err
+	}
+	if stats.RatingHistory, err = s.getRatingHistory(ctx, username); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// GetHeadToHead returns the record between two players
+func (s *SQLiteStore) GetHeadToHead(ctx context.Context, player, opponent string) (*HeadToHead, error) {
+	query := `
+		SELECT COALESCE(winner, ''), COALESCE(is_draw, FALSE), COALESCE(duration_seconds, 0)
+		FROM games
+		WHERE (player1 = ?1 AND player2 = ?2) OR (player1 = ?2 AND player2 = ?1)
+		ORDER BY COALESCE(ended_at, created_at) DESC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, player, opponent)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanHeadToHead(rows, player, opponent)
+}
+
+// sqliteBucketStart is the start of a game_analytics row's hour as SQLite
+// timestamp text
+const sqliteBucketStart = `datetime(date, printf('+%d hours', hour))`
+
+// GetAnalytics returns aggregated game analytics, from the hourly buckets
+// plus the games since the last rollup like PostgresStore
+func (s *SQLiteStore) GetAnalytics(ctx context.Context) (*GameAnalytics, error) {
+	now := time.Now()
+	today := now.Truncate(24 * time.Hour)
+	thisHour := now.Truncate(time.Hour)
+
+	query := `
+		WITH rolled AS (
+			SELECT COALESCE(datetime(MAX(` + sqliteBucketStart + `), '+1 hour'), '') AS through
+			FROM game_analytics WHERE hour IS NOT NULL
+		), buckets AS (
+			SELECT
+				COALESCE(SUM(games_played), 0) AS games,
+				COALESCE(SUM(games_played * avg_duration_seconds), 0) AS duration,
+				COALESCE(SUM(bot_games), 0) AS bot_games,
+				COALESCE(SUM(games_played) FILTER (WHERE ` + sqliteBucketStart + ` >= datetime(?1)), 0) AS games_today
+			FROM game_analytics WHERE hour IS NOT NULL
+		), recent AS (
+			SELECT
+				COUNT(*) AS games,
+				COALESCE(SUM(duration_seconds), 0) AS duration,
+				COUNT(*) FILTER (WHERE 'BOT' IN (player1, player2)) AS bot_games,
+				COUNT(*) FILTER (WHERE created_at >= ?1) AS games_today,
+				COUNT(*) FILTER (WHERE created_at >= ?2) AS games_this_hour
+			FROM games, rolled WHERE created_at >= rolled.through
+		)
+		SELECT
+			b.games + r.games as total_games,
+			(SELECT COUNT(DISTINCT username) FROM (
+				SELECT player1 AS username FROM games UNION SELECT player2 FROM games
+			) WHERE username != 'BOT') as total_players,
+			CASE WHEN b.games + r.games > 0 THEN (b.duration + r.duration) * 1.0 / (b.games + r.games) ELSE 0 END as avg_duration,
+			b.bot_games + r.bot_games as bot_games,
+			b.games_today + r.games_today as games_today,
+			r.games_this_hour as games_this_hour,
+			(SELECT winner FROM games WHERE winner IS NOT NULL GROUP BY winner ORDER BY COUNT(*) DESC LIMIT 1) as most_frequent_winner
+		FROM buckets b, recent r
+	`
+
+	analytics, err := scanAnalytics(s.db.QueryRowContext(ctx, query, sqliteTime(today), sqliteTime(thisHour)))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, winTypeGroupQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	if analytics.WinTypes, err = scanWinTypeShares(rows); err != nil {
+		return nil, err
+	}
+	return analytics, nil
+}
+
+// BackfillWinTypes classifies games stored without a win type from their
+// moves and returns how many were updated
+func (s *SQLiteStore) BackfillWinTypes(ctx context.Context) (int64, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, COALESCE(moves, '[]'), COALESCE(is_draw, FALSE), COALESCE(is_forfeit, FALSE), COALESCE(result, ''),
+		       board_rows, board_cols, win_length
+		FROM games WHERE win_type IS NULL
+	`)
+	if err != nil {
+		return 0, err
+	}
+	// The single connection has to be free again before the updates
+	classified, err := scanWinTypeBackfill(rows)
+	rows.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var updated int64
+	for id, winType := range classified {
+		res, err := tx.ExecContext(ctx, `UPDATE games SET win_type = ?2 WHERE id = ?1 AND win_type IS NULL`, id, string(winType))
+		if err != nil {
+			return 0, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		updated += n
+	}
+	return updated, tx.Commit()
+}
+
+// RollupAnalytics upserts a game_analytics row for every finished hour
+// since the newest row minus analyticsRollupLookback, or since the first
+// game when there are none yet
+func (s *SQLiteStore) RollupAnalytics(ctx context.Context, now time.Time) error {
+	query := `
+		WITH RECURSIVE bounds AS (
+			SELECT COALESCE(
+				(SELECT datetime(MAX(` + sqliteBucketStart + `), ?2) FROM game_analytics WHERE hour IS NOT NULL),
+				(SELECT strftime('%Y-%m-%d %H:00:00', MIN(created_at)) FROM games)
+			) AS from_hour
+		), hours(h) AS (
+			SELECT from_hour FROM bounds WHERE from_hour < ?1
+			UNION ALL
+			SELECT datetime(h, '+1 hour') FROM hours WHERE datetime(h, '+1 hour') < ?1
+		)
+		INSERT INTO game_analytics (date, hour, games_played, avg_duration_seconds, bot_games)
+		SELECT
+			date(h),
+			CAST(strftime('%H', h) AS INTEGER),
+			COUNT(g.id),
+			COALESCE(AVG(g.duration_seconds), 0),
+			COUNT(g.id) FILTER (WHERE 'BOT' IN (g.player1, g.player2))
+		FROM hours
+		LEFT JOIN games g ON g.created_at >= h AND g.created_at < datetime(h, '+1 hour')
+		WHERE true
+		GROUP BY h
+		ON CONFLICT (date, hour) DO UPDATE SET
+			games_played = excluded.games_played,
+			avg_duration_seconds = excluded.avg_duration_seconds,
+			bot_games = excluded.bot_games
+	`
+
+	lookback := fmt.Sprintf("-%d hours", int(analyticsRollupLookback/time.Hour))
+	thisHour := now.UTC().Truncate(time.Hour).Format(time.DateTime)
+	_, err := s.db.ExecContext(ctx, query, thisHour, lookback)
+	return err
+}
+
+// CompareRollup returns the rolled up hours from since on whose
+// game_analytics row no longer counts the games table's games
+func (s *SQLiteStore) CompareRollup(ctx context.Context, since time.Time) ([]RollupMismatch, error) {
+	query := `
+		SELECT b.hour, b.games_played, COUNT(g.id)
+		FROM (
+			SELECT ` + sqliteBucketStart + ` AS hour, games_played
+			FROM game_analytics
+			WHERE hour IS NOT NULL AND ` + sqliteBucketStart + ` >= strftime('%Y-%m-%d %H:00:00', ?1)
+		) b
+		LEFT JOIN games g ON g.created_at >= b.hour AND g.created_at < datetime(b.hour, '+1 hour')
+		GROUP BY b.hour, b.games_played
+		HAVING b.games_played <> COUNT(g.id)
+		ORDER BY b.hour
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, sqliteTime(since))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanRollupMismatches(sqliteRows{rows})
+}
+
+// GetHourlyAnalytics returns games played per hour from since to now,
+// from the buckets for rolled up hours and the games table after them
+func (s *SQLiteStore) GetHourlyAnalytics(ctx context.Context, since, now time.Time) ([]HourlyAnalytics, error) {
+	query := `
+		WITH RECURSIVE rolled AS (
+			SELECT COALESCE(datetime(MAX(` + sqliteBucketStart + `), '+1 hour'), '') AS through
+			FROM game_analytics WHERE hour IS NOT NULL
+		), hours(hour) AS (
+			SELECT strftime('%Y-%m-%d %H:00:00', ?1)
+			UNION ALL
+			SELECT datetime(hour, '+1 hour') FROM hours WHERE datetime(hour, '+1 hour') <= strftime('%Y-%m-%d %H:00:00', ?2)
+		)
+		SELECT
+			h.hour,
+			COALESCE(a.games_played, (
+				SELECT COUNT(*) FROM games g
+				WHERE h.hour >= rolled.through AND g.created_at >= h.hour AND g.created_at < datetime(h.hour, '+1 hour')
+			)),
+			COALESCE(a.avg_duration_seconds, (
+				SELECT AVG(duration_seconds) FROM games g
+				WHERE h.hour >= rolled.through AND g.created_at >= h.hour AND g.created_at < datetime(h.hour, '+1 hour')
+			), 0)
+		FROM hours h
+		CROSS JOIN rolled
+		LEFT JOIN game_analytics a
+			ON a.hour IS NOT NULL AND datetime(a.date, printf('+%d hours', a.hour)) = h.hour AND h.hour < rolled.through
+		ORDER BY h.hour
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, sqliteTime(since), sqliteTime(now))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanHourlyAnalytics(sqliteRows{rows})
+}
+
+// GetBotVersionStats returns bot game results grouped by bot engine version
+func (s *SQLiteStore) GetBotVersionStats(ctx context.Context) ([]BotVersionStats, error) {
+	query := `
+		SELECT
+			COALESCE(bot_version, 'unknown') as version,
+			COUNT(*) as games,
+			COUNT(*) FILTER (WHERE winner = 'BOT') as bot_wins,
+			COUNT(*) FILTER (WHERE winner IS NOT NULL AND winner != 'BOT') as human_wins,
+			COUNT(*) FILTER (WHERE is_draw OR winner IS NULL) as draws
+		FROM games
+		WHERE 'BOT' IN (player1, player2)
+		GROUP BY COALESCE(bot_version, 'unknown')
+		ORDER BY version
+	`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanBotVersionStats(rows)
+}
+
+// ClearAllGames deletes all games, analytics and ratings in a single
+// transaction and returns the number of games deleted
+func (s *SQLiteStore) ClearAllGames(ctx context.Context) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM games")
+	if err != nil {
+		return 0, err
+	}
+	for _, table := range []string{"game_analytics", "rating_history", "ratings"} {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ResolveUsername returns the canonical username of an existing player.
+// SQLite has no Unicode normalization, so lookalike names are folded here
+// rather than in the query.
+func (s *SQLiteStore) ResolveUsername(ctx context.Context, username string) (string, error) {
+	canonical := CanonicalUsername(username)
+	if canonical == "" {
+		return "", ErrUnknownPlayer
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT player1 FROM games UNION SELECT player2 FROM games`)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	folded := foldUsername(canonical)
+	suggestion := ""
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return "", err
+		}
+		if name == canonical {
+			return canonical, nil
+		}
+		if suggestion == "" && foldUsername(name) == folded {
+			suggestion = name
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	if suggestion != "" {
+		return "", &NearCollisionError{Requested: canonical, Suggestion: suggestion}
+	}
+	return "", ErrUnknownPlayer
+}
+
+// Backend returns the storage backend name
+func (s *SQLiteStore) Backend() string {
+	return "sqlite"
+}
+
+// SaveAnalyticsSnapshot stores an analytics snapshot and drops old ones
+func (s *SQLiteStore) SaveAnalyticsSnapshot(ctx context.Context, data []byte) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO analytics_snapshots (data) VALUES (?)`, data); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM analytics_snapshots
+		WHERE id NOT IN (SELECT id FROM analytics_snapshots ORDER BY id DESC LIMIT ?)
+	`, analyticsSnapshotsKept); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// LatestAnalyticsSnapshot returns the newest analytics snapshot, nil if none
+func (s *SQLiteStore) LatestAnalyticsSnapshot(ctx context.Context) ([]byte, error) {
+	var data []byte
+	err := s.db.QueryRowContext(ctx, `SELECT data FROM analytics_snapshots ORDER BY id DESC LIMIT 1`).Scan(&data)
+	if isNoRows(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// Ping checks the database file is still usable
+func (s *SQLiteStore) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+// Close closes the database
+func (s *SQLiteStore) Close() {
+	s.db.Close()
+}
@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/connect-four/internal/game"
+)
+
+// MemoryURL is the database URL that selects the in-memory store
+const MemoryURL = "memory"
+
+// Open connects to the store dbURL names: MemoryURL keeps games in memory,
+// a sqlite: URL opens (or creates) a SQLite file, anything else is a
+// PostgreSQL connection string
+func Open(ctx context.Context, dbURL string) (Store, error) {
+	if dbURL == MemoryURL {
+		return NewMemoryStore(), nil
+	}
+	if path, ok := sqlitePath(dbURL); ok {
+		store, err := NewSQLiteStore(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	}
+
+	store, err := NewPostgresStore(ctx, dbURL)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// Store is the persistence backend used by the API and game-end callbacks
+type Store interface {
+	// SaveGame stores a completed game
+	SaveGame(ctx context.Context, g *game.Game) error
+
+	// SaveActiveGame stores the serialized snapshot of an unfinished game,
+	// replacing its previous one. SaveGame deletes it once the game ends.
+	SaveActiveGame(ctx context.Context, gameID string, data []byte) error
+
+	// DeleteActiveGame drops an unfinished game's snapshot, if any
+	DeleteActiveGame(ctx context.Context, gameID string) error
+
+	// LoadActiveGames returns the snapshots of games that haven't been
+	// saved as finished, oldest first
+	LoadActiveGames(ctx context.Context) ([][]byte, error)
+
+	// GetGameByID returns a completed game with its parsed move list,
+	// or ErrGameNotFound
+	GetGameByID(ctx context.Context, id string) (*CompletedGame, error)
+
+	// GetRecentGames returns completed games, newest first by creation time
+	// and then ID, so paging with Before follows the created_at index and
+	// BeforeID breaks ties between games created at the same time
+	GetRecentGames(ctx context.Context, opts RecentGamesOptions) ([]RecentGame, error)
+
+	// GetLeaderboard returns the top players by wins or rating
+	GetLeaderboard(ctx context.Context, opts LeaderboardOptions) ([]LeaderboardEntry, error)
+
+	// GetRating returns a player's current Elo rating, DefaultRating if unrated.
+	// It is safe to call from the matchmaker as well as the API.
+	GetRating(ctx context.Context, username string) (int, error)
+
+	// GetPlayerStats returns detailed statistics for a player
+	GetPlayerStats(ctx context.Context, username string) (*PlayerStats, error)
+
+	// GetHeadToHead returns the record between two players; a pair that has
+	// never played gets a zero summary, not an error
+	GetHeadToHead(ctx context.Context, player, opponent string) (*HeadToHead, error)
+
+	// GetAnalytics returns aggregated game analytics
+	GetAnalytics(ctx context.Context) (*GameAnalytics, error)
+
+	// RollupAnalytics updates the hourly analytics buckets for the hours
+	// finished by now
+	RollupAnalytics(ctx context.Context, now time.Time) error
+
+	// GetHourlyAnalytics returns games played per hour from since to now,
+	// oldest first and including hours without games
+	GetHourlyAnalytics(ctx context.Context, since, now time.Time) ([]HourlyAnalytics, error)
+
+	// CompareRollup returns the rolled up hours from since on whose bucket
+	// no longer matches the games table, oldest first, or ErrNoRollup
+	CompareRollup(ctx context.Context, since time.Time) ([]RollupMismatch, error)
+
+	// GetBotVersionStats returns bot game results grouped by bot engine version
+	GetBotVersionStats(ctx context.Context) ([]BotVersionStats, error)
+
+	// BackfillWinTypes classifies games saved before the win type was
+	// recorded, from their moves, and returns how many were updated
+	BackfillWinTypes(ctx context.Context) (int64, error)
+
+	// ClearAllGames deletes all games and returns how many were deleted
+	ClearAllGames(ctx context.Context) (int64, error)
+
+	// ResolveUsername returns the canonical username of an existing player
+	ResolveUsername(ctx context.Context, username string) (string, error)
+
+	// SaveAnalyticsSnapshot stores a serialized snapshot of the Kafka
+	// analytics, keeping only the most recent few
+	SaveAnalyticsSnapshot(ctx context.Context, data []byte) error
+
+	// LatestAnalyticsSnapshot returns the most recent analytics snapshot,
+	// or nil if there is none
+	LatestAnalyticsSnapshot(ctx context.Context) ([]byte, error)
+
+	// Ping checks the backend is reachable
+	Ping(ctx context.Context) error
+
+	// Backend names the storage implementation (e.g. "postgres", "sqlite", "memory")
+	Backend() string
+
+	// Close releases any resources held by the store
+	Close()
+}
@@ -0,0 +1,788 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/connect-four/internal/game"
+)
+
+// forEachBackend runs fn against a fresh store of every backend. Postgres
+// runs only when TEST_DATABASE_URL names a database the tests may wipe.
+func forEachBackend(t *testing.T, fn func(t *testing.T, s Store)) {
+	backends := []struct {
+		name string
+		open func(t *testing.T) Store
+	}{
+		{"memory", func(t *testing.T) Store { return NewMemoryStore() }},
+		{"sqlite", func(t *testing.T) Store {
+			s, err := NewSQLiteStore(context.Background(), filepath.Join(t.TempDir(), "test.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return s
+		}},
+		{"postgres", func(t *testing.T) Store {
+			dsn := os.Getenv("TEST_DATABASE_URL")
+			if dsn == "" {
+				t.Skip("TEST_DATABASE_URL not set")
+			}
+			s, err := NewPostgresStore(context.Background(), dsn)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.ClearAllGames(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			return s
+		}},
+	}
+
+	for _, b := range backends {
+		t.Run(b.name, func(t *testing.T) {
+			s := b.open(t)
+			t.Cleanup(func() {
+				s.ClearAllGames(context.Background())
+				s.Close()
+			})
+			fn(t, s)
+		})
+	}
+}
+
+// testEpoch is a fixed hour start, so games land in known analytics
+// buckets; times are whole seconds, which every backend stores exactly
+var testEpoch = time.Now().UTC().Truncate(time.Hour).Add(-6 * time.Hour)
+
+// saveFinished stores a game between p1 and p2 played from notation,
+// created at start and lasting duration. It is saved as played here, not
+// imported, so it counts towards the leaderboard and ratings.
+func saveFinished(t *testing.T, s Store, p1, p2, notation string, start time.Time, duration time.Duration) *game.Game {
+	t.Helper()
+	moves, err := game.FromNotation(notation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := game.NewImportedGame(p1, p2, moves)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Imported = false
+	g.StartTime = start
+	g.PlayStartedAt = start
+	g.EndTime = start.Add(duration)
+	if err := s.SaveGame(context.Background(), g); err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+// Player1 wins with a vertical four in column 1
+const player1Wins = "1212121 1-0"
+
+// Player2 wins with a vertical four in column 2
+const player2Wins = "12123232 0-1"
+
+func TestStoreSaveAndGetGame(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		g := saveFinished(t, s, "alice", "bob", player1Wins, testEpoch, 90*time.Second)
+
+		got, err := s.GetGameByID(ctx, g.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Player1 != "alice" || got.Player2 != "bob" || got.Winner != "alice" {
+			t.Errorf("players %q vs %q, winner %q", got.Player1, got.Player2, got.Winner)
+		}
+		if got.IsDraw || got.IsForfeit {
+			t.Errorf("isDraw = %v, isForfeit = %v", got.IsDraw, got.IsForfeit)
+		}
+		if got.MoveCount != 7 || len(got.MoveList) != 7 {
+			t.Errorf("moveCount = %d, moves parsed = %d, want 7", got.MoveCount, len(got.MoveList))
+		}
+		if got.DurationSeconds != 90 {
+			t.Errorf("duration = %d, want 90", got.DurationSeconds)
+		}
+		if !got.CreatedAt.Equal(testEpoch) {
+			t.Errorf("createdAt = %v, want %v", got.CreatedAt, testEpoch)
+		}
+		if game.ToNotation(got.MoveList) != player1Wins {
+			t.Errorf("moves = %q, want %q", game.ToNotation(got.MoveList), player1Wins)
+		}
+
+		// Saving again must not duplicate the game
+		if err := s.SaveGame(ctx, g); err != nil {
+			t.Fatal(err)
+		}
+		recent, err := s.GetRecentGames(ctx, RecentGamesOptions{Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(recent) != 1 {
+			t.Errorf("%d games after saving twice, want 1", len(recent))
+		}
+
+		if _, err := s.GetGameByID(ctx, "no-such-game"); !errors.Is(err, ErrGameNotFound) {
+			t.Errorf("unknown game: err = %v, want ErrGameNotFound", err)
+		}
+	})
+}
+
+func TestStoreDurationExcludesWaiting(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		moves, err := game.FromNotation(player1Wins)
+		if err != nil {
+			t.Fatal(err)
+		}
+		g, err := game.NewImportedGame("alice", "bob", moves)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Queued for 20 seconds before bob arrived
+		g.StartTime = testEpoch
+		g.PlayStartedAt = testEpoch.Add(20 * time.Second)
+		g.EndTime = testEpoch.Add(time.Minute)
+		if err := s.SaveGame(ctx, g); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := s.GetGameByID(ctx, g.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.DurationSeconds != 40 {
+			t.Errorf("duration = %ds, want 40s of play", got.DurationSeconds)
+		}
+		if !got.StartedAt.Equal(g.PlayStartedAt) || !got.CreatedAt.Equal(testEpoch) {
+			t.Errorf("startedAt %v, createdAt %v; want play start %v and creation %v", got.StartedAt, got.CreatedAt, g.PlayStartedAt, testEpoch)
+		}
+	})
+}
+
+func TestStoreRecentGames(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		var ids []string
+		for i := 0; i < 5; i++ {
+			g := saveFinished(t, s, "alice", "bob", player1Wins, testEpoch.Add(time.Duration(i)*time.Minute), time.Minute)
+			ids = append(ids, g.ID)
+		}
+		saveFinished(t, s, "alice", "BOT", player1Wins, testEpoch.Add(10*time.Minute), time.Minute)
+
+		first, err := s.GetRecentGames(ctx, RecentGamesOptions{Limit: 3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{ids[4], ids[3], ids[2]}
+		if got := recentIDs(first); !equalStrings(got, want) {
+			t.Fatalf("first page = %v, want %v", got, want)
+		}
+
+		next, err := s.GetRecentGames(ctx, RecentGamesOptions{Limit: 3, Before: first[len(first)-1].CreatedAt})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want = []string{ids[1], ids[0]}
+		if got := recentIDs(next); !equalStrings(got, want) {
+			t.Errorf("second page = %v, want %v", got, want)
+		}
+
+		withBots, err := s.GetRecentGames(ctx, RecentGamesOptions{Limit: 10, IncludeBots: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(withBots) != 6 || withBots[0].Player2 != "BOT" {
+			t.Errorf("with bots: %d games, newest against %q; want 6, newest against BOT", len(withBots), withBots[0].Player2)
+		}
+	})
+}
+
+func TestStoreRecentGamesSameCreationTime(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		saved := make(map[string]bool)
+		for i := 0; i < 5; i++ {
+			g := saveFinished(t, s, "alice", "bob", player1Wins, testEpoch, time.Minute)
+			saved[g.ID] = true
+		}
+		older := saveFinished(t, s, "alice", "bob", player1Wins, testEpoch.Add(-time.Minute), time.Minute)
+
+		// Every page but the last ends on a game created at testEpoch, so
+		// the ID has to carry the rest of the boundary
+		var seen []string
+		opts := RecentGamesOptions{Limit: 2}
+		for page := 0; page < 5; page++ {
+			games, err := s.GetRecentGames(ctx, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			seen = append(seen, recentIDs(games)...)
+			if len(games) < opts.Limit {
+				break
+			}
+			last := games[len(games)-1]
+			opts.Before, opts.BeforeID = last.CreatedAt, last.ID
+		}
+
+		if len(seen) != 6 || seen[5] != older.ID {
+			t.Fatalf("paged through %v, want the 5 tied games then %s", seen, older.ID)
+		}
+		for _, id := range seen[:5] {
+			if !saved[id] {
+				t.Errorf("game %s came before the tied games or twice", id)
+			}
+			delete(saved, id)
+		}
+	})
+}
+
+func recentIDs(games []RecentGame) []string {
+	ids := make([]string, len(games))
+	for i, g := range games {
+		ids[i] = g.ID
+	}
+	return ids
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestStoreLeaderboardAndStats(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		saveFinished(t, s, "alice", "bob", player1Wins, testEpoch, time.Minute)
+		saveFinished(t, s, "alice", "bob", player1Wins, testEpoch.Add(time.Minute), 2*time.Minute)
+		saveFinished(t, s, "carol", "alice", player1Wins, testEpoch.Add(2*time.Minute), 3*time.Minute)
+		saveFinished(t, s, "dave", "BOT", player1Wins, testEpoch.Add(3*time.Minute), time.Minute)
+
+		board, err := s.GetLeaderboard(ctx, LeaderboardOptions{Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(board) == 0 || board[0].Username != "alice" || board[0].Wins != 2 || board[0].Losses != 1 {
+			t.Fatalf("leaderboard = %+v, want alice first with 2 wins and 1 loss", board)
+		}
+		for _, e := range board {
+			if e.Username == "dave" || e.Username == "BOT" {
+				t.Errorf("bot game counted without IncludeBots: %+v", e)
+			}
+		}
+
+		withBots, err := s.GetLeaderboard(ctx, LeaderboardOptions{Limit: 10, IncludeBots: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !hasPlayer(withBots, "dave") {
+			t.Errorf("IncludeBots leaderboard %+v is missing dave", withBots)
+		}
+		if hasPlayer(withBots, "BOT") {
+			t.Errorf("the bot itself is ranked: %+v", withBots)
+		}
+
+		stats, err := s.GetPlayerStats(ctx, "alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Wins != 2 || stats.Losses != 1 || stats.Draws != 0 || stats.TotalGames != 3 {
+			t.Errorf("alice stats = %+v, want 2-1-0 over 3 games", stats)
+		}
+		if stats.AvgGameLength != 120 {
+			t.Errorf("alice avg game length = %v, want 120", stats.AvgGameLength)
+		}
+
+		dave, err := s.GetPlayerStats(ctx, "dave")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if dave.BotWins != 1 || dave.BotLosses != 0 {
+			t.Errorf("dave bot record = %d-%d, want 1-0", dave.BotWins, dave.BotLosses)
+		}
+	})
+}
+
+// TestStoreMixedHumanAndBotStats checks a player's bot results are split
+// out of their record however the bot game ended, and only count on the
+// leaderboard with IncludeBots
+func TestStoreMixedHumanAndBotStats(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		saveFinished(t, s, "alice", "bob", player1Wins, testEpoch, time.Minute)
+		saveFinished(t, s, "carol", "alice", player1Wins, testEpoch.Add(time.Minute), time.Minute)
+		saveBotGame(t, s, "alice", game.DifficultyEasy, player1Wins, testEpoch.Add(2*time.Minute))
+		saveBotGame(t, s, "alice", game.DifficultyHard, player2Wins, testEpoch.Add(3*time.Minute))
+		// The bot moving first and winning, and alice forfeiting to it
+		saveFinished(t, s, "BOT", "alice", player1Wins, testEpoch.Add(4*time.Minute), time.Minute)
+		saveForfeit(t, s, "alice", "BOT", game.Player1, testEpoch.Add(5*time.Minute))
+		saveFinished(t, s, "alice", "BOT", drawnGame, testEpoch.Add(6*time.Minute), time.Minute)
+
+		stats, err := s.GetPlayerStats(ctx, "alice")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Wins != 2 || stats.Losses != 4 || stats.Draws != 1 || stats.TotalGames != 7 {
+			t.Errorf("alice = %d-%d-%d over %d games, want 2-4-1 over 7", stats.Wins, stats.Losses, stats.Draws, stats.TotalGames)
+		}
+		if stats.BotWins != 1 || stats.BotLosses != 3 {
+			t.Errorf("alice bot record = %d-%d, want 1-3", stats.BotWins, stats.BotLosses)
+		}
+		if stats.Forfeits != 1 {
+			t.Errorf("alice forfeits = %d, want 1", stats.Forfeits)
+		}
+
+		humans, err := s.GetLeaderboard(ctx, LeaderboardOptions{Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		withBots, err := s.GetLeaderboard(ctx, LeaderboardOptions{Limit: 10, IncludeBots: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tt := range []struct {
+			name  string
+			board []LeaderboardEntry
+			want  [3]int
+		}{
+			{"humans only", humans, [3]int{1, 1, 0}},
+			{"with bots", withBots, [3]int{2, 4, 1}},
+		} {
+			var alice *LeaderboardEntry
+			for i := range tt.board {
+				if tt.board[i].Username == "alice" {
+					alice = &tt.board[i]
+				}
+			}
+			if alice == nil {
+				t.Errorf("%s: alice missing from %+v", tt.name, tt.board)
+				continue
+			}
+			if got := [3]int{alice.Wins, alice.Losses, alice.Draws}; got != tt.want {
+				t.Errorf("%s: alice = %v, want %v", tt.name, got, tt.want)
+			}
+			if hasPlayer(tt.board, "BOT") {
+				t.Errorf("%s: the bot is ranked", tt.name)
+			}
+		}
+	})
+}
+
+// drawnGame fills the board without either player getting four in a row
+const drawnGame = "211141121324223243433434655665655777577676 1/2"
+
+// saveForfeit stores a game between p1 and p2 that loser forfeited after
+// a few moves
+func saveForfeit(t *testing.T, s Store, p1, p2 string, loser int, start time.Time) *game.Game {
+	t.Helper()
+	moves, err := game.FromNotation("4455")
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := game.NewImportedGame(p1, p2, moves)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Imported = false
+	g.Forfeit(loser)
+	g.StartTime, g.PlayStartedAt, g.EndTime = start, start, start.Add(time.Minute)
+	if err := s.SaveGame(context.Background(), g); err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func TestStoreDrawsForfeitsAndWins(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		draw := saveFinished(t, s, "alice", "bob", drawnGame, testEpoch, time.Minute)
+		saveForfeit(t, s, "carol", "alice", game.Player1, testEpoch.Add(time.Minute))
+		saveFinished(t, s, "alice", "bob", player1Wins, testEpoch.Add(2*time.Minute), time.Minute)
+
+		got, err := s.GetGameByID(ctx, draw.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.IsDraw || got.Winner != "" {
+			t.Errorf("draw stored as isDraw = %v, winner %q", got.IsDraw, got.Winner)
+		}
+
+		want := map[string][4]int{ // wins, losses, draws, forfeits
+			"alice": {2, 0, 1, 0},
+			"bob":   {0, 1, 1, 0},
+			"carol": {0, 1, 0, 1},
+		}
+		for name, w := range want {
+			stats, err := s.GetPlayerStats(ctx, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := [4]int{stats.Wins, stats.Losses, stats.Draws, stats.Forfeits}; got != w {
+				t.Errorf("%s wins, losses, draws, forfeits = %v, want %v", name, got, w)
+			}
+		}
+
+		board, err := s.GetLeaderboard(ctx, LeaderboardOptions{Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range board {
+			w, ok := want[e.Username]
+			if !ok {
+				t.Errorf("unexpected leaderboard entry %+v", e)
+				continue
+			}
+			if e.Wins != w[0] || e.Losses != w[1] || e.Draws != w[2] || e.Games != w[0]+w[1]+w[2] {
+				t.Errorf("leaderboard %s = %d-%d-%d over %d games, want %v", e.Username, e.Wins, e.Losses, e.Draws, e.Games, w[:3])
+			}
+		}
+		if len(board) == 0 || board[0].Username != "alice" {
+			t.Errorf("leaderboard = %+v, want alice first", board)
+		}
+	})
+}
+
+// TestSQLiteEmptyWinnerIsADraw checks rows written before draws stored a
+// NULL winner still count as draws, not losses
+func TestSQLiteEmptyWinnerIsADraw(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewSQLiteStore(ctx, filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	g := saveFinished(t, s, "alice", "bob", drawnGame, testEpoch, time.Minute)
+	if _, err := s.db.ExecContext(ctx, `UPDATE games SET winner = '' WHERE id = ?`, g.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"alice", "bob"} {
+		stats, err := s.GetPlayerStats(ctx, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Draws != 1 || stats.Losses != 0 {
+			t.Errorf("%s: %d draws, %d losses; want the old row counted as a draw", name, stats.Draws, stats.Losses)
+		}
+	}
+}
+
+func TestStoreLeaderboardPeriods(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		now := time.Now().UTC().Truncate(time.Second)
+		day := 24 * time.Hour
+		// Each game ends a minute after it starts. Wins land in both halves
+		// of the union: carol and erin win as player 2.
+		saveFinished(t, s, "alice", "bob", player1Wins, now.Add(-2*time.Hour-time.Minute), time.Minute)
+		saveFinished(t, s, "bob", "carol", player2Wins, now.Add(-3*day-time.Minute), time.Minute)
+		saveFinished(t, s, "dave", "erin", player2Wins, now.Add(-20*day-time.Minute), time.Minute)
+		saveFinished(t, s, "frank", "alice", player1Wins, now.Add(-60*day-time.Minute), time.Minute)
+
+		records := func(since time.Time) map[string]int {
+			t.Helper()
+			board, err := s.GetLeaderboard(ctx, LeaderboardOptions{Limit: 10, Since: since})
+			if err != nil {
+				t.Fatal(err)
+			}
+			games := make(map[string]int)
+			for _, e := range board {
+				games[e.Username] = e.Wins*100 + e.Losses
+			}
+			return games
+		}
+
+		tests := []struct {
+			name  string
+			since time.Time
+			want  map[string]int // wins*100 + losses
+		}{
+			{"day", now.Add(-day), map[string]int{"alice": 100, "bob": 1}},
+			{"week", now.Add(-7 * day), map[string]int{"alice": 100, "bob": 2, "carol": 100}},
+			{"month", now.Add(-30 * day), map[string]int{"alice": 100, "bob": 2, "carol": 100, "dave": 1, "erin": 100}},
+			{"all", time.Time{}, map[string]int{"alice": 101, "bob": 2, "carol": 100, "dave": 1, "erin": 100, "frank": 100}},
+			// The cutoff is inclusive of a game ending exactly on it
+			{"at an end time", now.Add(-3 * day), map[string]int{"alice": 100, "bob": 2, "carol": 100}},
+			{"just after an end time", now.Add(-3*day + time.Second), map[string]int{"alice": 100, "bob": 1}},
+		}
+		for _, tt := range tests {
+			got := records(tt.since)
+			if len(got) != len(tt.want) {
+				t.Errorf("%s: leaderboard %v, want %v", tt.name, got, tt.want)
+				continue
+			}
+			for name, w := range tt.want {
+				if got[name] != w {
+					t.Errorf("%s: leaderboard %v, want %v", tt.name, got, tt.want)
+					break
+				}
+			}
+		}
+	})
+}
+
+func hasPlayer(board []LeaderboardEntry, username string) bool {
+	for _, e := range board {
+		if e.Username == username {
+			return true
+		}
+	}
+	return false
+}
+
+func TestStoreHeadToHead(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		saveFinished(t, s, "alice", "bob", player2Wins, testEpoch, time.Minute)
+		saveFinished(t, s, "alice", "bob", player1Wins, testEpoch.Add(time.Minute), time.Minute)
+		saveFinished(t, s, "bob", "alice", player2Wins, testEpoch.Add(2*time.Minute), 4*time.Minute)
+
+		h, err := s.GetHeadToHead(ctx, "alice", "bob")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if h.Games != 3 || h.PlayerWins != 2 || h.OpponentWins != 1 || h.Draws != 0 {
+			t.Errorf("alice vs bob = %+v, want 3 games, 2-1", h)
+		}
+		if h.StreakHolder != "alice" || h.StreakLength != 2 {
+			t.Errorf("streak = %q x%d, want alice x2", h.StreakHolder, h.StreakLength)
+		}
+		if h.AvgGameDuration != 120 {
+			t.Errorf("avg duration = %v, want 120", h.AvgGameDuration)
+		}
+
+		none, err := s.GetHeadToHead(ctx, "alice", "carol")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if none.Games != 0 || none.StreakLength != 0 {
+			t.Errorf("pair that never played = %+v, want a zero summary", none)
+		}
+	})
+}
+
+// saveBotGame stores a game between human, moving first, and a bot of the
+// given difficulty played from notation
+func saveBotGame(t *testing.T, s Store, human string, difficulty game.Difficulty, notation string, start time.Time) *game.Game {
+	t.Helper()
+	moves, err := game.FromNotation(notation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := game.NewGame(human)
+	g.AddBot(difficulty)
+	if err := g.SetFirstPlayer(game.Player1); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range moves {
+		if _, err := g.MakeMove(m.PlayerNum, m.Column); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g.StartTime = start
+	g.PlayStartedAt = start
+	g.EndTime = start.Add(time.Minute)
+	if err := s.SaveGame(context.Background(), g); err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+func TestStoreBotVersionStats(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		easy := saveBotGame(t, s, "alice", game.DifficultyEasy, player1Wins, testEpoch).GetState().BotVersion
+		saveBotGame(t, s, "bob", game.DifficultyEasy, player1Wins, testEpoch.Add(time.Minute))
+		saveBotGame(t, s, "carol", game.DifficultyEasy, player2Wins, testEpoch.Add(2*time.Minute))
+		hard := saveBotGame(t, s, "alice", game.DifficultyHard, player2Wins, testEpoch.Add(3*time.Minute)).GetState().BotVersion
+		saveFinished(t, s, "alice", "bob", player1Wins, testEpoch.Add(4*time.Minute), time.Minute)
+		if easy == hard {
+			t.Fatalf("easy and hard bots share version %q", easy)
+		}
+
+		stats, err := s.GetBotVersionStats(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		byVersion := make(map[string]BotVersionStats)
+		for _, v := range stats {
+			byVersion[v.Version] = v
+		}
+		if len(stats) != 2 {
+			t.Fatalf("stats = %+v, want one row per bot version", stats)
+		}
+
+		e := byVersion[easy]
+		if e.Games != 3 || e.HumanWins != 2 || e.BotWins != 1 || e.Draws != 0 {
+			t.Errorf("%s = %+v, want 3 games, 2 human wins, 1 bot win", easy, e)
+		}
+		if e.HumanWinRate < 66.6 || e.HumanWinRate > 66.7 {
+			t.Errorf("%s human win rate = %v, want 66.7", easy, e.HumanWinRate)
+		}
+		h := byVersion[hard]
+		if h.Games != 1 || h.BotWins != 1 || h.BotWinRate != 100 {
+			t.Errorf("%s = %+v, want 1 game won by the bot", hard, h)
+		}
+	})
+}
+
+func TestStoreResolveUsername(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		saveFinished(t, s, "alice", "Bob", player1Wins, testEpoch, time.Minute)
+
+		if got, err := s.ResolveUsername(ctx, " alice "); err != nil || got != "alice" {
+			t.Errorf("ResolveUsername(alice) = %q, %v", got, err)
+		}
+
+		_, err := s.ResolveUsername(ctx, "bob")
+		var near *NearCollisionError
+		if !errors.As(err, &near) || near.Suggestion != "Bob" {
+			t.Errorf("ResolveUsername(bob) err = %v, want a near collision suggesting Bob", err)
+		}
+		if !errors.Is(err, ErrUnknownPlayer) {
+			t.Errorf("near collision does not match ErrUnknownPlayer")
+		}
+
+		for _, name := range []string{"nobody", ""} {
+			if _, err := s.ResolveUsername(ctx, name); !errors.Is(err, ErrUnknownPlayer) || errors.As(err, &near) {
+				t.Errorf("ResolveUsername(%q) err = %v, want ErrUnknownPlayer", name, err)
+			}
+		}
+	})
+}
+
+func TestStoreResolveUsernameNormalization(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		saveFinished(t, s, "Jos\u00e9", "Carol", player1Wins, testEpoch, time.Minute)
+
+		// A decomposed accent is the same name once composed
+		if got, err := s.ResolveUsername(ctx, "Jose\u0301"); err != nil || got != "Jos\u00e9" {
+			t.Errorf("decomposed Jos\u00e9 resolves to %q, %v", got, err)
+		}
+
+		// Lookalikes only match as a near collision, never as the player
+		for _, name := range []string{"JOS\u00c9", "jose\u0301", "\uff23\uff41\uff52\uff4f\uff4c", "CAROL"} {
+			_, err := s.ResolveUsername(ctx, name)
+			var near *NearCollisionError
+			if !errors.As(err, &near) {
+				t.Errorf("ResolveUsername(%q) err = %v, want a near collision", name, err)
+				continue
+			}
+			if near.Suggestion != "Jos\u00e9" && near.Suggestion != "Carol" {
+				t.Errorf("ResolveUsername(%q) suggests %q", name, near.Suggestion)
+			}
+		}
+	})
+}
+
+func TestStoreActiveGames(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		g := saveFinished(t, s, "alice", "bob", player1Wins, testEpoch, time.Minute)
+
+		first := []byte(`{"id":"conformance-active-1"}`)
+		second := []byte(`{"id":"conformance-active-2"}`)
+		if err := s.SaveActiveGame(ctx, "conformance-active-1", []byte(`{"stale":true}`)); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SaveActiveGame(ctx, "conformance-active-1", first); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SaveActiveGame(ctx, "conformance-active-2", second); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.SaveActiveGame(ctx, g.ID, []byte(`{"finished":true}`)); err != nil {
+			t.Fatal(err)
+		}
+		// Finishing a game drops its snapshot
+		if err := s.SaveGame(ctx, g); err != nil {
+			t.Fatal(err)
+		}
+
+		loaded, err := s.LoadActiveGames(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !containsSnapshot(loaded, first) || !containsSnapshot(loaded, second) {
+			t.Errorf("loaded %q, want both snapshots", loaded)
+		}
+		if containsSnapshot(loaded, []byte(`{"stale":true}`)) || containsSnapshot(loaded, []byte(`{"finished":true}`)) {
+			t.Errorf("loaded %q, want no replaced or finished snapshots", loaded)
+		}
+
+		for _, id := range []string{"conformance-active-1", "conformance-active-2", "no-such-game"} {
+			if err := s.DeleteActiveGame(ctx, id); err != nil {
+				t.Fatal(err)
+			}
+		}
+		loaded, err = s.LoadActiveGames(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if containsSnapshot(loaded, first) || containsSnapshot(loaded, second) {
+			t.Errorf("snapshots left after delete: %q", loaded)
+		}
+	})
+}
+
+func containsSnapshot(snapshots [][]byte, want []byte) bool {
+	for _, s := range snapshots {
+		if bytes.Equal(s, want) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestStoreClearAllGames(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, s Store) {
+		ctx := context.Background()
+		saveFinished(t, s, "alice", "bob", player1Wins, testEpoch, time.Minute)
+		g := saveFinished(t, s, "carol", "dave", player1Wins, testEpoch.Add(time.Minute), time.Minute)
+		if err := s.RollupAnalytics(ctx, testEpoch.Add(2*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+
+		deleted, err := s.ClearAllGames(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if deleted != 2 {
+			t.Errorf("deleted %d games, want 2", deleted)
+		}
+
+		if _, err := s.GetGameByID(ctx, g.ID); !errors.Is(err, ErrGameNotFound) {
+			t.Errorf("game after clear: err = %v, want ErrGameNotFound", err)
+		}
+		if _, err := s.ResolveUsername(ctx, "alice"); !errors.Is(err, ErrUnknownPlayer) {
+			t.Errorf("player after clear: err = %v, want ErrUnknownPlayer", err)
+		}
+		if rating, err := s.GetRating(ctx, "alice"); err != nil || rating != DefaultRating {
+			t.Errorf("rating after clear = %d, %v; want %d", rating, err, DefaultRating)
+		}
+		hours, err := s.GetHourlyAnalytics(ctx, testEpoch, testEpoch.Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, h := range hours {
+			if h.GamesPlayed != 0 {
+				t.Errorf("hour %v still has %d games after clear", h.Hour, h.GamesPlayed)
+			}
+		}
+	})
+}
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"encoding/json"
+
+	"github.com/connect-four/internal/game"
+)
+
+// winTypeGroupQuery counts classified games per win type, most common
+// first; the SQL backends share it
+const winTypeGroupQuery = `
+	SELECT win_type, COUNT(*) FROM games
+	WHERE win_type IS NOT NULL
+	GROUP BY win_type
+	ORDER BY COUNT(*) DESC, win_type
+`
+
+// storedWinType classifies a stored game for BackfillWinTypes: draws and
+// forfeits by their flags, board wins by replaying the moves. Aborted and
+// unreadable games give "".
+func storedWinType(size game.BoardSize, movesJSON []byte, isDraw, isForfeit bool, result string) game.WinType {
+	switch {
+	case isDraw:
+		return game.WinDraw
+	case isForfeit || result == string(game.ResultResign) || result == string(game.ResultAbandoned):
+		return game.WinForfeit
+	case result == string(game.ResultAborted):
+		return ""
+	}
+
+	var moves []game.Move
+	if err := json.Unmarshal(movesJSON, &moves); err != nil {
+		return ""
+	}
+	return game.WinTypeOfMoves(size, moves)
+}
+
+// computeWinTypePercents fills in each share's percentage of the total
+func computeWinTypePercents(shares []WinTypeShare) {
+	total := 0
+	for _, share := range shares {
+		total += share.Games
+	}
+	if total == 0 {
+		return
+	}
+	for i := range shares {
+		shares[i].Percent = float64(shares[i].Games) / float64(total) * 100
+	}
+}
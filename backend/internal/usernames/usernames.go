@@ -0,0 +1,88 @@
+package usernames
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	// MinLength and MaxLength bound a username, counted in characters
+	MinLength = 2
+	MaxLength = 20
+)
+
+// Error codes returned to clients when a username is rejected
+const (
+	CodeRequired     = "usernameRequired"
+	CodeTooShort     = "usernameTooShort"
+	CodeTooLong      = "usernameTooLong"
+	CodeInvalidChars = "usernameInvalidCharacters"
+	CodeReserved     = "usernameReserved"
+)
+
+// reserved names can't be taken by players. "bot" is the sentinel stored
+// for the bot's seat, so a player using it would corrupt bot statistics.
+var reserved = map[string]bool{
+	"bot":       true,
+	"admin":     true,
+	"system":    true,
+	"server":    true,
+	"null":      true,
+	"undefined": true,
+}
+
+// Error describes why a username was rejected
+type Error struct {
+	Code string
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	return e.Msg
+}
+
+// Canonical trims a username and puts it in Unicode NFC form
+func Canonical(name string) string {
+	return norm.NFC.String(strings.TrimSpace(name))
+}
+
+// Fold returns the key under which usernames are considered the same:
+// NFKC folds compatibility forms (e.g. fullwidth letters) and case is
+// dropped. Usernames are unique case-insensitively, but the spelling
+// first seen is kept for display.
+func Fold(name string) string {
+	return strings.ToLower(norm.NFKC.String(strings.TrimSpace(name)))
+}
+
+// Validate returns the canonical form of name, or an *Error if it is
+// empty, too short or long, uses characters other than ASCII letters,
+// digits and '_' (the same rule the lobby applies), or is reserved
+func Validate(name string) (string, error) {
+	name = Canonical(name)
+	switch n := utf8.RuneCountInString(name); {
+	case n == 0:
+		return "", &Error{CodeRequired, "username is required"}
+	case n < MinLength:
+		return "", &Error{CodeTooShort, "username must be at least 2 characters"}
+	case n > MaxLength:
+		return "", &Error{CodeTooLong, "username must be at most 20 characters"}
+	}
+
+	for _, r := range name {
+		if !isNameChar(r) {
+			return "", &Error{CodeInvalidChars, "username may only contain letters, digits and underscores"}
+		}
+	}
+
+	if reserved[Fold(name)] {
+		return "", &Error{CodeReserved, "username is reserved"}
+	}
+	return name, nil
+}
+
+// isNameChar reports whether r may appear in a username
+func isNameChar(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_'
+}
@@ -0,0 +1,69 @@
+package usernames
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCanonical(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"alice", "alice"},
+		{"  alice\t", "alice"},
+		{"Jose\u0301", "Jos\u00e9"}, // combining accent composed
+		{"Jos\u00e9", "Jos\u00e9"},
+		{"ａlice", "ａlice"}, // fullwidth letters are kept
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Canonical(tt.in); got != tt.want {
+			t.Errorf("Canonical(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestFold(t *testing.T) {
+	same := [][]string{
+		{"alice", "Alice", "ALICE", " alice "},
+		{"alice", "ａｌｉｃｅ", "ＡLICE"}, // fullwidth
+		{"jos\u00e9", "Jose\u0301", "JOS\u00c9"},
+		{"fin", "ﬁn"}, // ligature
+	}
+	for _, group := range same {
+		for _, name := range group[1:] {
+			if Fold(name) != Fold(group[0]) {
+				t.Errorf("Fold(%q) = %q, want it to match Fold(%q) = %q", name, Fold(name), group[0], Fold(group[0]))
+			}
+		}
+	}
+	if Fold("alice") == Fold("alicia") {
+		t.Error("different names fold together")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		in, want, code string
+	}{
+		{"alice", "alice", ""},
+		{" Bob_42 ", "Bob_42", ""},
+		{"", "", CodeRequired},
+		{"   ", "", CodeRequired},
+		{"a", "", CodeTooShort},
+		{"abcdefghijklmnopqrstu", "", CodeTooLong},
+		{"al ice", "", CodeInvalidChars},
+		{"Jos\u00e9", "", CodeInvalidChars},
+		{"ａlice", "", CodeInvalidChars},
+		{"BOT", "", CodeReserved},
+		{"Admin", "", CodeReserved},
+	}
+	for _, tt := range tests {
+		got, err := Validate(tt.in)
+		var verr *Error
+		switch {
+		case tt.code == "" && (err != nil || got != tt.want):
+			t.Errorf("Validate(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		case tt.code != "" && (!errors.As(err, &verr) || verr.Code != tt.code):
+			t.Errorf("Validate(%q) err = %v, want code %s", tt.in, err, tt.code)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package websocket
+
+import (
+	"testing"
+	"time"
+
+	"github.com/connect-four/internal/game"
+	"github.com/connect-four/internal/matchmaker"
+)
+
+// startBotGame connects alice to a bot game she moves first in, with the
+// bot taking botDelay over each move
+func startBotGame(t *testing.T, botDelay, reconnectWindow time.Duration) (*testServer, *testConn, Message) {
+	t.Helper()
+	s := newTestServer(t, func(h *Hub, mm *matchmaker.Matchmaker) {
+		h.botMoveDelay = botDelay
+		mm.SetFirstPlayerPolicy(matchmaker.FirstPlayerPlayer1)
+		mm.SetReconnectWindow(reconnectWindow)
+	})
+	c := s.dial(t, "alice", nil)
+	c.send(map[string]interface{}{"type": "join", "vsBot": true, "botDifficulty": "easy"})
+	matched := c.readType(TypeMatched)
+	if !matched.YourTurn {
+		t.Fatal("alice doesn't move first")
+	}
+	return s, c, matched
+}
+
+// refresh drops c's connection the way a page reload does, without a
+// close handshake, and reconnects alice to her game
+func (s *testServer) refresh(t *testing.T, c *testConn, matched Message, away time.Duration) (*testConn, Message) {
+	t.Helper()
+	g := s.mm.GetGame(matched.GameID)
+	c.conn.Close()
+	waitFor(t, "the disconnect", func() bool { return g.GetState().Status == game.StatusDisconnect })
+	waitFor(t, "alice to unregister", func() bool { return s.hub.GetClient("alice") == nil })
+	time.Sleep(away)
+
+	if status := g.GetState().Status; status != game.StatusDisconnect {
+		t.Fatalf("status while away = %s, want the game paused", status)
+	}
+	back := s.dial(t, "alice", nil)
+	back.send(map[string]interface{}{"type": "reconnect", "gameId": matched.GameID, "token": matched.Token})
+	return back, back.readType(TypeMatched)
+}
+
+func TestBotGameRefreshDuringMyTurn(t *testing.T) {
+	s, c, matched := startBotGame(t, 0, game.DefaultReconnectWindow)
+	c.send(map[string]interface{}{"type": "move", "column": 3, "token": matched.Token})
+	c.readMoves(2) // alice's move and the bot's reply
+
+	back, rematched := s.refresh(t, c, matched, 100*time.Millisecond)
+	state := rematched.State
+	if state.Status != game.StatusPlaying || state.MoveCount != 2 || !rematched.YourTurn {
+		t.Fatalf("after reconnecting: status %s, %d moves, your turn %v; want playing, 2, true",
+			state.Status, state.MoveCount, rematched.YourTurn)
+	}
+
+	// The game carries on where it was
+	back.send(map[string]interface{}{"type": "move", "column": 3, "token": matched.Token})
+	back.readMoves(4)
+}
+
+func TestBotGameRefreshWhileBotThinks(t *testing.T) {
+	const botDelay = 300 * time.Millisecond
+	s, c, matched := startBotGame(t, botDelay, game.DefaultReconnectWindow)
+	g := s.mm.GetGame(matched.GameID)
+
+	// Leave while the bot is still deciding on its reply
+	c.send(map[string]interface{}{"type": "move", "column": 3, "token": matched.Token})
+	c.readMoves(1)
+	back, rematched := s.refresh(t, c, matched, 2*botDelay)
+
+	// The bot held its move while alice was away
+	if n := rematched.State.MoveCount; n != 1 {
+		t.Fatalf("%d moves when alice came back, want the bot to have waited", n)
+	}
+	if rematched.YourTurn {
+		t.Fatal("alice's turn after reconnecting, want the bot's")
+	}
+
+	// and makes it once she is back
+	back.readMoves(2)
+	if state := g.GetState(); state.Status != game.StatusPlaying || state.CurrentTurn != game.Player1 {
+		t.Errorf("after the bot's reply: status %s, turn %d; want playing, alice", state.Status, state.CurrentTurn)
+	}
+}
+
+func TestBotGameForfeitsAfterReconnectWindow(t *testing.T) {
+	s, c, matched := startBotGame(t, 0, 200*time.Millisecond)
+	g := s.mm.GetGame(matched.GameID)
+	c.send(map[string]interface{}{"type": "move", "column": 3, "token": matched.Token})
+	c.readMoves(2)
+
+	c.conn.Close()
+	waitFor(t, "the disconnect", func() bool { return g.GetState().Status == game.StatusDisconnect })
+	waitFor(t, "the reconnect window to run out", func() bool { return g.GetState().Status == game.StatusFinished })
+	if state := g.GetState(); state.Winner != "BOT" {
+		t.Errorf("winner = %q, want the bot after alice stayed away", state.Winner)
+	}
+}
@@ -0,0 +1,58 @@
+package websocket
+
+import (
+	"testing"
+
+	"github.com/connect-four/internal/game"
+	"github.com/connect-four/internal/matchmaker"
+)
+
+// TestBotGameBroadcastsInSequence plays fast bot games and checks that
+// every game broadcast a client receives carries a higher sequence number
+// than the one before, with the final state ahead of gameOver
+func TestBotGameBroadcastsInSequence(t *testing.T) {
+	s := newTestServer(t, func(h *Hub, _ *matchmaker.Matchmaker) { h.botMoveDelay = 0 })
+	for round := 0; round < 20; round++ {
+		c := s.dial(t, "alice", nil)
+		c.send(map[string]interface{}{"type": "join", "vsBot": true, "botDifficulty": "easy"})
+		matched := c.readType(TypeMatched)
+
+		var last int64
+		var lastState *game.GameState
+		play := func(state *game.GameState) {
+			if state.CurrentTurnUsername != "alice" {
+				return
+			}
+			for col := range state.Board[0] {
+				if state.Board[0][col] == game.Empty {
+					c.send(map[string]interface{}{"type": "move", "column": col, "token": matched.Token})
+					return
+				}
+			}
+		}
+		play(matched.State)
+
+		for {
+			msg := c.read()
+			if msg.Seq == 0 {
+				continue
+			}
+			if msg.Seq <= last {
+				t.Fatalf("round %d: %s with seq %d after seq %d", round, msg.Type, msg.Seq, last)
+			}
+			last = msg.Seq
+			if msg.Type == TypeGameOver {
+				if lastState == nil || lastState.Status != game.StatusFinished {
+					t.Fatalf("round %d: gameOver arrived before the final state", round)
+				}
+				break
+			}
+			if msg.State != nil {
+				lastState = msg.State
+				play(msg.State)
+			}
+		}
+		c.conn.Close()
+		waitFor(t, "alice to leave", func() bool { return s.hub.GetClient("alice") == nil })
+	}
+}
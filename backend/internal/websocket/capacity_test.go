@@ -0,0 +1,61 @@
+package websocket
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/connect-four/internal/matchmaker"
+	gws "github.com/gorilla/websocket"
+)
+
+func TestConnectionLimitRefusesUpgrades(t *testing.T) {
+	s := newTestServer(t, func(h *Hub, _ *matchmaker.Matchmaker) { h.SetMaxConnections(1) })
+	alice := s.dial(t, "alice", nil)
+	waitFor(t, "alice to register", func() bool { return s.hub.GetClient("alice") != nil })
+
+	// The refused connection is closed with a reason the browser can read
+	bob := s.dial(t, "bob", nil)
+	_, err := bob.tryRead(5 * time.Second)
+	var closeErr *gws.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != gws.CloseTryAgainLater || closeErr.Text != ErrServerFull.Error() {
+		t.Fatalf("over the limit: read error = %v, want a try again later close", err)
+	}
+	if stats := s.hub.Stats(); stats.OpenConnections != 1 || stats.MaxConnections != 1 || stats.RefusedConnections != 1 {
+		t.Errorf("hub stats = %+v, want 1 of 1 open and 1 refused", stats)
+	}
+
+	// The admitted connection is unaffected, and its slot frees when it closes
+	alice.send(map[string]interface{}{"type": "join"})
+	alice.readType(TypeWaiting)
+	alice.conn.Close()
+	waitFor(t, "alice's slot to free", func() bool { return s.hub.Stats().OpenConnections == 0 })
+
+	carol := s.dial(t, "carol", nil)
+	carol.send(map[string]interface{}{"type": "join"})
+	carol.readType(TypeWaiting)
+}
+
+func TestFullQueueAnswersServerBusy(t *testing.T) {
+	s := newTestServer(t, func(_ *Hub, mm *matchmaker.Matchmaker) { mm.SetLimits(1, 0) })
+	alice := s.dial(t, "alice", nil)
+	alice.send(map[string]interface{}{"type": "join", "allowBot": false})
+	alice.readType(TypeWaiting)
+
+	// bob wants a different board, so he would have to wait too
+	bob := s.dial(t, "bob", nil)
+	bob.send(map[string]interface{}{"type": "join", "allowBot": false, "rows": 7, "columns": 8, "winLength": 4})
+	msg := bob.readType(TypeError)
+	if msg.Reason != ReasonServerBusy {
+		t.Fatalf("join with the queue full: reason = %q (%s), want %q", msg.Reason, msg.Message, ReasonServerBusy)
+	}
+	if c := s.mm.Capacity(); c.Waiting != 1 || c.QueueRefusals != 1 {
+		t.Errorf("capacity = %+v, want alice waiting and 1 refusal", c)
+	}
+
+	// bob's connection stays open to try again once there is room
+	alice.send(map[string]interface{}{"type": "leaveQueue"})
+	alice.readType(TypeQueueLeft)
+	bob.send(map[string]interface{}{"type": "join", "allowBot": false, "rows": 7, "columns": 8, "winLength": 4})
+	bob.readType(TypeWaiting)
+}
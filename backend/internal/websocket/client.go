@@ -2,10 +2,15 @@ package websocket
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
 	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/connect-four/internal/auth"
+	"github.com/connect-four/internal/ratelimit"
+	"github.com/connect-four/internal/usernames"
 	"github.com/gorilla/websocket"
 )
 
@@ -13,22 +18,72 @@ const (
 	// Time allowed to write a message to the peer
 	writeWait = 10 * time.Second
 
-	// Time allowed to read the next pong message from the peer
-	pongWait = 60 * time.Second
+	// Default time allowed to read the next pong message from the peer
+	defaultPongWait = 60 * time.Second
 
-	// Send pings to peer with this period (must be less than pongWait)
-	pingPeriod = (pongWait * 9) / 10
+	// Default period for sending pings to the peer (must be less than the pong wait)
+	defaultPingPeriod = (defaultPongWait * 9) / 10
 
-	// Maximum message size allowed from peer
-	maxMessageSize = 512
+	// Maximum message size allowed from peer; it has to fit a join with
+	// every field at its Validate limit, even with all of it JSON-escaped
+	maxMessageSize = 8192
+
+	// How many recent move msgIds a connection remembers replies for
+	processedMoveIDs = 16
+
+	// Shortest time between hovers relayed for a connection; more are
+	// coalesced to the latest
+	hoverInterval = time.Second / 10
+
+	// Sync requests a connection may make per second, and in a burst
+	syncRate  = 2
+	syncBurst = 2
+
+	// Rate-limited messages a connection may send before it is dropped,
+	// and how fast that allowance recovers (per second)
+	rateLimitStrikes       = 20
+	rateLimitStrikeRecover = 0.2
+)
+
+var (
+	// ErrClientClosed is returned when sending to a client that has gone away
+	ErrClientClosed = errors.New("client connection closed")
+
+	// ErrSendBufferFull is returned when a client isn't keeping up with messages
+	ErrSendBufferFull = errors.New("client send buffer full")
+
+	// ErrRateLimited is sent when a connection sends messages faster than allowed
+	ErrRateLimited = errors.New("too many messages, slow down")
+
+	// ErrSyncRateLimited is sent when a connection asks to sync too often
+	ErrSyncRateLimited = errors.New("too many sync requests, slow down")
+
+	// ErrSessionExists is sent to a connection rejected because the username is already connected
+	ErrSessionExists = errors.New("username is already connected in another session")
+
+	// ErrServerFull is the close reason for connections refused at the connection limit
+	ErrServerFull = errors.New("server is full, try again later")
 )
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all origins for development
-	},
+// tokenProtocol is the subprotocol a browser offers alongside its auth
+// token, since it can't set headers on a WebSocket request
+const tokenProtocol = "access_token"
+
+// upgrader returns the upgrader for the hub's connections
+func (h *Hub) upgrader() *websocket.Upgrader {
+	return &websocket.Upgrader{
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+		Subprotocols:    []string{tokenProtocol},
+		CheckOrigin:     h.checkOrigin,
+	}
+}
+
+// checkOrigin allows requests from the allowed origins, and any without
+// an Origin header, which don't come from a browser page
+func (h *Hub) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	return h.origins == nil || origin == "" || h.origins.Allowed(origin)
 }
 
 // Client represents a single WebSocket connection
@@ -38,28 +93,179 @@ type Client struct {
 	send     chan []byte
 	username string
 	gameID   string
+
+	// How the connection can prove it is the username's owner when that
+	// name is already connected: a verified auth token, or the seat token
+	// of the connected session's game
+	authenticated bool
+	gameToken     string
+
+	// sendMu guards send against being closed while a message is queued
+	sendMu sync.Mutex
+	closed bool
+	done   chan struct{} // closed with send; cancels matchmaking
+
+	// Set once the client's game has been told about the disconnect
+	gameDisconnected atomic.Bool
+
+	// Set when the peer closed with a normal closure, meaning it left
+	// rather than lost the connection
+	closedNormally atomic.Bool
+
+	// Unix nanoseconds of the last message or pong received
+	lastActivity atomic.Int64
+
+	// Per-connection message rate limit, nil when disabled. Every rejected
+	// message costs a strike; running out of strikes closes the connection.
+	messages *ratelimit.Bucket
+	strikes  *ratelimit.Bucket
+
+	// The replay streaming to this connection, if any
+	replayMu sync.Mutex
+	replay   *replaySession
+
+	// Limits sync requests, which are cheap to send but not to answer
+	syncs *ratelimit.Bucket
+
+	// Set when a game broadcast was dropped because the send buffer was
+	// full; the write pump sends a sync once the buffer drains
+	needsSync atomic.Bool
+
+	// Throttles for ephemeral message types, by message type
+	throttlesMu sync.Mutex
+	throttles   map[string]*throttle
+
+	// Replies to the last few moves sent with a msgId, oldest first. Only
+	// the read pump touches it.
+	processed []processedMove
+}
+
+// processedMove is the reply sent for a move with a msgId
+type processedMove struct {
+	msgID string
+	reply Message
 }
 
 // NewClient creates a new client
 func NewClient(hub *Hub, conn *websocket.Conn, username string) *Client {
-	return &Client{
+	c := &Client{
 		hub:      hub,
 		conn:     conn,
 		send:     make(chan []byte, 256),
+		done:     make(chan struct{}),
 		username: username,
+		syncs:    ratelimit.NewBucket(syncRate, syncBurst),
+	}
+	if hub.messageRate > 0 {
+		c.messages = ratelimit.NewBucket(hub.messageRate, hub.messageBurst)
+		c.strikes = ratelimit.NewBucket(rateLimitStrikeRecover, rateLimitStrikes)
+	}
+	c.touch()
+	return c
+}
+
+// allowMessage applies the connection's rate limit to an incoming message.
+// A client that keeps sending past the limit is disconnected.
+func (c *Client) allowMessage() bool {
+	if c.messages == nil || c.messages.Allow() {
+		return true
+	}
+	if !c.strikes.Allow() {
+		c.hub.logger.Warn("disconnecting client: rate limit exceeded repeatedly", "username", c.username)
+		c.closeSend()
+	}
+	return false
+}
+
+// movePreviouslyProcessed returns the reply already sent for a move with msgID
+func (c *Client) movePreviouslyProcessed(msgID string) (Message, bool) {
+	if msgID == "" {
+		return Message{}, false
+	}
+	for _, p := range c.processed {
+		if p.msgID == msgID {
+			return p.reply, true
+		}
+	}
+	return Message{}, false
+}
+
+// rememberMove records the reply to a move with msgID, forgetting the
+// oldest beyond processedMoveIDs
+func (c *Client) rememberMove(msgID string, reply Message) {
+	if msgID == "" {
+		return
+	}
+	if len(c.processed) == processedMoveIDs {
+		c.processed = c.processed[1:]
+	}
+	c.processed = append(c.processed, processedMove{msgID: msgID, reply: reply})
+}
+
+// touch records activity from the peer
+func (c *Client) touch() {
+	c.lastActivity.Store(time.Now().UnixNano())
+}
+
+// LastActivity returns when the peer last sent a message or pong
+func (c *Client) LastActivity() time.Time {
+	return time.Unix(0, c.lastActivity.Load())
+}
+
+// enqueue queues raw data for the writePump without blocking
+func (c *Client) enqueue(data []byte) error {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	if c.closed {
+		return ErrClientClosed
+	}
+
+	select {
+	case c.send <- data:
+		c.hub.messagesSent.Add(1)
+		return nil
+	default:
+		c.hub.messagesDropped.Add(1)
+		return ErrSendBufferFull
 	}
 }
 
+// closeSend closes the send channel once, so writePump sends a close frame
+// and exits. Later sends fail with ErrClientClosed instead of panicking.
+func (c *Client) closeSend() {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	if !c.closed {
+		c.closed = true
+		close(c.send)
+		close(c.done)
+	}
+}
+
+// isClosed reports whether the connection has been unregistered
+func (c *Client) isClosed() bool {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	return c.closed
+}
+
 // readPump pumps messages from the websocket connection to the hub
 func (c *Client) readPump(handler *Handler) {
 	defer func() {
 		c.hub.unregister <- c
 		c.conn.Close()
+		c.hub.releaseConnection()
 	}()
 
+	// A missed pong expires the read deadline, which ends this loop and
+	// runs the normal unregister and disconnect flow
+	pongWait := c.hub.pongWait
 	c.conn.SetReadLimit(maxMessageSize)
 	c.conn.SetReadDeadline(time.Now().Add(pongWait))
 	c.conn.SetPongHandler(func(string) error {
+		c.touch()
 		c.conn.SetReadDeadline(time.Now().Add(pongWait))
 		return nil
 	})
@@ -67,19 +273,24 @@ func (c *Client) readPump(handler *Handler) {
 	for {
 		_, message, err := c.conn.ReadMessage()
 		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				c.closedNormally.Store(true)
+			}
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("WebSocket error: %v", err)
+				c.hub.logger.Warn("websocket read failed", "username", c.username, "error", err)
 			}
 			break
 		}
 
+		c.touch()
+		c.hub.messagesReceived.Add(1)
 		handler.HandleMessage(c, message)
 	}
 }
 
 // writePump pumps messages from the hub to the websocket connection
 func (c *Client) writePump() {
-	ticker := time.NewTicker(pingPeriod)
+	ticker := time.NewTicker(c.hub.pingPeriod)
 	defer func() {
 		ticker.Stop()
 		c.conn.Close()
@@ -98,9 +309,12 @@ func (c *Client) writePump() {
 			// Send each message as a separate WebSocket frame
 			// Do NOT batch messages - frontend expects individual JSON objects
 			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
-				log.Printf("WebSocket write error: %v", err)
+				c.hub.logger.Warn("websocket write failed", "username", c.username, "error", err)
 				return
 			}
+			if len(c.send) == 0 && c.needsSync.CompareAndSwap(true, false) {
+				c.hub.resync(c)
+			}
 
 		case <-ticker.C:
 			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
@@ -112,37 +326,113 @@ func (c *Client) writePump() {
 }
 
 // sendMessage sends a message to this client
-func (c *Client) sendMessage(msg Message) {
+func (c *Client) sendMessage(msg Message) error {
 	data, err := json.Marshal(msg)
 	if err != nil {
-		log.Printf("Error marshaling message: %v", err)
-		return
+		c.hub.logger.Error("marshaling message failed", "messageType", msg.Type, "error", err)
+		return err
 	}
 
-	select {
-	case c.send <- data:
-	default:
-		// Buffer full
+	if err := c.enqueue(data); err != nil {
+		c.hub.logger.Warn("send failed", "username", c.username, "messageType", msg.Type, "error", err)
+		return err
 	}
+	return nil
 }
 
 // ServeWs handles websocket requests from clients
+// With auth on, the username comes from the token, sent as the token
+// query parameter or as the subprotocol following access_token, and any
+// username parameter is ignored. The gameToken parameter carries a seat
+// token, which lets the connection take over a session still open in that
+// game under SessionReplace.
 func ServeWs(hub *Hub, handler *Handler, w http.ResponseWriter, r *http.Request) {
-	username := r.URL.Query().Get("username")
-	if username == "" {
-		http.Error(w, "username is required", http.StatusBadRequest)
-		return
+	var username string
+	if hub.auth != nil {
+		claims, err := hub.auth.Verify(requestToken(r))
+		if err != nil {
+			respondAuthError(w, err)
+			return
+		}
+		username = claims.Username
+	} else {
+		var err error
+		username, err = usernames.Validate(r.URL.Query().Get("username"))
+		if err != nil {
+			respondUsernameError(w, err)
+			return
+		}
 	}
+	username = hub.CanonicalUsername(r.Context(), username)
 
-	conn, err := upgrader.Upgrade(w, r, nil)
+	if !hub.admitConnection() {
+		hub.logger.Warn("connection refused: connection limit reached", "username", username, "maxConnections", hub.maxConnections)
+		refuseConnection(hub, w, r)
+		return
+	}
+	conn, err := hub.upgrader().Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("WebSocket upgrade error: %v", err)
+		hub.releaseConnection()
+		hub.logger.Warn("websocket upgrade failed", "username", username, "error", err)
 		return
 	}
 
 	client := NewClient(hub, conn, username)
+	client.authenticated = hub.auth != nil
+	client.gameToken = r.URL.Query().Get("gameToken")
 	hub.register <- client
 
 	go client.writePump()
 	go client.readPump(handler)
 }
+
+// refuseConnection completes the upgrade only to close the connection with
+// "try again later", which a browser can read, unlike a refused upgrade
+func refuseConnection(hub *Hub, w http.ResponseWriter, r *http.Request) {
+	conn, err := hub.upgrader().Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	msg := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, ErrServerFull.Error())
+	conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(writeWait))
+}
+
+// requestToken returns the auth token from the token query parameter, or
+// from the subprotocols offered as "access_token, <token>"
+func requestToken(r *http.Request) string {
+	if token := r.URL.Query().Get("token"); token != "" {
+		return token
+	}
+	protocols := websocket.Subprotocols(r)
+	for i, protocol := range protocols {
+		if protocol == tokenProtocol && i+1 < len(protocols) {
+			return protocols[i+1]
+		}
+	}
+	return ""
+}
+
+// respondAuthError rejects a connection whose token failed verification
+func respondAuthError(w http.ResponseWriter, err error) {
+	body := map[string]string{"error": err.Error()}
+	var aerr *auth.Error
+	if errors.As(err, &aerr) {
+		body["code"] = aerr.Code
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(body)
+}
+
+// respondUsernameError rejects a connection whose username failed validation
+func respondUsernameError(w http.ResponseWriter, err error) {
+	body := map[string]string{"error": err.Error()}
+	var uerr *usernames.Error
+	if errors.As(err, &uerr) {
+		body["code"] = uerr.Code
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,36 @@
+package websocket
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/connect-four/internal/game"
+)
+
+func TestGameOverCarriesWinningCells(t *testing.T) {
+	s := newTestServer(t)
+	a, ma, b, mb := s.matchPlayers(t, "alice", "bob")
+
+	// Whoever moves first fills the bottom row from the left while the
+	// other stacks in the last column
+	first, firstToken, second, secondToken := a, ma.Token, b, mb.Token
+	if ma.State.CurrentTurnUsername != "alice" {
+		first, firstToken, second, secondToken = b, mb.Token, a, ma.Token
+	}
+	for col := 0; col < 4; col++ {
+		first.send(map[string]interface{}{"type": "move", "column": col, "token": firstToken})
+		if col < 3 {
+			second.readMoves(2*col + 1)
+			second.send(map[string]interface{}{"type": "move", "column": 6, "token": secondToken})
+			first.readMoves(2*col + 2)
+		}
+	}
+
+	want := []game.MoveInfo{{Column: 0, Row: 5}, {Column: 1, Row: 5}, {Column: 2, Row: 5}, {Column: 3, Row: 5}}
+	for _, c := range []*testConn{first, second} {
+		msg := c.readType(TypeGameOver)
+		if !reflect.DeepEqual(msg.WinningCells, want) {
+			t.Errorf("gameOver winning cells = %v, want %v", msg.WinningCells, want)
+		}
+	}
+}
@@ -1,11 +1,15 @@
 package websocket
 
 import (
-	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
+	"time"
 
+	"github.com/connect-four/internal/cosmetics"
 	"github.com/connect-four/internal/game"
+	"github.com/connect-four/internal/jsonutil"
 	"github.com/connect-four/internal/matchmaker"
+	"github.com/connect-four/internal/safego"
 )
 
 // Message types
@@ -13,6 +17,17 @@ const (
 	TypeJoin                 = "join"
 	TypeMove                 = "move"
 	TypeReconnect            = "reconnect"
+	TypeResign               = "resign"
+	TypeLeaveGame            = "leaveGame"
+	TypeHint                 = "hint"
+	TypeLeaveQueue           = "leaveQueue"
+	TypeSync                 = "sync"
+	TypeHover                = "hover"
+	TypeTakebackRequest      = "takebackRequest"
+	TypeTakebackResponse     = "takebackResponse"
+	TypeOfferDraw            = "offerDraw"
+	TypeDrawResponse         = "drawResponse"
+	TypeQueueLeft            = "queueLeft"
 	TypeWaiting              = "waiting"
 	TypeMatched              = "matched"
 	TypeState                = "state"
@@ -20,37 +35,227 @@ const (
 	TypeError                = "error"
 	TypeOpponentDisconnected = "opponentDisconnected"
 	TypeOpponentReconnected  = "opponentReconnected"
+	TypeTurnTimeout          = "turnTimeout"
+	TypeIdleWarning          = "idleWarning"
+	TypeReconnectCountdown   = "reconnectCountdown"
+	TypeSessionReplaced      = "sessionReplaced"
+	TypeServerShutdown       = "serverShutdown"
+	TypeAck                  = "ack"
+	TypeOpponentHover        = "opponentHover"
+
+	// Replays of completed games
+	TypeReplay       = "replay"
+	TypeReplayPause  = "replayPause"
+	TypeReplayResume = "replayResume"
+	TypeReplaySeek   = "replaySeek"
+	TypeReplayStop   = "replayStop"
+	TypeReplayPaused = "replayPaused"
+	TypeReplayEnded  = "replayEnded"
 )
 
+// ReasonDuplicateSession is the reason on the error refusing a connection
+// whose username is already connected, under SessionReject
+const ReasonDuplicateSession = "duplicateSession"
+
+// ReasonServerBusy is the reason on the error refusing a join because the
+// waiting queue is full
+const ReasonServerBusy = "serverBusy"
+
 // Message represents a WebSocket message
 type Message struct {
-	Type              string           `json:"type"`
-	Username          string           `json:"username,omitempty"`
-	Column            int              `json:"column,omitempty"`
-	Row               int              `json:"row,omitempty"`
-	GameID            string           `json:"gameId,omitempty"`
-	Opponent          string           `json:"opponent,omitempty"`
-	YourTurn          bool             `json:"yourTurn,omitempty"`
-	State             *game.GameState  `json:"state,omitempty"`
-	Winner            string           `json:"winner,omitempty"`
-	Reason            string           `json:"reason,omitempty"`
-	Message           string           `json:"message,omitempty"`
-	ReconnectDeadline string           `json:"reconnectDeadline,omitempty"`
-	PlayerNum         int              `json:"playerNum,omitempty"`
+	Type              string                `json:"type"`
+	Username          string                `json:"username,omitempty"`
+	Column            int                   `json:"column,omitempty"`
+	Row               int                   `json:"row,omitempty"`
+	GameID            string                `json:"gameId,omitempty"`
+	Opponent          string                `json:"opponent,omitempty"`
+	YourTurn          bool                  `json:"yourTurn,omitempty"`
+	FirstPlayer       string                `json:"firstPlayer,omitempty"` // who moves first, in matched
+	State             *game.GameState       `json:"state,omitempty"`
+	Winner            string                `json:"winner,omitempty"`
+	Reason            string                `json:"reason,omitempty"`
+	Message           string                `json:"message,omitempty"`
+	ReconnectDeadline string                `json:"reconnectDeadline,omitempty"`
+	SecondsRemaining  int                   `json:"secondsRemaining,omitempty"`
+	QueuePosition     int                   `json:"queuePosition,omitempty"`
+	PlayersWaiting    int                   `json:"playersWaiting,omitempty"`
+	BotFallbackIn     int                   `json:"botFallbackSeconds,omitempty"`
+	EstimatedWait     int                   `json:"estimatedWaitSeconds,omitempty"`
+	PlayerNum         int                   `json:"playerNum,omitempty"`
+	Token             string                `json:"token,omitempty"`
+	Fields            []jsonutil.FieldError `json:"fields,omitempty"`
+	Warnings          []string              `json:"warnings,omitempty"`
+	WinningCells      []game.MoveInfo       `json:"winningCells,omitempty"`
+	Hint              *game.Hint            `json:"hint,omitempty"`
+	Replay            *ReplayPosition       `json:"replay,omitempty"`
+	MsgID             string                `json:"msgId,omitempty"`
+	Seq               int64                 `json:"seq,omitempty"` // game broadcasts' order, counting from 1 per game
+	HoverColumn       *int                  `json:"hoverColumn,omitempty"`
+	AttemptedColumn   *int                  `json:"attemptedColumn,omitempty"` // the column of a rejected move
+	UndoMoves         int                   `json:"undoMoves,omitempty"`       // moves a takeback undoes
+	Accepted          *bool                 `json:"accepted,omitempty"`        // the answer in takebackResponse and drawResponse
 }
 
 // IncomingMessage represents a message from the client
 type IncomingMessage struct {
 	Type     string `json:"type"`
-	Column   int    `json:"column,omitempty"`
+	Column   *int   `json:"column,omitempty"`
 	GameID   string `json:"gameId,omitempty"`
 	Username string `json:"username,omitempty"`
+	Token    string `json:"token,omitempty"`  // seat token from the matched message
+	MsgID    string `json:"msgId,omitempty"`  // client's ID for a move, so retries aren't applied twice
+	Accept   *bool  `json:"accept,omitempty"` // answer to the opponent's takebackRequest or offerDraw
+
+	// Replay options
+	Speed float64 `json:"speed,omitempty"` // playback speed for replay, default 1
+	Move  *int    `json:"move,omitempty"`  // moves to show for replaySeek
+
+	// Join options
+	BotDifficulty string `json:"botDifficulty,omitempty"`
+	BotFirst      bool   `json:"botFirst,omitempty"`
+	AllowBot      *bool  `json:"allowBot,omitempty"` // false waits for a human indefinitely
+	VsBot         bool   `json:"vsBot,omitempty"`    // start a bot game immediately
+	Friendly      bool   `json:"friendly,omitempty"` // unrated game with takebacks
+	DiscEmoji     string `json:"discEmoji,omitempty"`
+	AvatarURL     string `json:"avatarUrl,omitempty"`
+
+	// Board variant for join; zero fields take the standard 6x7 connect-4
+	Rows      int `json:"rows,omitempty"`
+	Columns   int `json:"columns,omitempty"`
+	WinLength int `json:"winLength,omitempty"`
+}
+
+// Validate checks that the fields required by the message type are present and in range
+func (m *IncomingMessage) Validate() error {
+	verr := &jsonutil.ValidationError{}
+
+	if len(m.GameID) > 64 {
+		verr.Add("gameId", "must be at most 64 characters")
+	}
+	if len(m.Username) > 50 {
+		verr.Add("username", "must be at most 50 characters")
+	}
+	if len(m.Token) > 64 {
+		verr.Add("token", "must be at most 64 characters")
+	}
+	if m.MsgID != "" && m.Type != TypeMove {
+		verr.Add("msgId", "only allowed for move")
+	} else if len(m.MsgID) > 64 {
+		verr.Add("msgId", "must be at most 64 characters")
+	}
+
+	if m.Type != TypeJoin && m.DiscEmoji != "" {
+		verr.Add("discEmoji", "only allowed for join")
+	}
+	if m.Type != TypeJoin && m.AvatarURL != "" {
+		verr.Add("avatarUrl", "only allowed for join")
+	}
+	if len(m.DiscEmoji) > cosmetics.MaxDiscEmojiBytes {
+		verr.Add("discEmoji", fmt.Sprintf("must be at most %d bytes", cosmetics.MaxDiscEmojiBytes))
+	}
+	if len(m.AvatarURL) > cosmetics.MaxAvatarURLLength {
+		verr.Add("avatarUrl", fmt.Sprintf("must be at most %d characters", cosmetics.MaxAvatarURLLength))
+	}
+
+	if m.BotFirst && m.Type != TypeJoin {
+		verr.Add("botFirst", "only allowed for join")
+	}
+	if (m.AllowBot != nil || m.VsBot) && m.Type != TypeJoin {
+		verr.Add("allowBot", "only allowed for join")
+	}
+	if m.VsBot && m.AllowBot != nil && !*m.AllowBot {
+		verr.Add("vsBot", "cannot be combined with allowBot false")
+	}
+	if m.Friendly && m.Type != TypeJoin {
+		verr.Add("friendly", "only allowed for join")
+	} else if m.Friendly && m.VsBot {
+		verr.Add("friendly", "cannot be combined with vsBot")
+	}
+	if m.Accept != nil && m.Type != TypeTakebackResponse && m.Type != TypeDrawResponse {
+		verr.Add("accept", "only allowed for takebackResponse and drawResponse")
+	}
+
+	if m.Rows != 0 || m.Columns != 0 || m.WinLength != 0 {
+		if m.Type != TypeJoin {
+			verr.Add("rows", "board size only allowed for join")
+		} else if _, err := game.NewBoardSize(m.Rows, m.Columns, m.WinLength); err != nil {
+			verr.Add("rows", err.Error())
+		}
+	}
+
+	if m.BotDifficulty != "" {
+		if m.Type != TypeJoin {
+			verr.Add("botDifficulty", "only allowed for join")
+		} else if _, ok := game.ParseDifficulty(m.BotDifficulty); !ok {
+			verr.Add("botDifficulty", "must be easy, medium or hard")
+		}
+	}
+
+	if m.Speed != 0 {
+		if m.Type != TypeReplay {
+			verr.Add("speed", "only allowed for replay")
+		} else if m.Speed < minReplaySpeed || m.Speed > maxReplaySpeed {
+			verr.Add("speed", "must be between 0.25 and 8")
+		}
+	}
+	if m.Move != nil && m.Type != TypeReplaySeek {
+		verr.Add("move", "only allowed for replaySeek")
+	}
+
+	switch m.Type {
+	case TypeJoin, TypeReconnect, TypeResign, TypeLeaveGame, TypeHint, TypeLeaveQueue, TypeSync,
+		TypeReplayPause, TypeReplayResume, TypeReplayStop, TypeTakebackRequest, TypeOfferDraw:
+		if m.Column != nil {
+			verr.Add("column", "not allowed for "+m.Type)
+		}
+	case TypeTakebackResponse, TypeDrawResponse:
+		if m.Column != nil {
+			verr.Add("column", "not allowed for "+m.Type)
+		}
+		if m.Accept == nil {
+			verr.Add("accept", "is required")
+		}
+	case TypeReplay:
+		if m.Column != nil {
+			verr.Add("column", "not allowed for "+m.Type)
+		}
+		if m.GameID == "" {
+			verr.Add("gameId", "is required")
+		}
+	case TypeReplaySeek:
+		if m.Column != nil {
+			verr.Add("column", "not allowed for "+m.Type)
+		}
+		if m.Move == nil {
+			verr.Add("move", "is required")
+		} else if *m.Move < 0 {
+			verr.Add("move", "must not be negative")
+		}
+	case TypeMove, TypeHover:
+		if m.Column == nil {
+			verr.Add("column", "is required")
+		} else if *m.Column < 0 || *m.Column >= game.MaxBoardSide {
+			// The game checks the column against its own board
+			verr.Add("column", "must be between 0 and 9")
+		}
+	case "":
+		verr.Add("type", "is required")
+	default:
+		verr.Add("type", "unknown message type")
+	}
+
+	return verr.ErrOrNil()
 }
 
+// waitingUpdateInterval is how often waiting players are told where they
+// stand in the queue
+const waitingUpdateInterval = 3 * time.Second
+
 // Handler processes WebSocket messages
 type Handler struct {
-	hub        *Hub
-	matchmaker *matchmaker.Matchmaker
+	hub         *Hub
+	matchmaker  *matchmaker.Matchmaker
+	avatarHosts []string
 }
 
 // NewHandler creates a new message handler
@@ -61,182 +266,645 @@ func NewHandler(hub *Hub, mm *matchmaker.Matchmaker) *Handler {
 	}
 }
 
+// SetAvatarHosts sets the hosts player avatar URLs may point at; with
+// none, avatar URLs are rejected
+func (h *Handler) SetAvatarHosts(hosts []string) {
+	h.avatarHosts = hosts
+}
+
 // HandleMessage processes an incoming message
 func (h *Handler) HandleMessage(client *Client, data []byte) {
+	// Hovers have their own, coalescing limit instead of the message rate
+	// limit, which a moving pointer would soon use up
 	var msg IncomingMessage
-	if err := json.Unmarshal(data, &msg); err != nil {
-		log.Printf("Error parsing message: %v", err)
-		client.sendMessage(Message{Type: TypeError, Message: "Invalid message format"})
+	err := jsonutil.Decode(data, &msg)
+	if (err != nil || msg.Type != TypeHover) && !client.allowMessage() {
+		client.sendMessage(Message{Type: TypeError, Message: ErrRateLimited.Error()})
+		return
+	}
+	if err != nil {
+		h.hub.logger.Debug("invalid message", "username", client.username, "error", err)
+		reply := Message{Type: TypeError, Message: "Invalid message format"}
+		var verr *jsonutil.ValidationError
+		if errors.As(err, &verr) {
+			reply.Fields = verr.Fields
+		}
+		client.sendMessage(reply)
 		return
 	}
 
 	switch msg.Type {
 	case TypeJoin:
-		h.handleJoin(client)
+		h.handleJoin(client, h.joinOptions(msg))
 	case TypeMove:
-		h.handleMove(client, msg.Column)
+		h.handleMove(client, *msg.Column, msg.Token, msg.MsgID)
 	case TypeReconnect:
-		h.handleReconnect(client, msg.GameID)
-	default:
-		client.sendMessage(Message{Type: TypeError, Message: "Unknown message type"})
+		h.handleReconnect(client, msg.GameID, msg.Token)
+	case TypeResign:
+		h.handleResign(client, msg.Token)
+	case TypeLeaveGame:
+		h.handleLeaveGame(client, msg.Token)
+	case TypeHint:
+		h.handleHint(client, msg.Token)
+	case TypeTakebackRequest:
+		h.handleTakebackRequest(client, msg.Token)
+	case TypeTakebackResponse:
+		h.handleTakebackResponse(client, *msg.Accept, msg.Token)
+	case TypeOfferDraw:
+		h.handleOfferDraw(client, msg.Token)
+	case TypeDrawResponse:
+		h.handleDrawResponse(client, *msg.Accept, msg.Token)
+	case TypeHover:
+		h.handleHover(client, *msg.Column, msg.Token)
+	case TypeLeaveQueue:
+		h.handleLeaveQueue(client)
+	case TypeSync:
+		h.handleSync(client)
+	case TypeReplay:
+		h.handleReplay(client, msg.GameID, msg.Speed)
+	case TypeReplayPause, TypeReplayResume, TypeReplayStop:
+		h.handleReplayControl(client, replayCommand{kind: msg.Type})
+	case TypeReplaySeek:
+		h.handleReplayControl(client, replayCommand{kind: msg.Type, move: *msg.Move})
 	}
 }
 
+// joinOptions builds matchmaking options from a join message. Cosmetics
+// that fail validation are dropped and reported as warnings.
+func (h *Handler) joinOptions(msg IncomingMessage) sanitizedJoin {
+	difficulty, _ := game.ParseDifficulty(msg.BotDifficulty)
+	size, _ := game.NewBoardSize(msg.Rows, msg.Columns, msg.WinLength)
+	join := sanitizedJoin{
+		options: matchmaker.JoinOptions{
+			Size:          size,
+			BotDifficulty: difficulty,
+			BotFirst:      msg.BotFirst,
+			NoBotFallback: msg.AllowBot != nil && !*msg.AllowBot,
+			VsBot:         msg.VsBot,
+			Friendly:      msg.Friendly,
+		},
+		token: msg.Token,
+	}
+
+	if msg.DiscEmoji != "" {
+		if cosmetics.IsSingleEmoji(msg.DiscEmoji) {
+			join.options.DiscEmoji = msg.DiscEmoji
+		} else {
+			join.warnings = append(join.warnings, cosmetics.WarningDiscEmojiRejected)
+		}
+	}
+	if msg.AvatarURL != "" {
+		if cosmetics.IsAllowedAvatarURL(msg.AvatarURL, h.avatarHosts) {
+			join.options.AvatarURL = msg.AvatarURL
+		} else {
+			join.warnings = append(join.warnings, cosmetics.WarningAvatarURLRejected)
+		}
+	}
+
+	return join
+}
+
+// sanitizedJoin holds validated join options and any dropped-field warnings
+type sanitizedJoin struct {
+	options  matchmaker.JoinOptions
+	warnings []string
+	token    string // resumes an existing game
+}
+
 // handleJoin handles a player joining the matchmaking queue
-func (h *Handler) handleJoin(client *Client) {
+func (h *Handler) handleJoin(client *Client, join sanitizedJoin) {
 	// Check for existing game to reconnect
 	existingGame := h.matchmaker.GetGameByPlayer(client.username)
 	if existingGame != nil && existingGame.GetState().Status != game.StatusFinished {
-		h.handleReconnectToGame(client, existingGame)
+		h.handleReconnectToGame(client, existingGame, join.token)
 		return
 	}
 
 	// Notify client they're waiting
+	for _, warning := range join.warnings {
+		h.hub.logger.Info("dropped cosmetic", "username", client.username, "warning", warning)
+	}
 	client.sendMessage(Message{
-		Type:    TypeWaiting,
-		Message: "Looking for opponent...",
+		Type:     TypeWaiting,
+		Message:  "Looking for opponent...",
+		Warnings: join.warnings,
 	})
 
-	// Join matchmaking queue
-	gameChan, err := h.matchmaker.JoinQueue(client.username)
+	// Join matchmaking queue; the match is abandoned if the socket closes
+	join.options.Cancel = client.done
+	gameChan, err := h.matchmaker.JoinQueue(client.username, join.options)
+	if errors.Is(err, matchmaker.ErrServerBusy) {
+		client.sendMessage(Message{Type: TypeError, Message: err.Error(), Reason: ReasonServerBusy})
+		return
+	}
 	if err != nil {
 		client.sendMessage(Message{Type: TypeError, Message: err.Error()})
 		return
 	}
 
-	// Wait for match in goroutine
-	go func() {
-		g := <-gameChan
+	// Wait for match in goroutine, sending queue updates until it arrives
+	safego.Go(h.hub.logger.With("username", client.username), "match wait", func() {
+		ticker := time.NewTicker(waitingUpdateInterval)
+		defer ticker.Stop()
+		heartbeat := ticker.C
+
+		// After a disconnect keep waiting: the matchmaker closes the channel,
+		// or a match made in the meantime is handled as a disconnect below
+		closed := client.done
+		var g *game.Game
+	wait:
+		for {
+			select {
+			case g = <-gameChan:
+				break wait
+			case <-closed:
+				closed, heartbeat = nil, nil
+			case <-heartbeat:
+				if status, ok := h.matchmaker.QueueStatus(client.username); ok {
+					client.sendMessage(waitingMessage(status))
+				}
+			}
+		}
 		if g == nil {
+			// Channel closed by LeaveQueue; the client stays unassigned
 			return
 		}
 
-		// Register client to game
+		// Register client to game and start the turn clock
 		h.hub.RegisterToGame(g.ID, client)
+		h.hub.ScheduleTurnTimer(g)
+
+		// The socket may have closed while the match was being made; treat
+		// it as a disconnect so the game doesn't wait on a player who's gone
+		if client.isClosed() {
+			h.hub.logger.Info("client disconnected before being matched", "username", client.username, "gameID", g.ID)
+			h.hub.handleDisconnect(client)
+			return
+		}
 
 		// Determine opponent
 		state := g.GetState()
 		opponent := state.Player2
-		yourTurn := state.CurrentTurn == game.Player1
 		if client.username == state.Player2 {
 			opponent = state.Player1
-			yourTurn = state.CurrentTurn == game.Player2
 		}
 
-		// Send matched message
+		// Send matched message with the token controlling this seat
+		playerNum := g.GetPlayerByUsername(client.username)
 		client.sendMessage(Message{
-			Type:      TypeMatched,
-			GameID:    g.ID,
-			Opponent:  opponent,
-			YourTurn:  yourTurn,
-			PlayerNum: g.GetPlayerByUsername(client.username),
-			State:     state,
+			Type:        TypeMatched,
+			GameID:      g.ID,
+			Opponent:    opponent,
+			YourTurn:    state.CurrentTurnUsername == client.username,
+			FirstPlayer: state.FirstPlayerUsername(),
+			PlayerNum:   playerNum,
+			State:       state,
+			Token:       h.matchmaker.PlayerToken(g.ID, playerNum),
 		})
-	}()
+
+		// A bot moving first opens as soon as the player knows the game
+		if state.IsVsBot && state.CurrentTurn == state.BotPlayer {
+			h.hub.goBotMove(g)
+		}
+	}, nil)
 }
 
-// handleMove handles a player making a move
-func (h *Handler) handleMove(client *Client, column int) {
-	log.Printf("[handleMove] Player %s attempting move on column %d", client.username, column)
-	
-	if client.gameID == "" {
-		log.Printf("[handleMove] Error: client has no gameID")
-		client.sendMessage(Message{Type: TypeError, Message: "Not in a game"})
+// waitingMessage builds a queue update for a waiting player
+func waitingMessage(status matchmaker.QueueStatus) Message {
+	msg := Message{
+		Type:           TypeWaiting,
+		Message:        "Looking for opponent...",
+		QueuePosition:  status.Position,
+		PlayersWaiting: status.Waiting,
+		EstimatedWait:  int(status.EstimatedWait.Round(time.Second) / time.Second),
+	}
+	if !status.NoBotFallback {
+		// Round up so the countdown doesn't hit zero before the bot arrives
+		msg.BotFallbackIn = int((status.BotFallbackIn + time.Second - 1) / time.Second)
+	}
+	return msg
+}
+
+// handleLeaveQueue takes the sender out of matchmaking. Closing the match
+// channel ends the join goroutine without registering the client to a game.
+func (h *Handler) handleLeaveQueue(client *Client) {
+	if err := h.matchmaker.LeaveQueue(client.username); err != nil {
+		client.sendMessage(Message{Type: TypeError, Message: err.Error()})
 		return
 	}
+	h.hub.logger.Info("left the queue", "username", client.username)
+	client.sendMessage(Message{Type: TypeQueueLeft})
+}
+
+// authorize checks the seat token for a player's action in a game
+func (h *Handler) authorize(client *Client, g *game.Game, playerNum int, token string) bool {
+	if err := h.matchmaker.ValidateToken(g.ID, playerNum, token); err != nil {
+		h.hub.logger.Warn("rejected action", "username", client.username, "gameID", g.ID, "error", err)
+		client.sendMessage(Message{Type: TypeError, Message: err.Error()})
+		return false
+	}
+	return true
+}
 
-	g := h.matchmaker.GetGame(client.gameID)
+// seatedGame returns the sender's current game and their seat in it,
+// checking token for the seat. Otherwise it replies with the error and
+// returns false.
+func (h *Handler) seatedGame(client *Client, token string) (*game.Game, int, bool) {
+	gameID := h.hub.clientGameID(client)
+	if gameID == "" {
+		client.sendMessage(Message{Type: TypeError, Message: "Not in a game"})
+		return nil, 0, false
+	}
+
+	g := h.matchmaker.GetGame(gameID)
 	if g == nil {
-		log.Printf("[handleMove] Error: game not found for ID %s", client.gameID)
-		client.sendMessage(Message{Type: TypeError, Message: "Game not found"})
-		return
+		client.sendMessage(Message{Type: TypeError, Message: game.ErrGameNotFound.Error()})
+		return nil, 0, false
 	}
 
 	playerNum := g.GetPlayerByUsername(client.username)
 	if playerNum == 0 {
-		log.Printf("[handleMove] Error: player not found in game")
-		client.sendMessage(Message{Type: TypeError, Message: "Player not found"})
+		client.sendMessage(Message{Type: TypeError, Message: game.ErrPlayerNotFound.Error()})
+		return nil, 0, false
+	}
+	if !h.authorize(client, g, playerNum, token) {
+		return nil, 0, false
+	}
+	return g, playerNum, true
+}
+
+// handleResign concedes the sender's current game
+func (h *Handler) handleResign(client *Client, token string) {
+	g, playerNum, ok := h.seatedGame(client, token)
+	if !ok {
 		return
 	}
 
-	log.Printf("[handleMove] Player %s (num=%d) making move on column %d", client.username, playerNum, column)
-	row, err := g.MakeMove(playerNum, column)
+	if err := g.Resign(playerNum); err != nil {
+		client.sendMessage(Message{Type: TypeError, Message: err.Error()})
+		return
+	}
+	h.hub.logger.Info("player resigned", "username", client.username, "gameID", g.ID)
+
+	h.hub.handleGameEnd(g)
+	h.hub.broadcastToGame(g.ID, Message{
+		Type:   TypeGameOver,
+		Winner: g.GetState().Winner,
+		Reason: string(game.ResultResign),
+	})
+}
+
+// handleLeaveGame handles a player quitting their game on purpose: they
+// lose at once, without the reconnect window a lost connection gets
+func (h *Handler) handleLeaveGame(client *Client, token string) {
+	g, playerNum, ok := h.seatedGame(client, token)
+	if !ok {
+		return
+	}
+
+	if err := h.hub.abandonGame(g, playerNum, client.username); err != nil {
+		client.sendMessage(Message{Type: TypeError, Message: err.Error()})
+	}
+}
+
+// handleHover relays the column a player is pointing at to their
+// opponent. Hovers are best effort: they are never answered with errors,
+// and are dropped for bot games, for anyone not seated in the game and
+// once the game is over.
+func (h *Handler) handleHover(client *Client, column int, token string) {
+	gameID := h.hub.clientGameID(client)
+	if gameID == "" {
+		return
+	}
+	g := h.matchmaker.GetGame(gameID)
+	if g == nil || column >= g.Board.Columns() {
+		return
+	}
+	playerNum := g.GetPlayerByUsername(client.username)
+	if playerNum == 0 || h.matchmaker.ValidateToken(g.ID, playerNum, token) != nil {
+		return
+	}
+
+	client.throttled(TypeHover, hoverInterval, func() {
+		state := g.GetState()
+		if state.IsVsBot || state.Status != game.StatusPlaying || client.isClosed() {
+			return
+		}
+		h.hub.notifyOpponent(g, playerNum, Message{Type: TypeOpponentHover, GameID: g.ID, HoverColumn: &column})
+	})
+}
+
+// handleHint replies with a suggested move for the sender's turn
+func (h *Handler) handleHint(client *Client, token string) {
+	g, playerNum, ok := h.seatedGame(client, token)
+	if !ok {
+		return
+	}
+
+	hint, err := g.Hint(playerNum)
 	if err != nil {
-		log.Printf("[handleMove] MakeMove error: %v", err)
 		client.sendMessage(Message{Type: TypeError, Message: err.Error()})
 		return
 	}
-	log.Printf("[handleMove] Move succeeded, disc placed at row %d", row)
+
+	client.sendMessage(Message{Type: TypeHint, GameID: g.ID, Hint: hint})
+}
+
+// handleTakebackRequest passes the sender's request to take back their
+// last move on to the opponent
+func (h *Handler) handleTakebackRequest(client *Client, token string) {
+	g, playerNum, ok := h.seatedGame(client, token)
+	if !ok {
+		return
+	}
+
+	n, err := g.RequestTakeback(playerNum)
+	if err != nil {
+		client.sendMessage(Message{Type: TypeError, Message: err.Error(), GameID: g.ID})
+		return
+	}
+
+	h.hub.logger.Info("takeback requested", "gameID", g.ID, "username", client.username, "moves", n)
+	h.hub.notifyOpponent(g, playerNum, Message{
+		Type:      TypeTakebackRequest,
+		GameID:    g.ID,
+		Username:  client.username,
+		UndoMoves: n,
+	})
+}
+
+// handleTakebackResponse answers the opponent's takeback request. An
+// accepted takeback restarts the turn clock and is broadcast as a new state.
+func (h *Handler) handleTakebackResponse(client *Client, accept bool, token string) {
+	g, playerNum, ok := h.seatedGame(client, token)
+	if !ok {
+		return
+	}
+
+	_, undone, err := g.AnswerTakeback(playerNum, accept)
+	if err != nil {
+		client.sendMessage(Message{Type: TypeError, Message: err.Error(), GameID: g.ID})
+		return
+	}
+
+	h.hub.logger.Info("takeback answered", "gameID", g.ID, "username", client.username, "accepted", accept, "undone", undone)
+	h.hub.notifyOpponent(g, playerNum, Message{
+		Type:      TypeTakebackResponse,
+		GameID:    g.ID,
+		Username:  client.username,
+		Accepted:  &accept,
+		UndoMoves: undone,
+	})
+	if accept {
+		h.hub.ScheduleTurnTimer(g)
+		h.hub.BroadcastGameState(g)
+	}
+}
+
+// handleOfferDraw passes the sender's draw offer on to the opponent. An
+// offer nobody answers lapses after game.DrawOfferTimeout, and both players
+// are told.
+func (h *Handler) handleOfferDraw(client *Client, token string) {
+	g, playerNum, ok := h.seatedGame(client, token)
+	if !ok {
+		return
+	}
+
+	username := client.username
+	_, err := g.OfferDraw(playerNum, func() {
+		safego.Run(h.hub.logger.With("gameID", g.ID), "draw offer expiry", func() {
+			declined := false
+			h.hub.broadcastToGame(g.ID, Message{
+				Type:     TypeDrawResponse,
+				GameID:   g.ID,
+				Username: username,
+				Accepted: &declined,
+				Reason:   "expired",
+			})
+		}, nil)
+	})
+	if err != nil {
+		client.sendMessage(Message{Type: TypeError, Message: err.Error(), GameID: g.ID})
+		return
+	}
+
+	h.hub.logger.Info("draw offered", "gameID", g.ID, "username", client.username)
+	h.hub.notifyOpponent(g, playerNum, Message{
+		Type:             TypeOfferDraw,
+		GameID:           g.ID,
+		Username:         client.username,
+		SecondsRemaining: int(game.DrawOfferTimeout.Seconds()),
+	})
+}
+
+// handleDrawResponse answers the opponent's draw offer. An accepted draw
+// ends the game like any other.
+func (h *Handler) handleDrawResponse(client *Client, accept bool, token string) {
+	g, playerNum, ok := h.seatedGame(client, token)
+	if !ok {
+		return
+	}
+
+	if _, err := g.AnswerDraw(playerNum, accept); err != nil {
+		client.sendMessage(Message{Type: TypeError, Message: err.Error(), GameID: g.ID})
+		return
+	}
+	h.hub.logger.Info("draw offer answered", "gameID", g.ID, "username", client.username, "accepted", accept)
+
+	h.hub.notifyOpponent(g, playerNum, Message{
+		Type:     TypeDrawResponse,
+		GameID:   g.ID,
+		Username: client.username,
+		Accepted: &accept,
+	})
+	if !accept {
+		return
+	}
+
+	h.hub.handleGameEnd(g)
+	h.hub.broadcastToGame(g.ID, Message{
+		Type:   TypeGameOver,
+		Reason: string(game.ResultDraw),
+	})
+}
+
+// handleMove handles a player making a move and replies with an ack or
+// an error. A move whose msgId was already processed gets the same reply
+// again without being reapplied.
+func (h *Handler) handleMove(client *Client, column int, token, msgID string) {
+	if reply, ok := client.movePreviouslyProcessed(msgID); ok {
+		h.hub.logger.Debug("duplicate move", "username", client.username, "msgId", msgID)
+		h.sendMoveReply(client, reply)
+		return
+	}
+
+	reply := h.applyMove(client, column, token)
+	reply.MsgID = msgID
+	if reply.Type == TypeError {
+		reply.AttemptedColumn = &column
+	}
+	client.rememberMove(msgID, reply)
+	h.sendMoveReply(client, reply)
+}
+
+// sendMoveReply sends the reply to a move. A rejection from a game the
+// client is seated in carries the game's current state, so the client can
+// correct its board without waiting for the next broadcast.
+func (h *Handler) sendMoveReply(client *Client, reply Message) {
+	if reply.Type == TypeError && reply.GameID != "" {
+		if g := h.matchmaker.GetGame(reply.GameID); g != nil {
+			h.hub.sendWithState(client, g, reply)
+			return
+		}
+	}
+	client.sendMessage(reply)
+}
+
+// applyMove plays a move and sends out its effects, returning the reply
+// for the mover
+func (h *Handler) applyMove(client *Client, column int, token string) Message {
+	logger := h.hub.logger.With("username", client.username, "messageType", TypeMove, "column", column)
+
+	gameID := h.hub.clientGameID(client)
+	if gameID == "" {
+		logger.Debug("move rejected: not in a game")
+		return Message{Type: TypeError, Message: "Not in a game"}
+	}
+
+	g := h.matchmaker.GetGame(gameID)
+	if g == nil {
+		logger.Debug("move rejected: game not found", "gameID", gameID)
+		return Message{Type: TypeError, Message: "Game not found"}
+	}
+
+	playerNum := g.GetPlayerByUsername(client.username)
+	if playerNum == 0 {
+		logger.Debug("move rejected: player not in game", "gameID", g.ID)
+		return Message{Type: TypeError, Message: "Player not found"}
+	}
+	if err := h.matchmaker.ValidateToken(g.ID, playerNum, token); err != nil {
+		h.hub.logger.Warn("rejected action", "username", client.username, "gameID", g.ID, "error", err)
+		return Message{Type: TypeError, GameID: g.ID, Message: err.Error()}
+	}
+
+	logger = logger.With("gameID", g.ID, "player", playerNum)
+	row, err := g.MakeMove(playerNum, column)
+	if err != nil {
+		logger.Debug("move rejected", "error", err)
+		return Message{Type: TypeError, GameID: g.ID, Message: err.Error()}
+	}
+	logger.Debug("move made", "row", row)
+	ack := Message{Type: TypeAck, GameID: g.ID, Column: column, Row: row}
+	h.hub.handleMoveMade(g, client.username, column, row)
 
 	// Broadcast updated state
 	h.hub.BroadcastGameState(g)
 
 	// Check if game ended
 	state := g.GetState()
-	log.Printf("[handleMove] After move - Status=%s, CurrentTurn=%d, IsVsBot=%v", state.Status, state.CurrentTurn, state.IsVsBot)
-	
 	if state.Status == game.StatusFinished {
-		log.Printf("[handleMove] Game finished, winner=%s", state.Winner)
+		logger.Debug("game finished", "winner", state.Winner, "result", state.Result)
 		h.hub.broadcastToGame(g.ID, Message{
-			Type:   TypeGameOver,
-			Winner: state.Winner,
-			Reason: state.Result,
+			Type:         TypeGameOver,
+			Winner:       state.Winner,
+			Reason:       state.Result,
+			WinningCells: state.WinningCells,
 		})
 		h.hub.handleGameEnd(g)
-		return
+		return ack
 	}
 
 	// If next turn is bot, make bot move
-	log.Printf("[handleMove] Checking bot trigger: Player2=%v, IsBot=%v, CurrentTurn=%d", 
-		g.Player2 != nil, g.Player2 != nil && g.Player2.IsBot, state.CurrentTurn)
-	
-	if g.Player2 != nil && g.Player2.IsBot && state.CurrentTurn == game.Player2 {
-		log.Printf("[handleMove] Triggering bot move...")
-		go h.hub.HandleBotMove(g)
+	if state.IsVsBot && state.CurrentTurn == state.BotPlayer {
+		h.hub.goBotMove(g)
 	} else {
-		log.Printf("[handleMove] Bot move NOT triggered")
+		h.hub.ScheduleTurnTimer(g)
 	}
-	
-	_ = row // row is part of broadcast state
+	return ack
+}
+
+// handleSync replies with the current state of the client's game, and
+// the game over if it has finished, for a client that may have missed
+// a broadcast
+func (h *Handler) handleSync(client *Client) {
+	if !client.syncs.Allow() {
+		client.sendMessage(Message{Type: TypeError, Message: ErrSyncRateLimited.Error()})
+		return
+	}
+	gameID := h.hub.clientGameID(client)
+	if gameID == "" {
+		client.sendMessage(Message{Type: TypeError, Message: "Not in a game"})
+		return
+	}
+	g := h.matchmaker.GetGame(gameID)
+	if g == nil {
+		g = h.matchmaker.GetFinishedGame(gameID)
+	}
+	if g == nil {
+		client.sendMessage(Message{Type: TypeError, Message: "Game not found"})
+		return
+	}
+
+	h.hub.sendSync(client, g)
 }
 
 // handleReconnect handles a player trying to reconnect to a game
-func (h *Handler) handleReconnect(client *Client, gameID string) {
+func (h *Handler) handleReconnect(client *Client, gameID, token string) {
 	g := h.matchmaker.GetGame(gameID)
 	if g == nil {
 		// Try to find by player
 		g = h.matchmaker.GetGameByPlayer(client.username)
 	}
+	if g == nil {
+		// The game may have ended while they were away
+		g = h.matchmaker.GetFinishedGame(gameID)
+	}
+	if g == nil {
+		g = h.matchmaker.GetFinishedGameByPlayer(client.username)
+	}
 
 	if g == nil {
 		client.sendMessage(Message{Type: TypeError, Message: "Game not found"})
 		return
 	}
 
-	h.handleReconnectToGame(client, g)
+	h.handleReconnectToGame(client, g, token)
 }
 
 // handleReconnectToGame handles reconnection to a specific game
-func (h *Handler) handleReconnectToGame(client *Client, g *game.Game) {
+func (h *Handler) handleReconnectToGame(client *Client, g *game.Game, token string) {
 	playerNum := g.GetPlayerByUsername(client.username)
 	if playerNum == 0 {
 		client.sendMessage(Message{Type: TypeError, Message: "Not a player in this game"})
 		return
 	}
+	if !h.authorize(client, g, playerNum, token) {
+		return
+	}
 
-	// Try to reconnect
-	if !g.PlayerReconnected(playerNum) {
-		state := g.GetState()
-		if state.Status == game.StatusFinished {
-			client.sendMessage(Message{Type: TypeError, Message: "Game has already ended"})
+	// A replacement session takes over a player who never disconnected
+	wasDisconnected := !g.IsPlayerConnected(playerNum)
+	if wasDisconnected && !g.PlayerReconnected(playerNum) {
+		if g.GetState().Status == game.StatusFinished {
+			h.showFinishedGame(client, g, playerNum, token)
 			return
 		}
 		client.sendMessage(Message{Type: TypeError, Message: "Reconnection failed"})
 		return
 	}
+	if wasDisconnected {
+		h.hub.reportReconnect(g, client.username, true)
+	}
 
-	// Register client to game
+	// Register client to game and resume the turn clock. A bot game
+	// paused by the disconnect, or restored after a restart, may have
+	// been waiting on the bot's move.
 	h.hub.RegisterToGame(g.ID, client)
+	if state := g.GetState(); wasDisconnected && state.IsVsBot && state.CurrentTurn == state.BotPlayer {
+		h.hub.goBotMove(g)
+	} else {
+		h.hub.ScheduleTurnTimer(g)
+	}
 
 	// Notify opponent
 	h.hub.broadcastToGame(g.ID, Message{
@@ -244,20 +912,34 @@ func (h *Handler) handleReconnectToGame(client *Client, g *game.Game) {
 	})
 
 	// Send current state
-	state := g.GetState()
+	client.sendMessage(matchedMessage(g, client.username, playerNum, token))
+}
+
+// showFinishedGame answers a reconnect to a game that ended while the
+// player was away with its final state and result. The client isn't
+// registered to the game, which has nothing more to broadcast.
+func (h *Handler) showFinishedGame(client *Client, g *game.Game, playerNum int, token string) {
+	client.sendMessage(matchedMessage(g, client.username, playerNum, token))
+	h.hub.sendSync(client, g)
+}
+
+// matchedMessage tells a reconnecting player where their game stands,
+// with the moves so far to catch up on
+func matchedMessage(g *game.Game, username string, playerNum int, token string) Message {
+	state := g.GetStateWithHistory()
 	opponent := state.Player2
-	yourTurn := state.CurrentTurn == game.Player1
-	if client.username == state.Player2 {
+	if username == state.Player2 {
 		opponent = state.Player1
-		yourTurn = state.CurrentTurn == game.Player2
 	}
 
-	client.sendMessage(Message{
-		Type:      TypeMatched,
-		GameID:    g.ID,
-		Opponent:  opponent,
-		YourTurn:  yourTurn,
-		PlayerNum: playerNum,
-		State:     state,
-	})
+	return Message{
+		Type:        TypeMatched,
+		GameID:      g.ID,
+		Opponent:    opponent,
+		YourTurn:    state.CurrentTurnUsername == username,
+		FirstPlayer: state.FirstPlayerUsername(),
+		PlayerNum:   playerNum,
+		State:       state,
+		Token:       token,
+	}
 }
@@ -0,0 +1,222 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/connect-four/internal/cosmetics"
+	"github.com/connect-four/internal/game"
+	"github.com/connect-four/internal/jsonutil"
+	"github.com/connect-four/internal/matchmaker"
+)
+
+// largestJoin is a join with every string field at its Validate limit
+func largestJoin() IncomingMessage {
+	allowBot := true
+	return IncomingMessage{
+		Type:          TypeJoin,
+		GameID:        strings.Repeat("g", 64),
+		Username:      strings.Repeat("u", 50),
+		Token:         strings.Repeat("t", 64),
+		BotDifficulty: "medium",
+		BotFirst:      true,
+		AllowBot:      &allowBot,
+		DiscEmoji:     strings.Repeat("\U0001F600", cosmetics.MaxDiscEmojiBytes/4),
+		AvatarURL:     "https://example.com/" + strings.Repeat("<", cosmetics.MaxAvatarURLLength-len("https://example.com/")),
+		Rows:          6,
+		Columns:       7,
+		WinLength:     4,
+	}
+}
+
+func TestLargestValidJoinFitsReadLimit(t *testing.T) {
+	msg := largestJoin()
+	if err := msg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) > maxMessageSize {
+		t.Fatalf("largest valid join is %d bytes, over the %d byte read limit", len(data), maxMessageSize)
+	}
+}
+
+func TestValidateCosmeticLimits(t *testing.T) {
+	msg := largestJoin()
+	msg.DiscEmoji += "x"
+	if msg.Validate() == nil {
+		t.Error("disc emoji over the limit was accepted")
+	}
+	msg = largestJoin()
+	msg.AvatarURL += "x"
+	if msg.Validate() == nil {
+		t.Error("avatar URL over the limit was accepted")
+	}
+}
+
+// invalidFields returns the fields Validate reports for msg
+func invalidFields(t *testing.T, msg IncomingMessage) []string {
+	t.Helper()
+	var verr *jsonutil.ValidationError
+	if err := msg.Validate(); !errors.As(err, &verr) {
+		t.Fatalf("Validate = %v, want a ValidationError", err)
+	}
+	var fields []string
+	for _, f := range verr.Fields {
+		fields = append(fields, f.Field)
+	}
+	return fields
+}
+
+func TestValidateCosmeticsOnlyOnJoin(t *testing.T) {
+	column := 3
+	move := IncomingMessage{Type: TypeMove, Column: &column}
+	tests := []struct {
+		name  string
+		edit  func(*IncomingMessage)
+		wants []string
+	}{
+		{"disc emoji", func(m *IncomingMessage) { m.DiscEmoji = "\U0001F525" }, []string{"discEmoji"}},
+		{"avatar URL", func(m *IncomingMessage) { m.AvatarURL = "https://example.com/a.png" }, []string{"avatarUrl"}},
+		{"both", func(m *IncomingMessage) {
+			m.DiscEmoji = "\U0001F525"
+			m.AvatarURL = "https://example.com/a.png"
+		}, []string{"discEmoji", "avatarUrl"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := move
+			tt.edit(&msg)
+			if got := invalidFields(t, msg); !slices.Equal(got, tt.wants) {
+				t.Errorf("invalid fields = %q, want %q", got, tt.wants)
+			}
+		})
+	}
+}
+
+// checkRequiredFields fails t if msg lacks a field its type requires
+func checkRequiredFields(t *testing.T, msg *IncomingMessage) {
+	t.Helper()
+	switch msg.Type {
+	case TypeMove, TypeHover:
+		if msg.Column == nil || *msg.Column < 0 || *msg.Column >= game.MaxBoardSide {
+			t.Fatalf("accepted %s without a column in range: %+v", msg.Type, msg)
+		}
+	case TypeTakebackResponse, TypeDrawResponse:
+		if msg.Accept == nil {
+			t.Fatalf("accepted %s without accept", msg.Type)
+		}
+	case TypeReplay:
+		if msg.GameID == "" {
+			t.Fatal("accepted replay without a gameId")
+		}
+	case TypeReplaySeek:
+		if msg.Move == nil || *msg.Move < 0 {
+			t.Fatalf("accepted replaySeek without a valid move: %+v", msg)
+		}
+	case TypeJoin, TypeReconnect, TypeResign, TypeLeaveGame, TypeHint, TypeLeaveQueue, TypeSync,
+		TypeReplayPause, TypeReplayResume, TypeReplayStop, TypeTakebackRequest, TypeOfferDraw:
+	default:
+		t.Fatalf("accepted message with type %q", msg.Type)
+	}
+	if msg.Type != TypeJoin && (msg.DiscEmoji != "" || msg.AvatarURL != "" || msg.BotDifficulty != "") {
+		t.Fatalf("accepted join options on %s", msg.Type)
+	}
+	if msg.Type != TypeMove && msg.MsgID != "" {
+		t.Fatalf("accepted a msgId on %s", msg.Type)
+	}
+}
+
+// FuzzIncomingMessageValidate decodes through the same strict path as
+// HandleMessage. Decoding and Validate must never panic, and anything
+// accepted must carry its type's required fields and fit the read limit.
+func FuzzIncomingMessageValidate(f *testing.F) {
+	seeds := []string{
+		`{"type":"join"}`,
+		`{"type":"join","discEmoji":"🔥","avatarUrl":"https://example.com/a.png"}`,
+		`{"type":"join","rows":7,"columns":8,"winLength":5,"botDifficulty":"hard"}`,
+		`{"type":"move","column":3,"token":"abc","msgId":"m1"}`,
+		`{"type":"move"}`,
+		`{"type":"move","column":3} {}`,
+		`{"type":"move","column":"3"}`,
+		`{"type":"move","column":3,"extra":1}`,
+		`{"type":"replay","gameId":"g","speed":2}`,
+		`{"type":"replaySeek","move":4}`,
+		`{"type":"takebackResponse","accept":true}`,
+		`{"type":"drawResponse"}`,
+		`{"type":"hover","column":-1}`,
+		`{"type":"resign","discEmoji":"x"}`,
+		`{"type":"nope"}`,
+		`null`,
+		``,
+	}
+	for _, seed := range seeds {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var msg IncomingMessage
+		if err := jsonutil.Decode(data, &msg); err != nil {
+			var verr *jsonutil.ValidationError
+			if !errors.As(err, &verr) || len(verr.Fields) == 0 {
+				t.Fatalf("rejected with %v, want a ValidationError naming a problem", err)
+			}
+			return
+		}
+		checkRequiredFields(t, &msg)
+		out, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatalf("re-encoding accepted message: %v", err)
+		}
+		if len(out) > maxMessageSize {
+			t.Fatalf("accepted message is %d bytes re-encoded, over the %d byte read limit", len(out), maxMessageSize)
+		}
+	})
+}
+
+func TestLargestValidJoinOverSocket(t *testing.T) {
+	s := newTestServer(t)
+	c := s.dial(t, "alice", nil)
+	msg := largestJoin()
+	msg.GameID, msg.Token = "", ""
+	if err := c.conn.WriteJSON(msg); err != nil {
+		t.Fatal(err)
+	}
+	c.readType(TypeWaiting)
+}
+
+func TestSeatedActionsCheckGameAndToken(t *testing.T) {
+	s := newTestServer(t)
+	alice, matched, _, _ := s.matchPlayers(t, "alice", "bob")
+	carol := s.dial(t, "carol", nil)
+	g := s.mm.GetGame(matched.GameID)
+
+	actions := []map[string]interface{}{
+		{"type": TypeResign},
+		{"type": TypeLeaveGame},
+		{"type": TypeHint},
+		{"type": TypeTakebackRequest},
+		{"type": TypeTakebackResponse, "accept": true},
+		{"type": TypeOfferDraw},
+		{"type": TypeDrawResponse, "accept": true},
+	}
+	for _, action := range actions {
+		carol.send(action)
+		if reply := carol.readType(TypeError); reply.Message != "Not in a game" {
+			t.Errorf("%s outside a game: error %q", action["type"], reply.Message)
+		}
+
+		action["token"] = "wrong"
+		alice.send(action)
+		if reply := alice.readType(TypeError); reply.Message != matchmaker.ErrInvalidToken.Error() {
+			t.Errorf("%s with a wrong token: error %q", action["type"], reply.Message)
+		}
+	}
+	if state := g.GetState(); state.Status != game.StatusPlaying {
+		t.Errorf("status = %s after actions with a wrong token, want playing", state.Status)
+	}
+}
@@ -0,0 +1,141 @@
+package websocket
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/connect-four/internal/matchmaker"
+	gws "github.com/gorilla/websocket"
+)
+
+// testServer runs a hub and its handler behind a real HTTP server
+type testServer struct {
+	mm      *matchmaker.Matchmaker
+	hub     *Hub
+	handler *Handler
+	srv     *httptest.Server
+}
+
+// newTestServer starts a hub; configure it with setup before it runs
+func newTestServer(t *testing.T, setup ...func(*Hub, *matchmaker.Matchmaker)) *testServer {
+	t.Helper()
+	mm := matchmaker.NewMatchmaker()
+	hub := NewHub(mm)
+	hub.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	for _, f := range setup {
+		f(hub, mm)
+	}
+	go hub.Run()
+	handler := NewHandler(hub, mm)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ServeWs(hub, handler, w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return &testServer{mm: mm, hub: hub, handler: handler, srv: srv}
+}
+
+// testConn is a client connection reading the hub's messages
+type testConn struct {
+	t    *testing.T
+	conn *gws.Conn
+}
+
+// dial connects as username with any extra query parameters
+func (s *testServer) dial(t *testing.T, username string, query url.Values) *testConn {
+	t.Helper()
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("username", username)
+	u := "ws" + strings.TrimPrefix(s.srv.URL, "http") + "?" + query.Encode()
+	conn, _, err := gws.DefaultDialer.Dial(u, nil)
+	if err != nil {
+		t.Fatalf("dial %s: %v", username, err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &testConn{t: t, conn: conn}
+}
+
+// send writes a message
+func (c *testConn) send(msg map[string]interface{}) {
+	c.t.Helper()
+	if err := c.conn.WriteJSON(msg); err != nil {
+		c.t.Fatalf("send %v: %v", msg, err)
+	}
+}
+
+// read returns the next message, failing the test after a few seconds
+func (c *testConn) read() Message {
+	c.t.Helper()
+	msg, err := c.tryRead(5 * time.Second)
+	if err != nil {
+		c.t.Fatalf("read: %v", err)
+	}
+	return msg
+}
+
+// tryRead returns the next message or the read error
+func (c *testConn) tryRead(wait time.Duration) (Message, error) {
+	c.conn.SetReadDeadline(time.Now().Add(wait))
+	_, data, err := c.conn.ReadMessage()
+	if err != nil {
+		return Message{}, err
+	}
+	var msg Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		c.t.Fatalf("decoding %q: %v", data, err)
+	}
+	return msg, nil
+}
+
+// readType skips messages until one of type msgType arrives
+func (c *testConn) readType(msgType string) Message {
+	c.t.Helper()
+	for {
+		if msg := c.read(); msg.Type == msgType {
+			return msg
+		}
+	}
+}
+
+// readMoves skips messages until a state with at least n moves arrives
+func (c *testConn) readMoves(n int) {
+	c.t.Helper()
+	for {
+		if msg := c.read(); msg.State != nil && msg.State.MoveCount >= n {
+			return
+		}
+	}
+}
+
+// waitFor polls cond until it holds, failing after a few seconds
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// matchPlayers connects a and b and pairs them in a human game,
+// returning both connections and their matched messages
+func (s *testServer) matchPlayers(t *testing.T, a, b string) (*testConn, Message, *testConn, Message) {
+	t.Helper()
+	ca := s.dial(t, a, nil)
+	ca.send(map[string]interface{}{"type": "join", "allowBot": false})
+	ca.readType(TypeWaiting)
+	waitFor(t, a+" to queue", func() bool { return s.mm.GetWaitingCount() == 1 })
+	cb := s.dial(t, b, nil)
+	cb.send(map[string]interface{}{"type": "join", "allowBot": false})
+	return ca, ca.readType(TypeMatched), cb, cb.readType(TypeMatched)
+}
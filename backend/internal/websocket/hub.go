@@ -1,13 +1,40 @@
 package websocket
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
+	"maps"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/connect-four/internal/auth"
 	"github.com/connect-four/internal/game"
+	"github.com/connect-four/internal/logging"
 	"github.com/connect-four/internal/matchmaker"
+	"github.com/connect-four/internal/origins"
+	"github.com/connect-four/internal/safego"
+	"github.com/connect-four/internal/usernames"
+)
+
+// reconnectCountdownInterval is how often the opponent is told how long a
+// disconnected player has left to reconnect
+const reconnectCountdownInterval = 5 * time.Second
+
+const (
+	// shutdownMoveGrace is how long moves already on their way are given
+	// to land after players are told the server is shutting down
+	shutdownMoveGrace = 2 * time.Second
+
+	// minMovesToSaveAborted is how many moves an interrupted game needs
+	// (one from each player) before it is worth persisting
+	minMovesToSaveAborted = 2
+
+	// defaultBotMoveDelay is how long the bot pauses before moving, so its
+	// replies don't feel instant
+	defaultBotMoveDelay = 500 * time.Millisecond
 )
 
 // Hub maintains the set of active clients and broadcasts messages
@@ -18,6 +45,9 @@ type Hub struct {
 	// Clients by game ID
 	gameClients map[string]map[string]*Client
 
+	// Broadcast ordering by game ID, see gameSequence
+	sequences map[string]*gameSequence
+
 	// Register requests from clients
 	register chan *Client
 
@@ -28,7 +58,82 @@ type Hub struct {
 	matchmaker *matchmaker.Matchmaker
 
 	// Callbacks
-	onGameEnd func(g *game.Game)
+	onGameEnd    func(g *game.Game)
+	onMove       func(g *game.Game, player string, column, row, moveNum int)
+	onDisconnect func(g *game.Game, player string)
+	onReconnect  func(g *game.Game, player string, success bool)
+
+	// Total number of game broadcasts sent
+	broadcasts atomic.Int64
+
+	// Counters behind Stats, kept outside the lock. connected tracks
+	// len(clients); games and gameClientCount track gameClients.
+	connected        atomic.Int64
+	games            atomic.Int64
+	gameClientCount  atomic.Int64
+	messagesSent     atomic.Int64
+	messagesReceived atomic.Int64
+	messagesDropped  atomic.Int64
+
+	// Turn clock timers by game ID
+	turnTimers map[string]*time.Timer
+
+	// Background tasks running for each game, counted by task name. It
+	// has its own lock so tasks can start while h.mu is held.
+	taskMu sync.Mutex
+	tasks  map[string]map[string]int
+
+	// What happens when a turn clock runs out
+	turnTimeoutAction TurnTimeoutAction
+
+	// What happens when a username connects twice
+	sessionPolicy SessionPolicy
+
+	// How long the bot pauses before moving
+	botMoveDelay time.Duration
+
+	// Heartbeat settings for new connections
+	pingPeriod time.Duration
+	pongWait   time.Duration
+
+	// Source for forced random moves
+	rng *game.Rand
+
+	logger *slog.Logger
+
+	// Looks up the stored spelling of a username, see CanonicalUsername
+	resolveName func(ctx context.Context, username string) string
+
+	// Loads completed games for replay, see SetReplayLoader
+	loadReplay func(ctx context.Context, gameID string) (*ReplayGame, error)
+
+	// Verifies the token new connections must present; nil when auth is off
+	auth *auth.Signer
+
+	// Origins allowed to open connections
+	origins *origins.Matcher
+
+	// Per-connection message rate limit for new connections, 0 disables it
+	messageRate  float64
+	messageBurst int
+
+	// Most open connections allowed, 0 for no limit. Slots are taken
+	// under mu, so concurrent upgrades can't overshoot; the counts are
+	// atomic for Stats.
+	maxConnections     int
+	openConnections    atomic.Int64
+	refusedConnections atomic.Int64
+
+	// The last activity of each game its players were warned is idle, by
+	// game ID
+	idleWarned map[string]time.Time
+
+	// Shutdown checkpoints unfinished games instead of aborting them
+	keepGames bool
+
+	// Set once a game-keeping shutdown starts; disconnects no longer
+	// change the games
+	shuttingDown atomic.Bool
 
 	mu sync.RWMutex
 }
@@ -36,12 +141,162 @@ type Hub struct {
 // NewHub creates a new Hub instance
 func NewHub(mm *matchmaker.Matchmaker) *Hub {
 	return &Hub{
-		clients:     make(map[string]*Client),
-		gameClients: make(map[string]map[string]*Client),
-		register:    make(chan *Client),
-		unregister:  make(chan *Client),
-		matchmaker:  mm,
+		clients:           make(map[string]*Client),
+		gameClients:       make(map[string]map[string]*Client),
+		sequences:         make(map[string]*gameSequence),
+		register:          make(chan *Client),
+		unregister:        make(chan *Client),
+		matchmaker:        mm,
+		turnTimers:        make(map[string]*time.Timer),
+		tasks:             make(map[string]map[string]int),
+		idleWarned:        make(map[string]time.Time),
+		turnTimeoutAction: TurnTimeoutForfeit,
+		sessionPolicy:     SessionReject,
+		botMoveDelay:      defaultBotMoveDelay,
+		pingPeriod:        defaultPingPeriod,
+		pongWait:          defaultPongWait,
+		rng:               game.NewTimeSeededRand(),
+		logger:            slog.Default(),
+	}
+}
+
+// SetLogger sets the logger used by the hub, its clients and the message
+// handler; nil falls back to slog's default logger
+func (h *Hub) SetLogger(logger *slog.Logger) {
+	h.logger = logging.OrDefault(logger)
+}
+
+// SetRand sets the random source used for forced timeout moves
+func (h *Hub) SetRand(rng *game.Rand) {
+	h.rng = rng
+}
+
+// SetHeartbeat sets how often connections are pinged and how long a pong
+// may take before the connection is treated as dead. The interval is capped
+// below the timeout so a healthy peer always answers in time.
+func (h *Hub) SetHeartbeat(interval, timeout time.Duration) {
+	if interval >= timeout {
+		interval = timeout * 9 / 10
+	}
+	h.pingPeriod = interval
+	h.pongWait = timeout
+}
+
+// SetAuth requires new connections to present a token signed by signer,
+// taking their username from it; nil turns auth off and usernames come
+// from the query string
+func (h *Hub) SetAuth(signer *auth.Signer) {
+	h.auth = signer
+}
+
+// SetAllowedOrigins limits which browser origins may connect; nil (the
+// default) lets any origin connect
+func (h *Hub) SetAllowedOrigins(allowed *origins.Matcher) {
+	h.origins = allowed
+}
+
+// SetMessageRateLimit limits each new connection to rate messages per
+// second with bursts of up to burst. A rate of zero disables the limit.
+func (h *Hub) SetMessageRateLimit(rate float64, burst int) {
+	h.messageRate = rate
+	h.messageBurst = burst
+}
+
+// SetMaxConnections limits how many WebSocket connections may be open at
+// once; zero removes the limit
+func (h *Hub) SetMaxConnections(n int) {
+	h.maxConnections = n
+}
+
+// admitConnection takes a connection slot, reporting false if the hub is
+// at its connection limit. Every admitted connection releases its slot
+// with releaseConnection.
+func (h *Hub) admitConnection() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.maxConnections > 0 && h.openConnections.Load() >= int64(h.maxConnections) {
+		h.refusedConnections.Add(1)
+		return false
+	}
+	h.openConnections.Add(1)
+	return true
+}
+
+// releaseConnection gives back a slot taken by admitConnection
+func (h *Hub) releaseConnection() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.openConnections.Add(-1)
+}
+
+// SetUsernameResolver sets the lookup returning the spelling a username
+// already has in stored game history, or the name unchanged if it has none
+func (h *Hub) SetUsernameResolver(resolve func(ctx context.Context, username string) string) {
+	h.resolveName = resolve
+}
+
+// CanonicalUsername applies the case policy to a validated username:
+// usernames are unique case-insensitively and keep the spelling first
+// seen, so a connected client's or stored player's spelling wins over
+// the one given
+func (h *Hub) CanonicalUsername(ctx context.Context, username string) string {
+	key := usernames.Fold(username)
+	h.mu.RLock()
+	for existing := range h.clients {
+		if usernames.Fold(existing) == key {
+			h.mu.RUnlock()
+			return existing
+		}
 	}
+	h.mu.RUnlock()
+
+	if h.resolveName != nil {
+		return h.resolveName(ctx, username)
+	}
+	return username
+}
+
+// SessionPolicy selects what happens when a username that is already
+// connected opens another connection
+type SessionPolicy string
+
+const (
+	// SessionReplace closes the old connection with a sessionReplaced message
+	// and hands its game over to the new one, if the new connection proves
+	// it owns the username; otherwise it is refused as under SessionReject
+	SessionReplace SessionPolicy = "replace"
+
+	// SessionReject refuses the new connection with ErrSessionExists
+	SessionReject SessionPolicy = "reject"
+)
+
+// SetSessionPolicy sets how duplicate connections for a username are
+// handled; the default is SessionReject
+func (h *Hub) SetSessionPolicy(policy SessionPolicy) {
+	h.sessionPolicy = policy
+}
+
+// TurnTimeoutAction selects what happens when a player's turn clock expires
+type TurnTimeoutAction string
+
+const (
+	// TurnTimeoutForfeit ends the game with the stalled player losing
+	TurnTimeoutForfeit TurnTimeoutAction = "forfeit"
+
+	// TurnTimeoutRandomMove plays a random valid column for the stalled player
+	TurnTimeoutRandomMove TurnTimeoutAction = "random"
+)
+
+// SetTurnTimeoutAction sets what happens when a turn clock expires
+func (h *Hub) SetTurnTimeoutAction(action TurnTimeoutAction) {
+	h.turnTimeoutAction = action
+}
+
+// SetKeepGamesOnShutdown makes Shutdown save unfinished games through the
+// matchmaker's game store, so they resume after a restart, instead of
+// aborting them
+func (h *Hub) SetKeepGamesOnShutdown(keep bool) {
+	h.keepGames = keep
 }
 
 // SetOnGameEnd sets the callback for when a game ends
@@ -49,40 +304,162 @@ func (h *Hub) SetOnGameEnd(callback func(g *game.Game)) {
 	h.onGameEnd = callback
 }
 
+// SetOnMove sets the callback for when a move is successfully applied
+func (h *Hub) SetOnMove(callback func(g *game.Game, player string, column, row, moveNum int)) {
+	h.onMove = callback
+}
+
+// SetOnDisconnect sets the callback for when a player drops out of a game
+func (h *Hub) SetOnDisconnect(callback func(g *game.Game, player string)) {
+	h.onDisconnect = callback
+}
+
+// SetOnReconnect sets the callback for when a disconnected player comes
+// back, or fails to before the reconnect window closes
+func (h *Hub) SetOnReconnect(callback func(g *game.Game, player string, success bool)) {
+	h.onReconnect = callback
+}
+
+// reportReconnect notifies the reconnect callback
+func (h *Hub) reportReconnect(g *game.Game, player string, success bool) {
+	if h.onReconnect != nil {
+		h.onReconnect(g, player, success)
+	}
+}
+
+// handleMoveMade notifies the move callback about a successful move
+func (h *Hub) handleMoveMade(g *game.Game, player string, column, row int) {
+	if h.onMove != nil {
+		h.onMove(g, player, column, row, g.GetState().MoveCount)
+	}
+}
+
 // Run starts the hub's main loop
 func (h *Hub) Run() {
 	for {
 		select {
 		case client := <-h.register:
-			h.mu.Lock()
-			h.clients[client.username] = client
-			h.mu.Unlock()
-			log.Printf("Client registered: %s", client.username)
+			h.registerClient(client)
 
 		case client := <-h.unregister:
 			h.mu.Lock()
-			if _, ok := h.clients[client.username]; ok {
+			// A replaced or rejected connection doesn't own the username
+			current := h.clients[client.username] == client
+			if current {
 				delete(h.clients, client.username)
-				close(client.send)
+				h.connected.Add(-1)
 			}
 			h.mu.Unlock()
-			log.Printf("Client unregistered: %s", client.username)
+			client.closeSend()
+			h.logger.Info("client unregistered", "username", client.username)
 
 			// Handle disconnect for active game
-			h.handleDisconnect(client)
+			if current {
+				h.handleDisconnect(client)
+			}
 		}
 	}
 }
 
+// registerClient adds a client, applying the session policy if the
+// username is already connected. Only Run registers and unregisters
+// clients, so the existing session can't change between the two locks.
+func (h *Hub) registerClient(client *Client) {
+	h.mu.RLock()
+	existing := h.clients[client.username]
+	var existingGame string
+	if existing != nil {
+		existingGame = existing.gameID
+	}
+	h.mu.RUnlock()
+
+	if existing != nil && !h.mayReplace(client, existingGame) {
+		h.logger.Info("client rejected, already connected", "username", client.username)
+		client.sendMessage(Message{Type: TypeError, Message: ErrSessionExists.Error(), Reason: ReasonDuplicateSession})
+		client.closeSend()
+		return
+	}
+
+	h.mu.Lock()
+	h.clients[client.username] = client
+	if existing == nil {
+		h.connected.Add(1)
+	}
+	if existing != nil && existing.gameID != "" {
+		// The new connection takes over the old one's game seat
+		client.gameID = existing.gameID
+		if clients := h.gameClients[existing.gameID]; clients != nil {
+			clients[client.username] = client
+		}
+	}
+	h.mu.Unlock()
+	h.logger.Info("client registered", "username", client.username)
+
+	if existing == nil {
+		return
+	}
+
+	h.logger.Info("client replaced an existing session", "username", client.username)
+	existing.sendMessage(Message{
+		Type:    TypeSessionReplaced,
+		Message: "Connected from another session",
+	})
+	existing.closeSend()
+	if existingGame == "" {
+		// Drop the old session's queue entry so the new one can join
+		h.matchmaker.LeaveQueue(client.username)
+	}
+}
+
+// mayReplace reports whether a new connection may take over the session
+// already open for its username, in gameID if it is in a game. A claimed
+// username alone is not enough: the connection must be authenticated or
+// hold the seat token of that game.
+func (h *Hub) mayReplace(client *Client, gameID string) bool {
+	if h.sessionPolicy != SessionReplace {
+		return false
+	}
+	if client.authenticated {
+		return true
+	}
+	if gameID == "" || client.gameToken == "" {
+		return false
+	}
+	g := h.matchmaker.GetGame(gameID)
+	if g == nil {
+		return false
+	}
+	seat := g.GetPlayerByUsername(client.username)
+	return h.matchmaker.ValidateToken(gameID, seat, client.gameToken) == nil
+}
+
+// clientGameID returns the game a client is registered to, or ""
+func (h *Hub) clientGameID(client *Client) string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return client.gameID
+}
+
 // handleDisconnect handles a player disconnect
 func (h *Hub) handleDisconnect(client *Client) {
-	if client.gameID == "" {
+	gameID := h.clientGameID(client)
+	if gameID == "" {
 		// Player was not in a game, just leave queue
 		h.matchmaker.LeaveQueue(client.username)
 		return
 	}
 
-	g := h.matchmaker.GetGame(client.gameID)
+	// Unregister and a late match can both report the same disconnect
+	if !client.gameDisconnected.CompareAndSwap(false, true) {
+		return
+	}
+
+	// The game was checkpointed as it stood and resumes after the restart
+	if h.shuttingDown.Load() {
+		return
+	}
+
+	g := h.matchmaker.GetGame(gameID)
 	if g == nil {
 		return
 	}
@@ -92,60 +469,297 @@ func (h *Hub) handleDisconnect(client *Client) {
 		return
 	}
 
-	// Handle bot game - forfeit immediately since bot doesn't wait
-	if g.Player2 != nil && g.Player2.IsBot && playerNum == game.Player1 {
-		g.Forfeit(game.Player1)
-		h.handleGameEnd(g)
+	// Closing the connection normally is leaving, not losing the connection
+	if client.closedNormally.Load() && h.abandonGame(g, playerNum, client.username) == nil {
 		return
 	}
 
-	// Mark player as disconnected
+	if h.onDisconnect != nil {
+		h.onDisconnect(g, client.username)
+	}
+
+	// Mark player as disconnected. In a bot game this also pauses the
+	// bot, which only moves while the game is playing.
 	g.PlayerDisconnected(playerNum)
+	h.StopTurnTimer(g.ID)
 
 	// Notify opponent
-	h.notifyOpponentDisconnected(g, playerNum)
+	deadline := g.ReconnectDeadline()
+	if deadline.IsZero() {
+		return // the game had already ended
+	}
+	h.notifyOpponent(g, playerNum, Message{
+		Type:              TypeOpponentDisconnected,
+		Username:          client.username,
+		ReconnectDeadline: deadline.Format(time.RFC3339),
+	})
+
+	// Forfeit if they don't make it back in time
+	h.goGameTask(h.logger.With("gameID", g.ID, "username", client.username), g.ID, "reconnect timeout", func() {
+		h.handleReconnectTimeout(g, playerNum, client.username, deadline)
+	}, nil)
+}
+
+// goGameTask runs fn on a new goroutine through safego.Go, counted as one
+// of gameID's running tasks under name until it returns
+func (h *Hub) goGameTask(logger *slog.Logger, gameID, name string, fn, onPanic func()) {
+	h.trackTask(gameID, name, 1)
+	safego.Go(logger, name, func() {
+		defer h.trackTask(gameID, name, -1)
+		fn()
+	}, onPanic)
+}
+
+// trackTask adds delta to the count of gameID's tasks called name
+func (h *Hub) trackTask(gameID, name string, delta int) {
+	h.taskMu.Lock()
+	defer h.taskMu.Unlock()
+
+	tasks := h.tasks[gameID]
+	if tasks == nil {
+		tasks = make(map[string]int)
+		h.tasks[gameID] = tasks
+	}
+	tasks[name] += delta
+	if tasks[name] <= 0 {
+		delete(tasks, name)
+	}
+	if len(tasks) == 0 {
+		delete(h.tasks, gameID)
+	}
+}
+
+// gameTasks copies the running task counts of every game
+func (h *Hub) gameTasks() map[string]map[string]int {
+	h.taskMu.Lock()
+	defer h.taskMu.Unlock()
 
-	// Start 30-second timeout
-	go h.handleReconnectTimeout(g, playerNum)
+	tasks := make(map[string]map[string]int, len(h.tasks))
+	for gameID, counts := range h.tasks {
+		tasks[gameID] = maps.Clone(counts)
+	}
+	return tasks
 }
 
-// handleReconnectTimeout waits 30 seconds for reconnection
-func (h *Hub) handleReconnectTimeout(g *game.Game, disconnectedPlayer int) {
-	time.Sleep(30 * time.Second)
+// abandonGame ends a game that username, in seat playerNum, left on
+// purpose and tells the players, skipping the reconnect window
+func (h *Hub) abandonGame(g *game.Game, playerNum int, username string) error {
+	if err := g.Abandon(playerNum); err != nil {
+		return err
+	}
+	h.logger.Info("player left game", "username", username, "gameID", g.ID)
+
+	h.handleGameEnd(g)
+	h.broadcastToGame(g.ID, Message{
+		Type:   TypeGameOver,
+		Winner: g.GetState().Winner,
+		Reason: string(game.ResultAbandoned),
+	})
+	return nil
+}
 
+// handleReconnectTimeout waits until the deadline for reconnection, sending
+// the opponent a countdown every reconnectCountdownInterval. It returns early
+// when the player reconnects or the game ends some other way.
+func (h *Hub) handleReconnectTimeout(g *game.Game, disconnectedPlayer int, username string, deadline time.Time) {
+	done := g.ReconnectWait()
+	if done == nil {
+		return
+	}
+
+	ticker := time.NewTicker(reconnectCountdownInterval)
+	defer ticker.Stop()
+	timeout := time.NewTimer(time.Until(deadline))
+	defer timeout.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+
+		case <-ticker.C:
+			remaining := int(time.Until(deadline).Round(time.Second).Seconds())
+			if remaining <= 0 {
+				continue
+			}
+			h.notifyOpponent(g, disconnectedPlayer, Message{
+				Type:             TypeReconnectCountdown,
+				Username:         username,
+				SecondsRemaining: remaining,
+			})
+
+		case <-timeout.C:
+			// Player didn't reconnect, forfeit. The game refuses until the
+			// clock is past the deadline, so wait a little longer if the
+			// timer fired right on it.
+			if g.ReconnectDeadline().Equal(deadline) && !time.Now().After(deadline) {
+				timeout.Reset(time.Millisecond)
+				continue
+			}
+			if g.ForfeitIfDisconnected(disconnectedPlayer, deadline) {
+				h.reportReconnect(g, username, false)
+				h.handleGameEnd(g)
+
+				// Notify remaining player
+				h.broadcastToGame(g.ID, Message{
+					Type:   TypeGameOver,
+					Winner: g.Winner.Username,
+					Reason: "forfeit",
+				})
+			}
+			return
+		}
+	}
+}
+
+// notifyOpponent sends msg to every client in the game except the given player
+func (h *Hub) notifyOpponent(g *game.Game, exceptPlayerNum int, msg Message) {
+	h.mu.RLock()
+	clients := make([]*Client, 0, len(h.gameClients[g.ID]))
+	for username, client := range h.gameClients[g.ID] {
+		if g.GetPlayerByUsername(username) != exceptPlayerNum {
+			clients = append(clients, client)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, client := range clients {
+		client.sendMessage(msg)
+	}
+}
+
+// ScheduleTurnTimer (re)starts the turn clock timer for the game's current
+// turn. Bot turns and games that aren't being played have no timer.
+func (h *Hub) ScheduleTurnTimer(g *game.Game) {
+	remaining, moveCount, ok := g.TurnRemaining()
 	state := g.GetState()
-	if state.Status == game.StatusDisconnect {
-		// Player didn't reconnect, forfeit
-		g.Forfeit(disconnectedPlayer)
-		h.handleGameEnd(g)
 
-		// Notify remaining player
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if timer, exists := h.turnTimers[g.ID]; exists {
+		timer.Stop()
+		delete(h.turnTimers, g.ID)
+	}
+
+	if !ok || (state.IsVsBot && state.CurrentTurn == state.BotPlayer) {
+		return
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(remaining, func() {
+		// Only the most recently scheduled timer may act
+		h.mu.Lock()
+		current := h.turnTimers[g.ID] == timer
+		if current {
+			delete(h.turnTimers, g.ID)
+		}
+		h.mu.Unlock()
+
+		if current {
+			safego.Run(h.logger.With("gameID", g.ID), "turn timeout", func() {
+				h.handleTurnTimeout(g, moveCount)
+			}, nil)
+		}
+	})
+	h.turnTimers[g.ID] = timer
+}
+
+// StopTurnTimer cancels the turn clock timer for a game
+func (h *Hub) StopTurnTimer(gameID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if timer, ok := h.turnTimers[gameID]; ok {
+		timer.Stop()
+		delete(h.turnTimers, gameID)
+	}
+}
+
+// handleTurnTimeout applies the timeout action when a turn clock expires.
+// moveCount guards against a timer firing after the turn already changed.
+func (h *Hub) handleTurnTimeout(g *game.Game, moveCount int) {
+	state := g.GetState()
+	if state.Status != game.StatusPlaying || state.MoveCount != moveCount {
+		return
+	}
+	if remaining, _, ok := g.TurnRemaining(); !ok || remaining > 0 {
+		// Clock was paused and resumed; wait for the rescheduled timer
+		return
+	}
+
+	stalled := state.CurrentTurn
+	h.logger.Info("turn clock expired", "gameID", g.ID, "player", stalled)
+
+	if h.turnTimeoutAction == TurnTimeoutRandomMove {
+		column := game.GetRandomValidMove(g.Board, h.rng)
+		row, err := g.MakeMove(stalled, column)
+		if err != nil {
+			h.logger.Error("forced move failed", "gameID", g.ID, "error", err)
+			return
+		}
+
+		player := state.Player1
+		if stalled == game.Player2 {
+			player = state.Player2
+		}
+		h.handleMoveMade(g, player, column, row)
+
+		newState := g.GetState()
 		h.broadcastToGame(g.ID, Message{
-			Type:   TypeGameOver,
-			Winner: g.Winner.Username,
-			Reason: "forfeit",
+			Type:   TypeTurnTimeout,
+			State:  newState,
+			Column: column,
+			Row:    row,
 		})
+
+		if newState.Status == game.StatusFinished {
+			h.broadcastToGame(g.ID, Message{
+				Type:         TypeGameOver,
+				Winner:       newState.Winner,
+				Reason:       newState.Result,
+				WinningCells: newState.WinningCells,
+			})
+			h.handleGameEnd(g)
+			return
+		}
+
+		if newState.IsVsBot && newState.CurrentTurn == newState.BotPlayer {
+			h.goBotMove(g)
+			return
+		}
+		h.ScheduleTurnTimer(g)
+		return
 	}
-}
 
-// notifyOpponentDisconnected notifies the opponent about disconnect
-func (h *Hub) notifyOpponentDisconnected(g *game.Game, disconnectedPlayerNum int) {
-	deadline := time.Now().Add(30 * time.Second)
+	if !g.ForfeitIfStillOnTurn(stalled, moveCount) {
+		// A move landed after the checks above
+		return
+	}
+	h.broadcastToGame(g.ID, Message{
+		Type:   TypeGameOver,
+		Winner: g.GetState().Winner,
+		Reason: "turnTimeout",
+	})
+	h.handleGameEnd(g)
+}
 
+// IsGameAttended reports whether any player of the game has a live connection
+func (h *Hub) IsGameAttended(gameID string) bool {
 	h.mu.RLock()
-	clients := h.gameClients[g.ID]
-	h.mu.RUnlock()
+	defer h.mu.RUnlock()
+	return h.attendedLocked(gameID)
+}
 
-	for username, client := range clients {
-		playerNum := g.GetPlayerByUsername(username)
-		if playerNum != disconnectedPlayerNum {
-			msg := Message{
-				Type:              TypeOpponentDisconnected,
-				ReconnectDeadline: deadline.Format(time.RFC3339),
-			}
-			client.sendMessage(msg)
+// attendedLocked reports whether any player of the game has a live
+// connection; caller holds h.mu
+func (h *Hub) attendedLocked(gameID string) bool {
+	for username, client := range h.gameClients[gameID] {
+		if h.clients[username] == client && !client.isClosed() {
+			return true
 		}
 	}
+	return false
 }
 
 // RegisterToGame adds a client to a game's client list
@@ -153,44 +767,199 @@ func (h *Hub) RegisterToGame(gameID string, client *Client) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	if h.gameClients[gameID] == nil {
-		h.gameClients[gameID] = make(map[string]*Client)
+	clients := h.gameClients[gameID]
+	if clients == nil {
+		clients = make(map[string]*Client)
+		h.gameClients[gameID] = clients
+		h.games.Add(1)
+	}
+	if _, ok := clients[client.username]; !ok {
+		h.gameClientCount.Add(1)
 	}
-	h.gameClients[gameID][client.username] = client
+	clients[client.username] = client
 	client.gameID = gameID
 }
 
+// forgetGameLocked drops a game's client list; caller holds h.mu
+func (h *Hub) forgetGameLocked(gameID string) {
+	if clients, ok := h.gameClients[gameID]; ok {
+		h.games.Add(-1)
+		h.gameClientCount.Add(-int64(len(clients)))
+		delete(h.gameClients, gameID)
+	}
+}
+
+// forgetSequenceLater drops a forgotten game's broadcast sequence once the
+// matchmaker stops keeping it as finished. Until then anything still sent
+// for the game carries on from its last number instead of starting over.
+func (h *Hub) forgetSequenceLater(gameID string) {
+	h.mu.RLock()
+	seq := h.sequences[gameID]
+	h.mu.RUnlock()
+	if seq == nil {
+		return
+	}
+
+	time.AfterFunc(h.matchmaker.FinishedGrace(), func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, playing := h.gameClients[gameID]; !playing && h.sequences[gameID] == seq {
+			delete(h.sequences, gameID)
+		}
+	})
+}
+
+// gameSequence numbers a game's broadcasts. Broadcasts for one game are
+// built, numbered and queued to every client while holding mu, so each
+// client receives them in sequence order, and a state message always
+// reflects the game as of its number.
+type gameSequence struct {
+	mu   sync.Mutex
+	last int64 // number of the latest broadcast
+}
+
+// sequence returns a game's broadcast sequence, creating it on first use
+func (h *Hub) sequence(gameID string) *gameSequence {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	seq := h.sequences[gameID]
+	if seq == nil {
+		seq = &gameSequence{}
+		h.sequences[gameID] = seq
+	}
+	return seq
+}
+
 // BroadcastGameState sends game state to all players in a game
 func (h *Hub) BroadcastGameState(g *game.Game) {
-	state := g.GetState()
-	h.broadcastToGame(g.ID, Message{
-		Type:  TypeState,
-		State: state,
+	h.broadcastBuilt(g.ID, func() Message {
+		return Message{
+			Type:  TypeState,
+			State: g.GetState(),
+		}
 	})
 }
 
 // broadcastToGame sends a message to all clients in a game
 func (h *Hub) broadcastToGame(gameID string, msg Message) {
+	h.broadcastBuilt(gameID, func() Message { return msg })
+}
+
+// broadcastBuilt sends the message build returns to all clients in a game,
+// stamped with the game's next sequence number. build runs in sequence
+// order, so a message built from the game's state is never overtaken by
+// one built from an older state.
+func (h *Hub) broadcastBuilt(gameID string, build func() Message) {
+	seq := h.sequence(gameID)
+	seq.mu.Lock()
+	defer seq.mu.Unlock()
+
+	// Copy the recipients so the map isn't read while RegisterToGame writes it
 	h.mu.RLock()
-	clients := h.gameClients[gameID]
+	clients := make(map[string]*Client, len(h.gameClients[gameID]))
+	for username, client := range h.gameClients[gameID] {
+		clients[username] = client
+	}
 	h.mu.RUnlock()
 
-	log.Printf("[broadcast] Sending %s to game %s (clients: %d)", msg.Type, gameID, len(clients))
+	seq.last++
+	msg := build()
+	msg.Seq = seq.last
+
+	h.logger.Debug("broadcasting", "gameID", gameID, "messageType", msg.Type, "clients", len(clients))
+	h.broadcasts.Add(1)
 
 	data, err := json.Marshal(msg)
 	if err != nil {
-		log.Printf("Error marshaling message: %v", err)
+		h.logger.Error("marshaling message failed", "messageType", msg.Type, "error", err)
 		return
 	}
 
 	for username, client := range clients {
-		select {
-		case client.send <- data:
-			log.Printf("[broadcast] Sent %s to %s", msg.Type, username)
-		default:
-			log.Printf("[broadcast] Failed to send %s to %s - buffer full", msg.Type, username)
+		err := client.enqueue(data)
+		if errors.Is(err, ErrSendBufferFull) {
+			// The client will be missing this message; bring it up to date
+			// once it has caught up with the rest
+			client.needsSync.Store(true)
+		}
+		if err != nil {
+			h.logger.Warn("broadcast send failed", "gameID", gameID, "username", username, "messageType", msg.Type, "error", err)
+		}
+	}
+}
+
+// syncMessages returns the messages bringing a player up to date with a
+// game: its full state with the move history and, once it has finished,
+// the game over
+func syncMessages(g *game.Game) []Message {
+	state := g.GetStateWithHistory()
+	messages := []Message{{Type: TypeState, GameID: g.ID, State: state}}
+	if state.Status == game.StatusFinished {
+		messages = append(messages, Message{
+			Type:         TypeGameOver,
+			GameID:       g.ID,
+			Winner:       state.Winner,
+			Reason:       state.Result,
+			WinningCells: state.WinningCells,
+		})
+	}
+	return messages
+}
+
+// sendSync sends a client the messages bringing it up to date with g,
+// stamped with the number of the game's latest broadcast, which they
+// include
+func (h *Hub) sendSync(client *Client, g *game.Game) {
+	h.mu.RLock()
+	seq := h.sequences[g.ID]
+	h.mu.RUnlock()
+
+	// A game that finished and was cleaned up broadcasts nothing more
+	if seq == nil {
+		for _, msg := range syncMessages(g) {
+			client.sendMessage(msg)
 		}
+		return
+	}
+
+	seq.mu.Lock()
+	defer seq.mu.Unlock()
+	for _, msg := range syncMessages(g) {
+		msg.Seq = seq.last
+		client.sendMessage(msg)
+	}
+}
+
+// sendWithState sends a client msg carrying g's current state, stamped
+// like a sync with the number of the latest broadcast the state includes
+func (h *Hub) sendWithState(client *Client, g *game.Game, msg Message) {
+	h.mu.RLock()
+	seq := h.sequences[g.ID]
+	h.mu.RUnlock()
+
+	if seq != nil {
+		seq.mu.Lock()
+		defer seq.mu.Unlock()
+		msg.Seq = seq.last
+	}
+	msg.State = g.GetState()
+	client.sendMessage(msg)
+}
+
+// resync sends a client its game's current state after broadcasts to it
+// were dropped
+func (h *Hub) resync(client *Client) {
+	h.mu.RLock()
+	gameID := client.gameID
+	h.mu.RUnlock()
+
+	g := h.matchmaker.GetGame(gameID)
+	if g == nil {
+		return
 	}
+	h.logger.Info("resyncing client after dropped broadcasts", "gameID", gameID, "username", client.username)
+	h.sendSync(client, g)
 }
 
 // SendToClient sends a message to a specific client
@@ -208,72 +977,223 @@ func (h *Hub) SendToClient(username string, msg Message) {
 
 // handleGameEnd processes game completion
 func (h *Hub) handleGameEnd(g *game.Game) {
+	h.StopTurnTimer(g.ID)
+
 	if h.onGameEnd != nil {
 		h.onGameEnd(g)
 	}
+	h.removeGameLater(g)
+}
 
-	// Clean up after a delay
-	go func() {
+// ForgetGame drops the hub's state for a game the matchmaker ended and
+// removed itself, such as one reaped by its sweep
+func (h *Hub) ForgetGame(gameID string) {
+	h.StopTurnTimer(gameID)
+	h.mu.Lock()
+	h.forgetGameLocked(gameID)
+	h.mu.Unlock()
+	h.forgetSequenceLater(gameID)
+}
+
+// removeGameLater drops a finished game from the hub and matchmaker after
+// a short delay
+func (h *Hub) removeGameLater(g *game.Game) {
+	h.goGameTask(h.logger.With("gameID", g.ID), g.ID, "remove finished game", func() {
 		time.Sleep(5 * time.Second)
 		h.mu.Lock()
-		delete(h.gameClients, g.ID)
+		h.forgetGameLocked(g.ID)
 		h.mu.Unlock()
 		h.matchmaker.RemoveGame(g.ID)
-	}()
+		h.forgetSequenceLater(g.ID)
+	}, nil)
+}
+
+// ForceEnd forfeits the stalled player of a game and tells its clients the
+// game is over. It returns false if the game wasn't in progress.
+func (h *Hub) ForceEnd(g *game.Game) bool {
+	loser := g.ForfeitStalled()
+	if loser == 0 {
+		return false
+	}
+	h.logger.Info("game force-ended", "gameID", g.ID, "forfeited", loser)
+
+	state := g.GetState()
+	h.broadcastToGame(g.ID, Message{
+		Type:   TypeGameOver,
+		Winner: state.Winner,
+		Reason: state.Result,
+	})
+	h.handleGameEnd(g)
+	return true
+}
+
+// Shutdown stops new games, tells every connected client the server is
+// going away and, after a short grace period for moves in flight (cut
+// short if ctx ends), aborts the games still in progress. Aborted games
+// with enough moves go through the game-end callback so they are
+// persisted and emitted, with the aborted result instead of a forfeit.
+func (h *Hub) Shutdown(ctx context.Context) {
+	h.matchmaker.StopAccepting()
+
+	notice := "Server is shutting down"
+	if h.keepGames {
+		notice = "Server is restarting; reconnect to resume your game"
+	}
+
+	h.mu.RLock()
+	clients := make([]*Client, 0, len(h.clients))
+	for _, client := range h.clients {
+		clients = append(clients, client)
+	}
+	h.mu.RUnlock()
+	for _, client := range clients {
+		client.sendMessage(Message{Type: TypeServerShutdown, Message: notice})
+	}
+
+	grace := time.NewTimer(shutdownMoveGrace)
+	defer grace.Stop()
+	select {
+	case <-grace.C:
+	case <-ctx.Done():
+	}
+
+	if h.keepGames {
+		// Freeze the games as they are: clients dropping from here on
+		// must not forfeit or pause them
+		h.shuttingDown.Store(true)
+		for _, g := range h.matchmaker.ListGames() {
+			h.StopTurnTimer(g.ID)
+		}
+		kept := h.matchmaker.Checkpoint(ctx)
+		h.logger.Info("hub shut down", "clients", len(clients), "gamesCheckpointed", kept)
+		return
+	}
+
+	aborted, saved := 0, 0
+	for _, g := range h.matchmaker.ListGames() {
+		if !g.Abort() {
+			continue
+		}
+		aborted++
+		h.StopTurnTimer(g.ID)
+		h.broadcastToGame(g.ID, Message{Type: TypeGameOver, Reason: string(game.ResultAborted)})
+
+		if g.GetState().MoveCount >= minMovesToSaveAborted && h.onGameEnd != nil {
+			h.onGameEnd(g)
+			saved++
+		}
+	}
+	h.logger.Info("hub shut down", "clients", len(clients), "gamesAborted", aborted, "gamesSaved", saved)
+}
+
+// goBotMove plays the bot's move on a new goroutine. If the bot panics the
+// game is aborted, so the player isn't left waiting on a move that never
+// comes.
+func (h *Hub) goBotMove(g *game.Game) {
+	h.goGameTask(h.logger.With("gameID", g.ID), g.ID, "bot move", func() {
+		h.HandleBotMove(g)
+	}, func() {
+		if !g.Abort() {
+			return
+		}
+		h.logger.Warn("aborting game after bot failure", "gameID", g.ID)
+		h.broadcastToGame(g.ID, Message{Type: TypeGameOver, Reason: string(game.ResultAborted)})
+		if g.GetState().MoveCount >= minMovesToSaveAborted {
+			h.handleGameEnd(g)
+		} else {
+			h.StopTurnTimer(g.ID)
+			h.removeGameLater(g)
+		}
+	})
 }
 
 // HandleBotMove processes the bot's move
 func (h *Hub) HandleBotMove(g *game.Game) {
-	log.Printf("HandleBotMove called for game %s", g.ID)
-	
-	if g.Player2 == nil || !g.Player2.IsBot {
-		log.Printf("Bot move skipped: Player2 is nil or not a bot")
+	logger := h.logger.With("gameID", g.ID)
+
+	seat := g.BotPlayer()
+	if seat == 0 {
+		logger.Debug("bot move skipped: no bot in game")
 		return
 	}
 
 	state := g.GetState()
-	log.Printf("Game state: Status=%s, CurrentTurn=%d", state.Status, state.CurrentTurn)
-	
 	if state.Status != game.StatusPlaying {
-		log.Printf("Bot move skipped: game not playing (status=%s)", state.Status)
+		logger.Debug("bot move skipped: game not playing", "status", state.Status)
 		return
 	}
-	
-	if state.CurrentTurn != game.Player2 {
-		log.Printf("Bot move skipped: not bot's turn (currentTurn=%d)", state.CurrentTurn)
+
+	if state.CurrentTurn != seat {
+		logger.Debug("bot move skipped: not bot's turn", "currentTurn", state.CurrentTurn)
 		return
 	}
 
-	// Add a small delay to make it feel more natural
-	time.Sleep(500 * time.Millisecond)
+	time.Sleep(h.botMoveDelay)
 
-	log.Printf("Bot computing best move...")
 	col, row, err := g.MakeBotMove()
+	if errors.Is(err, game.ErrGameNotInProgress) {
+		// The player disconnected while the bot was thinking; it moves
+		// again when they reconnect
+		logger.Debug("bot move skipped: game paused or ended")
+		return
+	}
+	if errors.Is(err, game.ErrVersionConflict) {
+		// The position changed under the search, so whoever changed it
+		// is responsible for the bot's next turn
+		logger.Debug("bot move skipped: position changed while thinking")
+		return
+	}
 	if err != nil {
-		log.Printf("Bot move error: %v", err)
+		logger.Error("bot move failed", "error", err)
+		return
+	}
+	logger.Debug("bot moved", "column", col, "row", row)
+	if h.FinishMove(g, "BOT", col, row) {
+		logger.Debug("game finished after bot move", "winner", g.GetState().Winner)
 		return
 	}
-	log.Printf("Bot played column %d, row %d", col, row)
 
-	// Broadcast the move
+	h.ScheduleTurnTimer(g)
+}
+
+// PlayBotMove makes the bot's reply immediately, for callers outside the
+// WebSocket flow that want it synchronously. ok is false if the bot wasn't
+// on turn.
+func (h *Hub) PlayBotMove(g *game.Game) (col, row int, ok bool) {
+	col, row, err := g.MakeBotMove()
+	if err != nil {
+		return -1, -1, false
+	}
+	h.FinishMove(g, "BOT", col, row)
+	return col, row, true
+}
+
+// FinishMove runs the after-move steps for a move already applied to g:
+// the move callback, the state broadcast and, if the move ended the game,
+// the game-over broadcast and game-end handling. It reports whether the
+// game is over.
+func (h *Hub) FinishMove(g *game.Game, player string, column, row int) bool {
+	h.handleMoveMade(g, player, column, row)
+
+	state := g.GetState()
 	h.broadcastToGame(g.ID, Message{
 		Type:   TypeState,
-		State:  g.GetState(),
-		Column: col,
+		State:  state,
+		Column: column,
 		Row:    row,
 	})
 
-	// Check if game ended
-	newState := g.GetState()
-	if newState.Status == game.StatusFinished {
-		log.Printf("Game finished after bot move. Winner: %s", newState.Winner)
-		h.broadcastToGame(g.ID, Message{
-			Type:   TypeGameOver,
-			Winner: newState.Winner,
-			Reason: newState.Result,
-		})
-		h.handleGameEnd(g)
+	if state.Status != game.StatusFinished {
+		return false
 	}
+	h.broadcastToGame(g.ID, Message{
+		Type:         TypeGameOver,
+		Winner:       state.Winner,
+		Reason:       state.Result,
+		WinningCells: state.WinningCells,
+	})
+	h.handleGameEnd(g)
+	return true
 }
 
 // GetClient returns a client by username
@@ -282,3 +1202,170 @@ func (h *Hub) GetClient(username string) *Client {
 	defer h.mu.RUnlock()
 	return h.clients[username]
 }
+
+// ClientCount returns the number of connected clients
+func (h *Hub) ClientCount() int {
+	return int(h.connected.Load())
+}
+
+// AliveClientCount returns how many clients have been heard from within the
+// pong timeout, i.e. connections that are actually alive
+func (h *Hub) AliveClientCount() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	cutoff := time.Now().Add(-h.pongWait)
+	alive := 0
+	for _, client := range h.clients {
+		if client.LastActivity().After(cutoff) {
+			alive++
+		}
+	}
+	return alive
+}
+
+// BroadcastCount returns the total number of game broadcasts sent
+func (h *Hub) BroadcastCount() int64 {
+	return h.broadcasts.Load()
+}
+
+// HubStats counts the hub's connections and the messages through them
+type HubStats struct {
+	ConnectedClients int64   `json:"connectedClients"`
+	GamesWithClients int64   `json:"gamesWithClients"`
+	ClientsInGames   int64   `json:"clientsInGames"`
+	ClientsPerGame   float64 `json:"clientsPerGame"`
+	MessagesSent     int64   `json:"messagesSent"`
+	MessagesReceived int64   `json:"messagesReceived"`
+	MessagesDropped  int64   `json:"messagesDropped"` // send buffer full
+
+	OpenConnections    int64 `json:"openConnections"`    // including ones not yet registered
+	MaxConnections     int   `json:"maxConnections"`     // zero for no limit
+	RefusedConnections int64 `json:"refusedConnections"` // at the connection limit
+}
+
+// Stats returns the hub's counters without taking the hub lock, so the
+// values are each current but not a consistent snapshot
+func (h *Hub) Stats() HubStats {
+	stats := HubStats{
+		ConnectedClients: h.connected.Load(),
+		GamesWithClients: h.games.Load(),
+		ClientsInGames:   h.gameClientCount.Load(),
+		MessagesSent:     h.messagesSent.Load(),
+		MessagesReceived: h.messagesReceived.Load(),
+		MessagesDropped:  h.messagesDropped.Load(),
+
+		OpenConnections:    h.openConnections.Load(),
+		MaxConnections:     h.maxConnections,
+		RefusedConnections: h.refusedConnections.Load(),
+	}
+	if stats.GamesWithClients > 0 {
+		stats.ClientsPerGame = float64(stats.ClientsInGames) / float64(stats.GamesWithClients)
+	}
+	return stats
+}
+
+// ClientDebug describes a connected client in a debug snapshot
+type ClientDebug struct {
+	Username       string    `json:"username"`
+	GameID         string    `json:"gameId,omitempty"`
+	SendQueueDepth int       `json:"sendQueueDepth"`
+	LastActivity   time.Time `json:"lastActivity"`
+}
+
+// HubGameDebug is the hub's state for one game in a debug snapshot
+type HubGameDebug struct {
+	Clients   int            `json:"clients"`
+	LastSeq   int64          `json:"lastSeq"`         // number of the game's latest broadcast
+	TurnTimer bool           `json:"turnTimer"`       // a turn clock timer is armed
+	Tasks     map[string]int `json:"tasks,omitempty"` // running background tasks by name
+}
+
+// HubDebugSnapshot is the hub's in-memory state for debugging
+type HubDebugSnapshot struct {
+	Clients          []ClientDebug           `json:"clients"`
+	ClientsTruncated bool                    `json:"clientsTruncated"`
+	TotalClients     int                     `json:"totalClients"`
+	GameClients      map[string]int          `json:"gameClients"`
+	Games            map[string]HubGameDebug `json:"games"`
+	GamesTruncated   bool                    `json:"gamesTruncated"`
+	TurnTimers       int                     `json:"turnTimers"`
+	Broadcasts       int64                   `json:"broadcasts"`
+}
+
+// DebugSnapshot copies the hub's client registry and per-game state,
+// including at most maxClients clients and maxGames games
+func (h *Hub) DebugSnapshot(maxClients, maxGames int) HubDebugSnapshot {
+	tasks := h.gameTasks()
+
+	h.mu.RLock()
+	snapshot := HubDebugSnapshot{
+		TotalClients: len(h.clients),
+		GameClients:  make(map[string]int, len(h.gameClients)),
+		Games:        make(map[string]HubGameDebug),
+		TurnTimers:   len(h.turnTimers),
+		Broadcasts:   h.broadcasts.Load(),
+		Clients:      make([]ClientDebug, 0, len(h.clients)),
+	}
+	for gameID, clients := range h.gameClients {
+		snapshot.GameClients[gameID] = len(clients)
+	}
+
+	// Every game the hub holds anything for
+	sequences := make(map[string]*gameSequence)
+	addGame := func(gameID string) {
+		if _, ok := snapshot.Games[gameID]; ok {
+			return
+		}
+		if maxGames >= 0 && len(snapshot.Games) >= maxGames {
+			snapshot.GamesTruncated = true
+			return
+		}
+		_, timer := h.turnTimers[gameID]
+		snapshot.Games[gameID] = HubGameDebug{
+			Clients:   len(h.gameClients[gameID]),
+			TurnTimer: timer,
+			Tasks:     tasks[gameID],
+		}
+		sequences[gameID] = h.sequences[gameID]
+	}
+	for gameID := range h.gameClients {
+		addGame(gameID)
+	}
+	for gameID := range h.sequences {
+		addGame(gameID)
+	}
+	for gameID := range h.turnTimers {
+		addGame(gameID)
+	}
+	for gameID := range tasks {
+		addGame(gameID)
+	}
+	for _, client := range h.clients {
+		if maxClients >= 0 && len(snapshot.Clients) >= maxClients {
+			snapshot.ClientsTruncated = true
+			break
+		}
+		snapshot.Clients = append(snapshot.Clients, ClientDebug{
+			Username:       client.username,
+			GameID:         client.gameID,
+			SendQueueDepth: len(client.send),
+			LastActivity:   client.LastActivity(),
+		})
+	}
+	h.mu.RUnlock()
+
+	// Broadcasts take a sequence's lock before h.mu, so sequences are read
+	// after letting go of it
+	for gameID, seq := range sequences {
+		if seq == nil {
+			continue
+		}
+		seq.mu.Lock()
+		entry := snapshot.Games[gameID]
+		entry.LastSeq = seq.last
+		snapshot.Games[gameID] = entry
+		seq.mu.Unlock()
+	}
+	return snapshot
+}
@@ -0,0 +1,194 @@
+package websocket
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/connect-four/internal/game"
+	"github.com/connect-four/internal/matchmaker"
+	"github.com/connect-four/internal/safego"
+)
+
+// TestDisconnectWhileRegistering runs a disconnect against the match wait
+// registering the same client to its game; run it with -race
+func TestDisconnectWhileRegistering(t *testing.T) {
+	mm := matchmaker.NewMatchmaker()
+	hub := NewHub(mm)
+	if _, err := mm.JoinQueue("alice", matchmaker.JoinOptions{NoBotFallback: true}); err != nil {
+		t.Fatal(err)
+	}
+	ch, err := mm.JoinQueue("bob", matchmaker.JoinOptions{NoBotFallback: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := <-ch
+
+	for i := 0; i < 50; i++ {
+		client := NewClient(hub, nil, "bob")
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			hub.RegisterToGame(g.ID, client)
+		}()
+		go func() {
+			defer wg.Done()
+			hub.handleDisconnect(client)
+		}()
+		wg.Wait()
+	}
+}
+
+// TestBroadcastDuringUnregister registers and unregisters connections for
+// a game's players while other goroutines broadcast to the game and send
+// to the players. A send on a closed channel would panic the test.
+func TestBroadcastDuringUnregister(t *testing.T) {
+	mm := matchmaker.NewMatchmaker()
+	hub := NewHub(mm)
+	go hub.Run()
+	if _, err := mm.JoinQueue("alice", matchmaker.JoinOptions{NoBotFallback: true}); err != nil {
+		t.Fatal(err)
+	}
+	ch, err := mm.JoinQueue("bob", matchmaker.JoinOptions{NoBotFallback: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := <-ch
+
+	var stop atomic.Bool
+	var senders sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		senders.Add(1)
+		go func() {
+			defer senders.Done()
+			for !stop.Load() {
+				hub.broadcastToGame(g.ID, Message{Type: TypeState})
+				hub.SendToClient("alice", Message{Type: TypeOpponentHover})
+			}
+		}()
+	}
+
+	var clients sync.WaitGroup
+	for _, username := range []string{"alice", "bob"} {
+		clients.Add(1)
+		go func(username string) {
+			defer clients.Done()
+			for i := 0; i < 200; i++ {
+				client := NewClient(hub, nil, username)
+				drained := make(chan struct{})
+				go func() {
+					// Stands in for writePump, which exits once send is closed
+					for range client.send {
+					}
+					close(drained)
+				}()
+				hub.register <- client
+				hub.RegisterToGame(g.ID, client)
+				hub.unregister <- client
+				<-drained
+				if err := client.sendMessage(Message{Type: TypeState}); !errors.Is(err, ErrClientClosed) {
+					t.Errorf("send after unregister: err = %v, want ErrClientClosed", err)
+					return
+				}
+			}
+		}(username)
+	}
+	clients.Wait()
+	stop.Store(true)
+	senders.Wait()
+}
+
+func TestPanickingBotAbortsGame(t *testing.T) {
+	s := newTestServer(t, func(h *Hub, mm *matchmaker.Matchmaker) {
+		mm.SetFirstPlayerPolicy(matchmaker.FirstPlayerPlayer1)
+		// An easy bot's GetBestMove draws from the source first, and a zero
+		// Rand panics on the draw
+		mm.SetRand(&game.Rand{})
+	})
+	bob, bobMatched, carol, _ := s.matchPlayers(t, "bob", "carol")
+
+	alice := s.dial(t, "alice", nil)
+	alice.send(map[string]interface{}{"type": "join", "vsBot": true, "botDifficulty": "easy"})
+	matched := alice.readType(TypeMatched)
+	g := s.mm.GetGame(matched.GameID)
+	panics := safego.PanicCount()
+
+	alice.send(map[string]interface{}{"type": "move", "column": 3, "token": matched.Token})
+	if over := alice.readType(TypeGameOver); over.Reason != string(game.ResultAborted) {
+		t.Fatalf("gameOver reason = %q, want aborted", over.Reason)
+	}
+	if state := g.GetState(); state.Status != game.StatusFinished || state.Result != string(game.ResultAborted) {
+		t.Errorf("bot game: status %s, result %s; want finished and aborted", state.Status, state.Result)
+	}
+	if got := safego.PanicCount(); got <= panics {
+		t.Errorf("PanicCount = %d, want it past %d", got, panics)
+	}
+
+	// The hub carries on serving the human game, with both players told of
+	// each move
+	bob.send(map[string]interface{}{"type": "move", "column": 0, "token": bobMatched.Token})
+	bob.readMoves(1)
+	carol.readMoves(1)
+}
+
+func TestDebugSnapshotGameState(t *testing.T) {
+	s, alice, matched := startBotGame(t, 300*time.Millisecond, game.DefaultReconnectWindow)
+	bob, bobMatched, _, _ := s.matchPlayers(t, "bob", "carol")
+	human := s.mm.GetGame(bobMatched.GameID)
+	bob.send(map[string]interface{}{"type": "move", "column": 0, "token": bobMatched.Token})
+	bob.readMoves(1)
+
+	alice.send(map[string]interface{}{"type": "move", "column": 3, "token": matched.Token})
+	alice.readMoves(1)
+	waitFor(t, "the bot move task", func() bool {
+		return s.hub.DebugSnapshot(-1, -1).Games[matched.GameID].Tasks["bot move"] == 1
+	})
+
+	snapshot := s.hub.DebugSnapshot(-1, -1)
+	botGame := snapshot.Games[matched.GameID]
+	if botGame.Clients != 1 || botGame.LastSeq == 0 {
+		t.Errorf("bot game while the bot thinks = %+v, want 1 client and a sequence", botGame)
+	}
+	if humanGame := snapshot.Games[human.ID]; humanGame.Clients != 2 || humanGame.LastSeq == 0 || !humanGame.TurnTimer {
+		t.Errorf("human game = %+v, want 2 clients, a sequence and a turn timer", humanGame)
+	}
+
+	// The task is gone once the bot has moved, and the sequence has moved on
+	alice.readMoves(2)
+	waitFor(t, "the bot move task to finish", func() bool {
+		return len(s.hub.DebugSnapshot(-1, -1).Games[matched.GameID].Tasks) == 0
+	})
+	if seq := s.hub.DebugSnapshot(-1, -1).Games[matched.GameID].LastSeq; seq <= botGame.LastSeq {
+		t.Errorf("last sequence %d after the bot's move, want past %d", seq, botGame.LastSeq)
+	}
+
+	if limited := s.hub.DebugSnapshot(-1, 1); len(limited.Games) != 1 || !limited.GamesTruncated {
+		t.Errorf("snapshot capped at 1 game has %d games, truncated %v", len(limited.Games), limited.GamesTruncated)
+	}
+}
+
+func TestSequenceOutlivesForgottenGame(t *testing.T) {
+	s := newTestServer(t, func(_ *Hub, mm *matchmaker.Matchmaker) { mm.SetFinishedGrace(200 * time.Millisecond) })
+	alice, matched, bob, _ := s.matchPlayers(t, "alice", "bob")
+	g := s.mm.GetGame(matched.GameID)
+	alice.send(map[string]interface{}{"type": "resign", "token": matched.Token})
+	over := bob.readType(TypeGameOver)
+	if over.Seq == 0 {
+		t.Fatal("game over carried no sequence number")
+	}
+
+	// Whatever is still sent for the game after the hub forgets it
+	// carries on from the last number
+	s.hub.ForgetGame(matched.GameID)
+	s.hub.BroadcastGameState(g)
+	if seq := s.hub.DebugSnapshot(-1, -1).Games[matched.GameID].LastSeq; seq != over.Seq+1 {
+		t.Errorf("last sequence after the forgotten game's broadcast = %d, want %d", seq, over.Seq+1)
+	}
+
+	waitFor(t, "the sequence to go after the finished grace", func() bool {
+		return s.hub.DebugSnapshot(-1, -1).Games[matched.GameID].LastSeq == 0
+	})
+}
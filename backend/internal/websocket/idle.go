@@ -0,0 +1,94 @@
+package websocket
+
+import (
+	"time"
+
+	"github.com/connect-four/internal/game"
+)
+
+const (
+	// idleCheckInterval is how often the hub looks for games without moves
+	idleCheckInterval = 15 * time.Second
+
+	// idleAbortGrace is how long after the idle warning a game that still
+	// has no move is aborted
+	idleAbortGrace = 2 * time.Minute
+)
+
+// RunIdleWatchdog periodically warns the players of games that have gone
+// idleAfter without a move, and aborts those still without one
+// idleAbortGrace later. Nobody wins or loses an aborted game. It never
+// returns.
+func (h *Hub) RunIdleWatchdog(idleAfter time.Duration) {
+	ticker := time.NewTicker(idleCheckInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		h.checkIdle(time.Now(), idleAfter)
+	}
+}
+
+// checkIdle warns or aborts the games that have been idle since before
+// now-idleAfter. A game is warned once per stretch without moves.
+func (h *Hub) checkIdle(now time.Time, idleAfter time.Duration) {
+	type idleGame struct {
+		g         *game.Game
+		remaining time.Duration
+	}
+	var warn []idleGame
+	var abort []*game.Game
+	active := make(map[string]bool)
+
+	games := h.matchmaker.ListGames()
+	h.mu.Lock()
+	for _, g := range games {
+		if g.GetState().Status != game.StatusPlaying {
+			continue
+		}
+		active[g.ID] = true
+		last := g.LastActivity()
+		idle := now.Sub(last)
+		switch {
+		case idle >= idleAfter+idleAbortGrace:
+			abort = append(abort, g)
+			delete(h.idleWarned, g.ID)
+		case idle >= idleAfter:
+			// The warning remembers the stretch it was sent for, so a
+			// move followed by another idle stretch warns again
+			if warned, ok := h.idleWarned[g.ID]; !ok || !warned.Equal(last) {
+				h.idleWarned[g.ID] = last
+				warn = append(warn, idleGame{g, idleAfter + idleAbortGrace - idle})
+			}
+		}
+	}
+	// Forget games that ended or moved on
+	for gameID := range h.idleWarned {
+		if !active[gameID] {
+			delete(h.idleWarned, gameID)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, w := range warn {
+		h.logger.Info("warning idle game", "gameID", w.g.ID)
+		h.broadcastToGame(w.g.ID, Message{
+			Type:             TypeIdleWarning,
+			Message:          "No moves for a while; the game will be aborted soon",
+			SecondsRemaining: int(w.remaining.Round(time.Second) / time.Second),
+		})
+	}
+
+	for _, g := range abort {
+		if !g.Abort() {
+			continue
+		}
+		h.logger.Info("aborting idle game", "gameID", g.ID)
+		h.broadcastToGame(g.ID, Message{Type: TypeGameOver, Reason: string(game.ResultAborted)})
+		if g.GetState().MoveCount >= minMovesToSaveAborted {
+			h.handleGameEnd(g)
+		} else {
+			h.StopTurnTimer(g.ID)
+			h.removeGameLater(g)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package websocket
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/connect-four/internal/game"
+	"github.com/connect-four/internal/matchmaker"
+)
+
+// moveEvent is one call of the hub's move callback
+type moveEvent struct {
+	game             *game.Game
+	player           string
+	column, row, num int
+}
+
+// fakeEmitter records move callbacks the way main.go registers EmitMove
+type fakeEmitter struct {
+	mu     sync.Mutex
+	events []moveEvent
+}
+
+func (e *fakeEmitter) EmitMove(g *game.Game, player string, column, row, moveNum int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.events = append(e.events, moveEvent{g, player, column, row, moveNum})
+}
+
+func (e *fakeEmitter) snapshot() []moveEvent {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]moveEvent(nil), e.events...)
+}
+
+// TestMoveCallbackPerMove plays a bot game over the socket and checks the
+// move callback fired once for every human and bot move, in order
+func TestMoveCallbackPerMove(t *testing.T) {
+	emitter := &fakeEmitter{}
+	s := newTestServer(t, func(h *Hub, _ *matchmaker.Matchmaker) {
+		h.botMoveDelay = 0
+		h.SetOnMove(emitter.EmitMove)
+	})
+	c := s.dial(t, "alice", nil)
+	c.send(map[string]interface{}{"type": "join", "vsBot": true, "botDifficulty": "easy"})
+	matched := c.readType(TypeMatched)
+
+	state := matched.State
+	for state.Status != game.StatusFinished {
+		if state.CurrentTurnUsername == "alice" {
+			for col := range state.Board[0] {
+				if state.Board[0][col] == game.Empty {
+					c.send(map[string]interface{}{"type": "move", "column": col, "token": matched.Token})
+					break
+				}
+			}
+		}
+		for msg := c.read(); ; msg = c.read() {
+			if msg.State != nil && msg.State.MoveCount > state.MoveCount {
+				state = msg.State
+				break
+			}
+		}
+	}
+
+	moves := s.mm.GetGame(state.ID).GetStateWithHistory().Moves
+	waitFor(t, "a callback per move", func() bool { return len(emitter.snapshot()) == len(moves) })
+	events := emitter.snapshot()
+	bot := 0
+	for i, m := range moves {
+		e := events[i]
+		player := "alice"
+		if m.Bot {
+			player = "BOT"
+			bot++
+		}
+		if e.game.ID != state.ID || e.player != player || e.column != m.Column || e.row != m.Row || e.num != i+1 {
+			t.Errorf("event %d = %s column %d row %d move %d; want %s column %d row %d move %d",
+				i, e.player, e.column, e.row, e.num, player, m.Column, m.Row, i+1)
+		}
+	}
+	if bot == 0 || bot == len(moves) {
+		t.Errorf("%d of %d moves were the bot's, want both sides", bot, len(moves))
+	}
+}
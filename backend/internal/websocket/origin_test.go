@@ -0,0 +1,63 @@
+package websocket
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/connect-four/internal/matchmaker"
+	"github.com/connect-four/internal/origins"
+	gws "github.com/gorilla/websocket"
+)
+
+// dialFrom opens a connection as alice with the given Origin header,
+// returning the handshake's status code
+func (s *testServer) dialFrom(t *testing.T, origin string) int {
+	t.Helper()
+	header := http.Header{}
+	if origin != "" {
+		header.Set("Origin", origin)
+	}
+	u := "ws" + strings.TrimPrefix(s.srv.URL, "http") + "?username=alice"
+	conn, resp, err := gws.DefaultDialer.Dial(u, header)
+	if err == nil {
+		conn.Close()
+	}
+	if resp == nil {
+		t.Fatalf("dial from %q: %v", origin, err)
+	}
+	return resp.StatusCode
+}
+
+func TestWebSocketOrigins(t *testing.T) {
+	s := newTestServer(t, func(h *Hub, _ *matchmaker.Matchmaker) {
+		h.SetAllowedOrigins(origins.NewMatcher([]string{"https://play.example.com", "https://*.example.org"}))
+	})
+	tests := []struct {
+		name   string
+		origin string
+		want   int
+	}{
+		{"allowed origin", "https://play.example.com", http.StatusSwitchingProtocols},
+		{"rejected origin", "https://evil.example.net", http.StatusForbidden},
+		{"wildcard subdomain", "https://beta.example.org", http.StatusSwitchingProtocols},
+		{"wildcard parent domain", "https://example.org", http.StatusForbidden},
+		{"no origin, not a browser", "", http.StatusSwitchingProtocols},
+	}
+	for _, tt := range tests {
+		if got := s.dialFrom(t, tt.origin); got != tt.want {
+			t.Errorf("%s: handshake status = %d, want %d", tt.name, got, tt.want)
+		}
+		// Let the hub drop alice before the next dial reuses the name
+		waitFor(t, "alice to unregister", func() bool { return s.hub.GetClient("alice") == nil })
+	}
+}
+
+func TestWebSocketOriginsPermissiveWhenUnset(t *testing.T) {
+	s := newTestServer(t, func(h *Hub, _ *matchmaker.Matchmaker) {
+		h.SetAllowedOrigins(origins.NewMatcher(nil))
+	})
+	if got := s.dialFrom(t, "https://anywhere.example"); got != http.StatusSwitchingProtocols {
+		t.Errorf("handshake status = %d, want any origin accepted", got)
+	}
+}
@@ -0,0 +1,48 @@
+package websocket
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/connect-four/internal/matchmaker"
+)
+
+func TestLeaveQueue(t *testing.T) {
+	s := newTestServer(t, func(_ *Hub, mm *matchmaker.Matchmaker) {
+		mm.SetMatchmakingTimeout(100 * time.Millisecond)
+	})
+	c := s.dial(t, "alice", nil)
+
+	c.send(map[string]interface{}{"type": "leaveQueue"})
+	if msg := c.readType(TypeError); !strings.Contains(msg.Message, matchmaker.ErrNotQueued.Error()) {
+		t.Fatalf("leaving while not queued: error %q, want %q", msg.Message, matchmaker.ErrNotQueued)
+	}
+
+	c.send(map[string]interface{}{"type": "join"})
+	c.readType(TypeWaiting)
+	c.send(map[string]interface{}{"type": "leaveQueue"})
+	c.readType(TypeQueueLeft)
+	if n := s.mm.GetWaitingCount(); n != 0 {
+		t.Fatalf("%d players waiting after leaving", n)
+	}
+
+	// The bot fallback was cancelled along with the queue entry, and the
+	// join goroutine gave up without seating alice anywhere
+	time.Sleep(300 * time.Millisecond)
+	if g := s.mm.GetGameByPlayer("alice"); g != nil {
+		t.Fatalf("alice was put in game %s", g.ID)
+	}
+	if id := s.hub.clientGameID(s.hub.GetClient("alice")); id != "" {
+		t.Fatalf("client game ID = %q after leaving the queue", id)
+	}
+
+	// Leaving twice is an error, and it's the next message: no match
+	// arrived in between. Joining again works.
+	c.send(map[string]interface{}{"type": "leaveQueue"})
+	if msg := c.read(); msg.Type != TypeError {
+		t.Fatalf("got %s after leaving the queue, want the error for leaving again", msg.Type)
+	}
+	c.send(map[string]interface{}{"type": "join", "allowBot": false})
+	c.readType(TypeWaiting)
+}
@@ -0,0 +1,277 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/connect-four/internal/game"
+)
+
+// Replay pacing
+const (
+	// replayMoveInterval is the time between moves at speed 1
+	replayMoveInterval = time.Second
+
+	// Playback speeds a replay may be requested at
+	minReplaySpeed = 0.25
+	maxReplaySpeed = 8
+
+	// replayLoadTimeout bounds loading the game from the store
+	replayLoadTimeout = 5 * time.Second
+)
+
+var (
+	// ErrReplayRunning is sent when a client starts a replay while one is
+	// already streaming to it
+	ErrReplayRunning = errors.New("a replay is already running")
+
+	// ErrNoReplay is sent for replay controls without a running replay
+	ErrNoReplay = errors.New("no replay running")
+)
+
+// ReplayGame is a completed game loaded for replay
+type ReplayGame struct {
+	ID      string
+	Player1 string
+	Player2 string
+	Winner  string
+	Result  string
+	Moves   []game.Move
+	Size    game.BoardSize // zero for the standard board
+}
+
+// ReplayPosition tells a replay's viewer where playback is
+type ReplayPosition struct {
+	Move   int     `json:"move"` // moves shown so far, 0 for the empty board
+	Total  int     `json:"total"`
+	Speed  float64 `json:"speed"`
+	Paused bool    `json:"paused"`
+}
+
+// SetReplayLoader sets how completed games are loaded for replay; it
+// should return game.ErrGameNotFound for unknown games. Without one,
+// replay requests are refused.
+func (h *Hub) SetReplayLoader(load func(ctx context.Context, gameID string) (*ReplayGame, error)) {
+	h.loadReplay = load
+}
+
+// replayCommand is a control message for a running replay
+type replayCommand struct {
+	kind string // TypeReplayPause, TypeReplayResume, TypeReplaySeek or TypeReplayStop
+	move int    // for seeks
+}
+
+// replaySession streams one completed game to a client a move at a time.
+// Each client runs at most one; it ends when the last move has been shown,
+// when stopped, or when the client disconnects.
+type replaySession struct {
+	client   *Client
+	game     *ReplayGame
+	boards   [][][]int // board after each move
+	interval time.Duration
+	speed    float64
+
+	control chan replayCommand
+	done    chan struct{}
+}
+
+// startReplay starts streaming g to the client unless a replay is
+// already running for it
+func (c *Client) startReplay(g *ReplayGame, boards [][][]int, speed float64) error {
+	c.replayMu.Lock()
+	defer c.replayMu.Unlock()
+
+	if c.replay != nil {
+		return ErrReplayRunning
+	}
+	s := &replaySession{
+		client:   c,
+		game:     g,
+		boards:   boards,
+		interval: time.Duration(float64(replayMoveInterval) / speed),
+		speed:    speed,
+		control:  make(chan replayCommand),
+		done:     make(chan struct{}),
+	}
+	c.replay = s
+	go s.run()
+	return nil
+}
+
+// controlReplay hands a command to the client's running replay
+func (c *Client) controlReplay(cmd replayCommand) error {
+	c.replayMu.Lock()
+	s := c.replay
+	c.replayMu.Unlock()
+
+	if s == nil {
+		return ErrNoReplay
+	}
+	select {
+	case s.control <- cmd:
+		return nil
+	case <-s.done:
+		return ErrNoReplay
+	}
+}
+
+// run streams the replay until it finishes, is stopped or the client goes away
+func (s *replaySession) run() {
+	defer func() {
+		close(s.done)
+		s.client.replayMu.Lock()
+		if s.client.replay == s {
+			s.client.replay = nil
+		}
+		s.client.replayMu.Unlock()
+	}()
+
+	total := len(s.game.Moves)
+	position := 0
+	paused := false
+	timer := time.NewTimer(s.interval)
+	defer timer.Stop()
+
+	s.sendPosition(position, paused)
+	for {
+		if position == total {
+			s.client.sendMessage(Message{Type: TypeReplayEnded, GameID: s.game.ID})
+			return
+		}
+
+		var tick <-chan time.Time
+		if !paused {
+			tick = timer.C
+		}
+		select {
+		case <-s.client.done:
+			return
+		case <-tick:
+			position++
+			s.sendPosition(position, paused)
+			timer.Reset(s.interval)
+		case cmd := <-s.control:
+			switch cmd.kind {
+			case TypeReplayStop:
+				s.client.sendMessage(Message{Type: TypeReplayEnded, GameID: s.game.ID, Reason: "stopped"})
+				return
+			case TypeReplayPause:
+				paused = true
+				s.client.sendMessage(Message{
+					Type:   TypeReplayPaused,
+					GameID: s.game.ID,
+					Replay: &ReplayPosition{Move: position, Total: total, Speed: s.speed, Paused: true},
+				})
+			case TypeReplayResume:
+				if paused {
+					paused = false
+					resetTimer(timer, s.interval)
+				}
+				s.sendPosition(position, paused)
+			case TypeReplaySeek:
+				position = min(cmd.move, total)
+				s.sendPosition(position, paused)
+				resetTimer(timer, s.interval)
+			}
+		}
+	}
+}
+
+// sendPosition sends the board after the first position moves as a state message
+func (s *replaySession) sendPosition(position int, paused bool) {
+	total := len(s.game.Moves)
+	state := &game.GameState{
+		ID:          s.game.ID,
+		Player1:     s.game.Player1,
+		Player2:     s.game.Player2,
+		Board:       game.NewBoardOfSize(s.game.Size).ToSlice(),
+		BoardSize:   s.game.Size.OrStandard(),
+		CurrentTurn: game.Player1,
+		FirstPlayer: game.Player1,
+		Status:      game.StatusPlaying,
+		MoveCount:   position,
+	}
+	if total > 0 {
+		state.FirstPlayer = s.game.Moves[0].PlayerNum
+		state.CurrentTurn = state.FirstPlayer
+	}
+	if position > 0 {
+		last := s.game.Moves[position-1]
+		state.Board = s.boards[position-1]
+		state.LastMove = &game.MoveInfo{Column: last.Column, Row: last.Row}
+		state.CurrentTurn = game.Player1 + game.Player2 - last.PlayerNum
+	}
+	if position == total {
+		state.Status = game.StatusFinished
+		state.Winner = s.game.Winner
+		state.Result = s.game.Result
+		state.CurrentTurn = 0
+	}
+
+	s.client.sendMessage(Message{
+		Type:   TypeState,
+		GameID: s.game.ID,
+		State:  state,
+		Replay: &ReplayPosition{Move: position, Total: total, Speed: s.speed, Paused: paused},
+	})
+}
+
+// resetTimer restarts a timer that may or may not have fired
+func resetTimer(timer *time.Timer, d time.Duration) {
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+	timer.Reset(d)
+}
+
+// handleReplay loads a completed game and starts streaming it to the client
+func (h *Handler) handleReplay(client *Client, gameID string, speed float64) {
+	if h.hub.loadReplay == nil {
+		client.sendMessage(Message{Type: TypeError, Message: "Replays are not available"})
+		return
+	}
+	if h.hub.clientGameID(client) != "" {
+		client.sendMessage(Message{Type: TypeError, Message: "Cannot watch a replay during a game"})
+		return
+	}
+	if speed == 0 {
+		speed = 1
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), replayLoadTimeout)
+	defer cancel()
+	g, err := h.hub.loadReplay(ctx, gameID)
+	if errors.Is(err, game.ErrGameNotFound) {
+		client.sendMessage(Message{Type: TypeError, Message: "Game not found"})
+		return
+	}
+	if err != nil {
+		h.hub.logger.Error("loading replay failed", "gameID", gameID, "error", err)
+		client.sendMessage(Message{Type: TypeError, Message: "Failed to load game"})
+		return
+	}
+
+	if err := g.Size.OrStandard().Validate(); err != nil {
+		client.sendMessage(Message{Type: TypeError, Message: "Stored board size is corrupt: " + err.Error()})
+		return
+	}
+	boards, err := game.ReplayMoves(g.Size, g.Moves)
+	if err != nil {
+		client.sendMessage(Message{Type: TypeError, Message: "Stored move sequence is corrupt: " + err.Error()})
+		return
+	}
+	if err := client.startReplay(g, boards, speed); err != nil {
+		client.sendMessage(Message{Type: TypeError, Message: err.Error()})
+	}
+}
+
+// handleReplayControl pauses, resumes, seeks or stops the client's replay
+func (h *Handler) handleReplayControl(client *Client, cmd replayCommand) {
+	if err := client.controlReplay(cmd); err != nil {
+		client.sendMessage(Message{Type: TypeError, Message: err.Error()})
+	}
+}
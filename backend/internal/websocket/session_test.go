@@ -0,0 +1,96 @@
+package websocket
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/connect-four/internal/game"
+	"github.com/connect-four/internal/matchmaker"
+)
+
+func TestDuplicateSessionWithoutProofIsRejected(t *testing.T) {
+	s := newTestServer(t)
+	first := s.dial(t, "alice", nil)
+	waitFor(t, "alice to register", func() bool { return s.hub.GetClient("alice") != nil })
+
+	second := s.dial(t, "alice", nil)
+	msg := second.readType(TypeError)
+	if msg.Reason != ReasonDuplicateSession {
+		t.Fatalf("reason = %q, want %q", msg.Reason, ReasonDuplicateSession)
+	}
+	if _, err := second.tryRead(time.Second); err == nil {
+		t.Fatal("rejected connection was left open")
+	}
+
+	// The first session is untouched and can still queue
+	first.send(map[string]interface{}{"type": "join"})
+	first.readType(TypeWaiting)
+}
+
+func TestSessionReplaceNeedsTheSeatToken(t *testing.T) {
+	s := newTestServer(t, func(h *Hub, _ *matchmaker.Matchmaker) { h.SetSessionPolicy(SessionReplace) })
+	alice, matched, _, _ := s.matchPlayers(t, "alice", "bob")
+	if matched.Token == "" {
+		t.Fatal("matched message carried no seat token")
+	}
+
+	wrong := s.dial(t, "alice", url.Values{"gameToken": {"not-the-token"}})
+	if msg := wrong.readType(TypeError); msg.Reason != ReasonDuplicateSession {
+		t.Fatalf("wrong token: reason = %q, want %q", msg.Reason, ReasonDuplicateSession)
+	}
+
+	replacement := s.dial(t, "alice", url.Values{"gameToken": {matched.Token}})
+	alice.readType(TypeSessionReplaced)
+
+	// The old session is told only after the new one took over its seat
+	replacement.send(map[string]interface{}{"type": "sync"})
+	if msg := replacement.readType(TypeState); msg.State == nil || msg.State.ID != matched.GameID {
+		t.Fatalf("state after takeover = %+v, want game %s", msg.State, matched.GameID)
+	}
+}
+
+func TestSessionRejectIsTheDefault(t *testing.T) {
+	s := newTestServer(t)
+	alice, matched, _, _ := s.matchPlayers(t, "alice", "bob")
+
+	// Even the seat token doesn't replace a session under reject
+	second := s.dial(t, "alice", url.Values{"gameToken": {matched.Token}})
+	if msg := second.readType(TypeError); msg.Reason != ReasonDuplicateSession {
+		t.Fatalf("reason = %q, want %q", msg.Reason, ReasonDuplicateSession)
+	}
+	alice.send(map[string]interface{}{"type": "sync"})
+	alice.readType(TypeState)
+}
+
+func TestSessionReplaceWhileOpponentDisconnected(t *testing.T) {
+	s := newTestServer(t, func(h *Hub, _ *matchmaker.Matchmaker) { h.SetSessionPolicy(SessionReplace) })
+	alice, matched, bob, bobMatched := s.matchPlayers(t, "alice", "bob")
+	g := s.mm.GetGame(matched.GameID)
+	aliceNum := g.GetPlayerByUsername("alice")
+
+	bob.conn.Close()
+	waitFor(t, "bob's disconnect", func() bool { return g.GetState().Status == game.StatusDisconnect })
+
+	replacement := s.dial(t, "alice", url.Values{"gameToken": {matched.Token}})
+	alice.readType(TypeSessionReplaced)
+	replacement.send(map[string]interface{}{"type": "reconnect", "gameId": matched.GameID, "token": matched.Token})
+	msg := replacement.read()
+	for msg.Type != TypeMatched && msg.Type != TypeError {
+		msg = replacement.read()
+	}
+	if msg.Type != TypeMatched {
+		t.Fatalf("replacement session got %q (%s), want matched", msg.Type, msg.Message)
+	}
+
+	// Bob's reconnect window is still running, and he can come back
+	if state := g.GetState(); state.Status != game.StatusDisconnect || !g.IsPlayerConnected(aliceNum) {
+		t.Fatalf("status %s, alice connected %v; want bob's disconnect still pending", state.Status, g.IsPlayerConnected(aliceNum))
+	}
+	back := s.dial(t, "bob", nil)
+	back.send(map[string]interface{}{"type": "reconnect", "gameId": matched.GameID, "token": bobMatched.Token})
+	back.readType(TypeMatched)
+	if status := g.GetState().Status; status != game.StatusPlaying {
+		t.Errorf("status after bob reconnected = %s, want playing", status)
+	}
+}
@@ -0,0 +1,69 @@
+package websocket
+
+import (
+	"sync"
+	"time"
+)
+
+// throttle delivers a stream of ephemeral updates at most once per
+// interval. Updates arriving in between are coalesced: only the latest is
+// delivered, when the interval has passed.
+type throttle struct {
+	interval time.Duration
+
+	mu      sync.Mutex
+	last    time.Time
+	pending func()
+	timer   *time.Timer
+}
+
+// do delivers now if the interval has passed since the last delivery,
+// otherwise replaces whatever is pending with deliver
+func (t *throttle) do(deliver func()) {
+	t.mu.Lock()
+	now := time.Now()
+	if wait := t.interval - now.Sub(t.last); wait > 0 {
+		t.pending = deliver
+		if t.timer == nil {
+			t.timer = time.AfterFunc(wait, t.flush)
+		}
+		t.mu.Unlock()
+		return
+	}
+	t.last = now
+	t.mu.Unlock()
+
+	deliver()
+}
+
+// flush delivers the latest pending update
+func (t *throttle) flush() {
+	t.mu.Lock()
+	deliver := t.pending
+	t.pending, t.timer = nil, nil
+	t.last = time.Now()
+	t.mu.Unlock()
+
+	if deliver != nil {
+		deliver()
+	}
+}
+
+// throttled runs deliver through the connection's throttle for kind, so
+// an ephemeral message type is relayed at most once per interval with the
+// excess coalesced to the latest. deliver may run later on another
+// goroutine and should check that it still applies.
+func (c *Client) throttled(kind string, interval time.Duration, deliver func()) {
+	c.throttlesMu.Lock()
+	t, ok := c.throttles[kind]
+	if !ok {
+		if c.throttles == nil {
+			c.throttles = make(map[string]*throttle)
+		}
+		t = &throttle{interval: interval}
+		c.throttles[kind] = t
+	}
+	c.throttlesMu.Unlock()
+
+	t.do(deliver)
+}
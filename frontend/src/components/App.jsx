@@ -84,6 +84,11 @@ function App() {
 
                 case 'error':
                     console.error('Game error:', message.message);
+                    // A rejected move carries the real board to fall back to
+                    if (message.state) {
+                        setBoard(message.state.board);
+                        setCurrentTurn(message.state.currentTurn);
+                    }
                     break;
             }
         });
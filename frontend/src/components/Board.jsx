@@ -5,10 +5,14 @@ const Board = memo(function Board({ board, onColumnClick, disabled, currentPlaye
     // Board is stored as [row][col] with row 0 at top
     // We need to render columns for click handling
 
+    // Games can be played on other sizes, so take them from the board
+    const rowCount = board.length;
+    const columnCount = board[0].length;
+
     const columns = [];
-    for (let col = 0; col < 7; col++) {
+    for (let col = 0; col < columnCount; col++) {
         const cells = [];
-        for (let row = 0; row < 6; row++) {
+        for (let row = 0; row < rowCount; row++) {
             cells.push(
                 <Cell
                     key={`${row}-${col}`}
@@ -33,7 +37,7 @@ const Board = memo(function Board({ board, onColumnClick, disabled, currentPlaye
 
     return (
         <div className="board-wrapper">
-            <div className="board">
+            <div className="board" style={{ gridTemplateColumns: `repeat(${columnCount}, 1fr)` }}>
                 {columns}
             </div>
         </div>
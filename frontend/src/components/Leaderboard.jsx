@@ -20,7 +20,7 @@ function Leaderboard() {
                 throw new Error('Failed to fetch leaderboard');
             }
             const data = await response.json();
-            setEntries(data || []);
+            setEntries(data?.entries || []);
             setError(null);
         } catch (err) {
             console.error('Leaderboard error:', err);
@@ -4,6 +4,24 @@ import { useState, useCallback, useRef, useEffect } from 'react';
 const BACKEND_URL = import.meta.env.VITE_BACKEND_URL || 'localhost:8080';
 const WS_PROTOCOL = import.meta.env.PROD ? 'wss' : 'ws';
 const WS_URL = `${WS_PROTOCOL}://${BACKEND_URL}/ws`;
+const API_URL = `${import.meta.env.PROD ? 'https' : 'http'}://${BACKEND_URL}/api`;
+
+// Asks for a guest token binding the username. Resolves to null when the
+// server has auth turned off, in which case the username alone is enough.
+async function fetchGuestToken(username) {
+    const response = await fetch(`${API_URL}/auth/guest`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ username })
+    });
+    if (response.status === 404) {
+        return null;
+    }
+    if (!response.ok) {
+        throw new Error(`Guest token request failed: ${response.status}`);
+    }
+    return (await response.json()).token;
+}
 
 export function useWebSocket() {
     const [isConnected, setIsConnected] = useState(false);
@@ -12,6 +30,8 @@ export function useWebSocket() {
     const usernameRef = useRef(null);
     const reconnectTimeoutRef = useRef(null);
     const messageHandlersRef = useRef([]);
+    // Seat token from the last matched message, required to move, resign or reconnect
+    const gameTokenRef = useRef(null);
 
     const connect = useCallback((username) => {
         if (wsRef.current?.readyState === WebSocket.OPEN) {
@@ -20,27 +40,7 @@ export function useWebSocket() {
 
         usernameRef.current = username;
 
-        const ws = new WebSocket(`${WS_URL}?username=${encodeURIComponent(username)}`);
-
-        ws.onopen = () => {
-            console.log('WebSocket connected');
-            setIsConnected(true);
-        };
-
-        ws.onmessage = (event) => {
-            try {
-                const data = JSON.parse(event.data);
-                setLastMessage(data);
-                messageHandlersRef.current.forEach(handler => handler(data));
-            } catch (e) {
-                console.error('Failed to parse message:', e);
-            }
-        };
-
-        ws.onclose = () => {
-            console.log('WebSocket disconnected');
-            setIsConnected(false);
-
+        const scheduleReconnect = () => {
             // Auto-reconnect if we have a username
             if (usernameRef.current) {
                 reconnectTimeoutRef.current = setTimeout(() => {
@@ -50,11 +50,60 @@ export function useWebSocket() {
             }
         };
 
-        ws.onerror = (error) => {
-            console.error('WebSocket error:', error);
+        const open = (token) => {
+            // The seat token lets this connection take over one the server
+            // hasn't noticed is gone yet
+            let url = `${WS_URL}?username=${encodeURIComponent(username)}`;
+            if (gameTokenRef.current) {
+                url += `&gameToken=${encodeURIComponent(gameTokenRef.current)}`;
+            }
+            // Browsers can't set headers on a WebSocket, so the token rides
+            // along as a subprotocol
+            const ws = token ? new WebSocket(url, ['access_token', token]) : new WebSocket(url);
+
+            ws.onopen = () => {
+                console.log('WebSocket connected');
+                setIsConnected(true);
+            };
+
+            ws.onmessage = (event) => {
+                try {
+                    const data = JSON.parse(event.data);
+                    if (data.type === 'matched' && data.token) {
+                        gameTokenRef.current = data.token;
+                    } else if (data.type === 'gameOver') {
+                        gameTokenRef.current = null;
+                    }
+                    setLastMessage(data);
+                    messageHandlersRef.current.forEach(handler => handler(data));
+                } catch (e) {
+                    console.error('Failed to parse message:', e);
+                }
+            };
+
+            ws.onclose = () => {
+                console.log('WebSocket disconnected');
+                setIsConnected(false);
+                scheduleReconnect();
+            };
+
+            ws.onerror = (error) => {
+                console.error('WebSocket error:', error);
+            };
+
+            wsRef.current = ws;
         };
 
-        wsRef.current = ws;
+        fetchGuestToken(username)
+            .then((token) => {
+                if (usernameRef.current === username) {
+                    open(token);
+                }
+            })
+            .catch((error) => {
+                console.error('Failed to get guest token:', error);
+                scheduleReconnect();
+            });
     }, []);
 
     const disconnect = useCallback(() => {
@@ -62,8 +111,11 @@ export function useWebSocket() {
             clearTimeout(reconnectTimeoutRef.current);
         }
         usernameRef.current = null;
+        gameTokenRef.current = null;
         if (wsRef.current) {
-            wsRef.current.close();
+            // A normal closure tells the server we left on purpose, so a
+            // game still in progress ends now instead of waiting for us
+            wsRef.current.close(1000);
             wsRef.current = null;
         }
         setIsConnected(false);
@@ -84,16 +136,24 @@ export function useWebSocket() {
         };
     }, []);
 
+    const withToken = (message) => (
+        gameTokenRef.current ? { ...message, token: gameTokenRef.current } : message
+    );
+
     const joinGame = useCallback(() => {
-        sendMessage({ type: 'join' });
+        sendMessage(withToken({ type: 'join' }));
     }, [sendMessage]);
 
     const makeMove = useCallback((column) => {
-        sendMessage({ type: 'move', column });
+        sendMessage(withToken({ type: 'move', column }));
     }, [sendMessage]);
 
     const reconnectToGame = useCallback((gameId) => {
-        sendMessage({ type: 'reconnect', gameId });
+        sendMessage(withToken({ type: 'reconnect', gameId }));
+    }, [sendMessage]);
+
+    const leaveGame = useCallback(() => {
+        sendMessage(withToken({ type: 'leaveGame' }));
     }, [sendMessage]);
 
     useEffect(() => {
@@ -116,6 +176,7 @@ export function useWebSocket() {
         addMessageHandler,
         joinGame,
         makeMove,
-        reconnectToGame
+        reconnectToGame,
+        leaveGame
     };
 }